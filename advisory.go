@@ -0,0 +1,44 @@
+package jewelcase
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// drawParentalAdvisory adds a Parental Advisory label to the bottom right of the art,
+// sized as it would be on a real CD cover.
+func drawParentalAdvisory(art *image.RGBA) error {
+	bounds := art.Bounds()
+	width := bounds.Dx() * 22 / 100
+	height := width * 62 / 100
+	inset := bounds.Dx() * 3 / 100
+
+	label := image.Rect(bounds.Max.X-inset-width, bounds.Max.Y-inset-height, bounds.Max.X-inset, bounds.Max.Y-inset)
+	border := max(width/50, 1)
+
+	// Black label, with a white band across the middle
+	top := label.Min.Y + height*34/100
+	bottom := label.Min.Y + height*72/100
+	band := image.Rect(label.Min.X+border*2, top, label.Max.X-border*2, bottom)
+	draw.Draw(art, label, image.Black, image.Point{}, draw.Src)
+	draw.Draw(art, band, image.White, image.Point{}, draw.Src)
+
+	sections := []struct {
+		text   string
+		area   image.Rectangle
+		colour color.Color
+	}{
+		{"PARENTAL", image.Rect(band.Min.X, label.Min.Y+border*2, band.Max.X, top), color.White},
+		{"ADVISORY", band.Inset(border), color.Black},
+		{"EXPLICIT CONTENT", image.Rect(band.Min.X, bottom, band.Max.X, label.Max.Y-border*2), color.White},
+	}
+	for _, section := range sections {
+		mask, err := fitText([]string{section.text}, section.area.Size(), float64(section.area.Dy()))
+		if err != nil {
+			return err
+		}
+		drawMaskCentred(art, section.area, mask, image.NewUniform(section.colour))
+	}
+	return nil
+}
@@ -0,0 +1,409 @@
+package jewelcase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"slices"
+	"time"
+)
+
+// AnimationMode selects the movement shown by Animate.
+type AnimationMode string
+
+const (
+	// AnimationGlint sweeps a band of light across the case, then pauses before it comes
+	// round again. This is the default.
+	AnimationGlint AnimationMode = "glint"
+
+	// AnimationSway turns the case back and forth about its vertical axis, like it is
+	// sitting on a turntable.
+	AnimationSway AnimationMode = "sway"
+)
+
+// AnimationOptions configures the animation made by Animate and EncodeAnimation.
+type AnimationOptions struct {
+	// Mode is the movement to show (defaults to AnimationGlint)
+	Mode AnimationMode
+
+	// Frames is the number of frames in one loop of the animation (defaults to 30)
+	Frames int
+
+	// Delay is how long each frame is shown for (defaults to 50ms)
+	Delay time.Duration
+}
+
+func (o AnimationOptions) frames() int {
+	if o.Frames <= 0 {
+		return 30
+	}
+	return o.Frames
+}
+
+func (o AnimationOptions) delay() time.Duration {
+	if o.Delay <= 0 {
+		return 50 * time.Millisecond
+	}
+	return o.Delay
+}
+
+// Animate returns the frames of a looping animation of a processed image. Every frame is
+// the same size as the image.
+func Animate(img image.Image, opts AnimationOptions) ([]*image.RGBA, error) {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return nil, errors.New("cannot animate an empty image")
+	}
+
+	src := image.NewRGBA(image.Rectangle{Max: bounds.Size()})
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	// The case is shrunk just enough to fit when it is turned the furthest, and kept the
+	// same size throughout
+	const sway = 25 * math.Pi / 180
+	size := src.Bounds().Size()
+	scale := newView(size, sway, 0).fit(size, corners(size, 0))
+
+	n := opts.frames()
+	frames := make([]*image.RGBA, n)
+	for i := range frames {
+		t := float64(i) / float64(n)
+		switch opts.Mode {
+		case "", AnimationGlint:
+			frames[i] = glintFrame(src, t)
+		case AnimationSway:
+			frames[i] = image.NewRGBA(src.Bounds())
+			drawTurned(frames[i], src, sway*math.Sin(2*math.Pi*t), 0, scale)
+		default:
+			return nil, fmt.Errorf("unknown animation mode %q", opts.Mode)
+		}
+	}
+	return frames, nil
+}
+
+// glintFrame returns a copy of the image with a diagonal band of light part way across
+// it. The band crosses the image while t goes from 0 to 0.6, and is out of sight for the
+// rest of the loop.
+func glintFrame(src *image.RGBA, t float64) *image.RGBA {
+	result := image.NewRGBA(src.Bounds())
+	copy(result.Pix, src.Pix)
+
+	w, h := float64(src.Bounds().Dx()), float64(src.Bounds().Dy())
+	width := 0.08 * (w + h)
+	centre := -3*width + (t/0.6)*(w+h+6*width)
+
+	parallelRows(result.Bounds(), func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			for x := range result.Bounds().Dx() {
+				d := (float64(x) + float64(y)*0.6 - centre) / width
+				s := 0.35 * math.Exp(-d*d)
+				if s < 0.002 {
+					continue
+				}
+
+				// Lighten towards the pixel's alpha, so transparent areas stay transparent
+				o := result.PixOffset(x, y)
+				p := result.Pix[o : o+4 : o+4]
+				for c := range 3 {
+					p[c] += uint8(float64(p[3]-p[c]) * s)
+				}
+			}
+		}
+	})
+	return result
+}
+
+// EncodeAnimation writes the frames to w as a looping animation, in the given format
+// ("gif", or "png" or "apng" for an animated PNG). The frames must all be the same size.
+// GIFs are limited to 256 colours, and pixels that are more than half transparent are
+// made fully transparent.
+func EncodeAnimation(w io.Writer, frames []*image.RGBA, format string, opts AnimationOptions) error {
+	if len(frames) == 0 {
+		return errors.New("no frames to encode")
+	}
+	for _, f := range frames[1:] {
+		if f.Bounds().Size() != frames[0].Bounds().Size() {
+			return fmt.Errorf("frames must all be the same size, got %v and %v", frames[0].Bounds().Size(), f.Bounds().Size())
+		}
+	}
+
+	switch normaliseFormat(format) {
+	case "gif":
+		return encodeGIF(w, frames, opts.delay())
+	case "png", "apng":
+		return encodeAPNG(w, frames, opts.delay())
+	default:
+		return &UnsupportedFormatError{Ext: format, Op: "encode"}
+	}
+}
+
+// encodeGIF writes the frames as a GIF, sharing a single palette chosen from the colours
+// of all of them.
+func encodeGIF(w io.Writer, frames []*image.RGBA, delay time.Duration) error {
+	palette := quantise(frames, 255)
+	palette = append(color.Palette{color.RGBA{}}, palette...)
+
+	anim := &gif.GIF{Config: image.Config{ColorModel: palette, Width: frames[0].Bounds().Dx(), Height: frames[0].Bounds().Dy()}}
+	nearest := newPaletteLookup(palette)
+	for _, f := range frames {
+		anim.Image = append(anim.Image, dither(f, palette, nearest))
+		anim.Delay = append(anim.Delay, int(math.Round(delay.Seconds()*100)))
+		anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// paletteLookup finds the nearest opaque colour in a palette, caching the result for
+// each colour with its channels reduced to 5 bits.
+type paletteLookup struct {
+	palette color.Palette
+	cache   []int16
+}
+
+func newPaletteLookup(palette color.Palette) *paletteLookup {
+	cache := make([]int16, 1<<15)
+	for i := range cache {
+		cache[i] = -1
+	}
+	return &paletteLookup{palette: palette, cache: cache}
+}
+
+// index returns the index of the palette colour nearest to the given colour, ignoring
+// the transparent colour at index 0.
+func (l *paletteLookup) index(r, g, b int) int {
+	key := (r>>3)<<10 | (g>>3)<<5 | b>>3
+	if i := l.cache[key]; i >= 0 {
+		return int(i)
+	}
+
+	best, bestDist := 1, math.MaxInt
+	for i, c := range l.palette[1:] {
+		pr, pg, pb, _ := c.RGBA()
+		dr, dg, db := r-int(pr>>8), g-int(pg>>8), b-int(pb>>8)
+		if dist := dr*dr + dg*dg + db*db; dist < bestDist {
+			best, bestDist = i+1, dist
+		}
+	}
+	l.cache[key] = int16(best)
+	return best
+}
+
+// dither converts the image to the palette with Floyd-Steinberg dithering. GIFs only have
+// on/off transparency, so pixels that are more than half transparent use the transparent
+// colour at index 0, and the rest are treated as opaque.
+func dither(img *image.RGBA, palette color.Palette, nearest *paletteLookup) *image.Paletted {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	result := image.NewPaletted(bounds, palette)
+
+	// The error carried to the current row and the next, with a column of padding at
+	// either end
+	errs := [2][]float32{make([]float32, (width+2)*3), make([]float32, (width+2)*3)}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		current, next := errs[0], errs[1]
+		clear(next)
+		for x := range width {
+			o := img.PixOffset(bounds.Min.X+x, y)
+			a := uint32(img.Pix[o+3])
+			if a < 128 {
+				continue
+			}
+
+			var want [3]int
+			for c := range 3 {
+				v := float32(min(uint32(img.Pix[o+c])*255/a, 255)) + current[(x+1)*3+c]
+				want[c] = int(min(max(v, 0), 255))
+			}
+			i := nearest.index(want[0], want[1], want[2])
+			result.Pix[result.PixOffset(bounds.Min.X+x, y)] = uint8(i)
+
+			pr, pg, pb, _ := palette[i].RGBA()
+			got := [3]int{int(pr >> 8), int(pg >> 8), int(pb >> 8)}
+			for c := range 3 {
+				e := float32(want[c] - got[c])
+				current[(x+2)*3+c] += e * 7 / 16
+				next[x*3+c] += e * 3 / 16
+				next[(x+1)*3+c] += e * 5 / 16
+				next[(x+2)*3+c] += e * 1 / 16
+			}
+		}
+		errs[0], errs[1] = next, current
+	}
+	return result
+}
+
+// quantise picks up to n colours to represent the opaque pixels of the frames, by
+// repeatedly splitting the group of colours with the widest range of any one channel at
+// its median (the median cut algorithm).
+func quantise(frames []*image.RGBA, n int) color.Palette {
+	// Sample at most around a quarter of a million pixels, spread over all the frames
+	total := 0
+	for _, f := range frames {
+		total += len(f.Pix) / 4
+	}
+	step := max(total/250000, 1)
+
+	var pixels [][3]uint8
+	for _, f := range frames {
+		for i := 0; i < len(f.Pix); i += 4 * step {
+			if a := uint32(f.Pix[i+3]); a >= 128 {
+				pixels = append(pixels, [3]uint8{
+					uint8(min(uint32(f.Pix[i])*255/a, 255)),
+					uint8(min(uint32(f.Pix[i+1])*255/a, 255)),
+					uint8(min(uint32(f.Pix[i+2])*255/a, 255)),
+				})
+			}
+		}
+	}
+	if len(pixels) == 0 {
+		return color.Palette{color.RGBA{A: 255}}
+	}
+
+	boxes := [][][3]uint8{pixels}
+	for len(boxes) < n {
+		// Find the box with the widest channel, and split it in two
+		best, channel, widest := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			for c := range 3 {
+				lo, hi := uint8(255), uint8(0)
+				for _, p := range box {
+					lo, hi = min(lo, p[c]), max(hi, p[c])
+				}
+				if int(hi-lo) > widest {
+					best, channel, widest = i, c, int(hi-lo)
+				}
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		slices.SortFunc(box, func(a, b [3]uint8) int { return int(a[channel]) - int(b[channel]) })
+		boxes[best] = box[:len(box)/2]
+		boxes = append(boxes, box[len(box)/2:])
+	}
+
+	palette := make(color.Palette, len(boxes))
+	for i, box := range boxes {
+		var sum [3]int
+		for _, p := range box {
+			for c := range 3 {
+				sum[c] += int(p[c])
+			}
+		}
+		palette[i] = color.RGBA{
+			R: uint8(sum[0] / len(box)),
+			G: uint8(sum[1] / len(box)),
+			B: uint8(sum[2] / len(box)),
+			A: 255,
+		}
+	}
+	return palette
+}
+
+// encodeAPNG writes the frames as an animated PNG. Each frame is encoded as a normal PNG,
+// and its image data is then copied into the animation, which is laid out as described
+// by the APNG specification.
+func encodeAPNG(w io.Writer, frames []*image.RGBA, delay time.Duration) error {
+	out := &bytes.Buffer{}
+	out.WriteString("\x89PNG\r\n\x1a\n")
+
+	var header []byte
+	sequence := uint32(0)
+	for i, f := range frames {
+		encoded := &bytes.Buffer{}
+		if err := png.Encode(encoded, f); err != nil {
+			return err
+		}
+
+		chunks, err := pngChunks(encoded.Bytes())
+		if err != nil {
+			return err
+		}
+
+		// Every frame must have the same colour type as the first, which is described by
+		// the header chunk, so they can share it
+		if i == 0 {
+			header = chunks[0].data
+			writePNGChunk(out, "IHDR", header)
+			writePNGChunk(out, "acTL", binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, uint32(len(frames))), 0))
+		} else if !bytes.Equal(chunks[0].data, header) {
+			return errors.New("frames could not be encoded with the same colour type")
+		}
+
+		control := binary.BigEndian.AppendUint32(nil, sequence)
+		control = binary.BigEndian.AppendUint32(control, uint32(f.Bounds().Dx()))
+		control = binary.BigEndian.AppendUint32(control, uint32(f.Bounds().Dy()))
+		control = binary.BigEndian.AppendUint32(control, 0)
+		control = binary.BigEndian.AppendUint32(control, 0)
+		control = binary.BigEndian.AppendUint16(control, uint16(delay.Milliseconds()))
+		control = binary.BigEndian.AppendUint16(control, 1000)
+		control = append(control, 1, 0) // Clear to transparent afterwards, and don't blend
+		writePNGChunk(out, "fcTL", control)
+		sequence++
+
+		for _, chunk := range chunks {
+			if chunk.kind != "IDAT" {
+				continue
+			}
+			if i == 0 {
+				writePNGChunk(out, "IDAT", chunk.data)
+			} else {
+				writePNGChunk(out, "fdAT", append(binary.BigEndian.AppendUint32(nil, sequence), chunk.data...))
+				sequence++
+			}
+		}
+	}
+
+	writePNGChunk(out, "IEND", nil)
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// pngChunk is a single chunk of a PNG file.
+type pngChunk struct {
+	kind string
+	data []byte
+}
+
+// pngChunks splits an encoded PNG into its chunks, the first of which is the header.
+func pngChunks(data []byte) ([]pngChunk, error) {
+	data = data[8:]
+	var chunks []pngChunk
+	for len(data) >= 12 {
+		length := int(binary.BigEndian.Uint32(data))
+		if len(data) < 12+length {
+			return nil, errors.New("truncated PNG chunk")
+		}
+		chunks = append(chunks, pngChunk{kind: string(data[4:8]), data: data[8 : 8+length]})
+		data = data[12+length:]
+	}
+	if len(chunks) == 0 || chunks[0].kind != "IHDR" {
+		return nil, errors.New("PNG does not start with a header")
+	}
+	return chunks, nil
+}
+
+// writePNGChunk writes a chunk of the given kind to w, with its length and checksum.
+func writePNGChunk(w *bytes.Buffer, kind string, data []byte) {
+	w.Write(binary.BigEndian.AppendUint32(nil, uint32(len(data))))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(kind))
+	crc.Write(data)
+	w.WriteString(kind)
+	w.Write(data)
+	w.Write(binary.BigEndian.AppendUint32(nil, crc.Sum32()))
+}
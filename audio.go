@@ -0,0 +1,132 @@
+package jewelcase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoEmbeddedArt is returned when an audio file doesn't contain any cover art.
+var ErrNoEmbeddedArt = errors.New("no embedded cover art found")
+
+// pictureFrontCover is the picture type used by ID3 and FLAC for the front cover.
+const pictureFrontCover = 3
+
+// artSplicer finds the cover art embedded in an audio file, returning the image data and
+// a function that returns a copy of the file with the art replaced.
+type artSplicer func(data []byte) (art []byte, replace func(art []byte, mime string, size image.Point) ([]byte, error), err error)
+
+// audioFormats maps audio file extensions onto the splicer for their tag format.
+var audioFormats = map[string]artSplicer{
+	".mp3":  id3Art,
+	".flac": flacArt,
+	".m4a":  mp4Art,
+	".m4b":  mp4Art,
+	".mp4":  mp4Art,
+	".ogg":  oggArt,
+	".oga":  oggArt,
+	".opus": oggArt,
+}
+
+// IsAudioFile reports whether the path has the extension of an audio format supported by
+// ProcessAudioFile.
+func IsAudioFile(path string) bool {
+	_, ok := audioFormats[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// ProcessAudioFile applies the jewel case effect to the cover art embedded in an audio
+// file, and writes a copy of the file with the processed art to outputPath. Supports ID3v2
+// tags in MP3 files, FLAC pictures, MP4 cover atoms (M4A/M4B) and Ogg Vorbis or Opus
+// pictures. The art is saved in the same format it was found in, and ErrNoEmbeddedArt is
+// returned if there isn't any. BackupSuffix and WriteSidecar behave as for ProcessFile.
+func ProcessAudioFile(inputPath, outputPath string, opts Options) error {
+	return ProcessAudioFileContext(context.Background(), inputPath, outputPath, opts)
+}
+
+// ProcessAudioFileContext behaves like ProcessAudioFile, but stops and returns the
+// context's error if ctx is cancelled before the art has been processed.
+func ProcessAudioFileContext(ctx context.Context, inputPath, outputPath string, opts Options) error {
+	ext := strings.ToLower(filepath.Ext(inputPath))
+	splice, ok := audioFormats[ext]
+	if !ok {
+		return &UnsupportedFormatError{Ext: ext, Op: "decode"}
+	}
+
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return err
+	}
+
+	art, replace, err := splice(data)
+	if err != nil {
+		return err
+	}
+
+	format := sniffFormat(art)
+	img, err := decodeData(art, "", opts.maxInputPixels())
+	if err != nil {
+		return err
+	}
+
+	img = applyOrientation(img, jpegOrientation(art))
+	if opts.DryRun {
+		// The art is always re-encoded in a supported format, so only the input matters
+		return checkFile(img, "", opts, hasFileMarker(art))
+	}
+
+	buf := &buffers{pool: &encodePool}
+	defer buf.release()
+	result, report, err := process(ctx, buf, img, opts, hasFileMarker(art))
+	if err != nil {
+		return err
+	}
+
+	// Keep PNG art as PNG, but convert anything more unusual to JPEG
+	if format != "png" {
+		format = "jpeg"
+	}
+	var encoded bytes.Buffer
+	if err := encodeMarked(&encoded, result, format, opts, readMetadata(art)); err != nil {
+		return err
+	}
+
+	output, err := replace(encoded.Bytes(), "image/"+format, result.Bounds().Size())
+	if err != nil {
+		return err
+	}
+
+	if opts.BackupSuffix != "" && samePath(inputPath, outputPath) {
+		if err := backupFile(inputPath, inputPath+opts.BackupSuffix); err != nil {
+			return err
+		}
+	}
+
+	inputInfo, err := os.Stat(inputPath)
+	if err != nil {
+		return err
+	}
+	err = WriteFileAtomic(outputPath, inputInfo.Mode().Perm(), func(w io.Writer) error {
+		_, err := w.Write(output)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if opts.PreserveFileAttributes {
+		if err := copyAttributes(outputPath, inputInfo); err != nil {
+			return err
+		}
+	}
+
+	if opts.WriteSidecar {
+		return writeSidecar(outputPath+".json", opts, report)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build avif
+
+package jewelcase
+
+import (
+	"image"
+	"io"
+
+	"github.com/gen2brain/avif"
+)
+
+func init() {
+	optionalDecoders["avif"] = decoder{decode: avif.Decode, config: avif.DecodeConfig}
+	optionalEncoders["avif"] = encodeAVIF
+}
+
+func encodeAVIF(w io.Writer, img image.Image, opts Options) error {
+	quality := opts.AVIFQuality
+	if quality <= 0 {
+		quality = avif.DefaultQuality
+	}
+	return avif.Encode(w, img, avif.Options{
+		Quality:      quality,
+		QualityAlpha: quality,
+		Speed:        avif.DefaultSpeed,
+	})
+}
@@ -0,0 +1,31 @@
+//go:build avif
+
+package jewelcase
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAVIFRoundTrip(t *testing.T) {
+	img, err := Process(testArt(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, "avif", Options{AVIFQuality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	if format := sniffFormat(buf.Bytes()); format != "avif" {
+		t.Errorf("encoded data sniffed as %q, want avif", format)
+	}
+
+	decoded, err := Decode(&buf, "avif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decoded.Bounds().Size(), img.Bounds().Size(); got != want {
+		t.Errorf("decoded image is %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,188 @@
+package jewelcase
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+)
+
+const (
+	backWidth  = 940
+	backHeight = 743
+
+	// backSpine is the width of each of the spines at the sides of the tray insert
+	backSpine = 39
+)
+
+// backArtRect is the area of the rear tray covered by the insert, including both spines.
+var backArtRect = image.Rect(20, 20, 20+900, 20+703)
+
+// backFrame renders the rear tray used for StyleBack.
+var backFrame = sync.OnceValue(func() frameSpec {
+	return frameSpec{
+		img:      renderRearTray(),
+		art:      backArtRect,
+		decorate: decorateBackInsert,
+	}
+})
+
+// renderRearTray draws the clear plastic back of a jewel case, which the insert sits
+// behind.
+func renderRearTray() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, backWidth, backHeight))
+	body := image.Rect(6, 6, backWidth-6, backHeight-6)
+
+	for y := range backHeight {
+		for x := range backWidth {
+			fx, fy := float64(x)+0.5, float64(y)+0.5
+			coverage := roundedRectCoverage(fx, fy, body, 10)
+			if coverage == 0 {
+				continue
+			}
+
+			// Mostly clear plastic, catching the light around the rim
+			v, alpha := 200.0, 0.55
+			if edge := roundedRectDistance(fx, fy, body, 10); edge < 5 {
+				v, alpha = 235-edge*8, 0.9
+			}
+
+			a := alpha * coverage
+			o := img.PixOffset(x, y)
+			img.Pix[o+0] = uint8(v * a)
+			img.Pix[o+1] = uint8(v * a)
+			img.Pix[o+2] = uint8(math.Min(v+8, 255) * a)
+			img.Pix[o+3] = uint8(255 * a)
+		}
+	}
+	return img
+}
+
+// decorateBackInsert turns the art into a tray insert: the art (or opts.BackImage) with
+// the track listing, a barcode and spines at either side.
+func decorateBackInsert(ctx *effectContext, art *image.RGBA) (*image.RGBA, error) {
+	opts := ctx.opts
+	if opts.BackImage != nil {
+		var err error
+		art, err = scaleAndCrop(ctx.buf, opts.BackImage, art.Bounds().Size(), opts.crop(), opts.matteColour(), opts.resampler())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bounds := art.Bounds()
+	panel := image.Rect(bounds.Min.X+backSpine, bounds.Min.Y, bounds.Max.X-backSpine, bounds.Max.Y)
+
+	// Darken the spines slightly, and mark the folds between them and the back
+	shade(art, image.Rect(bounds.Min.X, bounds.Min.Y, panel.Min.X, bounds.Max.Y), 0.2)
+	shade(art, image.Rect(panel.Max.X, bounds.Min.Y, bounds.Max.X, bounds.Max.Y), 0.2)
+	shade(art, image.Rect(panel.Min.X-1, bounds.Min.Y, panel.Min.X+1, bounds.Max.Y), 0.4)
+	shade(art, image.Rect(panel.Max.X-1, bounds.Min.Y, panel.Max.X+1, bounds.Max.Y), 0.4)
+
+	if opts.SpineText != "" {
+		mask, err := renderText(opts.SpineText, math.Min(opts.spineTextSize(), backSpine*0.7))
+		if err != nil {
+			return nil, err
+		}
+		rotated := rotateMaskClockwise(mask)
+		colour := image.NewUniform(opts.spineTextColour())
+		drawMaskCentred(art, image.Rect(bounds.Min.X, bounds.Min.Y+20, panel.Min.X, bounds.Max.Y-20), rotated, colour)
+		drawMaskCentred(art, image.Rect(panel.Max.X, bounds.Min.Y+20, bounds.Max.X, bounds.Max.Y-20), rotated, colour)
+	}
+
+	number := opts.Barcode
+	if number == "" {
+		number = randomBarcode(ctx.rng)
+	}
+	digits, upc, err := parseBarcode(number)
+	if err != nil {
+		return nil, err
+	}
+	ctx.report.Barcode = digits
+	if upc {
+		ctx.report.Barcode = digits[1:]
+	}
+
+	margin := panel.Dx() / 16
+	barcode := image.Rect(panel.Max.X-margin-236, panel.Max.Y-margin-110, panel.Max.X-margin, panel.Max.Y-margin)
+	if err := drawBarcode(art, barcode, digits, upc); err != nil {
+		return nil, err
+	}
+
+	if len(opts.TrackListing) > 0 {
+		listing := image.Rect(panel.Min.X+margin, panel.Min.Y+margin, panel.Max.X-margin, barcode.Min.Y-margin/2)
+		if err := drawTrackListing(art, listing, opts.TrackListing); err != nil {
+			return nil, err
+		}
+	}
+
+	return art, nil
+}
+
+// drawTrackListing draws the numbered tracks on a translucent dark panel, in one or two
+// columns depending on how many there are.
+func drawTrackListing(img *image.RGBA, area image.Rectangle, tracks []string) error {
+	columns := [][]string{make([]string, len(tracks))}
+	for i, track := range tracks {
+		columns[0][i] = fmt.Sprintf("%2d.  %s", i+1, track)
+	}
+	if len(tracks) > 12 {
+		half := (len(tracks) + 1) / 2
+		columns = [][]string{columns[0][:half], columns[0][half:]}
+	}
+
+	padding := 16
+	columnWidth := (area.Dx() - padding*(len(columns)+1)) / len(columns)
+	textArea := image.Point{X: columnWidth, Y: area.Dy() - padding*2}
+
+	// Use the largest text size that lets every column fit
+	var masks []*image.Alpha
+	for size := 26.0; ; size *= 0.92 {
+		masks = masks[:0]
+		fits := true
+		for _, column := range columns {
+			mask, err := renderLines(regularFont, column, size, false)
+			if err != nil {
+				return err
+			}
+			masks = append(masks, mask)
+			fits = fits && mask.Bounds().Dx() <= textArea.X && mask.Bounds().Dy() <= textArea.Y
+		}
+		if fits || size < 8 {
+			break
+		}
+	}
+
+	// Shrink the panel to fit the text, so short listings don't leave a large empty box
+	var width, height int
+	for _, mask := range masks {
+		width += mask.Bounds().Dx() + padding
+		height = max(height, mask.Bounds().Dy())
+	}
+	panel := image.Rect(area.Min.X, area.Min.Y, area.Min.X+min(width+padding, area.Dx()), area.Min.Y+min(height+padding*2, area.Dy()))
+	draw.DrawMask(img, panel, image.Black, image.Point{}, image.NewUniform(color.Alpha{A: 115}), image.Point{}, draw.Over)
+
+	origin := image.Point{X: panel.Min.X + padding, Y: panel.Min.Y + padding}
+	for _, mask := range masks {
+		size := mask.Bounds().Size()
+		r := image.Rectangle{Min: origin, Max: origin.Add(size)}.Intersect(panel)
+		draw.DrawMask(img, r, image.White, image.Point{}, mask, image.Point{}, draw.Over)
+		origin.X += size.X + padding
+	}
+	return nil
+}
+
+// shade darkens the given area of the image by the given fraction.
+func shade(img *image.RGBA, area image.Rectangle, amount float64) {
+	area = area.Intersect(img.Bounds())
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(area.Min.X, y):img.PixOffset(area.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			for c := range 3 {
+				row[i+c] = uint8(float64(row[i+c]) * (1 - amount))
+			}
+		}
+	}
+}
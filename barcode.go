@@ -0,0 +1,196 @@
+package jewelcase
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math/rand"
+	"strings"
+)
+
+// eanCodes are the bar patterns for each digit in the L (odd parity) set. The G set is
+// the reverse of the R set, which is the inverse of the L set.
+var eanCodes = [10]string{
+	"0001101", "0011001", "0010011", "0111101", "0100011",
+	"0110001", "0101111", "0111011", "0110111", "0001011",
+}
+
+// eanParity gives which of the left-hand digits use the G set rather than the L set,
+// which is how the first digit of an EAN-13 is encoded.
+var eanParity = [10]string{
+	"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG",
+	"LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL",
+}
+
+// barcodePrefixes are the starts of barcodes commonly found on CDs, used for random ones.
+var barcodePrefixes = []string{"50", "40", "45", "49", "60", "72", "88", "07"}
+
+// barcodeModule is the width in pixels of the narrowest bar.
+const barcodeModule = 2
+
+// eanCheckDigit returns the check digit for the first 12 digits of an EAN-13.
+func eanCheckDigit(digits string) int {
+	sum := 0
+	for i, d := range digits[:12] {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(d-'0') * weight
+	}
+	return (10 - sum%10) % 10
+}
+
+// parseBarcode checks that s is a valid 12-digit UPC-A or 13-digit EAN-13 and returns it
+// as an EAN-13, along with whether it was a UPC-A.
+func parseBarcode(s string) (string, bool, error) {
+	digits := strings.ReplaceAll(strings.ReplaceAll(s, " ", ""), "-", "")
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", false, fmt.Errorf("invalid barcode %q: must only contain digits", s)
+		}
+	}
+
+	upc := len(digits) == 12
+	if upc {
+		digits = "0" + digits
+	} else if len(digits) != 13 {
+		return "", false, fmt.Errorf("invalid barcode %q: must be 12 (UPC-A) or 13 (EAN-13) digits", s)
+	}
+
+	if check := eanCheckDigit(digits); int(digits[12]-'0') != check {
+		return "", false, fmt.Errorf("invalid barcode %q: check digit should be %d", s, check)
+	}
+	return digits, upc, nil
+}
+
+// randomBarcode returns a plausible EAN-13 for a CD, with a valid check digit.
+func randomBarcode(rng *rand.Rand) string {
+	var b strings.Builder
+	b.WriteString(barcodePrefixes[rng.Intn(len(barcodePrefixes))])
+	for b.Len() < 12 {
+		b.WriteByte(byte('0' + rng.Intn(10)))
+	}
+	b.WriteByte(byte('0' + eanCheckDigit(b.String())))
+	return b.String()
+}
+
+// eanModules returns the bars of an EAN-13 from left to right, including the guards, as
+// a string of '1's for black and '0's for white. guard reports which modules belong to
+// bars that extend below the others.
+func eanModules(digits string, upc bool) (string, []bool) {
+	var bars strings.Builder
+	var guard []bool
+	add := func(pattern string, long bool) {
+		bars.WriteString(pattern)
+		for range pattern {
+			guard = append(guard, long)
+		}
+	}
+
+	parity := eanParity[digits[0]-'0']
+	add("101", true)
+	for i := range 6 {
+		code := eanCodes[digits[i+1]-'0']
+		if parity[i] == 'G' {
+			code = reverse(invert(code))
+		}
+		// UPC-A extends the bars of its first and last digits along with the guards
+		add(code, upc && i == 0)
+	}
+	add("01010", true)
+	for i := range 6 {
+		add(invert(eanCodes[digits[i+7]-'0']), upc && i == 5)
+	}
+	add("101", true)
+	return bars.String(), guard
+}
+
+// drawBarcode draws the barcode in a white box filling the given area, with the digits
+// printed beneath the bars in the usual positions.
+func drawBarcode(img *image.RGBA, area image.Rectangle, digits string, upc bool) error {
+	draw.Draw(img, area, image.White, image.Point{}, draw.Src)
+
+	// Centre the bars, leaving room for the digit printed in the quiet zone on either side
+	bars, guard := eanModules(digits, upc)
+	width := len(bars) * barcodeModule
+	left := area.Min.X + (area.Dx()-width)/2
+	top := area.Min.Y + 8
+	textHeight := 18
+	bottom := area.Max.Y - 6 - textHeight/2
+	guardBottom := area.Max.Y - 6
+
+	for i, b := range bars {
+		if b != '1' {
+			continue
+		}
+		x := left + i*barcodeModule
+		end := bottom
+		if guard[i] {
+			end = guardBottom
+		}
+		draw.Draw(img, image.Rect(x, top, x+barcodeModule, end), image.Black, image.Point{}, draw.Src)
+	}
+
+	// The digits sit below the two halves of the bars, with the first one (and the last,
+	// for a UPC-A) outside them
+	digit := func(s string, from, to int) error {
+		mask, err := renderText(s, float64(textHeight))
+		if err != nil {
+			return err
+		}
+		r := image.Rect(from, bottom, to, area.Max.Y)
+		drawMaskCentred(img, r, mask, image.Black)
+		return nil
+	}
+	module := func(n int) int { return left + n*barcodeModule }
+	spread := func(s string, from, to int) error {
+		step := float64(to-from) / float64(len(s))
+		for i := range s {
+			if err := digit(s[i:i+1], from+int(float64(i)*step), from+int(float64(i+1)*step)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if upc {
+		if err := digit(digits[1:2], area.Min.X, left); err != nil {
+			return err
+		}
+		if err := spread(digits[2:7], module(3+7), module(45)); err != nil {
+			return err
+		}
+		if err := spread(digits[7:12], module(50), module(92-7)); err != nil {
+			return err
+		}
+		return digit(digits[12:], module(95), area.Max.X)
+	}
+
+	if err := digit(digits[:1], area.Min.X, left); err != nil {
+		return err
+	}
+	if err := spread(digits[1:7], module(3), module(45)); err != nil {
+		return err
+	}
+	return spread(digits[7:], module(50), module(92))
+}
+
+// invert swaps the black and white modules of a pattern.
+func invert(pattern string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '0' {
+			return '1'
+		}
+		return '0'
+	}, pattern)
+}
+
+// reverse returns the pattern backwards.
+func reverse(pattern string) string {
+	b := []byte(pattern)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
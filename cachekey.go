@@ -0,0 +1,226 @@
+package jewelcase
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"image"
+)
+
+// CacheKey returns a stable hash of all the options that affect the output of Process,
+// suitable for use as a cache key alongside a hash of the source image.
+//
+// Options that don't change the processed image are excluded:
+//   - Force, which only controls whether already-processed images are skipped
+//   - MaxInputPixels, which only controls whether large images are rejected
+//   - JPEGQuality, JPEGProgressive, PNGCompression, AVIFQuality, OutputFormat and
+//     PreserveMetadata, which only apply when encoding
+//   - BackupSuffix, WriteSidecar, PreserveFileAttributes and DryRun, which only affect
+//     how files are written
+//   - OnStart, OnFile, OnResult, RateLimit, Newest, Include, Exclude, IncludeAudio and
+//     AlbumArtOutput, which only affect ProcessDirectory and ProcessMusicLibrary
+//   - Rand, which can't meaningfully be compared
+//
+// Custom entries in Effects are identified only by their type.
+//
+// Parameters of disabled effects are also excluded, and defaulted parameters hash the
+// same as their explicit equivalents.
+func (o Options) CacheKey() string {
+	h := sha256.New()
+
+	fmt.Fprintf(h, "colour=%t\n", o.ColourCorrection)
+	if o.ColourCorrection {
+		fmt.Fprintf(h, "preserve-grayscale=%t\n", o.PreserveGrayscale)
+		fmt.Fprintf(h, "tint=%g\n", o.tintAmount())
+		r, g, b, a := o.tintColour().RGBA()
+		fmt.Fprintf(h, "tint-colour=%d,%d,%d,%d\n", r, g, b, a)
+		fmt.Fprintf(h, "saturation-reduction=%g\n", o.saturationReduction())
+		fmt.Fprintf(h, "contrast-reduction=%g\n", o.contrastReduction())
+	}
+	fmt.Fprintf(h, "paper=%t\n", o.PaperTexture)
+	if o.PaperTexture {
+		fmt.Fprintf(h, "paper-opacity=%g\n", o.paperOpacity())
+	}
+	fmt.Fprintf(h, "grain=%t\n", o.Grain)
+	if o.Grain {
+		fmt.Fprintf(h, "grain-intensity=%g\n", o.grainIntensity())
+	}
+	fmt.Fprintf(h, "vignette=%t\n", o.Vignette)
+	if o.Vignette {
+		fmt.Fprintf(h, "vignette-strength=%g\n", o.vignetteStrength())
+	}
+	fmt.Fprintf(h, "inner-shadow=%t\n", o.InnerShadow)
+	if o.InnerShadow {
+		fmt.Fprintf(h, "inner-shadow-width=%g\n", o.innerShadowWidth())
+	}
+	fmt.Fprintf(h, "crop=%s\n", o.crop())
+	if o.crop() == CropLetterbox {
+		r, g, b, a := o.matteColour().RGBA()
+		fmt.Fprintf(h, "matte-colour=%d,%d,%d,%d\n", r, g, b, a)
+	}
+	fmt.Fprintf(h, "flatten=%t\n", o.Flatten)
+	if o.Flatten {
+		r, g, b, a := o.flattenColour().RGBA()
+		fmt.Fprintf(h, "flatten-colour=%d,%d,%d,%d\n", r, g, b, a)
+	}
+	fmt.Fprintf(h, "filter=%s\n", o.filter())
+	fmt.Fprintf(h, "linear-light=%t\n", o.LinearLight)
+	fmt.Fprintf(h, "high-bit-depth=%t\n", o.HighBitDepth)
+	fmt.Fprintf(h, "sharpen=%t\n", o.Sharpen)
+	if o.Sharpen {
+		amount, radius := o.sharpening()
+		fmt.Fprintf(h, "sharpen-amount=%g\n", amount)
+		fmt.Fprintf(h, "sharpen-radius=%g\n", radius)
+	}
+	fmt.Fprintf(h, "corners=%t\n", o.RoundedCorners)
+	if o.RoundedCorners {
+		lo, hi := o.cornerRadii()
+		fmt.Fprintf(h, "corner-radii=%g,%g\n", lo, hi)
+	}
+	fmt.Fprintf(h, "edges=%t\n", o.EdgeSoftening)
+	fmt.Fprintf(h, "offset=%t\n", o.RandomOffset)
+	if o.RandomOffset {
+		x, y := o.maxOffset()
+		fmt.Fprintf(h, "max-offset=%d,%d\n", x, y)
+	}
+	fmt.Fprintf(h, "rotation=%t\n", o.RandomRotation)
+	if o.RandomRotation {
+		fmt.Fprintf(h, "max-rotation=%g\n", o.maxRotation())
+	}
+	fmt.Fprintf(h, "reflection=%t\n", o.Reflection)
+	if o.Reflection {
+		fmt.Fprintf(h, "reflection-strength=%g\n", o.reflectionStrength())
+		fmt.Fprintf(h, "reflection-angle=%g\n", o.ReflectionAngle)
+		fmt.Fprintf(h, "reflection-width=%g\n", o.reflectionWidth())
+		fmt.Fprintf(h, "double-reflection=%t\n", o.DoubleReflection)
+	}
+	fmt.Fprintf(h, "glare=%t\n", o.Glare)
+	if o.Glare {
+		fmt.Fprintf(h, "glare-angle=%g\n", o.GlareAngle)
+		fmt.Fprintf(h, "glare-width=%g\n", o.glareWidth())
+	}
+	fmt.Fprintf(h, "scratches=%t\n", o.Scratches)
+	if o.Scratches {
+		fmt.Fprintf(h, "scratch-density=%g\n", o.scratchDensity())
+	}
+	fmt.Fprintf(h, "dust=%t\n", o.Dust)
+	if o.Dust {
+		fmt.Fprintf(h, "dust-density=%g\n", o.dustDensity())
+	}
+	fmt.Fprintf(h, "yellowing=%t\n", o.Yellowing)
+	if o.Yellowing {
+		fmt.Fprintf(h, "yellowing-strength=%g\n", o.yellowingStrength())
+	}
+	fmt.Fprintf(h, "fingerprints=%t\n", o.Fingerprints)
+	if o.Fingerprints {
+		fmt.Fprintf(h, "fingerprint-intensity=%g\n", o.fingerprintIntensity())
+	}
+	fmt.Fprintf(h, "cracks=%t\n", o.Cracks)
+	if o.Cracks {
+		fmt.Fprintf(h, "crack-probability=%g\n", o.crackProbability())
+	}
+	fmt.Fprintf(h, "shrink-wrap=%t\n", o.ShrinkWrap)
+	fmt.Fprintf(h, "perspective=%t\n", o.Perspective)
+	if o.Perspective {
+		fmt.Fprintf(h, "perspective-tilt=%g\n", o.perspectiveTilt())
+	}
+
+	fmt.Fprintf(h, "spine-text=%q\n", o.SpineText)
+	if o.SpineText != "" {
+		fmt.Fprintf(h, "spine-text-size=%g\n", o.spineTextSize())
+		r, g, b, a := o.spineTextColour().RGBA()
+		fmt.Fprintf(h, "spine-text-colour=%d,%d,%d,%d\n", r, g, b, a)
+	}
+	fmt.Fprintf(h, "parental-advisory=%t\n", o.ParentalAdvisory)
+	fmt.Fprintf(h, "obi=%t\n", o.Obi)
+	if o.Obi {
+		r, g, b, a := o.obiColour().RGBA()
+		fmt.Fprintf(h, "obi-text=%q,%q,%q\n", o.ObiTitle, o.ObiArtist, o.ObiPrice)
+		fmt.Fprintf(h, "obi-look=%d,%d,%d,%d,%s\n", r, g, b, a, o.obiPaper())
+	}
+	fmt.Fprintf(h, "hype-sticker-text=%q\n", o.HypeStickerText)
+	if o.HypeStickerText != "" {
+		r, g, b, a := o.hypeStickerColour().RGBA()
+		fmt.Fprintf(h, "hype-sticker=%s,%d,%d,%d,%d,%g,%s\n", o.hypeStickerShape(), r, g, b, a, o.hypeStickerSize(), o.hypeStickerCorner())
+	}
+	fmt.Fprintf(h, "shop-sticker-text=%q\n", o.ShopStickerText)
+	if o.ShopStickerText != "" {
+		r, g, b, a := o.shopStickerColour().RGBA()
+		fmt.Fprintf(h, "shop-sticker=%d,%d,%d,%d,%g,%g,%s\n", r, g, b, a, o.shopStickerSize(), o.ShopStickerRotation, o.shopStickerCorner())
+	}
+	fmt.Fprintf(h, "price-sticker=%t\n", o.PriceSticker)
+	if o.PriceSticker {
+		fmt.Fprintf(h, "price=%q,%q,%s\n", o.priceText(), o.priceCurrency(), o.priceStyle())
+	}
+	fmt.Fprintf(h, "tilt=%t\n", o.Tilt)
+	if o.Tilt {
+		fmt.Fprintf(h, "tilt-yaw=%g\n", o.TiltYaw)
+		fmt.Fprintf(h, "tilt-pitch=%g\n", o.TiltPitch)
+	}
+	fmt.Fprintf(h, "drop-shadow=%t\n", o.DropShadow)
+	if o.DropShadow {
+		fmt.Fprintf(h, "background=%v,%v\n", premultiplied(o.BackgroundColour), premultiplied(o.BackgroundGradient))
+	}
+	fmt.Fprintf(h, "output-size=%d,%d\n", max(o.OutputWidth, 0), max(o.OutputHeight, 0))
+	fmt.Fprintf(h, "scale=%d\n", o.scale())
+	fmt.Fprintf(h, "seed-from-content=%t\n", o.SeedFromContent)
+
+	fmt.Fprintf(h, "frames=%d\n", len(o.Frames))
+	for i := range o.Frames {
+		hashImage(h, o.Frames[i])
+		if i < len(o.FrameOffsets) {
+			fmt.Fprintf(h, "frame-offset=%d,%d\n", o.FrameOffsets[i].X, o.FrameOffsets[i].Y)
+		}
+	}
+
+	if o.Effects != nil {
+		fmt.Fprintf(h, "effects=%d\n", len(o.Effects))
+		for _, e := range o.Effects {
+			if b, ok := e.(builtinEffect); ok {
+				fmt.Fprintf(h, "effect=%s\n", b.name)
+			} else {
+				fmt.Fprintf(h, "effect=%T\n", e)
+			}
+		}
+	}
+
+	if len(o.Frames) == 0 && o.Frame != nil {
+		fmt.Fprintf(h, "frame\n")
+		hashImage(h, o.Frame)
+		fmt.Fprintf(h, "frame-art=%v\n", o.FrameArtRect.Sub(o.Frame.Bounds().Min))
+		for _, m := range o.FrameMasks {
+			fmt.Fprintf(h, "frame-mask=%v\n", m.Sub(o.Frame.Bounds().Min))
+		}
+	} else if len(o.Frames) == 0 && (o.Style == "" || o.Style == StyleJewelCase) {
+		fmt.Fprintf(h, "frame-variant=%d\n", o.FrameVariant)
+		fmt.Fprintf(h, "tray=%s\n", o.tray())
+	} else if len(o.Frames) == 0 {
+		fmt.Fprintf(h, "style=%s\n", o.Style)
+		if o.Style == StyleBack {
+			fmt.Fprintf(h, "track-listing=%q\n", o.TrackListing)
+			fmt.Fprintf(h, "barcode=%q\n", o.Barcode)
+			if o.BackImage != nil {
+				hashImage(h, o.BackImage)
+			}
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashImage writes the dimensions and pixel data of the image to the hash.
+func hashImage(h hash.Hash, img image.Image) {
+	bounds := img.Bounds()
+	fmt.Fprintf(h, "image=%dx%d\n", bounds.Dx(), bounds.Dy())
+
+	buf := make([]byte, 0, 8*bounds.Dx())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		buf = buf[:0]
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			buf = append(buf, byte(r>>8), byte(r), byte(g>>8), byte(g), byte(b>>8), byte(b), byte(a>>8), byte(a))
+		}
+		h.Write(buf)
+	}
+}
@@ -0,0 +1,232 @@
+package jewelcase
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// cacheKeyExcluded lists the options documented as not affecting CacheKey.
+var cacheKeyExcluded = []string{
+	"Force", "MaxInputPixels", "JPEGQuality", "JPEGProgressive", "PNGCompression",
+	"AVIFQuality", "OutputFormat", "PreserveMetadata", "BackupSuffix", "WriteSidecar",
+	"PreserveFileAttributes", "DryRun", "OnStart", "OnFile", "OnResult", "RateLimit",
+	"Newest", "Include", "Exclude", "IncludeAudio", "AlbumArtOutput", "Rand",
+}
+
+// cacheKeyBase returns options with every effect enabled, so that their parameters
+// contribute to the key.
+func cacheKeyBase() Options {
+	return Options{
+		ColourCorrection: true, RoundedCorners: true, EdgeSoftening: true, RandomOffset: true,
+		RandomRotation: true, Reflection: true, ParentalAdvisory: true, Obi: true,
+		HypeStickerText: "New!", ShopStickerText: "Sale", PriceSticker: true,
+		PaperTexture: true, Grain: true, Vignette: true, InnerShadow: true, Glare: true,
+		Scratches: true, Dust: true, Cracks: true, Fingerprints: true, ShrinkWrap: true,
+		Yellowing: true, Perspective: true, Tilt: true, DropShadow: true, Crop: CropLetterbox,
+		Flatten: true, Sharpen: true, SpineText: "Artist - Album",
+	}
+}
+
+type testEffect struct{}
+
+func (testEffect) Apply(img *image.RGBA) *image.RGBA { return img }
+
+func TestCacheKeyChangesWithEveryOption(t *testing.T) {
+	red := color.RGBA{R: 200, G: 10, B: 10, A: 255}
+	frame := solidImage(100, 100, red)
+	withFrame := func(o Options) Options {
+		o.Frame = frame
+		o.FrameArtRect = image.Rect(10, 10, 90, 90)
+		return o
+	}
+	withFrames := func(o Options) Options {
+		o.Frames = []image.Image{frame}
+		o.FrameOffsets = []image.Point{{10, 10}}
+		return o
+	}
+	withBack := func(o Options) Options {
+		o.Style = StyleBack
+		return o
+	}
+
+	tests := map[string]struct {
+		base   func(Options) Options
+		change func(*Options)
+	}{
+		"ColourCorrection":     {change: func(o *Options) { o.ColourCorrection = false }},
+		"RoundedCorners":       {change: func(o *Options) { o.RoundedCorners = false }},
+		"EdgeSoftening":        {change: func(o *Options) { o.EdgeSoftening = false }},
+		"RandomOffset":         {change: func(o *Options) { o.RandomOffset = false }},
+		"RandomRotation":       {change: func(o *Options) { o.RandomRotation = false }},
+		"Reflection":           {change: func(o *Options) { o.Reflection = false }},
+		"ParentalAdvisory":     {change: func(o *Options) { o.ParentalAdvisory = false }},
+		"Obi":                  {change: func(o *Options) { o.Obi = false }},
+		"ObiTitle":             {change: func(o *Options) { o.ObiTitle = "Title" }},
+		"ObiArtist":            {change: func(o *Options) { o.ObiArtist = "Artist" }},
+		"ObiPrice":             {change: func(o *Options) { o.ObiPrice = "¥2500" }},
+		"ObiColour":            {change: func(o *Options) { o.ObiColour = red }},
+		"ObiPaper":             {change: func(o *Options) { o.ObiPaper = ObiPaperWashi }},
+		"HypeStickerText":      {change: func(o *Options) { o.HypeStickerText = "Hit!" }},
+		"HypeStickerShape":     {change: func(o *Options) { o.HypeStickerShape = StickerRoundedRect }},
+		"HypeStickerColour":    {change: func(o *Options) { o.HypeStickerColour = red }},
+		"HypeStickerSize":      {change: func(o *Options) { o.HypeStickerSize = 0.123 }},
+		"HypeStickerCorner":    {change: func(o *Options) { o.HypeStickerCorner = CornerBottomLeft }},
+		"ShopStickerText":      {change: func(o *Options) { o.ShopStickerText = "£3.99" }},
+		"ShopStickerColour":    {change: func(o *Options) { o.ShopStickerColour = red }},
+		"ShopStickerSize":      {change: func(o *Options) { o.ShopStickerSize = 0.123 }},
+		"ShopStickerRotation":  {change: func(o *Options) { o.ShopStickerRotation = 12 }},
+		"ShopStickerCorner":    {change: func(o *Options) { o.ShopStickerCorner = CornerTopRight }},
+		"PriceSticker":         {change: func(o *Options) { o.PriceSticker = false }},
+		"PriceText":            {change: func(o *Options) { o.PriceText = "12.34" }},
+		"PriceCurrency":        {change: func(o *Options) { o.PriceCurrency = "€" }},
+		"PriceStyle":           {change: func(o *Options) { o.PriceStyle = PriceSecurityStrip }},
+		"Style":                {change: func(o *Options) { o.Style = StyleVinyl }},
+		"BackImage":            {base: withBack, change: func(o *Options) { o.BackImage = frame }},
+		"TrackListing":         {base: withBack, change: func(o *Options) { o.TrackListing = []string{"One"} }},
+		"Barcode":              {base: withBack, change: func(o *Options) { o.Barcode = "5012345678900" }},
+		"Frames":               {change: func(o *Options) { *o = withFrames(*o) }},
+		"FrameOffsets":         {base: withFrames, change: func(o *Options) { o.FrameOffsets = []image.Point{{20, 20}} }},
+		"Frame":                {change: func(o *Options) { *o = withFrame(*o) }},
+		"FrameArtRect":         {base: withFrame, change: func(o *Options) { o.FrameArtRect = image.Rect(20, 20, 80, 80) }},
+		"FrameMasks":           {base: withFrame, change: func(o *Options) { o.FrameMasks = []image.Rectangle{image.Rect(0, 0, 5, 5)} }},
+		"FrameVariant":         {change: func(o *Options) { o.FrameVariant = 1 }},
+		"Tray":                 {change: func(o *Options) { o.Tray = TrayRed }},
+		"PreserveGrayscale":    {change: func(o *Options) { o.PreserveGrayscale = true }},
+		"PaperTexture":         {change: func(o *Options) { o.PaperTexture = false }},
+		"PaperOpacity":         {change: func(o *Options) { o.PaperOpacity = 0.123 }},
+		"Grain":                {change: func(o *Options) { o.Grain = false }},
+		"GrainIntensity":       {change: func(o *Options) { o.GrainIntensity = 0.123 }},
+		"Vignette":             {change: func(o *Options) { o.Vignette = false }},
+		"VignetteStrength":     {change: func(o *Options) { o.VignetteStrength = 0.123 }},
+		"InnerShadow":          {change: func(o *Options) { o.InnerShadow = false }},
+		"InnerShadowWidth":     {change: func(o *Options) { o.InnerShadowWidth = 12.3 }},
+		"Glare":                {change: func(o *Options) { o.Glare = false }},
+		"GlareAngle":           {change: func(o *Options) { o.GlareAngle = 12.3 }},
+		"GlareWidth":           {change: func(o *Options) { o.GlareWidth = 12.3 }},
+		"Scratches":            {change: func(o *Options) { o.Scratches = false }},
+		"ScratchDensity":       {change: func(o *Options) { o.ScratchDensity = 0.123 }},
+		"Dust":                 {change: func(o *Options) { o.Dust = false }},
+		"DustDensity":          {change: func(o *Options) { o.DustDensity = 0.123 }},
+		"Cracks":               {change: func(o *Options) { o.Cracks = false }},
+		"CrackProbability":     {change: func(o *Options) { o.CrackProbability = 0.123 }},
+		"Fingerprints":         {change: func(o *Options) { o.Fingerprints = false }},
+		"FingerprintIntensity": {change: func(o *Options) { o.FingerprintIntensity = 0.123 }},
+		"ShrinkWrap":           {change: func(o *Options) { o.ShrinkWrap = false }},
+		"Yellowing":            {change: func(o *Options) { o.Yellowing = false }},
+		"YellowingStrength":    {change: func(o *Options) { o.YellowingStrength = 0.123 }},
+		"Perspective":          {change: func(o *Options) { o.Perspective = false }},
+		"PerspectiveTilt":      {change: func(o *Options) { o.PerspectiveTilt = 0.123 }},
+		"Tilt":                 {change: func(o *Options) { o.Tilt = false }},
+		"TiltYaw":              {change: func(o *Options) { o.TiltYaw = 12.3 }},
+		"TiltPitch":            {change: func(o *Options) { o.TiltPitch = 12.3 }},
+		"DropShadow":           {change: func(o *Options) { o.DropShadow = false }},
+		"BackgroundColour":     {change: func(o *Options) { o.BackgroundColour = red }},
+		"BackgroundGradient":   {change: func(o *Options) { o.BackgroundGradient = red }},
+		"Crop":                 {change: func(o *Options) { o.Crop = CropTop }},
+		"MatteColour":          {change: func(o *Options) { o.MatteColour = red }},
+		"Flatten":              {change: func(o *Options) { o.Flatten = false }},
+		"FlattenColour":        {change: func(o *Options) { o.FlattenColour = red }},
+		"Filter":               {change: func(o *Options) { o.Filter = FilterNearest }},
+		"LinearLight":          {change: func(o *Options) { o.LinearLight = true }},
+		"HighBitDepth":         {change: func(o *Options) { o.HighBitDepth = true }},
+		"Sharpen":              {change: func(o *Options) { o.Sharpen = false }},
+		"SharpenAmount":        {change: func(o *Options) { o.SharpenAmount = 0.123 }},
+		"SharpenRadius":        {change: func(o *Options) { o.SharpenRadius = 0.123 }},
+		"OutputWidth":          {change: func(o *Options) { o.OutputWidth = 123 }},
+		"OutputHeight":         {change: func(o *Options) { o.OutputHeight = 123 }},
+		"Scale":                {change: func(o *Options) { o.Scale = 2 }},
+		"SeedFromContent":      {change: func(o *Options) { o.SeedFromContent = true }},
+		"SpineText":            {change: func(o *Options) { o.SpineText = "Other" }},
+		"SpineTextSize":        {change: func(o *Options) { o.SpineTextSize = 12.3 }},
+		"SpineTextColour":      {change: func(o *Options) { o.SpineTextColour = red }},
+		"Effects":              {change: func(o *Options) { o.Effects = []Effect{testEffect{}} }},
+		"CornerRadiusMin":      {change: func(o *Options) { o.CornerRadiusMin = 1.23 }},
+		"CornerRadiusMax":      {change: func(o *Options) { o.CornerRadiusMax = 12.3 }},
+		"MaxRotation":          {change: func(o *Options) { o.MaxRotation = 1.23 }},
+		"MaxOffsetX":           {change: func(o *Options) { o.MaxOffsetX = 12 }},
+		"MaxOffsetY":           {change: func(o *Options) { o.MaxOffsetY = 12 }},
+		"ReflectionStrength":   {change: func(o *Options) { o.ReflectionStrength = 0.123 }},
+		"ReflectionAngle":      {change: func(o *Options) { o.ReflectionAngle = 12.3 }},
+		"ReflectionWidth":      {change: func(o *Options) { o.ReflectionWidth = 0.123 }},
+		"DoubleReflection":     {change: func(o *Options) { o.DoubleReflection = true }},
+		"TintAmount":           {change: func(o *Options) { o.TintAmount = 0.123 }},
+		"TintColour":           {change: func(o *Options) { o.TintColour = red }},
+		"SaturationReduction":  {change: func(o *Options) { o.SaturationReduction = 0.123 }},
+		"ContrastReduction":    {change: func(o *Options) { o.ContrastReduction = 0.123 }},
+	}
+
+	// Every option must either be tested here or documented as excluded
+	fields := reflect.TypeOf(Options{})
+	for i := range fields.NumField() {
+		name := fields.Field(i).Name
+		if _, ok := tests[name]; !ok && !slices.Contains(cacheKeyExcluded, name) {
+			t.Errorf("%s isn't covered by the test or listed as excluded", name)
+		}
+	}
+
+	for name, tt := range tests {
+		base := cacheKeyBase()
+		if tt.base != nil {
+			base = tt.base(base)
+		}
+		changed := base
+		tt.change(&changed)
+		if base.CacheKey() == changed.CacheKey() {
+			t.Errorf("changing %s didn't change the cache key", name)
+		}
+	}
+}
+
+func TestCacheKeyIgnoresExcludedOptions(t *testing.T) {
+	base := cacheKeyBase()
+	changed := base
+	changed.Force = true
+	changed.MaxInputPixels = 123
+	changed.JPEGQuality = 12
+	changed.JPEGProgressive = true
+	changed.PNGCompression = png.BestCompression
+	changed.AVIFQuality = 12
+	changed.OutputFormat = "webp"
+	changed.PreserveMetadata = true
+	changed.BackupSuffix = ".orig"
+	changed.WriteSidecar = true
+	changed.PreserveFileAttributes = true
+	changed.DryRun = true
+	changed.OnStart = func(int) {}
+	changed.OnFile = func(string, error) {}
+	changed.OnResult = func(FileResult) {}
+	changed.RateLimit = 5
+	changed.Newest = 5
+	changed.Include = []string{"*.png"}
+	changed.Exclude = []string{"*.jpg"}
+	changed.IncludeAudio = true
+	changed.AlbumArtOutput = "cover.png"
+	changed.Rand = rand.New(rand.NewSource(1))
+
+	if base.CacheKey() != changed.CacheKey() {
+		t.Error("changing options that don't affect the output changed the cache key")
+	}
+}
+
+func TestCacheKeyMatchesEquivalentOptions(t *testing.T) {
+	if cacheKeyBase().CacheKey() != cacheKeyBase().CacheKey() {
+		t.Error("equal options gave different cache keys")
+	}
+
+	// Defaulted parameters hash the same as their explicit equivalents
+	explicit := cacheKeyBase().withDefaults()
+	if cacheKeyBase().CacheKey() != explicit.CacheKey() {
+		t.Error("explicit default parameters gave a different cache key to zero values")
+	}
+
+	// Parameters of disabled effects don't matter
+	a, b := Options{}, Options{GlareAngle: 12, VignetteStrength: 0.5, SpineTextSize: 10}
+	if a.CacheKey() != b.CacheKey() {
+		t.Error("parameters of disabled effects changed the cache key")
+	}
+}
@@ -0,0 +1,103 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+	"sync"
+)
+
+const (
+	cassetteWidth  = 603
+	cassetteHeight = 760
+
+	// cassetteSpine is the width of the spine of the J-card, which along with the front
+	// panel is visible through the case
+	cassetteSpine = 86
+)
+
+// cassetteArtRect is the area of the cassette frame covered by the J-card's spine and
+// front panel, in the proportions of a real J-card.
+var cassetteArtRect = image.Rect(40, 40, 40+523, 40+680)
+
+// cassetteFrame renders the case and J-card fold used for StyleCassette.
+var cassetteFrame = sync.OnceValue(func() frameSpec {
+	return frameSpec{
+		img:        renderCassetteCase(),
+		art:        cassetteArtRect,
+		artOverlay: renderJCardFold(),
+	}
+})
+
+// renderCassetteCase draws an empty smoked plastic cassette case, with the hinge on
+// the left.
+func renderCassetteCase() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, cassetteWidth, cassetteHeight))
+	body := image.Rect(20, 20, cassetteWidth-20, cassetteHeight-20)
+
+	for y := range cassetteHeight {
+		for x := range cassetteWidth {
+			fx, fy := float64(x)+0.5, float64(y)+0.5
+			coverage := roundedRectCoverage(fx, fy, body, 14)
+			if coverage == 0 {
+				continue
+			}
+
+			// Dark smoked plastic, lighter around the rim
+			v, alpha := 58.0, 0.85
+			if edge := roundedRectDistance(fx, fy, body, 14); edge < 4 {
+				v, alpha = 150-edge*20, 0.95
+			}
+
+			// Hinge knuckles along the spine
+			if x < cassetteArtRect.Min.X && x > body.Min.X+3 && (y%170 > 40 && y%170 < 80) {
+				v = 110 + 30*math.Sin(fx/4)
+			}
+
+			a := alpha * coverage
+			o := img.PixOffset(x, y)
+			img.Pix[o+0] = uint8(v * a)
+			img.Pix[o+1] = uint8(v * a)
+			img.Pix[o+2] = uint8((v + 6) * a)
+			img.Pix[o+3] = uint8(255 * a)
+		}
+	}
+	return img
+}
+
+// renderJCardFold shades the J-card's spine and the fold between it and the front panel.
+func renderJCardFold() *image.RGBA {
+	size := cassetteArtRect.Size()
+	img := image.NewRGBA(image.Rectangle{Max: size})
+
+	for y := range size.Y {
+		for x := range size.X {
+			var alpha float64
+			if d := math.Abs(float64(x) + 0.5 - cassetteSpine); d < 3 {
+				alpha = 0.35 * (1 - d/3)
+			} else if x < cassetteSpine {
+				alpha = 0.1
+			}
+
+			o := img.PixOffset(x, y)
+			img.Pix[o+3] = uint8(alpha * 255)
+		}
+	}
+	return img
+}
+
+// roundedRectDistance returns how far inside the rounded rectangle the point is, or a
+// negative number if it's outside.
+func roundedRectDistance(x, y float64, r image.Rectangle, radius float64) float64 {
+	dx := math.Min(x-float64(r.Min.X), float64(r.Max.X)-x)
+	dy := math.Min(y-float64(r.Min.Y), float64(r.Max.Y)-y)
+	if dx < radius && dy < radius {
+		return radius - math.Hypot(radius-dx, radius-dy)
+	}
+	return math.Min(dx, dy)
+}
+
+// roundedRectCoverage returns how much of the pixel centred on the point is covered by
+// the rounded rectangle, between 0 and 1.
+func roundedRectCoverage(x, y float64, r image.Rectangle, radius float64) float64 {
+	return math.Min(math.Max(roundedRectDistance(x, y, r, radius)+0.5, 0), 1)
+}
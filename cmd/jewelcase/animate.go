@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/csmith/jewelcase"
+)
+
+// animate saves a looping animation of a processed image, configured by the given command
+// line arguments.
+func animate(args []string) {
+	fs := flag.NewFlagSet("animate", flag.ExitOnError)
+	buildOptions := optionFlags(fs)
+	mode := fs.String("mode", "glint", "Movement to show: glint or sway")
+	frames := fs.Int("frames", 30, "Number of frames in one loop of the animation")
+	delay := fs.Duration("delay", 50*time.Millisecond, "How long to show each frame for")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s animate [options] <input-image> <output.gif|output.png>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	opts, err := buildOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	covers, err := loadCovers(fs.Args()[:1], opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	animOpts := jewelcase.AnimationOptions{
+		Mode:   jewelcase.AnimationMode(*mode),
+		Frames: *frames,
+		Delay:  *delay,
+	}
+	images, err := jewelcase.Animate(covers[0], animOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error animating: %v\n", err)
+		os.Exit(1)
+	}
+
+	output := fs.Arg(1)
+	err = jewelcase.WriteFileAtomic(output, 0o644, func(w io.Writer) error {
+		return jewelcase.EncodeAnimation(w, images, filepath.Ext(output), animOpts)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving %s: %v\n", output, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// config holds the settings read from a config file. Settings are keyed by flag name, and
+// apply to any command that has that flag.
+type config struct {
+	settings map[string]any
+	presets  map[string]map[string]any
+}
+
+// defaultConfigPath returns where the config file is read from if --config isn't given.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "jewelcase", "config.toml")
+}
+
+// loadConfig reads the TOML config file at path, or the default config file if path is
+// empty. It's not an error for the default config file to be missing.
+func loadConfig(path string) (*config, error) {
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigPath()
+		if path == "" {
+			return &config{}, nil
+		}
+	}
+
+	var settings map[string]any
+	if _, err := toml.DecodeFile(path, &settings); err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return &config{}, nil
+		}
+		return nil, fmt.Errorf("error reading config: %w", err)
+	}
+
+	c := &config{settings: settings, presets: map[string]map[string]any{}}
+	if presets, ok := settings["presets"]; ok {
+		delete(settings, "presets")
+		table, ok := presets.(map[string]any)
+		if !ok {
+			return nil, errors.New("error reading config: presets must be a table")
+		}
+		for name, preset := range table {
+			values, ok := preset.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("error reading config: preset %q must be a table", name)
+			}
+			c.presets[name] = values
+		}
+	}
+	return c, nil
+}
+
+// apply sets the flags in fs to the config file's settings, except for those that were
+// given explicitly on the command line. Settings for flags that fs doesn't have are
+// ignored, as they may be meant for a different command.
+func (c *config) apply(fs *flag.FlagSet) error {
+	return setFlags(fs, c.settings)
+}
+
+// setFlags sets the flags in fs to the given values, except for those that have already
+// been set. Lists are passed to the flag one element at a time.
+func setFlags(fs *flag.FlagSet, values map[string]any) error {
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	for name, value := range values {
+		if explicit[name] || fs.Lookup(name) == nil {
+			continue
+		}
+
+		list, ok := value.([]any)
+		if !ok {
+			list = []any{value}
+		}
+		for _, v := range list {
+			if err := fs.Set(name, fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("invalid value %q for %s in config: %w", fmt.Sprint(v), name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// stringList is a flag that can be given multiple times, collecting each value.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/csmith/jewelcase"
+)
+
+var (
+	coverArtArchiveURL = "https://coverartarchive.org"
+	musicBrainzURL     = "https://musicbrainz.org/ws/2"
+)
+
+// httpClient is used for all requests, following redirects from the Cover Art Archive
+// to wherever the image is hosted.
+var httpClient = &http.Client{Timeout: time.Minute}
+
+// userAgent identifies the tool to MusicBrainz, which rejects anonymous clients.
+const userAgent = "jewelcase (https://github.com/csmith/jewelcase)"
+
+// fetch downloads a front cover from the Cover Art Archive and processes it, configured
+// by the given command line arguments.
+func fetch(args []string) {
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	buildOptions := optionFlags(fs)
+	mbid := fs.String("mbid", "", "MusicBrainz ID of the release to fetch the cover for")
+	releaseGroup := fs.String("release-group", "", "MusicBrainz ID of the release group to fetch the cover for")
+	artist := fs.String("artist", "", "Artist to search MusicBrainz for")
+	album := fs.String("album", "", "Album to search MusicBrainz for")
+	coverSize := fs.String("cover-size", "1200", "Size of cover to download: 250, 500, 1200 or original")
+	dryRun := fs.Bool("dry-run", false, "Report which cover would be fetched, without downloading or writing anything")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s fetch [options] (--mbid <id> | --release-group <id> | --artist <name> --album <name>) <output-image>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	opts, err := buildOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var coverURL string
+	switch {
+	case *mbid != "":
+		coverURL = fmt.Sprintf("%s/release/%s/front", coverArtArchiveURL, url.PathEscape(*mbid))
+	case *releaseGroup != "":
+		coverURL = fmt.Sprintf("%s/release-group/%s/front", coverArtArchiveURL, url.PathEscape(*releaseGroup))
+	case *artist != "" && *album != "":
+		id, err := searchReleaseGroup(ctx, *artist, *album)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error searching MusicBrainz: %v\n", err)
+			os.Exit(1)
+		}
+		coverURL = fmt.Sprintf("%s/release-group/%s/front", coverArtArchiveURL, id)
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *coverSize != "original" {
+		coverURL += "-" + *coverSize
+	}
+
+	if *dryRun {
+		fmt.Printf("Would fetch: %s to %s\n", coverURL, fs.Arg(0))
+		return
+	}
+
+	if err := fetchCover(ctx, coverURL, fs.Arg(0), opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error fetching cover: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// fetchCover downloads the image at coverURL, applies the jewel case effect, and saves
+// the result to outputPath in the format given by its extension.
+func fetchCover(ctx context.Context, coverURL, outputPath string, opts jewelcase.Options) error {
+	res, err := get(ctx, coverURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return errors.New("no front cover available")
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response: %s", res.Status)
+	}
+
+	return jewelcase.WriteFileAtomic(outputPath, 0o644, func(w io.Writer) error {
+		return jewelcase.ProcessReaderContext(ctx, res.Body, w, filepath.Ext(outputPath), opts)
+	})
+}
+
+// searchReleaseGroup returns the ID of the release group that best matches the artist and
+// album.
+func searchReleaseGroup(ctx context.Context, artist, album string) (string, error) {
+	query := url.Values{
+		"query": {fmt.Sprintf("releasegroup:%s AND artist:%s", luceneQuote(album), luceneQuote(artist))},
+		"limit": {"1"},
+		"fmt":   {"json"},
+	}
+
+	res, err := get(ctx, musicBrainzURL+"/release-group?"+query.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected response: %s", res.Status)
+	}
+
+	var result struct {
+		ReleaseGroups []struct {
+			ID string `json:"id"`
+		} `json:"release-groups"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if len(result.ReleaseGroups) == 0 {
+		return "", fmt.Errorf("no albums found matching %q by %q", album, artist)
+	}
+	return result.ReleaseGroups[0].ID, nil
+}
+
+// luceneQuote quotes a phrase for use in a MusicBrainz search query.
+func luceneQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// get makes a GET request with the tool's user agent.
+func get(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	return httpClient.Do(req)
+}
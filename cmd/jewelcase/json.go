@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/csmith/jewelcase"
+)
+
+// jsonResult is the record printed for each file with --json.
+type jsonResult struct {
+	Path       string `json:"path"`
+	Output     string `json:"output"`
+	Status     string `json:"status"`
+	Reason     string `json:"reason,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	DryRun     bool   `json:"dry_run,omitempty"`
+}
+
+// printJSONResult prints the outcome of processing a file to stdout as a single line of
+// JSON. If dryRun is set, the outcome is what would have happened.
+func printJSONResult(result jewelcase.FileResult, dryRun bool) {
+	record := jsonResult{
+		Path:       result.Path,
+		Output:     result.Output,
+		Status:     "processed",
+		DurationMS: result.Duration.Milliseconds(),
+		DryRun:     dryRun,
+	}
+
+	switch {
+	case result.Err == nil:
+	case errors.Is(result.Err, jewelcase.ErrAlreadyProcessed):
+		record.Status = "skipped"
+		record.Reason = "already processed"
+	case errors.Is(result.Err, jewelcase.ErrNoEmbeddedArt):
+		record.Status = "skipped"
+		record.Reason = "no embedded art"
+	default:
+		record.Status = "error"
+		record.Error = result.Err.Error()
+	}
+
+	// Errors writing to stdout have nowhere better to go
+	_ = json.NewEncoder(os.Stdout).Encode(record)
+}
@@ -1,108 +1,748 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/rand"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/csmith/jewelcase"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		serve(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "fetch" {
+		fetch(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "watch" {
+		watch(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "stack" {
+		stack(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "montage" {
+		montage(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "animate" {
+		animate(os.Args[2:])
+		return
+	}
+
+	buildOptions := optionFlags(flag.CommandLine)
 	var (
-		colourCorrection = flag.Bool("colour", true, "Apply colour correction effect")
-		roundedCorners   = flag.Bool("corners", true, "Apply rounded corners effect")
-		edgeSoftening    = flag.Bool("edges", true, "Apply edge softening effect")
-		randomOffset     = flag.Bool("offset", true, "Apply random position offset")
-		randomRotation   = flag.Bool("rotation", true, "Apply random rotation")
-		reflection       = flag.Bool("reflection", true, "Apply reflection effect")
-		inplace          = flag.Bool("inplace", false, "Modify file in-place")
-		recursive        = flag.Bool("recursive", false, "Process directory recursively")
-		force            = flag.Bool("force", false, "Process images even if they appear to be already processed")
-		quiet            = flag.Bool("quiet", false, "Suppress skipped messages in recursive mode")
+		inplace   = flag.Bool("inplace", false, "Modify file in-place")
+		recursive = flag.Bool("recursive", false, "Process directory recursively")
+		quiet     = flag.Bool("quiet", false, "Suppress skipped messages in recursive mode")
+		newest    = flag.Int("newest", 0, "Only process the N most recently modified unprocessed images in recursive mode (0 for all)")
+		backup    = flag.String("backup", "", "Copy originals to a file with this suffix (e.g. .orig) before modifying them in place")
+		preserve  = flag.Bool("preserve-attributes", false, "Give outputs the same modification time and permissions as their inputs")
+		sidecar   = flag.Bool("sidecar", false, "Write a JSON file alongside each output recording the options and random values used")
+		backImage = flag.String("back-image", "", "Path to an image to use for the back cover instead of the art")
+		framePath = flag.String("frame", "", "Path to a custom frame image to use instead of the built-in jewel case")
+		frameArt  = flag.String("frame-art", "", "Area of the custom frame to place the art in, as x,y,width,height")
+		framePack = flag.String("frame-pack", "", "Path to a frame pack (a directory or zip file with a manifest.json) to use instead of the built-in jewel case")
+		workers   = flag.Int("workers", 1, "Number of images to process concurrently in recursive mode (0 for one per CPU)")
+		rateLimit = flag.Float64("rate-limit", 0, "Maximum images to process per second in recursive mode (0 for unlimited)")
+		audio     = flag.Bool("audio", false, "Also process art embedded in audio files in recursive mode")
+		musicDir  = flag.String("music-dir", "", "Process the album art (cover, folder or front) in each directory of a music library")
+		outName   = flag.String("output-name", "", "Save processed album art under this name (e.g. folder.jpg) in music-dir mode, instead of replacing it")
+		include   = &stringList{}
+		exclude   = &stringList{}
+		dryRun    = flag.Bool("dry-run", false, "Report which files would be processed, skipped or rejected, without writing anything")
+		jsonOut   = flag.Bool("json", false, "Print a line of JSON describing the outcome for each file, instead of text")
+		variants  = flag.Int("variants", 0, "Save this many differently randomised versions of the output, numbered e.g. output-1.jpg")
+		format    = flag.String("format", "", "Format to write, such as jpeg, png, webp or avif (defaults to the output's extension, or the input's format when writing to stdout)")
 	)
+	flag.Var(include, "include", "Only process files matching this glob pattern in recursive mode (may be repeated)")
+	flag.Var(exclude, "exclude", "Skip files and directories matching this glob pattern in recursive mode (may be repeated)")
 	flag.Parse()
 
 	args := flag.Args()
 
-	opts := jewelcase.Options{
-		ColourCorrection: *colourCorrection,
-		RoundedCorners:   *roundedCorners,
-		EdgeSoftening:    *edgeSoftening,
-		RandomOffset:     *randomOffset,
-		RandomRotation:   *randomRotation,
-		Reflection:       *reflection,
-		Force:            *force,
+	opts, err := buildOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	opts.BackupSuffix = *backup
+	opts.WriteSidecar = *sidecar
+	opts.PreserveFileAttributes = *preserve
+	opts.RateLimit = *rateLimit
+	opts.Newest = *newest
+	opts.IncludeAudio = *audio
+	opts.Include = *include
+	opts.Exclude = *exclude
+	opts.DryRun = *dryRun
+	opts.AlbumArtOutput = *outName
+
+	if *backImage != "" {
+		img, err := loadImage(*backImage)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading back image: %v\n", err)
+			os.Exit(1)
+		}
+		opts.BackImage = img
+	}
+
+	if *framePath != "" && *framePack != "" {
+		fmt.Fprintf(os.Stderr, "--frame and --frame-pack can't be used together\n")
+		os.Exit(1)
 	}
 
-	if *recursive {
+	if *framePath != "" {
+		if err := loadFrame(&opts, *framePath, *frameArt); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading frame: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *framePack != "" {
+		pack, err := jewelcase.LoadFramePack(*framePack)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading frame pack: %v\n", err)
+			os.Exit(1)
+		}
+		pack.Apply(&opts)
+	}
+
+	// Stop cleanly on Ctrl-C, rather than leaving files half-written
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *variants > 0 && (*musicDir != "" || *recursive || *inplace || slices.Contains(args, "-")) {
+		fmt.Fprintf(os.Stderr, "--variants can only be used with an input and output image\n")
+		os.Exit(1)
+	}
+
+	if *jsonOut && len(args) == 2 && args[1] == "-" {
+		fmt.Fprintf(os.Stderr, "--json can't be used when writing the image to stdout\n")
+		os.Exit(1)
+	}
+
+	if *dryRun && slices.Contains(args, "-") {
+		fmt.Fprintf(os.Stderr, "--dry-run can't be used with stdin or stdout\n")
+		os.Exit(1)
+	}
+
+	if *musicDir != "" {
+		if len(args) != 0 {
+			printUsage()
+		}
+		processDirectory(ctx, jewelcase.ProcessMusicLibraryContext, *musicDir, opts, *quiet, *jsonOut, *workers)
+	} else if *recursive {
 		if len(args) != 1 {
 			printUsage()
 		}
-		processDirectory(args[0], opts, *quiet)
+		processDirectory(ctx, jewelcase.ProcessDirectoryContext, args[0], opts, *quiet, *jsonOut, *workers)
 	} else if *inplace {
 		if len(args) != 1 {
 			printUsage()
 		}
-		err := jewelcase.ProcessFile(args[0], args[0], opts)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error applying jewel case: %v\n", err)
-			os.Exit(1)
-		}
+		start := time.Now()
+		err := processFile(ctx, args[0], args[0], opts)
+		finish(jewelcase.FileResult{Path: args[0], Output: args[0], Err: err, Duration: time.Since(start)}, *jsonOut, *dryRun)
 	} else {
 		if len(args) != 2 {
 			printUsage()
 		}
-		err := jewelcase.ProcessFile(args[0], args[1], opts)
+		start := time.Now()
+		var err error
+		opts.OutputFormat = *format
+		if args[0] == "-" || args[1] == "-" {
+			err = streamFile(ctx, args[0], args[1], *format, opts)
+		} else if *variants > 0 {
+			err = jewelcase.ProcessFileVariantsContext(ctx, args[0], args[1], *variants, opts)
+		} else {
+			err = processFile(ctx, args[0], args[1], opts)
+		}
+		finish(jewelcase.FileResult{Path: args[0], Output: args[1], Err: err, Duration: time.Since(start)}, *jsonOut, *dryRun)
+	}
+}
+
+// optionFlags registers the flags that control the effects applied to images on fs,
+// and returns a function that builds Options from them once they have been parsed.
+func optionFlags(fs *flag.FlagSet) func() (jewelcase.Options, error) {
+	var (
+		colourCorrection = fs.Bool("colour", true, "Apply colour correction effect")
+		roundedCorners   = fs.Bool("corners", true, "Apply rounded corners effect")
+		edgeSoftening    = fs.Bool("edges", true, "Apply edge softening effect")
+		randomOffset     = fs.Bool("offset", true, "Apply random position offset")
+		randomRotation   = fs.Bool("rotation", true, "Apply random rotation")
+		reflection       = fs.Bool("reflection", true, "Apply reflection effect")
+		cornerRadiusMin  = fs.Float64("corner-radius-min", 6, "Smallest radius for rounded corners, in pixels")
+		cornerRadiusMax  = fs.Float64("corner-radius-max", 12, "Largest radius for rounded corners, in pixels")
+		maxRotation      = fs.Float64("max-rotation", 0.5, "Largest random rotation in either direction, in degrees")
+		maxOffsetX       = fs.Int("max-offset-x", 8, "Largest random horizontal offset, in pixels")
+		maxOffsetY       = fs.Int("max-offset-y", 5, "Largest random vertical offset, in pixels")
+		reflectionAmount = fs.Float64("reflection-strength", 1, "Strength of the reflection effect")
+		reflectionAngle  = fs.Float64("reflection-angle", 0, "Direction of the light for the reflection, in degrees clockwise from the top-left")
+		reflectionWidth  = fs.Float64("reflection-width", 0, "Width of a brighter streak in the reflection, in pixels (0 for none)")
+		doubleReflection = fs.Bool("double-reflection", false, "Add a second, fainter streak beside the first when using --reflection-width")
+		tint             = fs.Float64("tint", 0.02, "Amount of tint applied by colour correction")
+		tintColour       = fs.String("tint-colour", "cool", "Colour that colour correction tints towards: cool, warm, sepia or a hex triplet")
+		saturation       = fs.Float64("saturation-reduction", 0.1, "Fraction by which colour correction reduces saturation")
+		contrast         = fs.Float64("contrast-reduction", 0.05, "Fraction by which colour correction reduces contrast")
+		force            = fs.Bool("force", false, "Process images even if they appear to be already processed")
+		maxInputPixels   = fs.Int("max-input-pixels", 100_000_000, "Largest image to read, in pixels, to avoid running out of memory (negative for no limit)")
+		preserveGray     = fs.Bool("preserve-grayscale", false, "Keep grayscale images neutral when applying colour correction")
+		paper            = fs.Bool("paper", false, "Blend a halftoned print texture into the art, like a printed insert")
+		paperOpacity     = fs.Float64("paper-opacity", 0.3, "How strongly the print texture is blended into the art, from 0 to 1")
+		grain            = fs.Bool("grain", false, "Add fine film grain to the art after colour correction")
+		grainIntensity   = fs.Float64("grain-intensity", 0.03, "Strength of the film grain, as a fraction of full brightness")
+		vignette         = fs.Bool("vignette", false, "Darken the art slightly towards its edges, like a photographed insert")
+		vignetteStrength = fs.Float64("vignette-strength", 0.25, "How much the corners of the art are darkened, from 0 to 1")
+		innerShadow      = fs.Bool("inner-shadow", false, "Shade the edges of the art where the lip of the case overhangs it")
+		innerShadowWidth = fs.Float64("inner-shadow-width", 6, "How far the inner shadow reaches into the art, in pixels")
+		glare            = fs.Bool("glare", false, "Apply a bright glare streak across the case")
+		glareAngle       = fs.Float64("glare-angle", jewelcase.DefaultGlareAngle, "Angle of the glare streak in degrees")
+		glareWidth       = fs.Float64("glare-width", 80, "Width of the glare streak in pixels")
+		scratches        = fs.Bool("scratches", false, "Draw faint scratches at random across the case")
+		scratchDensity   = fs.Float64("scratch-density", 2, "Number of scratches per 100,000 pixels, up to 50")
+		dust             = fs.Bool("dust", false, "Scatter tiny specks of dust at random across the case")
+		dustDensity      = fs.Float64("dust-density", 1, "Number of dust specks per 10,000 pixels, up to 100")
+		fingerprints     = fs.Bool("fingerprints", false, "Overlay faint greasy fingerprints and smudges at random across the case")
+		fingerprintLevel = fs.Float64("fingerprint-intensity", 1, "How many fingerprints and smudges to add, relative to the default, up to 10")
+		cracks           = fs.Bool("cracks", false, "Draw one or two cracks in the plastic of the case")
+		crackChance      = fs.Float64("crack-probability", 1, "Chance of each image being cracked when using --cracks, from 0 to 1")
+		shrinkWrap       = fs.Bool("shrink-wrap", false, "Overlay plastic wrap wrinkles, as if the album is still sealed")
+		yellowing        = fs.Bool("yellowing", false, "Tint the case amber, like plastic that has spent decades in the sun")
+		yellowingLevel   = fs.Float64("yellowing-strength", 0.5, "How yellowed the case is, from 0 to 1")
+		perspective      = fs.Bool("perspective", false, "Apply a slight perspective tilt to the art")
+		perspectiveTilt  = fs.Float64("perspective-tilt", 0.04, "Fraction of the art's height to shorten the far edge by (negative tilts left)")
+		seedContent      = fs.Bool("seed-from-content", false, "Derive random effects from the image content, so re-processing gives the same result")
+		spineText        = fs.String("spine-text", "", "Text to draw along the spine of the case")
+		spineTextSize    = fs.Float64("spine-text-size", 28, "Font size of the spine text in pixels, up to 200")
+		spineTextColour  = fs.String("spine-text-colour", "", "Colour of the spine text, as a hex triplet (light grey if empty, or dark grey on a clear tray)")
+		advisory         = fs.Bool("advisory", false, "Add a Parental Advisory label to the art")
+		obi              = fs.Bool("obi", false, "Wrap a Japanese-style obi strip around the left-hand side of the case")
+		obiTitle         = fs.String("obi-title", "", "Album title to print on the obi strip")
+		obiArtist        = fs.String("obi-artist", "", "Artist to print on the obi strip")
+		obiPrice         = fs.String("obi-price", "", "Price to print at the bottom of the obi strip, including any currency")
+		obiColour        = fs.String("obi-colour", "#b31b2c", "Colour of the obi strip, as a hex triplet")
+		obiPaper         = fs.String("obi-paper", "matte", "Paper stock of the obi strip: matte, gloss or washi")
+		hypeText         = fs.String("hype-text", "", "Text for a hype sticker on the art, with lines separated by \\n")
+		hypeShape        = fs.String("hype-shape", "circle", "Shape of the hype sticker: circle or rounded")
+		hypeColour       = fs.String("hype-colour", "#ffd400", "Colour of the hype sticker, as a hex triplet")
+		hypeSize         = fs.Float64("hype-size", 170, "Width of the hype sticker in pixels, up to 1000")
+		hypeCorner       = fs.String("hype-corner", "top-right", "Corner of the art to put the hype sticker in: top-left, top-right, bottom-left or bottom-right")
+		shopText         = fs.String("shop-text", "", "Text for a round second-hand shop sticker on the art, with lines separated by \\n, or used or promo for the usual ones")
+		shopColour       = fs.String("shop-colour", "#f26a1b", "Colour of the shop sticker, as a hex triplet")
+		shopSize         = fs.Float64("shop-size", 100, "Width of the shop sticker in pixels, up to 1000")
+		shopRotation     = fs.Float64("shop-rotation", 0, "Angle of the shop sticker in degrees, clockwise")
+		shopCorner       = fs.String("shop-corner", "bottom-left", "Corner of the art to put the shop sticker in: top-left, top-right, bottom-left or bottom-right")
+		priceSticker     = fs.Bool("price-sticker", false, "Add a record shop price sticker to a random corner of the art")
+		price            = fs.String("price", "7.99", "Price printed on the price sticker")
+		priceCurrency    = fs.String("price-currency", "£", "Currency symbol printed before the price")
+		priceStyle       = fs.String("price-style", "label", "Style of price sticker: label or security")
+		style            = fs.String("style", "jewelcase", "Style of frame to use: jewelcase, vinyl, cassette, back, disc, slimline, dvd, minidisc, vhs, digipak or fatbox")
+		frameVariant     = fs.Int("frame-variant", 0, "Which photograph of the jewel case to use, from 1 to 4 (0 picks one at random for each image)")
+		tray             = fs.String("tray", "black", "Colour of the jewel case tray: black, clear, smoke, blue, red, green or random")
+		crop             = fs.String("crop", "center", "How to fit art that isn't square: center, top, entropy, letterbox or stretch")
+		matteColour      = fs.String("matte-colour", "#000000", "Colour around letterboxed art, as a hex triplet")
+		flatten          = fs.Bool("flatten", false, "Place art with transparent areas over a solid colour before scaling it")
+		flattenColour    = fs.String("flatten-colour", "#ffffff", "Colour to place transparent art over, as a hex triplet")
+		filter           = fs.String("filter", "bilinear", "Resampling filter for scaling and rotating the art: nearest, bilinear, catmull-rom or lanczos")
+		linearLight      = fs.Bool("linear-light", true, "Scale, rotate and composite the art in linear light, keeping fine detail from darkening")
+		highBitDepth     = fs.Bool("high-bit-depth", false, "Keep 16-bit images at 16 bits per channel, so PNG and TIFF output is 16-bit too")
+		sharpen          = fs.Bool("sharpen", false, "Sharpen the art after scaling it to fit the frame")
+		sharpenAmount    = fs.Float64("sharpen-amount", 0.5, "How strongly to sharpen the art")
+		sharpenRadius    = fs.Float64("sharpen-radius", 1, "Radius of the blur used to find detail to sharpen, in pixels, up to 20")
+		trackList        = fs.String("track-list", "", "Tracks to list on the back cover, separated by \\n")
+		barcode          = fs.String("barcode", "", "UPC-A or EAN-13 barcode to print on the back cover (random if empty)")
+		tilt             = fs.Bool("tilt", false, "Turn the finished case in 3D, showing its spine down the side")
+		tiltYaw          = fs.Float64("tilt-yaw", jewelcase.DefaultTiltYaw, "Degrees to turn the case about its vertical axis (positive turns the right edge away)")
+		tiltPitch        = fs.Float64("tilt-pitch", jewelcase.DefaultTiltPitch, "Degrees to turn the case about its horizontal axis (positive tips the top edge away)")
+		dropShadow       = fs.Bool("drop-shadow", false, "Place the case on a larger background with a soft drop shadow")
+		background       = fs.String("background", "", "Colour of the drop shadow background, as a hex triplet (transparent if empty)")
+		backgroundEnd    = fs.String("background-gradient", "", "Colour for the drop shadow background to fade to at the bottom, as a hex triplet")
+		size             = fs.String("size", "", "Resize the output to fit within WIDTHxHEIGHT pixels; either may be omitted (e.g. 400x)")
+		scale            = fs.Int("scale", 1, "Render the jewel case at this multiple of its usual size, up to 3, for high-density displays (the art keeps its detail, but the case photo is upscaled)")
+		jpegQuality      = fs.Int("jpeg-quality", 95, "Quality of JPEG output, from 1 to 100")
+		progressive      = fs.Bool("progressive", false, "Write progressive rather than baseline JPEGs")
+		stripMetadata    = fs.Bool("strip-metadata", false, "Don't copy EXIF data and ICC colour profiles from the input to the output")
+		pngCompression   = fs.String("png-compression", "default", "Compression level for PNG output: default, none, fast or best")
+		avifQuality      = fs.Int("avif-quality", 60, "Quality of AVIF output, from 1 to 100 (only available when built with the avif tag)")
+		preset           = fs.String("preset", "", "Start from a preset look: mint, used, thrashed or one defined in the config file (other options override it)")
+		configPath       = fs.String("config", "", "Path to a TOML file of default settings (defaults to jewelcase/config.toml in the user config directory, if it exists)")
+		seed             = fs.Int64("seed", 0, "Seed for the random effects, for reproducible output (0 for random)")
+	)
+
+	return func() (jewelcase.Options, error) {
+		cfg, err := loadConfig(*configPath)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error applying jewel case: %v\n", err)
-			os.Exit(1)
+			return jewelcase.Options{}, err
+		}
+		if err := cfg.apply(fs); err != nil {
+			return jewelcase.Options{}, err
+		}
+		customPreset, isCustomPreset := cfg.presets[*preset]
+		if isCustomPreset {
+			if err := setFlags(fs, customPreset); err != nil {
+				return jewelcase.Options{}, err
+			}
+		}
+
+		var spineColour color.Color
+		if *spineTextColour != "" {
+			if spineColour, err = jewelcase.ParseColour(*spineTextColour); err != nil {
+				return jewelcase.Options{}, fmt.Errorf("invalid spine text colour: %w", err)
+			}
+		}
+
+		tintTo, ok := jewelcase.TintColours[*tintColour]
+		if !ok {
+			if tintTo, err = jewelcase.ParseColour(*tintColour); err != nil {
+				return jewelcase.Options{}, fmt.Errorf("invalid tint colour: %w", err)
+			}
+		}
+
+		stickerColour, err := jewelcase.ParseColour(*hypeColour)
+		if err != nil {
+			return jewelcase.Options{}, fmt.Errorf("invalid hype sticker colour: %w", err)
+		}
+
+		obiStripColour, err := jewelcase.ParseColour(*obiColour)
+		if err != nil {
+			return jewelcase.Options{}, fmt.Errorf("invalid obi colour: %w", err)
+		}
+
+		shopStickerColour, err := jewelcase.ParseColour(*shopColour)
+		if err != nil {
+			return jewelcase.Options{}, fmt.Errorf("invalid shop sticker colour: %w", err)
+		}
+
+		matte, err := jewelcase.ParseColour(*matteColour)
+		if err != nil {
+			return jewelcase.Options{}, fmt.Errorf("invalid matte colour: %w", err)
+		}
+
+		flattenOver, err := jewelcase.ParseColour(*flattenColour)
+		if err != nil {
+			return jewelcase.Options{}, fmt.Errorf("invalid flatten colour: %w", err)
+		}
+
+		var backgroundColours [2]color.Color
+		for i, s := range []string{*background, *backgroundEnd} {
+			if s == "" {
+				continue
+			}
+			if backgroundColours[i], err = jewelcase.ParseColour(s); err != nil {
+				return jewelcase.Options{}, fmt.Errorf("invalid background colour: %w", err)
+			}
+		}
+
+		width, height, err := parseSize(*size)
+		if err != nil {
+			return jewelcase.Options{}, fmt.Errorf("invalid size: %w", err)
 		}
+
+		compression, err := jewelcase.ParseCompression(*pngCompression)
+		if err != nil {
+			return jewelcase.Options{}, err
+		}
+
+		opts := jewelcase.Options{
+			ColourCorrection:     *colourCorrection,
+			RoundedCorners:       *roundedCorners,
+			EdgeSoftening:        *edgeSoftening,
+			RandomOffset:         *randomOffset,
+			RandomRotation:       *randomRotation,
+			Reflection:           *reflection,
+			Force:                *force,
+			MaxInputPixels:       *maxInputPixels,
+			PreserveGrayscale:    *preserveGray,
+			PaperTexture:         *paper,
+			PaperOpacity:         *paperOpacity,
+			Grain:                *grain,
+			GrainIntensity:       *grainIntensity,
+			Vignette:             *vignette,
+			VignetteStrength:     *vignetteStrength,
+			InnerShadow:          *innerShadow,
+			InnerShadowWidth:     *innerShadowWidth,
+			Glare:                *glare,
+			GlareAngle:           *glareAngle,
+			GlareWidth:           *glareWidth,
+			Scratches:            *scratches,
+			ScratchDensity:       *scratchDensity,
+			Dust:                 *dust,
+			DustDensity:          *dustDensity,
+			Fingerprints:         *fingerprints,
+			FingerprintIntensity: *fingerprintLevel,
+			Cracks:               *cracks,
+			CrackProbability:     *crackChance,
+			ShrinkWrap:           *shrinkWrap,
+			Yellowing:            *yellowing,
+			YellowingStrength:    *yellowingLevel,
+			Perspective:          *perspective,
+			PerspectiveTilt:      *perspectiveTilt,
+			SeedFromContent:      *seedContent,
+			SpineText:            *spineText,
+			SpineTextSize:        *spineTextSize,
+			SpineTextColour:      spineColour,
+			CornerRadiusMin:      *cornerRadiusMin,
+			CornerRadiusMax:      *cornerRadiusMax,
+			MaxRotation:          *maxRotation,
+			MaxOffsetX:           *maxOffsetX,
+			MaxOffsetY:           *maxOffsetY,
+			ReflectionStrength:   *reflectionAmount,
+			ReflectionAngle:      *reflectionAngle,
+			ReflectionWidth:      *reflectionWidth,
+			DoubleReflection:     *doubleReflection,
+			TintAmount:           *tint,
+			TintColour:           tintTo,
+			SaturationReduction:  *saturation,
+			ContrastReduction:    *contrast,
+			Style:                jewelcase.Style(*style),
+			FrameVariant:         *frameVariant,
+			Tray:                 jewelcase.Tray(*tray),
+			TrackListing:         splitLines(*trackList),
+			Barcode:              *barcode,
+			Crop:                 jewelcase.CropMode(*crop),
+			MatteColour:          matte,
+			Flatten:              *flatten,
+			FlattenColour:        flattenOver,
+			Filter:               jewelcase.Filter(*filter),
+			LinearLight:          *linearLight,
+			HighBitDepth:         *highBitDepth,
+			Sharpen:              *sharpen,
+			SharpenAmount:        *sharpenAmount,
+			SharpenRadius:        *sharpenRadius,
+			Tilt:                 *tilt,
+			TiltYaw:              *tiltYaw,
+			TiltPitch:            *tiltPitch,
+			DropShadow:           *dropShadow,
+			BackgroundColour:     backgroundColours[0],
+			BackgroundGradient:   backgroundColours[1],
+			OutputWidth:          width,
+			OutputHeight:         height,
+			Scale:                *scale,
+			JPEGQuality:          *jpegQuality,
+			JPEGProgressive:      *progressive,
+			PNGCompression:       compression,
+			AVIFQuality:          *avifQuality,
+			PreserveMetadata:     !*stripMetadata,
+			ParentalAdvisory:     *advisory,
+			Obi:                  *obi,
+			ObiTitle:             *obiTitle,
+			ObiArtist:            *obiArtist,
+			ObiPrice:             *obiPrice,
+			ObiColour:            obiStripColour,
+			ObiPaper:             jewelcase.ObiPaper(*obiPaper),
+			HypeStickerText:      strings.Join(splitLines(*hypeText), "\n"),
+			HypeStickerShape:     jewelcase.StickerShape(*hypeShape),
+			HypeStickerColour:    stickerColour,
+			HypeStickerSize:      *hypeSize,
+			HypeStickerCorner:    jewelcase.Corner(*hypeCorner),
+			ShopStickerText:      shopStickerText(*shopText),
+			ShopStickerColour:    shopStickerColour,
+			ShopStickerSize:      *shopSize,
+			ShopStickerRotation:  *shopRotation,
+			ShopStickerCorner:    jewelcase.Corner(*shopCorner),
+			PriceSticker:         *priceSticker,
+			PriceText:            *price,
+			PriceCurrency:        *priceCurrency,
+			PriceStyle:           jewelcase.PriceStyle(*priceStyle),
+		}
+
+		if *preset != "" && !isCustomPreset {
+			p, ok := jewelcase.Presets[*preset]
+			if !ok {
+				return jewelcase.Options{}, fmt.Errorf("unknown preset %q", *preset)
+			}
+			applyPreset(fs, &opts, p)
+		}
+
+		if *seed != 0 {
+			opts.Rand = rand.New(rand.NewSource(*seed))
+		}
+		return opts, nil
+	}
+}
+
+// presetFlags maps the names of flags that presets can set to functions that copy the
+// corresponding options from a preset.
+var presetFlags = map[string]func(dst *jewelcase.Options, preset jewelcase.Options){
+	"colour":              func(dst *jewelcase.Options, p jewelcase.Options) { dst.ColourCorrection = p.ColourCorrection },
+	"corners":             func(dst *jewelcase.Options, p jewelcase.Options) { dst.RoundedCorners = p.RoundedCorners },
+	"edges":               func(dst *jewelcase.Options, p jewelcase.Options) { dst.EdgeSoftening = p.EdgeSoftening },
+	"offset":              func(dst *jewelcase.Options, p jewelcase.Options) { dst.RandomOffset = p.RandomOffset },
+	"rotation":            func(dst *jewelcase.Options, p jewelcase.Options) { dst.RandomRotation = p.RandomRotation },
+	"reflection":          func(dst *jewelcase.Options, p jewelcase.Options) { dst.Reflection = p.Reflection },
+	"corner-radius-min":   func(dst *jewelcase.Options, p jewelcase.Options) { dst.CornerRadiusMin = p.CornerRadiusMin },
+	"corner-radius-max":   func(dst *jewelcase.Options, p jewelcase.Options) { dst.CornerRadiusMax = p.CornerRadiusMax },
+	"max-rotation":        func(dst *jewelcase.Options, p jewelcase.Options) { dst.MaxRotation = p.MaxRotation },
+	"max-offset-x":        func(dst *jewelcase.Options, p jewelcase.Options) { dst.MaxOffsetX = p.MaxOffsetX },
+	"max-offset-y":        func(dst *jewelcase.Options, p jewelcase.Options) { dst.MaxOffsetY = p.MaxOffsetY },
+	"reflection-strength": func(dst *jewelcase.Options, p jewelcase.Options) { dst.ReflectionStrength = p.ReflectionStrength },
+	"tint":                func(dst *jewelcase.Options, p jewelcase.Options) { dst.TintAmount = p.TintAmount },
+	"scratches":           func(dst *jewelcase.Options, p jewelcase.Options) { dst.Scratches = p.Scratches },
+	"scratch-density":     func(dst *jewelcase.Options, p jewelcase.Options) { dst.ScratchDensity = p.ScratchDensity },
+}
+
+// applyPreset copies the preset's options into opts, except for those whose flags were
+// given explicitly.
+func applyPreset(fs *flag.FlagSet, opts *jewelcase.Options, preset jewelcase.Options) {
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	for name, apply := range presetFlags {
+		if !explicit[name] {
+			apply(opts, preset)
+		}
+	}
+}
+
+// parseSize parses a size in the form "WIDTHxHEIGHT", where either dimension may be
+// omitted. An empty string gives a zero size.
+func parseSize(s string) (int, int, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+
+	w, h, ok := strings.Cut(strings.ToLower(s), "x")
+	if !ok || w == "" && h == "" {
+		return 0, 0, fmt.Errorf("expected WIDTHxHEIGHT, got %q", s)
+	}
+
+	var dims [2]int
+	for i, part := range []string{w, h} {
+		if part == "" {
+			continue
+		}
+		v, err := strconv.Atoi(part)
+		if err != nil || v <= 0 {
+			return 0, 0, fmt.Errorf("expected WIDTHxHEIGHT, got %q", s)
+		}
+		if v > jewelcase.MaxOutputSize {
+			return 0, 0, fmt.Errorf("size %q exceeds the limit of %d pixels in each direction", s, jewelcase.MaxOutputSize)
+		}
+		dims[i] = v
+	}
+	return dims[0], dims[1], nil
+}
+
+// splitLines splits a flag value into lines separated by a literal "\n", returning nil
+// if the value is empty.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, `\n`)
+}
+
+// shopStickerText returns the text for a shop sticker given on the command line, which
+// may be the name of one of the usual ones.
+func shopStickerText(s string) string {
+	switch s {
+	case "used":
+		return jewelcase.ShopStickerUsed
+	case "promo":
+		return jewelcase.ShopStickerPromo
+	default:
+		return strings.Join(splitLines(s), "\n")
+	}
+}
+
+// loadImage reads an image file in any supported format.
+func loadImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// loadFrame reads a custom frame image and configures the options to use it, with the
+// art placed in the area described by artRect ("x,y,width,height").
+func loadFrame(opts *jewelcase.Options, path, artRect string) error {
+	parts := strings.Split(artRect, ",")
+	if len(parts) != 4 {
+		return fmt.Errorf("expected art area as x,y,width,height, got %q", artRect)
+	}
+
+	var values [4]int
+	for i, part := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return fmt.Errorf("expected art area as x,y,width,height, got %q", artRect)
+		}
+		values[i] = v
 	}
+
+	img, err := loadImage(path)
+	if err != nil {
+		return err
+	}
+
+	opts.Frame = img
+	opts.FrameArtRect = image.Rect(values[0], values[1], values[0]+values[2], values[1]+values[3])
+	return nil
 }
 
 func printUsage() {
 	fmt.Fprintf(os.Stderr, "Usage: %s [options] --recursive <directory>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "   or: %s [options] --music-dir <directory>\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "   or: %s [options] --inplace <image>\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "   or: %s [options] <input-image> <output-image>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "   or: %s [options] [--format <format>] - -\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "   or: %s [options] <input-audio> <output-audio>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "   or: %s serve [options]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "   or: %s fetch [options] --mbid <release-id> <output-image>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "   or: %s watch [options] <directory>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "   or: %s stack [options] -o <output-image> <input-image>...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "   or: %s montage [options] -o <output-image> <directory>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "   or: %s animate [options] <input-image> <output.gif|output.png>\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "Options:\n")
 	flag.PrintDefaults()
 	os.Exit(1)
 }
 
-func processDirectory(dir string, opts jewelcase.Options, quiet bool) {
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+// processFile processes a single image, or the art embedded in an audio file.
+func processFile(ctx context.Context, input, output string, opts jewelcase.Options) error {
+	if jewelcase.IsAudioFile(input) {
+		return jewelcase.ProcessAudioFileContext(ctx, input, output, opts)
+	}
+	return jewelcase.ProcessFileContext(ctx, input, output, opts)
+}
+
+// finish reports the outcome of processing a single file, as JSON if jsonOut is set, and
+// exits if it failed.
+func finish(result jewelcase.FileResult, jsonOut, dryRun bool) {
+	if jsonOut {
+		printJSONResult(result, dryRun)
+	} else if result.Err != nil {
+		fmt.Fprintf(os.Stderr, "Error applying jewel case: %v\n", result.Err)
+	} else if dryRun {
+		fmt.Printf("Would process: %s\n", result.Path)
+	}
+
+	if result.Err != nil {
+		os.Exit(1)
+	}
+}
+
+// streamFile processes an image read from input and written to output, either of which
+// may be "-" to use stdin or stdout. The output format is given by format, or failing that
+// the output's extension, or the format of the input image.
+func streamFile(ctx context.Context, input, output, format string, opts jewelcase.Options) error {
+	var r io.Reader = os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
 		if err != nil {
 			return err
 		}
+		defer f.Close()
+		r = f
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
 
-		if info.IsDir() {
-			return nil
+	if format == "" && output != "-" {
+		format = filepath.Ext(output)
+	}
+	if format == "" {
+		_, format, err = image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return err
 		}
+	}
 
-		ext := strings.ToLower(filepath.Ext(path))
-		if ext == ".jpg" || ext == ".jpeg" || ext == ".png" {
-			err := jewelcase.ProcessFile(path, path, opts)
+	if output != "-" {
+		return jewelcase.WriteFileAtomic(output, 0o644, func(w io.Writer) error {
+			return jewelcase.ProcessReaderContext(ctx, bytes.NewReader(data), w, format, opts)
+		})
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	if err := jewelcase.ProcessReaderContext(ctx, bytes.NewReader(data), w, format, opts); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// processDirectory processes files with the given walker (ProcessDirectoryContext or
+// ProcessMusicLibraryContext), printing the outcome for each one.
+func processDirectory(ctx context.Context, walk func(context.Context, string, jewelcase.Options, int) error, dir string, opts jewelcase.Options, quiet, jsonOut bool, workers int) {
+	p := newProgress()
+	if jsonOut {
+		// Keep stdout purely JSON for scripts to read
+		opts.OnResult = func(result jewelcase.FileResult) {
+			printJSONResult(result, opts.DryRun)
+		}
+	} else {
+		opts.OnStart = p.begin
+		opts.OnFile = func(path string, err error) {
 			if err != nil {
 				if errors.Is(err, jewelcase.ErrAlreadyProcessed) {
+					p.skipped++
+					if !quiet {
+						p.println(os.Stdout, "Skipped: %s (already processed)", path)
+					}
+				} else if errors.Is(err, jewelcase.ErrNoEmbeddedArt) {
+					p.skipped++
 					if !quiet {
-						fmt.Printf("Skipped: %s (already processed)\n", path)
+						p.println(os.Stdout, "Skipped: %s (no embedded art)", path)
 					}
+				} else if opts.DryRun {
+					p.failed++
+					p.println(os.Stdout, "Rejected: %s (%v)", path, err)
 				} else {
-					fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", path, err)
+					p.failed++
+					p.println(os.Stderr, "Error processing %s: %v", path, err)
 				}
+			} else if opts.DryRun {
+				p.processed++
+				p.println(os.Stdout, "Would process: %s", path)
 			} else {
-				fmt.Printf("Processed: %s\n", path)
+				p.processed++
+				p.println(os.Stdout, "Processed: %s", path)
 			}
 		}
+	}
 
-		return nil
-	})
-
-	if err != nil {
+	var fileErr *jewelcase.FileError
+	err := walk(ctx, dir, opts, workers)
+	if !jsonOut {
+		p.summary(os.Stdout, opts.DryRun)
+	}
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintf(os.Stderr, "Interrupted\n")
+		os.Exit(1)
+	} else if err != nil && !errors.As(err, &fileErr) {
 		fmt.Fprintf(os.Stderr, "Error walking directory: %v\n", err)
 		os.Exit(1)
 	}
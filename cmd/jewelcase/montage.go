@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/csmith/jewelcase"
+)
+
+// montage lays out all the images in a directory as a single poster, configured by the
+// given command line arguments.
+func montage(args []string) {
+	fs := flag.NewFlagSet("montage", flag.ExitOnError)
+	buildOptions := optionFlags(fs)
+	output := fs.String("o", "", "Path to save the montage to")
+	layout := fs.String("layout", "grid", "How to lay out the covers: grid or shelf")
+	columns := fs.Int("columns", 0, "Number of covers in each row (0 to make the montage roughly square)")
+	spacing := fs.Int("spacing", 40, "Gap between the covers in pixels")
+	background := fs.String("montage-background", "", "Colour to fill behind the covers (transparent if not set)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s montage [options] -o <output-image> <directory>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *output == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	opts, err := buildOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	montageOpts := jewelcase.MontageOptions{
+		Layout:  jewelcase.MontageLayout(*layout),
+		Columns: *columns,
+		Spacing: *spacing,
+	}
+	if *background != "" {
+		if montageOpts.Background, err = jewelcase.ParseColour(*background); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid montage background: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	entries, err := os.ReadDir(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && jewelcase.IsImageFile(entry.Name()) {
+			paths = append(paths, filepath.Join(fs.Arg(0), entry.Name()))
+		}
+	}
+	if len(paths) == 0 {
+		fmt.Fprintf(os.Stderr, "No images found in %s\n", fs.Arg(0))
+		os.Exit(1)
+	}
+
+	covers, err := loadCovers(paths, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	img, err := jewelcase.Montage(covers, montageOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating montage: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := saveImage(*output, img, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving %s: %v\n", *output, err)
+		os.Exit(1)
+	}
+}
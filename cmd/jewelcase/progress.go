@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// progressWidth is the number of characters in the progress bar itself.
+const progressWidth = 30
+
+// progress tracks the outcome of each file in a batch run, drawing a progress bar on a
+// terminal and printing a summary at the end. Its methods must not be called concurrently.
+type progress struct {
+	out     io.Writer
+	enabled bool
+	start   time.Time
+
+	total     int
+	processed int
+	skipped   int
+	failed    int
+}
+
+// newProgress returns a progress tracker that draws to stderr if it is a terminal.
+func newProgress() *progress {
+	info, err := os.Stderr.Stat()
+	return &progress{
+		out:     os.Stderr,
+		enabled: err == nil && info.Mode()&os.ModeCharDevice != 0,
+		start:   time.Now(),
+	}
+}
+
+func (p *progress) done() int {
+	return p.processed + p.skipped + p.failed
+}
+
+// begin records the number of files to process and draws the empty progress bar.
+func (p *progress) begin(total int) {
+	p.total = total
+	p.start = time.Now()
+	p.draw()
+}
+
+// println prints a line of output, moving the progress bar out of the way.
+func (p *progress) println(w io.Writer, format string, args ...any) {
+	p.clear()
+	fmt.Fprintf(w, format+"\n", args...)
+	p.draw()
+}
+
+// clear removes the progress bar from the terminal.
+func (p *progress) clear() {
+	if p.enabled && p.total > 0 {
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+}
+
+// draw redraws the progress bar, with an estimate of the time remaining once any files
+// have been finished.
+func (p *progress) draw() {
+	if !p.enabled || p.total == 0 {
+		return
+	}
+
+	done := p.done()
+	filled := progressWidth * done / p.total
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressWidth-filled)
+
+	eta := ""
+	if done > 0 && done < p.total {
+		remaining := time.Since(p.start) / time.Duration(done) * time.Duration(p.total-done)
+		eta = fmt.Sprintf("  ETA %s", remaining.Round(time.Second))
+	}
+	fmt.Fprintf(p.out, "\r[%s] %d/%d%s\033[K", bar, done, p.total, eta)
+}
+
+// summary clears the progress bar and prints the totals for the run.
+func (p *progress) summary(w io.Writer, dryRun bool) {
+	p.clear()
+	if dryRun {
+		fmt.Fprintf(w, "Would process %d, skip %d, reject %d\n", p.processed, p.skipped, p.failed)
+		return
+	}
+	fmt.Fprintf(w, "Processed %d, skipped %d, failed %d in %s\n", p.processed, p.skipped, p.failed, time.Since(p.start).Round(time.Millisecond))
+}
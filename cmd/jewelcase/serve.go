@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/csmith/jewelcase"
+	"github.com/csmith/jewelcase/grpcserver"
+	"google.golang.org/grpc"
+)
+
+// maxRequestSize is the largest image the server will accept, in bytes.
+const maxRequestSize = 32 << 20
+
+// serve runs the HTTP server, configured by the given command line arguments.
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "Address to listen on")
+	grpcAddr := fs.String("grpc-addr", "", "Address to serve the gRPC API on (disabled if empty)")
+	maxInputPixels := fs.Int("max-input-pixels", 100_000_000, "Largest image to accept, in pixels (negative for no limit)")
+	fs.Parse(args)
+
+	if *grpcAddr != "" {
+		listener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error running gRPC server: %v\n", err)
+			os.Exit(1)
+		}
+
+		grpcServer := grpc.NewServer(grpc.MaxRecvMsgSize(maxRequestSize))
+		grpcserver.RegisterJewelcaseServer(grpcServer, &grpcserver.Server{MaxInputPixels: *maxInputPixels})
+
+		log.Printf("Serving gRPC on %s", *grpcAddr)
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				fmt.Fprintf(os.Stderr, "Error running gRPC server: %v\n", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /process", func(w http.ResponseWriter, r *http.Request) {
+		handleProcess(w, r, *maxInputPixels)
+	})
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("Listening on %s", *addr)
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error running server: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// handleProcess applies the jewel case effect to the image in the request body. Query
+// parameters are named after the command line flags and set the corresponding options,
+// apart from "format" which selects the output format (default "png"). The config file
+// and the limit on the size of images, maxInputPixels, can't be changed by requests, and
+// values longer than jewelcase.MaxTextLength are rejected.
+func handleProcess(w http.ResponseWriter, r *http.Request, maxInputPixels int) {
+	fs := flag.NewFlagSet("process", flag.ContinueOnError)
+	buildOptions := optionFlags(fs)
+
+	format := "png"
+	for name, values := range r.URL.Query() {
+		value := values[len(values)-1]
+		if name == "format" {
+			format = value
+			continue
+		}
+		if name == "config" || name == "max-input-pixels" {
+			// Clients mustn't be able to make the server read arbitrary files, or lift
+			// the limit that stops them from exhausting its memory
+			http.Error(w, "invalid parameter "+name, http.StatusBadRequest)
+			return
+		}
+		if len(value) > jewelcase.MaxTextLength {
+			// Laying out very long text takes a lot of time and memory; the numeric
+			// options that drive the cost of a request are limited by the library
+			http.Error(w, fmt.Sprintf("invalid parameter %s: longer than %d bytes", name, jewelcase.MaxTextLength), http.StatusBadRequest)
+			return
+		}
+
+		if err := fs.Set(name, value); err != nil {
+			http.Error(w, fmt.Sprintf("invalid parameter %s: %v", name, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	opts, err := buildOptions()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.MaxInputPixels = maxInputPixels
+
+	var output bytes.Buffer
+	err = jewelcase.ProcessReaderContext(r.Context(), http.MaxBytesReader(w, r.Body, maxRequestSize), &output, format, opts)
+
+	var unsupported *jewelcase.UnsupportedFormatError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil:
+	case r.Context().Err() != nil:
+		// The client has gone away, so there's nobody to respond to
+		return
+	case errors.As(err, &tooLarge), errors.Is(err, jewelcase.ErrImageTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, jewelcase.ErrAlreadyProcessed):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.As(err, &unsupported), errors.Is(err, image.ErrFormat), errors.Is(err, io.ErrUnexpectedEOF):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		log.Printf("Error processing image: %v", err)
+		http.Error(w, "error processing image", http.StatusInternalServerError)
+		return
+	}
+
+	if contentType := mime.TypeByExtension("." + format); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	output.WriteTo(w)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/csmith/jewelcase"
+)
+
+// stack combines several covers into a pile or fan of cases, configured by the given
+// command line arguments.
+func stack(args []string) {
+	fs := flag.NewFlagSet("stack", flag.ExitOnError)
+	buildOptions := optionFlags(fs)
+	output := fs.String("o", "", "Path to save the combined image to")
+	layout := fs.String("layout", "pile", "How to arrange the covers: pile or fan")
+	background := fs.String("stack-background", "", "Colour to fill behind the covers (transparent if not set)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s stack [options] -o <output-image> <input-image>...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 || *output == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	opts, err := buildOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	stackOpts := jewelcase.StackOptions{
+		Layout: jewelcase.StackLayout(*layout),
+		Rand:   opts.Rand,
+	}
+	if stackOpts.Rand == nil {
+		stackOpts.Rand = rand.New(rand.NewSource(rand.Int63()))
+	}
+	if *background != "" {
+		if stackOpts.Background, err = jewelcase.ParseColour(*background); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid stack background: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	covers, err := loadCovers(fs.Args(), opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	img, err := jewelcase.Stack(covers, stackOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error stacking covers: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := saveImage(*output, img, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving %s: %v\n", *output, err)
+		os.Exit(1)
+	}
+}
+
+// loadCovers reads each of the images and puts them in a case. Images that have already
+// been processed are used as they are.
+func loadCovers(paths []string, opts jewelcase.Options) ([]image.Image, error) {
+	covers := make([]image.Image, len(paths))
+	for i, path := range paths {
+		img, err := loadImage(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", path, err)
+		}
+
+		covers[i], err = jewelcase.Process(img, opts)
+		if errors.Is(err, jewelcase.ErrAlreadyProcessed) {
+			covers[i] = img
+		} else if err != nil {
+			return nil, fmt.Errorf("error processing %s: %w", path, err)
+		}
+	}
+	return covers, nil
+}
+
+// saveImage writes the image to path, in the format given by its extension.
+func saveImage(path string, img image.Image, opts jewelcase.Options) error {
+	return jewelcase.WriteFileAtomic(path, 0o644, func(w io.Writer) error {
+		return jewelcase.Encode(w, img, filepath.Ext(path), opts)
+	})
+}
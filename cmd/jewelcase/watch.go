@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/csmith/jewelcase"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watch processes images in place as they're added to or changed in a directory,
+// configured by the given command line arguments.
+func watch(args []string) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	buildOptions := optionFlags(flags)
+	delay := flags.Duration("delay", time.Second, "How long a file must go unchanged before it is processed")
+	audio := flags.Bool("audio", false, "Also process art embedded in audio files")
+	backup := flags.String("backup", "", "Copy originals to a file with this suffix (e.g. .orig) before modifying them")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s watch [options] <directory>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	// Writing a processed file triggers another event for it, so forcing it to be
+	// processed again would never stop. This is checked before the config file is
+	// applied, as a force setting there is ignored instead.
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "force" {
+			fmt.Fprintf(os.Stderr, "--force can't be used with watch, as each file would be processed again every time it was written\n")
+			os.Exit(1)
+		}
+	})
+
+	opts, err := buildOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	opts.BackupSuffix = *backup
+	opts.Force = false
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting watcher: %v\n", err)
+		os.Exit(1)
+	}
+	defer fsw.Close()
+
+	w := &watcher{
+		fsw:    fsw,
+		delay:  *delay,
+		audio:  *audio,
+		timers: map[string]*time.Timer{},
+		ready:  make(chan string),
+	}
+	if err := w.addDir(ctx, flags.Arg(0), false); err != nil {
+		fmt.Fprintf(os.Stderr, "Error watching directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Watching %s\n", flags.Arg(0))
+	w.run(ctx, opts)
+}
+
+// watcher waits for files in a directory tree to be written, and hands them over for
+// processing once they've stopped changing. Its methods must not be called concurrently.
+type watcher struct {
+	fsw   *fsnotify.Watcher
+	delay time.Duration
+	audio bool
+
+	// timers holds the pending timer for each file that has changed recently
+	timers map[string]*time.Timer
+
+	// ready receives the path of each file once its timer has fired
+	ready chan string
+}
+
+// addDir watches dir and all the directories within it. If schedule is set, files already
+// in the directories are scheduled for processing, as they won't get events of their own.
+func (w *watcher) addDir(ctx context.Context, dir string, schedule bool) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return w.fsw.Add(path)
+		}
+		if schedule {
+			w.schedule(ctx, path)
+		}
+		return nil
+	})
+}
+
+// schedule processes the file once it has gone unchanged for the watcher's delay, so
+// that images are only read once whatever is writing them has finished.
+func (w *watcher) schedule(ctx context.Context, path string) {
+	if !jewelcase.CanProcessInPlace(path) && !(w.audio && jewelcase.IsAudioFile(path)) {
+		return
+	}
+
+	if timer, ok := w.timers[path]; ok {
+		timer.Reset(w.delay)
+		return
+	}
+	w.timers[path] = time.AfterFunc(w.delay, func() {
+		select {
+		case w.ready <- path:
+		case <-ctx.Done():
+		}
+	})
+}
+
+// run handles events until ctx is cancelled, processing each file in place once it's
+// ready. Writing the output triggers another event for the file, but that is then found
+// to be already processed and quietly ignored, as are any other images that have been
+// processed before.
+func (w *watcher) run(ctx context.Context, opts jewelcase.Options) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+				continue
+			}
+
+			if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+				if err := w.addDir(ctx, event.Name, true); err != nil {
+					fmt.Fprintf(os.Stderr, "Error watching %s: %v\n", event.Name, err)
+				}
+				continue
+			}
+			w.schedule(ctx, event.Name)
+
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "Error watching directory: %v\n", err)
+
+		case path := <-w.ready:
+			delete(w.timers, path)
+
+			err := processFile(ctx, path, path, opts)
+			if errors.Is(err, jewelcase.ErrAlreadyProcessed) || errors.Is(err, jewelcase.ErrNoEmbeddedArt) || errors.Is(err, fs.ErrNotExist) {
+				continue
+			} else if errors.Is(err, context.Canceled) {
+				return
+			} else if err != nil {
+				fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", path, err)
+			} else {
+				fmt.Printf("Processed: %s\n", path)
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package jewelcase
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"io"
+	"strings"
+)
+
+// adobeMarker is an APP14 segment declaring that a JPEG's components are stored without
+// any colour transform, i.e. as RGB or CMYK.
+var adobeMarker = []byte("\xff\xee\x00\x0eAdobe\x00\x64\x00\x00\x00\x00\x00")
+
+// decodeJPEG reads a JPEG image. CMYK and YCCK images are converted to RGB, as most of
+// the drawing code would otherwise convert them a pixel at a time.
+//
+// The standard library only reads four-component JPEGs that have Adobe's APP14 segment,
+// and assumes their values are inverted as Photoshop writes them. Images without the
+// segment are read as plain CMYK instead, as other decoders do.
+func decodeJPEG(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	inverted := true
+	var unsupported jpeg.UnsupportedError
+	if errors.As(err, &unsupported) && strings.Contains(string(unsupported), "APP14") && len(data) > 2 {
+		img, err = jpeg.Decode(bytes.NewReader(addAdobeMarker(data)))
+		inverted = false
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cmyk, ok := img.(*image.CMYK); ok {
+		return cmykToRGBA(cmyk, inverted), nil
+	}
+	return img, nil
+}
+
+// addAdobeMarker inserts adobeMarker immediately after the start of image marker.
+func addAdobeMarker(data []byte) []byte {
+	out := make([]byte, 0, len(data)+len(adobeMarker))
+	out = append(out, data[:2]...)
+	out = append(out, adobeMarker...)
+	return append(out, data[2:]...)
+}
+
+// cmykToRGBA converts a CMYK image to RGB. If inverted is false, the image was decoded as
+// though it had Photoshop's inverted values, so the ink values are flipped back first.
+func cmykToRGBA(src *image.CMYK, inverted bool) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		s := src.Pix[src.PixOffset(bounds.Min.X, y):]
+		d := dst.Pix[dst.PixOffset(bounds.Min.X, y):]
+		for x := 0; x < bounds.Dx(); x++ {
+			c, m, ye, k := s[4*x], s[4*x+1], s[4*x+2], s[4*x+3]
+			if !inverted {
+				c, m, ye, k = 255-c, 255-m, 255-ye, 255-k
+			}
+			w := 255 - uint32(k)
+			d[4*x] = uint8(((255-uint32(c))*w + 127) / 255)
+			d[4*x+1] = uint8(((255-uint32(m))*w + 127) / 255)
+			d[4*x+2] = uint8(((255-uint32(ye))*w + 127) / 255)
+			d[4*x+3] = 0xff
+		}
+	}
+	return dst
+}
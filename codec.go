@@ -0,0 +1,389 @@
+package jewelcase
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/gen2brain/heic"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+// UnsupportedFormatError is returned when asked to decode or encode an image in a format
+// that isn't supported.
+type UnsupportedFormatError struct {
+	// Ext is the file extension or format name that was requested
+	Ext string
+
+	// Op is the operation that was attempted: either "decode" or "encode"
+	Op string
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	if e.Op == "encode" {
+		if normaliseFormat(e.Ext) == "avif" {
+			return fmt.Sprintf("unsupported output format: %s (AVIF output needs building with the avif tag)", e.Ext)
+		}
+		return fmt.Sprintf("unsupported output format: %s", e.Ext)
+	}
+	if normaliseFormat(e.Ext) == "avif" {
+		return fmt.Sprintf("unsupported image format: %s (AVIF input needs building with the avif tag)", e.Ext)
+	}
+	return fmt.Sprintf("unsupported image format: %s", e.Ext)
+}
+
+// ErrImageTooLarge is returned when an image has more pixels than Options.MaxInputPixels
+// allows, or Options.OutputWidth or OutputHeight are more than MaxOutputSize. The error
+// includes the dimensions.
+var ErrImageTooLarge = errors.New("image is too large")
+
+// ProcessReader applies the jewel case effect to an image read from r, and writes the
+// result to w in the given output format. The input may be in any supported format; JPEG
+// images are rotated according to their EXIF orientation.
+func ProcessReader(r io.Reader, w io.Writer, format string, opts Options) error {
+	return ProcessReaderContext(context.Background(), r, w, format, opts)
+}
+
+// ProcessReaderContext behaves like ProcessReader, but stops and returns the context's
+// error if ctx is cancelled before the image has been processed.
+func ProcessReaderContext(ctx context.Context, r io.Reader, w io.Writer, format string, opts Options) error {
+	if _, ok := encoderFor(format); !ok {
+		return &UnsupportedFormatError{Ext: format, Op: "encode"}
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	img, err := decodeData(data, "", opts.maxInputPixels())
+	if err != nil {
+		return err
+	}
+
+	img = applyOrientation(img, jpegOrientation(data))
+	buf := &buffers{pool: &encodePool}
+	defer buf.release()
+	result, _, err := process(ctx, buf, img, opts, hasFileMarker(data))
+	if err != nil {
+		return err
+	}
+
+	return encodeMarked(w, result, format, opts, readMetadata(data))
+}
+
+type encoder func(w io.Writer, img image.Image, opts Options) error
+
+// optionalEncoders holds output formats that are only available when built with the
+// relevant build tags, keyed by canonical format name.
+var optionalEncoders = map[string]encoder{}
+
+// decoder reads images in a single format.
+type decoder struct {
+	// decode reads the whole image
+	decode func(r io.Reader) (image.Image, error)
+
+	// config reads only the dimensions and colour model from the image's header
+	config func(r io.Reader) (image.Config, error)
+}
+
+// optionalDecoders holds input formats that are only available when built with the
+// relevant build tags, keyed by canonical format name.
+var optionalDecoders = map[string]decoder{}
+
+// Decode reads an image in the given format ("jpeg", "png", "webp", "tiff", "bmp", "gif",
+// "heic", or any optional formats enabled with build tags) from r. Only the first frame
+// of an animated GIF is read.
+func Decode(r io.Reader, format string) (image.Image, error) {
+	switch f := normaliseFormat(format); f {
+	case "jpeg":
+		return decodeJPEG(r)
+	case "png":
+		return png.Decode(r)
+	case "webp":
+		return webp.Decode(r)
+	case "tiff":
+		return tiff.Decode(r)
+	case "bmp":
+		return bmp.Decode(r)
+	case "gif":
+		return gif.Decode(r)
+	case "heic":
+		return heic.Decode(r)
+	default:
+		if dec, ok := optionalDecoders[f]; ok {
+			return dec.decode(r)
+		}
+		return nil, &UnsupportedFormatError{Ext: format, Op: "decode"}
+	}
+}
+
+// decodeConfig reads the dimensions and colour model of an image in the given format from
+// its header, without decoding the pixel data.
+func decodeConfig(r io.Reader, format string) (image.Config, error) {
+	switch f := normaliseFormat(format); f {
+	case "jpeg":
+		return jpeg.DecodeConfig(r)
+	case "png":
+		return png.DecodeConfig(r)
+	case "webp":
+		return webp.DecodeConfig(r)
+	case "tiff":
+		return tiff.DecodeConfig(r)
+	case "bmp":
+		return bmp.DecodeConfig(r)
+	case "gif":
+		return gif.DecodeConfig(r)
+	case "heic":
+		return heic.DecodeConfig(r)
+	default:
+		if dec, ok := optionalDecoders[f]; ok {
+			return dec.config(r)
+		}
+		return image.Config{}, &UnsupportedFormatError{Ext: format, Op: "decode"}
+	}
+}
+
+// decodeData decodes an image in any supported format, identifying the format from the
+// data itself. If it isn't recognised, the fallback format (typically taken from the
+// file extension) is tried instead.
+//
+// If maxPixels is positive, images with more pixels than that are rejected with
+// ErrImageTooLarge before any pixel data is decoded.
+func decodeData(data []byte, fallback string, maxPixels int) (image.Image, error) {
+	format := sniffFormat(data)
+	if format == "" {
+		format = fallback
+	}
+	if format == "" {
+		return nil, image.ErrFormat
+	}
+	if err := checkPixels(data, format, maxPixels); err != nil {
+		return nil, err
+	}
+	return Decode(bytes.NewReader(data), format)
+}
+
+// checkPixels returns ErrImageTooLarge if the dimensions in the header of an image encoded
+// in the given format exceed maxPixels. If the header can't be read, its error is returned
+// instead, so that an image is never decoded without its size having been checked.
+func checkPixels(data []byte, format string, maxPixels int) error {
+	if maxPixels <= 0 {
+		return nil
+	}
+	config, err := decodeConfig(bytes.NewReader(data), format)
+	if err != nil {
+		return err
+	}
+	if int64(config.Width)*int64(config.Height) > int64(maxPixels) {
+		return fmt.Errorf("%w: %dx%d exceeds the limit of %d pixels", ErrImageTooLarge, config.Width, config.Height, maxPixels)
+	}
+	return nil
+}
+
+// sniffFormat identifies the format of an encoded image from its magic bytes, returning
+// the canonical format name, or "" if it isn't recognised. Formats are recognised even if
+// they can't be decoded, so that the error says what the image actually is.
+func sniffFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return "jpeg"
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return "gif"
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "webp"
+	case bytes.HasPrefix(data, []byte("II*\x00")), bytes.HasPrefix(data, []byte("MM\x00*")):
+		return "tiff"
+	case bytes.HasPrefix(data, []byte("BM")):
+		return "bmp"
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		return sniffISOBMFF(data)
+	default:
+		return ""
+	}
+}
+
+// sniffISOBMFF identifies HEIC and AVIF images from the major and compatible brands in
+// their leading ftyp box.
+func sniffISOBMFF(data []byte) string {
+	size := min(int(binary.BigEndian.Uint32(data)), len(data))
+	for i := 8; i+4 <= size; i += 4 {
+		if i == 12 {
+			// Skip the minor version
+			continue
+		}
+		switch string(data[i : i+4]) {
+		case "heic", "heix", "hevc", "hevx":
+			return "heic"
+		case "avif", "avis":
+			return "avif"
+		}
+	}
+	return ""
+}
+
+// canDecode reports whether Decode supports the given format.
+func canDecode(format string) bool {
+	switch f := normaliseFormat(format); f {
+	case "jpeg", "png", "webp", "tiff", "bmp", "gif", "heic":
+		return true
+	default:
+		_, ok := optionalDecoders[f]
+		return ok
+	}
+}
+
+// IsImageFile reports whether the path has the extension of an image format that
+// ProcessFile can read.
+func IsImageFile(path string) bool {
+	return canDecode(filepath.Ext(path))
+}
+
+// CanProcessInPlace reports whether the path has the extension of an image format that
+// ProcessFile can both read and write, so the image can be replaced with its processed
+// version. GIFs, for example, can be read but not written.
+func CanProcessInPlace(path string) bool {
+	ext := filepath.Ext(path)
+	_, ok := encoderFor(ext)
+	return ok && canDecode(ext)
+}
+
+// Encode writes img to w in the given format ("jpeg", "png", "webp", "tiff", "bmp", or any optional
+// formats enabled with build tags). Format-specific settings such as quality are taken from opts.
+func Encode(w io.Writer, img image.Image, format string, opts Options) error {
+	enc, ok := encoderFor(format)
+	if !ok {
+		return &UnsupportedFormatError{Ext: format, Op: "encode"}
+	}
+	return enc(w, img, opts)
+}
+
+func encoderFor(format string) (encoder, bool) {
+	switch f := normaliseFormat(format); f {
+	case "jpeg":
+		return encodeJPEG, true
+	case "png":
+		return encodePNG, true
+	case "webp":
+		return encodeWebP, true
+	case "tiff":
+		return encodeTIFF, true
+	case "bmp":
+		return encodeBMP, true
+	default:
+		enc, ok := optionalEncoders[f]
+		return enc, ok
+	}
+}
+
+func encodeJPEG(w io.Writer, img image.Image, opts Options) error {
+	quality := opts.JPEGQuality
+	if quality <= 0 {
+		quality = 95
+	}
+	if opts.JPEGProgressive {
+		return encodeProgressiveJPEG(w, img, quality)
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
+
+// ParseCompression parses the name of a PNG compression level for Options.PNGCompression:
+// "default", "none", "fast" or "best".
+func ParseCompression(s string) (png.CompressionLevel, error) {
+	switch s {
+	case "default":
+		return png.DefaultCompression, nil
+	case "none":
+		return png.NoCompression, nil
+	case "fast":
+		return png.BestSpeed, nil
+	case "best":
+		return png.BestCompression, nil
+	default:
+		return 0, fmt.Errorf("invalid PNG compression level %q", s)
+	}
+}
+
+func encodePNG(w io.Writer, img image.Image, opts Options) error {
+	enc := png.Encoder{CompressionLevel: opts.PNGCompression}
+	return enc.Encode(w, img)
+}
+
+// encodeWebP writes a lossless WebP image.
+func encodeWebP(w io.Writer, img image.Image, _ Options) error {
+	return nativewebp.Encode(w, img, nil)
+}
+
+// encodeTIFF writes a losslessly compressed TIFF image, so that scans processed in place
+// stay as TIFFs.
+func encodeTIFF(w io.Writer, img image.Image, _ Options) error {
+	return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate, Predictor: true})
+}
+
+// encodeBMP writes a BMP image. Images with transparency are written with a
+// BITMAPV4HEADER, as most readers (including our own) ignore the alpha channel of
+// images that only have the basic header, which would lose the processed marker.
+func encodeBMP(w io.Writer, img image.Image, _ Options) error {
+	var buf bytes.Buffer
+	if err := bmp.Encode(&buf, img); err != nil {
+		return err
+	}
+
+	const (
+		fileHeaderLen   = 14
+		infoHeaderLen   = 40
+		v4InfoHeaderLen = 108
+	)
+	data := buf.Bytes()
+	if binary.LittleEndian.Uint16(data[28:30]) != 32 {
+		_, err := w.Write(data)
+		return err
+	}
+
+	header := make([]byte, fileHeaderLen+v4InfoHeaderLen)
+	copy(header, data[:fileHeaderLen+infoHeaderLen])
+	binary.LittleEndian.PutUint32(header[2:6], uint32(len(data)+v4InfoHeaderLen-infoHeaderLen))
+	binary.LittleEndian.PutUint32(header[10:14], fileHeaderLen+v4InfoHeaderLen)
+	binary.LittleEndian.PutUint32(header[14:18], v4InfoHeaderLen)
+	binary.LittleEndian.PutUint32(header[30:34], 3) // BI_BITFIELDS
+	binary.LittleEndian.PutUint32(header[54:58], 0x00ff0000)
+	binary.LittleEndian.PutUint32(header[58:62], 0x0000ff00)
+	binary.LittleEndian.PutUint32(header[62:66], 0x000000ff)
+	binary.LittleEndian.PutUint32(header[66:70], 0xff000000)
+	copy(header[70:74], "BGRs") // LCS_sRGB
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data[fileHeaderLen+infoHeaderLen:])
+	return err
+}
+
+// normaliseFormat maps a format name or file extension (with or without the
+// leading dot) onto the canonical format name used by Decode and Encode.
+func normaliseFormat(format string) string {
+	switch f := strings.ToLower(strings.TrimPrefix(format, ".")); f {
+	case "jpg", "jpeg":
+		return "jpeg"
+	case "tif", "tiff":
+		return "tiff"
+	case "heic", "heif":
+		return "heic"
+	default:
+		return f
+	}
+}
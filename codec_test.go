@@ -0,0 +1,72 @@
+package jewelcase
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+)
+
+func TestCheckPixels(t *testing.T) {
+	// A format whose header image.DecodeConfig doesn't recognise, like HEIC images with
+	// less common brands
+	optionalDecoders["huge"] = decoder{
+		decode: func(io.Reader) (image.Image, error) { return nil, errors.New("not implemented") },
+		config: func(io.Reader) (image.Config, error) { return image.Config{Width: 20000, Height: 20000}, nil },
+	}
+	t.Cleanup(func() { delete(optionalDecoders, "huge") })
+
+	var small bytes.Buffer
+	if err := png.Encode(&small, testArt()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		format  string
+		wantErr bool
+	}{
+		{"small image", small.Bytes(), "png", false},
+		{"large image", []byte("anything"), "huge", true},
+		{"unreadable header", []byte("\x89PNG\r\n\x1a\ngarbage"), "png", true},
+		{"unsupported format", []byte("anything"), "tga", true},
+	}
+	for _, tt := range tests {
+		err := checkPixels(tt.data, tt.format, 100_000_000)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if err := checkPixels([]byte("anything"), "huge", 100_000_000); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("got %v for a large image, want ErrImageTooLarge", err)
+	}
+}
+
+func TestHEICIsSupported(t *testing.T) {
+	for _, path := range []string{"IMG_0001.HEIC", "photo.heif"} {
+		if !IsImageFile(path) {
+			t.Errorf("%s isn't recognised as an image", path)
+		}
+	}
+}
+
+func TestParseCompression(t *testing.T) {
+	tests := map[string]png.CompressionLevel{
+		"default": png.DefaultCompression,
+		"none":    png.NoCompression,
+		"fast":    png.BestSpeed,
+		"best":    png.BestCompression,
+	}
+	for s, want := range tests {
+		if got, err := ParseCompression(s); err != nil || got != want {
+			t.Errorf("ParseCompression(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	if _, err := ParseCompression("smallest"); err == nil {
+		t.Error("ParseCompression accepted an unknown level")
+	}
+}
@@ -0,0 +1,127 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+	"math/rand"
+)
+
+// crackMaps holds the light and dark lines that make up the cracks on an image. The
+// broken edge of the plastic catches the light, with a faint shadow along one side.
+type crackMaps struct {
+	width, height int
+	light, dark   []float32
+
+	// scale is how much larger than a case at its usual size the image is
+	scale float64
+}
+
+// applyCracks draws a few jagged cracks in the plastic, each spreading from a point on
+// the edge of the image with smaller cracks branching off it. It returns the number of
+// cracks that were drawn along with the result.
+func applyCracks(img *image.RGBA, rng *rand.Rand, scale float64) (*image.RGBA, int) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	maps := &crackMaps{
+		width:  width,
+		height: height,
+		light:  make([]float32, width*height),
+		dark:   make([]float32, width*height),
+		scale:  scale,
+	}
+
+	count := 1 + rng.Intn(2)
+	for range count {
+		// Cases usually crack from a knock to one of the edges, so start from a random
+		// point on one of them and head inwards
+		var x, y, angle float64
+		switch rng.Intn(4) {
+		case 0:
+			x, y, angle = rng.Float64()*float64(width), 0, math.Pi/2
+		case 1:
+			x, y, angle = float64(width), rng.Float64()*float64(height), math.Pi
+		case 2:
+			x, y, angle = rng.Float64()*float64(width), float64(height), -math.Pi/2
+		default:
+			x, y, angle = 0, rng.Float64()*float64(height), 0
+		}
+		angle += (rng.Float64() - 0.5) * math.Pi / 2
+
+		length := (0.2 + rng.Float64()*0.35) * float64(min(width, height))
+		maps.crack(rng, x, y, angle, length, 0.5+rng.Float64()*0.2, 2)
+	}
+
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			pix := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+			light := maps.light[(y-bounds.Min.Y)*width : (y-bounds.Min.Y+1)*width]
+			dark := maps.dark[(y-bounds.Min.Y)*width : (y-bounds.Min.Y+1)*width]
+
+			for i, x := 0, 0; i < len(pix); i, x = i+4, x+1 {
+				a := pix[i+3]
+				l, d := float64(light[x]), float64(dark[x])
+				for c := range 3 {
+					v := pix[i+c] + uint8(float64(a-pix[i+c])*l)
+					pix[i+c] = v - uint8(float64(v)*d)
+				}
+			}
+		}
+	})
+	return img, count
+}
+
+// crack draws a single jagged crack starting at (x, y) and heading in the given direction
+// (in radians) for about length pixels, fading out towards its tip. Branches are spawned
+// along the way, up to the given depth.
+func (m *crackMaps) crack(rng *rand.Rand, x, y, angle, length, strength float64, depth int) {
+	travelled := 0.0
+	for travelled < length {
+		// Plastic cracks in fairly straight runs, with the occasional sharp change of direction
+		step := math.Min((6+rng.Float64()*8)*m.scale, length-travelled)
+		angle += (rng.Float64() - 0.5) * 0.25
+		if rng.Float64() < 0.12 {
+			angle += (rng.Float64() - 0.5) * 1.2
+		}
+		nx, ny := x+math.Cos(angle)*step, y+math.Sin(angle)*step
+
+		// Cracks get fainter and thinner towards the tip
+		t := travelled / length
+		v := strength * (1 - t*t)
+		m.line(x, y, nx, ny, v)
+
+		if depth > 0 && rng.Float64() < 0.06 {
+			side := 1.0
+			if rng.Float64() < 0.5 {
+				side = -1
+			}
+			branch := angle + side*(0.4+rng.Float64()*0.6)
+			m.crack(rng, nx, ny, branch, (length-travelled)*(0.3+rng.Float64()*0.4), v*0.8, depth-1)
+		}
+
+		x, y = nx, ny
+		travelled += step
+	}
+}
+
+// line adds a straight section of crack from (x0, y0) to (x1, y1) to the maps.
+func (m *crackMaps) line(x0, y0, x1, y1, v float64) {
+	length := math.Hypot(x1-x0, y1-y0)
+	if length == 0 {
+		return
+	}
+
+	// The shadow sits a pixel to one side of the bright edge, and both are widened to
+	// match the scale
+	sx, sy := -(y1-y0)/length, (x1-x0)/length
+
+	steps := int(math.Ceil(length * 2))
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		px, py := x0+(x1-x0)*t, y0+(y1-y0)*t
+		for w := range int(m.scale) {
+			offset := float64(w) - (m.scale-1)/2
+			splatScratch(m.light, m.width, m.height, px+sx*offset, py+sy*offset, float32(v))
+			splatScratch(m.dark, m.width, m.height, px+sx*(offset+m.scale), py+sy*(offset+m.scale), float32(v*0.5))
+		}
+	}
+}
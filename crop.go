@@ -0,0 +1,176 @@
+package jewelcase
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// CropMode is how art that doesn't match the shape of the frame is fitted into it.
+type CropMode string
+
+const (
+	// CropCenter scales the art to cover the frame and keeps the middle of it. This is
+	// the default.
+	CropCenter CropMode = "center"
+
+	// CropTop scales the art to cover the frame and keeps the top of tall art, where
+	// titles usually are. Wide art is cropped to its middle.
+	CropTop CropMode = "top"
+
+	// CropEntropy scales the art to cover the frame and keeps the busiest part of it,
+	// trimming away whichever end has the least detail.
+	CropEntropy CropMode = "entropy"
+
+	// CropLetterbox scales the art to fit entirely within the frame, filling the rest
+	// with Options.MatteColour.
+	CropLetterbox CropMode = "letterbox"
+
+	// CropStretch scales the art to exactly fill the frame, stretching it if its shape
+	// doesn't match, as cheap video sleeves often did.
+	CropStretch CropMode = "stretch"
+)
+
+// cropStrip is the number of lines CropEntropy trims at a time.
+const cropStrip = 8
+
+// scaleAndCrop scales the art to the given size with the resampler, using the crop mode
+// to deal with any difference in aspect ratio.
+func scaleAndCrop(buf *buffers, albumArt image.Image, size image.Point, mode CropMode, matte color.Color, rs resampler) (*image.RGBA, error) {
+	bounds := albumArt.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+	targetWidth, targetHeight := size.X, size.Y
+
+	if mode == CropStretch {
+		output := buf.newRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+		rs.scale(buf, output, albumArt, draw.Over)
+		return output, nil
+	}
+
+	if mode == CropLetterbox {
+		scale := min(float64(targetWidth)/float64(width), float64(targetHeight)/float64(height))
+		scaledWidth := max(int(math.Round(float64(width)*scale)), 1)
+		scaledHeight := max(int(math.Round(float64(height)*scale)), 1)
+
+		output := buf.newRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+		draw.Draw(output, output.Bounds(), image.NewUniform(matte), image.Point{}, draw.Src)
+
+		x := (targetWidth - scaledWidth) / 2
+		y := (targetHeight - scaledHeight) / 2
+		scaled := output.SubImage(image.Rect(x, y, x+scaledWidth, y+scaledHeight)).(*image.RGBA)
+		rs.scale(buf, scaled, albumArt, draw.Over)
+		return output, nil
+	}
+
+	scale := max(float64(targetWidth)/float64(width), float64(targetHeight)/float64(height))
+	scaledWidth := int(float64(width) * scale)
+	scaledHeight := int(float64(height) * scale)
+
+	scaled := buf.newRGBA(image.Rect(0, 0, scaledWidth, scaledHeight))
+	rs.scale(buf, scaled, albumArt, draw.Over)
+
+	cropX := (scaledWidth - targetWidth) / 2
+	cropY := (scaledHeight - targetHeight) / 2
+	switch mode {
+	case CropCenter:
+	case CropTop:
+		cropY = 0
+	case CropEntropy:
+		if scaledWidth > targetWidth {
+			cropX = entropyCrop(scaled, targetWidth, true)
+		} else {
+			cropY = entropyCrop(scaled, targetHeight, false)
+		}
+	default:
+		return nil, fmt.Errorf("unknown crop mode %q", mode)
+	}
+
+	output := buf.newRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+	draw.Draw(output, output.Bounds(), scaled, image.Point{X: cropX, Y: cropY}, draw.Src)
+	buf.recycle(scaled)
+
+	return output, nil
+}
+
+// flattenArt draws the art over a solid background of the given colour, if it has any
+// transparent areas. 16-bit art is kept at 16 bits per channel.
+func flattenArt(buf *buffers, albumArt image.Image, background color.Color, rs resampler) image.Image {
+	if o, ok := albumArt.(interface{ Opaque() bool }); ok && o.Opaque() {
+		return albumArt
+	}
+
+	bounds := albumArt.Bounds()
+	fill := image.NewUniform(background)
+	if isHighBitDepth(albumArt) {
+		art := image.NewRGBA64(bounds)
+		draw.Draw(art, bounds, albumArt, bounds.Min, draw.Src)
+		output := image.NewRGBA64(bounds)
+		draw.Draw(output, bounds, fill, image.Point{}, draw.Src)
+		rs.over64(output, bounds, art, bounds.Min)
+		return output
+	}
+
+	art, ok := albumArt.(*image.RGBA)
+	if !ok {
+		art = buf.newRGBA(bounds)
+		draw.Draw(art, bounds, albumArt, bounds.Min, draw.Src)
+	}
+	output := buf.newRGBA(bounds)
+	draw.Draw(output, bounds, fill, image.Point{}, draw.Src)
+	rs.over(output, bounds, art, bounds.Min)
+	buf.recycle(art)
+	return output
+}
+
+// entropyCrop returns the offset of the most detailed length-sized window of the image,
+// across its columns if horizontal is set and otherwise its rows. Strips are repeatedly
+// trimmed from whichever end has the lower entropy until only the window remains.
+func entropyCrop(img *image.RGBA, length int, horizontal bool) int {
+	lo, hi := 0, img.Bounds().Dy()
+	if horizontal {
+		lo, hi = 0, img.Bounds().Dx()
+	}
+
+	for hi-lo > length {
+		strip := min(cropStrip, hi-lo-length)
+		if stripEntropy(img, lo, lo+strip, horizontal) < stripEntropy(img, hi-strip, hi, horizontal) {
+			lo += strip
+		} else {
+			hi -= strip
+		}
+	}
+	return lo
+}
+
+// stripEntropy returns the Shannon entropy of the luminance of the columns (if
+// horizontal is set) or rows of the image from start to end.
+func stripEntropy(img *image.RGBA, start, end int, horizontal bool) float64 {
+	bounds := img.Bounds()
+	rect := image.Rect(0, start, bounds.Dx(), end)
+	if horizontal {
+		rect = image.Rect(start, 0, end, bounds.Dy())
+	}
+	rect = rect.Add(bounds.Min)
+
+	var histogram [256]int
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(rect.Min.X, y):img.PixOffset(rect.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			luma := (299*int(row[i]) + 587*int(row[i+1]) + 114*int(row[i+2])) / 1000
+			histogram[luma]++
+		}
+	}
+
+	total := float64(rect.Dx() * rect.Dy())
+	var entropy float64
+	for _, n := range histogram {
+		if n > 0 {
+			p := float64(n) / total
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
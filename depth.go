@@ -0,0 +1,434 @@
+package jewelcase
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+
+	"golang.org/x/image/math/f64"
+)
+
+// isHighBitDepth reports whether the image has more than 8 bits per channel, as 16-bit
+// PNGs and TIFFs do.
+func isHighBitDepth(img image.Image) bool {
+	switch img.(type) {
+	case *image.RGBA64, *image.NRGBA64, *image.Gray16:
+		return true
+	default:
+		return false
+	}
+}
+
+// deepEffects holds versions of the built-in effects that work with 16 bits per channel,
+// keyed by name, for use while Options.HighBitDepth keeps the art at full depth. Any
+// other effect reduces the art to 8 bits per channel first.
+var deepEffects = map[string]func(ctx *effectContext, img *image.RGBA64) *image.RGBA64{
+	"colour": func(ctx *effectContext, img *image.RGBA64) *image.RGBA64 {
+		monochrome := ctx.opts.PreserveGrayscale && isGrayscale(ctx.source)
+		return applyColourCorrection64(img, ctx.opts.colourGrade(), monochrome)
+	},
+	"edges": func(ctx *effectContext, img *image.RGBA64) *image.RGBA64 {
+		return applyEdgeSoftening64(img, int(2*ctx.scale))
+	},
+	"corners": func(ctx *effectContext, img *image.RGBA64) *image.RGBA64 {
+		lo, hi := ctx.opts.cornerRadii()
+		lo, hi = lo*ctx.scale, hi*ctx.scale
+		for i := range ctx.report.CornerRadii {
+			ctx.report.CornerRadii[i] = lo + ctx.rng.Float64()*(hi-lo)
+		}
+		return applyRoundedCorners64(img, ctx.report.CornerRadii)
+	},
+	"reflection": func(ctx *effectContext, img *image.RGBA64) *image.RGBA64 {
+		light := ctx.opts.reflectionLight()
+		light.streakWidth *= ctx.scale
+		return applyReflection64(img, light)
+	},
+	"rotation": func(ctx *effectContext, img *image.RGBA64) *image.RGBA64 {
+		angle := (ctx.rng.Float64() - 0.5) * (2 * ctx.opts.maxRotation()) * math.Pi / 180
+		ctx.report.RotationAngle = angle * 180 / math.Pi
+		bounds := img.Bounds()
+		result := ctx.buf.newRGBA64(bounds)
+		ctx.opts.resampler().transform64(ctx.buf, result, rotationTransform(bounds, angle), img)
+		ctx.buf.recycle64(img)
+		return result
+	},
+}
+
+// finishes8Bit reports whether any of the steps after the art is placed in the frame are
+// enabled. These only work with 8 bits per channel, so the case is then finished at
+// that depth even if Options.HighBitDepth is set.
+func (o Options) finishes8Bit(selected frameSpec) bool {
+	return o.SpineText != "" && !selected.spine.Empty() ||
+		o.Yellowing || o.Obi || o.HypeStickerText != "" || o.ShopStickerText != "" ||
+		o.PriceSticker || o.Glare || o.Scratches || o.Dust || o.Fingerprints || o.Cracks ||
+		o.ShrinkWrap || o.Tilt || o.DropShadow || o.OutputWidth > 0 || o.OutputHeight > 0
+}
+
+// reduceDepth converts a 16-bit image to 8 bits per channel, rounding to the nearest
+// value.
+func reduceDepth(buf *buffers, img *image.RGBA64) *image.RGBA {
+	bounds := img.Bounds()
+	result := buf.newRGBA(bounds)
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			src := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+			dst := result.Pix[result.PixOffset(bounds.Min.X, y):result.PixOffset(bounds.Max.X, y)]
+			for i := range dst {
+				v := uint32(src[2*i])<<8 | uint32(src[2*i+1])
+				dst[i] = uint8((v*0xff + 0x7fff) / 0xffff)
+			}
+		}
+	})
+	return result
+}
+
+// scaleAndCrop64 behaves like scaleAndCrop, but keeps 16 bits per channel.
+func scaleAndCrop64(buf *buffers, albumArt image.Image, size image.Point, mode CropMode, matte color.Color, rs resampler) (*image.RGBA64, error) {
+	bounds := albumArt.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+	targetWidth, targetHeight := size.X, size.Y
+
+	if mode == CropStretch {
+		output := buf.newRGBA64(image.Rect(0, 0, targetWidth, targetHeight))
+		rs.scale64(buf, output, albumArt)
+		return output, nil
+	}
+
+	if mode == CropLetterbox {
+		scale := min(float64(targetWidth)/float64(width), float64(targetHeight)/float64(height))
+		scaledWidth := max(int(math.Round(float64(width)*scale)), 1)
+		scaledHeight := max(int(math.Round(float64(height)*scale)), 1)
+
+		output := buf.newRGBA64(image.Rect(0, 0, targetWidth, targetHeight))
+		draw.Draw(output, output.Bounds(), image.NewUniform(matte), image.Point{}, draw.Src)
+
+		x := (targetWidth - scaledWidth) / 2
+		y := (targetHeight - scaledHeight) / 2
+		scaled := buf.newRGBA64(image.Rect(x, y, x+scaledWidth, y+scaledHeight))
+		rs.scale64(buf, scaled, albumArt)
+		rs.over64(output, scaled.Bounds(), scaled, scaled.Bounds().Min)
+		buf.recycle64(scaled)
+		return output, nil
+	}
+
+	scale := max(float64(targetWidth)/float64(width), float64(targetHeight)/float64(height))
+	scaledWidth := int(float64(width) * scale)
+	scaledHeight := int(float64(height) * scale)
+
+	scaled := buf.newRGBA64(image.Rect(0, 0, scaledWidth, scaledHeight))
+	rs.scale64(buf, scaled, albumArt)
+
+	cropX := (scaledWidth - targetWidth) / 2
+	cropY := (scaledHeight - targetHeight) / 2
+	switch mode {
+	case CropCenter:
+	case CropTop:
+		cropY = 0
+	case CropEntropy:
+		// Eight bits are plenty to tell where the detail is
+		reduced := reduceDepth(buf, scaled)
+		if scaledWidth > targetWidth {
+			cropX = entropyCrop(reduced, targetWidth, true)
+		} else {
+			cropY = entropyCrop(reduced, targetHeight, false)
+		}
+		buf.recycle(reduced)
+	default:
+		return nil, fmt.Errorf("unknown crop mode %q", mode)
+	}
+
+	output := buf.newRGBA64(image.Rect(0, 0, targetWidth, targetHeight))
+	draw.Draw(output, output.Bounds(), scaled, image.Point{X: cropX, Y: cropY}, draw.Src)
+	buf.recycle64(scaled)
+
+	return output, nil
+}
+
+// scale64 scales the whole of src to fill dst, splitting the work across CPUs. Unlike
+// scale, the art is always drawn with draw.Src.
+func (rs resampler) scale64(buf *buffers, dst *image.RGBA64, src image.Image) {
+	dr := dst.Bounds()
+	if !rs.linear {
+		parallelRows(dr, func(minY, maxY int) {
+			band := dst.SubImage(image.Rect(dr.Min.X, minY, dr.Max.X, maxY)).(*image.RGBA64)
+			rs.filter.Scale(band, dr, src, src.Bounds(), draw.Src, nil)
+		})
+		return
+	}
+
+	lin := toLinear64(buf, src)
+	parallelRows(dr, func(minY, maxY int) {
+		band := dst.SubImage(image.Rect(dr.Min.X, minY, dr.Max.X, maxY)).(*image.RGBA64)
+		rs.filter.Scale(band, dr, lin, lin.Bounds(), draw.Src, nil)
+	})
+	fromLinear64(dst)
+	buf.recycle64(lin)
+}
+
+// transform64 draws src onto dst through the affine transform s2d with draw.Src,
+// splitting the work across CPUs.
+func (rs resampler) transform64(buf *buffers, dst *image.RGBA64, s2d f64.Aff3, src *image.RGBA64) {
+	if rs.linear {
+		src = toLinear64(buf, src)
+	}
+	dr := dst.Bounds()
+	parallelRows(dr, func(minY, maxY int) {
+		band := dst.SubImage(image.Rect(dr.Min.X, minY, dr.Max.X, maxY)).(*image.RGBA64)
+		rs.filter.Transform(band, s2d, src, src.Bounds(), draw.Src, nil)
+	})
+	if rs.linear {
+		fromLinear64(dst)
+		buf.recycle64(src)
+	}
+}
+
+// over64 draws src over the r part of dst, starting from the point sp in src, like
+// over.
+func (rs resampler) over64(dst *image.RGBA64, r image.Rectangle, src *image.RGBA64, sp image.Point) {
+	if !rs.linear {
+		draw.Draw(dst, r, src, sp, draw.Over)
+		return
+	}
+
+	t := deepLinearTables()
+	r = r.Intersect(dst.Bounds()).Intersect(src.Bounds().Add(r.Min.Sub(sp)))
+	parallelRows(r, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			sy := y - r.Min.Y + sp.Y
+			for x := r.Min.X; x < r.Max.X; x++ {
+				s := src.RGBA64At(x-r.Min.X+sp.X, sy)
+				switch s.A {
+				case 0:
+				case 0xffff:
+					dst.SetRGBA64(x, y, s)
+				default:
+					sl, dl := t.toLinear(s), t.toLinear(dst.RGBA64At(x, y))
+					for c := range dl {
+						dl[c] = sl[c] + dl[c]*(0xffff-sl[3])/0xffff
+					}
+					dst.SetRGBA64(x, y, t.fromLinear(dl))
+				}
+			}
+		}
+	})
+}
+
+// toLinear64 returns a copy of the image in linear light, keeping 16 bits per channel.
+func toLinear64(buf *buffers, src image.Image) *image.RGBA64 {
+	bounds := src.Bounds()
+	t := deepLinearTables()
+	lin := buf.newRGBA64(bounds)
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				lin.SetRGBA64(x, y, color64(t.toLinear(color.RGBA64Model.Convert(src.At(x, y)).(color.RGBA64))))
+			}
+		}
+	})
+	return lin
+}
+
+// fromLinear64 converts a linear light image back to sRGB in place.
+func fromLinear64(img *image.RGBA64) {
+	bounds := img.Bounds()
+	t := deepLinearTables()
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				c := img.RGBA64At(x, y)
+				img.SetRGBA64(x, y, t.fromLinear([4]uint32{uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A)}))
+			}
+		}
+	})
+}
+
+// deepLinearTable converts between 16 bit sRGB values and 16 bit linear light values.
+type deepLinearTable struct {
+	toLinearValue [0x10000]uint16
+	toSRGBValue   [0x10000]uint16
+}
+
+var deepLinearTables = sync.OnceValue(func() *deepLinearTable {
+	t := &deepLinearTable{}
+	for i := range t.toLinearValue {
+		c := float64(i) / 0xffff
+		if c <= 0.04045 {
+			c /= 12.92
+		} else {
+			c = math.Pow((c+0.055)/1.055, 2.4)
+		}
+		t.toLinearValue[i] = uint16(math.Round(c * 0xffff))
+	}
+	for i := range t.toSRGBValue {
+		l := float64(i) / 0xffff
+		if l <= 0.0031308 {
+			l *= 12.92
+		} else {
+			l = 1.055*math.Pow(l, 1/2.4) - 0.055
+		}
+		t.toSRGBValue[i] = uint16(math.Round(l * 0xffff))
+	}
+	return t
+})
+
+// toLinear converts a premultiplied sRGB colour to premultiplied linear light.
+func (t *deepLinearTable) toLinear(c color.RGBA64) [4]uint32 {
+	a := uint32(c.A)
+	switch a {
+	case 0:
+		return [4]uint32{}
+	case 0xffff:
+		return [4]uint32{uint32(t.toLinearValue[c.R]), uint32(t.toLinearValue[c.G]), uint32(t.toLinearValue[c.B]), 0xffff}
+	}
+
+	var l [4]uint32
+	for i, v := range [3]uint16{c.R, c.G, c.B} {
+		// Unpremultiply to find the real colour, then premultiply again once linear
+		u := min((uint32(v)*0xffff+a/2)/a, 0xffff)
+		l[i] = uint32(t.toLinearValue[u]) * a / 0xffff
+	}
+	l[3] = a
+	return l
+}
+
+// fromLinear converts a premultiplied linear light pixel to a premultiplied sRGB colour.
+func (t *deepLinearTable) fromLinear(l [4]uint32) color.RGBA64 {
+	a := min(l[3], 0xffff)
+	switch a {
+	case 0:
+		return color.RGBA64{}
+	case 0xffff:
+		return color.RGBA64{R: t.toSRGBValue[min(l[0], 0xffff)], G: t.toSRGBValue[min(l[1], 0xffff)], B: t.toSRGBValue[min(l[2], 0xffff)], A: 0xffff}
+	}
+
+	var c [3]uint16
+	for i := range c {
+		v := min(l[i]*0xffff/a, 0xffff)
+		c[i] = uint16((uint32(t.toSRGBValue[v])*a + 0x7fff) / 0xffff)
+	}
+	return color.RGBA64{R: c[0], G: c[1], B: c[2], A: uint16(a)}
+}
+
+// applyColourCorrection64 behaves like applyColourCorrection, but keeps 16 bits per
+// channel. The maths is done on the same 0-255 scale.
+func applyColourCorrection64(img *image.RGBA64, grade colourGrade, monochrome bool) *image.RGBA64 {
+	bounds := img.Bounds()
+	keep := 1 - grade.contrast
+
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				c := img.RGBA64At(x, y)
+				r, g, b := float64(c.R)/0x101, float64(c.G)/0x101, float64(c.B)/0x101
+
+				if monochrome {
+					// Reduce contrast only
+					r = r*keep + 128*grade.contrast
+					g = g*keep + 128*grade.contrast
+					b = b*keep + 128*grade.contrast
+				} else {
+					// Reduce saturation
+					avg := (r + g + b) / 3 * grade.saturation
+					r = r*(1-grade.saturation) + avg
+					g = g*(1-grade.saturation) + avg
+					b = b*(1-grade.saturation) + avg
+
+					// Reduce contrast, then tint
+					r = (r*keep + 128*grade.contrast) * (1 + grade.tint[0])
+					g = (g*keep + 128*grade.contrast) * (1 + grade.tint[1])
+					b = (b*keep + 128*grade.contrast) * (1 + grade.tint[2])
+				}
+
+				img.SetRGBA64(x, y, color.RGBA64{
+					R: uint16(math.Max(0, math.Min(255, r)) * 0x101),
+					G: uint16(math.Max(0, math.Min(255, g)) * 0x101),
+					B: uint16(math.Max(0, math.Min(255, b)) * 0x101),
+					A: c.A,
+				})
+			}
+		}
+	})
+
+	return img
+}
+
+// applyRoundedCorners64 behaves like applyRoundedCorners, but keeps 16 bits per channel.
+func applyRoundedCorners64(img *image.RGBA64, radii [4]float64) *image.RGBA64 {
+	bounds := img.Bounds()
+
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				distFromLeft := float64(x - bounds.Min.X)
+				distFromRight := float64(bounds.Max.X - x - 1)
+				distFromTop := float64(y - bounds.Min.Y)
+				distFromBottom := float64(bounds.Max.Y - y - 1)
+
+				if shouldRound(distFromLeft, distFromTop, radii[0]) ||
+					shouldRound(distFromRight, distFromTop, radii[1]) ||
+					shouldRound(distFromLeft, distFromBottom, radii[2]) ||
+					shouldRound(distFromRight, distFromBottom, radii[3]) {
+					img.SetRGBA64(x, y, color.RGBA64{})
+				}
+			}
+		}
+	})
+
+	return img
+}
+
+// applyEdgeSoftening64 behaves like applyEdgeSoftening, but keeps 16 bits per channel.
+func applyEdgeSoftening64(img *image.RGBA64, width int) *image.RGBA64 {
+	bounds := img.Bounds()
+
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				minDist := float64(min(x-bounds.Min.X, bounds.Max.X-x-1, y-bounds.Min.Y, bounds.Max.Y-y-1))
+				if minDist >= float64(width) {
+					continue
+				}
+
+				// Colours are premultiplied, so fade them along with the alpha
+				c := img.RGBA64At(x, y)
+				f := minDist / float64(width)
+				img.SetRGBA64(x, y, color.RGBA64{
+					R: uint16(float64(c.R) * f),
+					G: uint16(float64(c.G) * f),
+					B: uint16(float64(c.B) * f),
+					A: uint16(float64(c.A) * f),
+				})
+			}
+		}
+	})
+
+	return img
+}
+
+// applyReflection64 behaves like applyReflection, but keeps 16 bits per channel.
+func applyReflection64(img *image.RGBA64, light reflectionLight) *image.RGBA64 {
+	bounds := img.Bounds()
+	intensity := light.intensity(bounds)
+
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				c := img.RGBA64At(x, y)
+				add := intensity(x, y) * 40 * light.strength * 0x101
+				a := float64(c.A)
+				img.SetRGBA64(x, y, color.RGBA64{
+					R: uint16(math.Min(a, float64(c.R)+add)),
+					G: uint16(math.Min(a, float64(c.G)+add)),
+					B: uint16(math.Min(a, float64(c.B)+add)),
+					A: c.A,
+				})
+			}
+		}
+	})
+
+	return img
+}
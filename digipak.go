@@ -0,0 +1,73 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+	"sync"
+)
+
+const (
+	// digipakSpine is the width of the spine panel, on the left of the card
+	digipakSpine = 42
+
+	// digipakCorner is the radius of the card's rounded corners
+	digipakCorner = 6
+)
+
+// digipakArtRect is the area of the digipak frame covered by the card. The art is printed
+// across both the spine and the front panel, which is a little wider than it is tall like
+// a real one.
+var digipakArtRect = image.Rect(10, 10, 10+digipakSpine+834, 10+750)
+
+// digipakFrame is the empty frame used for StyleDigipak, leaving just the card.
+var digipakFrame = sync.OnceValue(func() frameSpec {
+	return frameSpec{
+		img:      image.NewRGBA(image.Rect(0, 0, digipakArtRect.Max.X+10, digipakArtRect.Max.Y+10)),
+		art:      digipakArtRect,
+		decorate: decorateDigipak,
+	}
+})
+
+// decorateDigipak makes the art look printed on matte card: the blacks are lifted, the
+// surface is given a paper texture, the spine is darkened with a fold between it and the
+// front, and the corners are rounded off.
+func decorateDigipak(ctx *effectContext, art *image.RGBA) (*image.RGBA, error) {
+	if err := texturePaper(art, ObiPaperMatte, ctx.rng); err != nil {
+		return nil, err
+	}
+
+	bounds := art.Bounds()
+	fold := float64(bounds.Min.X + digipakSpine)
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				fx, fy := float64(x)+0.5, float64(y)+0.5
+				coverage := roundedRectCoverage(fx, fy, bounds, digipakCorner)
+
+				// Matte ink never gets quite as dark as gloss. The spine faces away from the
+				// light, and the card bends into the fold with a highlight on its ridge
+				lift, shade := 0.04, 0.0
+				switch d := fx - fold; {
+				case d < -3:
+					shade = 0.2
+				case d < 3:
+					shade = 0.2 + 0.25*(1-math.Abs(d)/3)
+				case d < 6:
+					lift += 0.08 * (1 - math.Abs(d-4.5)/1.5)
+				}
+
+				o := art.PixOffset(x, y)
+				p := art.Pix[o : o+4 : o+4]
+				a := float64(p[3])
+				for c := range 3 {
+					v := float64(p[c])
+					v += (a - v) * lift
+					v *= 1 - shade
+					p[c] = uint8(v * coverage)
+				}
+				p[3] = uint8(a * coverage)
+			}
+		}
+	})
+	return art, nil
+}
@@ -0,0 +1,363 @@
+package jewelcase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// FileError records a failure to process a single file when processing a directory.
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// FileResult describes the outcome of processing a single file when processing a
+// directory.
+type FileResult struct {
+	// Path is the file that was processed
+	Path string
+
+	// Output is where the result was saved, which may be the same as Path
+	Output string
+
+	// Err is the error that occurred, if any. It is ErrAlreadyProcessed or
+	// ErrNoEmbeddedArt if the file was skipped
+	Err error
+
+	// Duration is how long the file took to process
+	Duration time.Duration
+}
+
+// ProcessDirectory applies the jewel case effect in place to every supported image
+// within dir and its subdirectories, using the given number of concurrent workers
+// (or one per CPU if workers is zero or negative). Images in formats that can only be
+// read, such as GIF, are left alone.
+//
+// If opts.IncludeAudio is set, the art embedded in supported audio files is processed
+// too, as with ProcessAudioFile. Files can be filtered by name with opts.Include and
+// opts.Exclude.
+//
+// Images that have already been processed, and audio files without any art, are
+// skipped. Failures to process individual files don't stop the others from being
+// processed; they are returned together as *FileError values joined with errors.Join.
+// opts.OnFile and opts.OnResult, if set, are called after each file is processed.
+func ProcessDirectory(dir string, opts Options, workers int) error {
+	return ProcessDirectoryContext(context.Background(), dir, opts, workers)
+}
+
+// ProcessDirectoryContext behaves like ProcessDirectory, but stops starting new files
+// once ctx is cancelled, and returns the context's error alongside any others. Files that
+// are already being written when ctx is cancelled are allowed to finish.
+func ProcessDirectoryContext(ctx context.Context, dir string, opts Options, workers int) error {
+	files, err := findImages(dir, opts)
+	if err != nil {
+		return err
+	}
+
+	return processFiles(ctx, files, opts, workers)
+}
+
+// ProcessDir behaves like ProcessDirectoryContext with one worker per CPU, and calls cb
+// (if it isn't nil) with the outcome for each file, as well as opts.OnFile. The error
+// passed to cb is nil if the file was processed, ErrAlreadyProcessed or ErrNoEmbeddedArt
+// if it was skipped, and otherwise the reason it failed. cb is never called concurrently.
+func ProcessDir(ctx context.Context, dir string, opts Options, cb func(path string, err error)) error {
+	if cb != nil {
+		onFile := opts.OnFile
+		opts.OnFile = func(path string, err error) {
+			if onFile != nil {
+				onFile(path, err)
+			}
+			cb(path, err)
+		}
+	}
+	return ProcessDirectoryContext(ctx, dir, opts, 0)
+}
+
+// processFiles processes each of the files with a pool of workers, honouring the options
+// that affect ProcessDirectory.
+func processFiles(ctx context.Context, files []imageFile, opts Options, workers int) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	if opts.Newest > 0 && len(files) > opts.Newest {
+		slices.SortStableFunc(files, func(a, b imageFile) int {
+			return b.modTime.Compare(a.modTime)
+		})
+		files = newestUnprocessed(files, opts)
+	}
+
+	if opts.OnStart != nil {
+		opts.OnStart(len(files))
+	}
+
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	if opts.RateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.RateLimit), 1)
+	}
+
+	type job struct {
+		file imageFile
+		opts Options
+	}
+
+	var (
+		jobs = make(chan job)
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				process := ProcessFileContext
+				if IsAudioFile(j.file.path) {
+					process = ProcessAudioFileContext
+				}
+
+				start := time.Now()
+				err := process(ctx, j.file.path, j.file.output, j.opts)
+				duration := time.Since(start)
+				if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+					// Cancelled before it was written, so the file hasn't been touched
+					continue
+				}
+
+				mu.Lock()
+				if err != nil && !errors.Is(err, ErrAlreadyProcessed) && !errors.Is(err, ErrNoEmbeddedArt) {
+					errs = append(errs, &FileError{Path: j.file.path, Err: err})
+				}
+				if opts.OnFile != nil {
+					opts.OnFile(j.file.path, err)
+				}
+				if opts.OnResult != nil {
+					opts.OnResult(FileResult{Path: j.file.path, Output: j.file.output, Err: err, Duration: duration})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, file := range files {
+		if err := limiter.Wait(ctx); err != nil {
+			break
+		}
+
+		fileOpts := opts
+		if opts.Rand != nil {
+			// A rand.Rand can't be shared between workers, so derive one per file
+			fileOpts.Rand = rand.New(rand.NewSource(opts.Rand.Int63()))
+		}
+		jobs <- job{file: file, opts: fileOpts}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return errors.Join(append(errs, ctx.Err())...)
+}
+
+// newestUnprocessed returns files, which are sorted with the newest first, up to and
+// including the opts.Newest'th that hasn't already been processed. Processed files are
+// kept so that they're reported as skipped, but don't count towards the limit, so new
+// files are still reached when they sit alongside ones processed by an earlier run.
+func newestUnprocessed(files []imageFile, opts Options) []imageFile {
+	n := 0
+	for i, file := range files {
+		if opts.Force || !isProcessedFile(file.path, opts) {
+			n++
+		}
+		if n == opts.Newest {
+			return files[:i+1]
+		}
+	}
+	return files
+}
+
+// isProcessedFile reports whether the image at path appears to have been processed
+// already. Audio files, and files that can't be read, are assumed not to have been.
+func isProcessedFile(path string, opts Options) bool {
+	if IsAudioFile(path) {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	if hasFileMarker(data) {
+		return true
+	}
+	if sniffFormat(data) == "jpeg" {
+		// The pixel marker doesn't survive JPEG compression, so isn't worth decoding for
+		return false
+	}
+	img, err := decodeData(data, strings.ToLower(filepath.Ext(path)), opts.maxInputPixels())
+	return err == nil && hasPixelMarker(img)
+}
+
+// imageFile is a file to be processed, and the path to write the result to.
+type imageFile struct {
+	path    string
+	output  string
+	modTime time.Time
+}
+
+// findImages walks the directory and returns all supported image files within it, and
+// audio files if opts.IncludeAudio is set, filtered by opts.Include and opts.Exclude.
+func findImages(dir string, opts Options) ([]imageFile, error) {
+	var files []imageFile
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path != dir {
+			excluded, err := matchAny(opts.Exclude, d.Name())
+			if err != nil {
+				return err
+			}
+			if excluded && d.IsDir() {
+				return filepath.SkipDir
+			}
+			if excluded {
+				return nil
+			}
+		}
+
+		if d.IsDir() || !CanProcessInPlace(path) && !(opts.IncludeAudio && IsAudioFile(path)) {
+			return nil
+		}
+
+		if len(opts.Include) > 0 {
+			included, err := matchAny(opts.Include, d.Name())
+			if err != nil || !included {
+				return err
+			}
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, imageFile{path: path, output: path, modTime: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+// matchAny reports whether name matches any of the glob patterns.
+func matchAny(patterns []string, name string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := filepath.Match(pattern, name)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// albumArtNames are the names (without extensions) of the files that ProcessMusicLibrary
+// looks for in each directory, in order of preference.
+var albumArtNames = []string{"cover", "folder", "front"}
+
+// ProcessMusicLibrary applies the jewel case effect to the album art in each directory
+// within dir, using the given number of concurrent workers (or one per CPU if workers
+// is zero or negative).
+//
+// Only one image is processed per directory: the first of cover, folder or front (in any
+// supported format, ignoring case) that exists, so scans and booklet pages are left
+// alone. If opts.AlbumArtOutput is set, the result is written to a file with that name
+// alongside the art, directories that already have one are skipped unless opts.Force is
+// set, and images in formats that can only be read, such as GIF, are considered too;
+// otherwise the art is processed in place. Errors are reported as for ProcessDirectory.
+func ProcessMusicLibrary(dir string, opts Options, workers int) error {
+	return ProcessMusicLibraryContext(context.Background(), dir, opts, workers)
+}
+
+// ProcessMusicLibraryContext behaves like ProcessMusicLibrary, but stops starting new
+// files once ctx is cancelled, and returns the context's error alongside any others.
+func ProcessMusicLibraryContext(ctx context.Context, dir string, opts Options, workers int) error {
+	files, err := findAlbumArt(dir, opts.AlbumArtOutput, opts.Force)
+	if err != nil {
+		return err
+	}
+	return processFiles(ctx, files, opts, workers)
+}
+
+// findAlbumArt walks the directory and returns the preferred album art file within each
+// directory, along with where to save the processed version.
+func findAlbumArt(dir, outputName string, force bool) ([]imageFile, error) {
+	var files []imageFile
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+
+		var best fs.DirEntry
+		bestRank := len(albumArtNames)
+		for _, entry := range entries {
+			name := entry.Name()
+			if outputName != "" && name == outputName {
+				if !force {
+					return nil
+				}
+				continue
+			}
+
+			ext := filepath.Ext(name)
+			rank := slices.Index(albumArtNames, strings.ToLower(strings.TrimSuffix(name, ext)))
+			readable := canDecode(ext) && (outputName != "" || CanProcessInPlace(name))
+			if entry.Type().IsRegular() && readable && rank >= 0 && rank < bestRank {
+				best, bestRank = entry, rank
+			}
+		}
+		if best == nil {
+			return nil
+		}
+
+		info, err := best.Info()
+		if err != nil {
+			return err
+		}
+
+		file := imageFile{path: filepath.Join(path, best.Name()), modTime: info.ModTime()}
+		file.output = file.path
+		if outputName != "" {
+			file.output = filepath.Join(path, outputName)
+		}
+		files = append(files, file)
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,102 @@
+package jewelcase
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePNG saves img to path as a PNG.
+func writePNG(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("takes nearly two seconds")
+	}
+
+	dir := t.TempDir()
+	for i := range 10 {
+		writePNG(t, filepath.Join(dir, fmt.Sprintf("%d.png", i)), testArt())
+	}
+
+	processed := 0
+	start := time.Now()
+	err := ProcessDirectory(dir, Options{
+		DryRun:    true,
+		RateLimit: 5,
+		OnFile: func(path string, err error) {
+			processed++
+		},
+	}, 4)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if processed != 10 {
+		t.Errorf("processed %d images, want 10", processed)
+	}
+	// The first image starts straight away, then one more every 200ms
+	if elapsed < 1800*time.Millisecond {
+		t.Errorf("took %v to process 10 images at 5 per second, want at least 1.8s", elapsed)
+	}
+}
+
+func TestNewestSkipsProcessedImages(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	names := []string{"a.png", "b.png", "c.png", "d.png"}
+	for i, name := range names {
+		path := filepath.Join(dir, name)
+		writePNG(t, path, testArt())
+		if name == "d.png" {
+			if err := ProcessFile(path, path, Options{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Oldest first, an hour apart
+		modTime := now.Add(time.Duration(i-len(names)) * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results := make(map[string]error)
+	err := ProcessDirectory(dir, Options{
+		DryRun: true,
+		Newest: 2,
+		OnFile: func(path string, err error) {
+			results[filepath.Base(path)] = err
+		},
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err, ok := results["d.png"]; !ok || !errors.Is(err, ErrAlreadyProcessed) {
+		t.Errorf("newest image: got %v, want it skipped as already processed", err)
+	}
+	for _, name := range []string{"b.png", "c.png"} {
+		if err, ok := results[name]; !ok || err != nil {
+			t.Errorf("%s: processed %t with error %v, want it processed", name, ok, err)
+		}
+	}
+	if _, ok := results["a.png"]; ok {
+		t.Errorf("oldest image was considered, want it left out by the limit")
+	}
+}
@@ -0,0 +1,197 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+	"sync"
+)
+
+// The parts of a disc, as radii in pixels for a disc targetWidth across. A real CD is
+// 120mm across with a 15mm hole, and the label is printed outside the clear hub.
+const (
+	discHole   = 47
+	discStack  = 103
+	discMirror = 115
+	discLabel  = 125
+	discRim    = 370
+
+	// discMargin is the space left around the disc in the StyleDisc frame
+	discMargin = 20
+)
+
+const (
+	slimlineWidth  = 888
+	slimlineHeight = 781
+
+	// slimlinePeek is how far the disc sticks out of the right of the slimline case
+	slimlinePeek = 300
+)
+
+// slimlineRect is the area of the StyleDiscSlimline frame covered by the case.
+var slimlineRect = image.Rect(10, 10, 10+slimlineWidth, 10+slimlineHeight)
+
+// slimlineArtRect is the area of the StyleDiscSlimline frame covered by the disc, which
+// is partly pulled out of the right of the case.
+var slimlineArtRect = image.Rect(0, 0, targetWidth, targetHeight).Add(image.Point{
+	X: slimlineRect.Max.X + slimlinePeek - targetWidth,
+	Y: slimlineRect.Min.Y + (slimlineHeight-targetHeight)/2,
+})
+
+// discFrame is the empty frame used for StyleDisc, leaving just the disc.
+var discFrame = sync.OnceValue(func() frameSpec {
+	return frameSpec{
+		img:      image.NewRGBA(image.Rect(0, 0, targetWidth+2*discMargin, targetHeight+2*discMargin)),
+		art:      image.Rect(discMargin, discMargin, discMargin+targetWidth, discMargin+targetHeight),
+		decorate: decorateDisc,
+	}
+})
+
+// slimlineFrame renders the slimline case used for StyleDiscSlimline.
+var slimlineFrame = sync.OnceValue(func() frameSpec {
+	return frameSpec{
+		img:      renderSlimlineTray(),
+		art:      slimlineArtRect,
+		decorate: decorateDisc,
+		front:    renderSlimlineLid(),
+	}
+})
+
+// decorateDisc turns the art into the printed face of a disc: cropped to a circle with a
+// hole in the middle, a clear hub with its stacking ring catching the light, a band of
+// the mirrored layer around the label, and a soft radial sheen across the print.
+func decorateDisc(_ *effectContext, art *image.RGBA) (*image.RGBA, error) {
+	bounds := art.Bounds()
+	cx, cy := float64(bounds.Min.X+bounds.Max.X)/2, float64(bounds.Min.Y+bounds.Max.Y)/2
+	scale := float64(bounds.Dx()) / targetWidth
+	radius := float64(bounds.Dx()) / 2
+
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+				r := math.Hypot(dx, dy)
+				o := art.PixOffset(x, y)
+				p := art.Pix[o : o+4 : o+4]
+
+				// Anti-alias the outer edge and the hole
+				coverage := math.Min(math.Max(radius-r+0.5, 0), 1) * math.Min(math.Max(r-discHole*scale+0.5, 0), 1)
+				if coverage == 0 {
+					p[0], p[1], p[2], p[3] = 0, 0, 0, 0
+					continue
+				}
+
+				// Light falls across the disc from the top left, so the sheen is strongest
+				// along that diagonal
+				angle := math.Atan2(dy, dx)
+				sheen := math.Pow(math.Abs(math.Cos(angle+math.Pi/4)), 12)
+				rs := r / scale
+
+				var v, alpha float64
+				switch {
+				case rs < discMirror:
+					// Clear polycarbonate, with raised rings at the edge of the hole and the
+					// stacking ring that catch the light
+					v, alpha = 215, 0.3
+					if rs < discHole+3 || math.Abs(rs-discStack) < 2.5 {
+						v, alpha = 245, 0.65
+					} else if math.Abs(rs-discStack-4) < 1.5 {
+						v, alpha = 90, 0.4
+					}
+					v += sheen * 30
+				case rs < discLabel:
+					// The mirrored layer shows between the hub and the printed label
+					v, alpha = 150+sheen*90, 1
+				case rs > discRim:
+					// A clear lip around the very edge
+					v, alpha = 225+sheen*30, 0.55
+				default:
+					// The printed label, with a soft sheen from its lacquer
+					a := float64(p[3])
+					for c := range 3 {
+						p[c] = uint8((float64(p[c]) + (a-float64(p[c]))*sheen*0.12) * coverage)
+					}
+					p[3] = uint8(a * coverage)
+					continue
+				}
+
+				a := alpha * coverage
+				p[0] = uint8(math.Min(v, 255) * a)
+				p[1] = uint8(math.Min(v, 255) * a)
+				p[2] = uint8(math.Min(v+6, 255) * a)
+				p[3] = uint8(255 * a)
+			}
+		}
+	})
+	return art, nil
+}
+
+// renderSlimlineTray draws the black base of a slimline case, with the hub that holds
+// the disc in the middle.
+func renderSlimlineTray() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, slimlineRect.Max.X+slimlinePeek+10, slimlineRect.Max.Y+10))
+	hub := image.Point{X: (slimlineRect.Min.X + slimlineRect.Max.X) / 2, Y: (slimlineRect.Min.Y + slimlineRect.Max.Y) / 2}
+
+	for y := slimlineRect.Min.Y; y < slimlineRect.Max.Y; y++ {
+		for x := slimlineRect.Min.X; x < slimlineRect.Max.X; x++ {
+			fx, fy := float64(x)+0.5, float64(y)+0.5
+			coverage := roundedRectCoverage(fx, fy, slimlineRect, 8)
+			if coverage == 0 {
+				continue
+			}
+
+			// Black plastic, with a well for the disc and the hub's teeth in the middle
+			v := 22.0
+			r := math.Hypot(fx-float64(hub.X), fy-float64(hub.Y))
+			switch {
+			case r < 36:
+				v = 12
+			case r < 90:
+				teeth := math.Sin(math.Atan2(fy-float64(hub.Y), fx-float64(hub.X)) * 12)
+				v = 40 + 12*teeth + 20*math.Max(0, 1-math.Abs(r-88)/2)
+			case math.Abs(r-targetWidth/2-6) < 1.5:
+				v = 34
+			}
+
+			o := img.PixOffset(x, y)
+			img.Pix[o+0] = uint8(v * coverage)
+			img.Pix[o+1] = uint8(v * coverage)
+			img.Pix[o+2] = uint8((v + 2) * coverage)
+			img.Pix[o+3] = uint8(255 * coverage)
+		}
+	}
+	return img
+}
+
+// renderSlimlineLid draws the clear lid of a slimline case, which is drawn over the disc,
+// as a translucent white layer with a bright rim, the hinge along the left and a glare
+// across the middle.
+func renderSlimlineLid() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, slimlineRect.Max.X+slimlinePeek+10, slimlineRect.Max.Y+10))
+
+	for y := slimlineRect.Min.Y; y < slimlineRect.Max.Y; y++ {
+		for x := slimlineRect.Min.X; x < slimlineRect.Max.X; x++ {
+			fx, fy := float64(x)+0.5, float64(y)+0.5
+			coverage := roundedRectCoverage(fx, fy, slimlineRect, 8)
+			if coverage == 0 {
+				continue
+			}
+
+			alpha := 0.06
+			if edge := roundedRectDistance(fx, fy, slimlineRect, 8); edge < 4 {
+				alpha = 0.7 - edge*0.15
+			} else if x < slimlineRect.Min.X+30 {
+				// Ridges along the hinge
+				alpha = 0.15 + 0.1*math.Sin(fy/3)
+			}
+
+			// A wide soft band of glare running diagonally across the lid
+			d := (fx - fy*0.6 - 320) / 90
+			alpha += 0.1 * math.Exp(-d*d)
+
+			a := uint8(math.Min(alpha*coverage, 1) * 255)
+			o := img.PixOffset(x, y)
+			img.Pix[o+0], img.Pix[o+1], img.Pix[o+2], img.Pix[o+3] = a, a, a, a
+		}
+	}
+	return img
+}
@@ -0,0 +1,53 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+	"math/rand"
+)
+
+// MaxDustDensity is the largest value used for Options.DustDensity; larger values are
+// treated as this, so that a request can't ask for an unbounded amount of work.
+const MaxDustDensity = 100
+
+// applyDust scatters tiny light and dark specks over the image at random, like dust
+// settled on the plastic. density is the number of specks per 10,000 pixels of a case at
+// its usual size, and scale is how much larger the image is.
+func applyDust(img *image.RGBA, rng *rand.Rand, density, scale float64) *image.RGBA {
+	bounds := img.Bounds()
+	count := int(math.Round(float64(bounds.Dx()*bounds.Dy()) / (scale * scale) / 10000 * density))
+	for range count {
+		cx := float64(bounds.Min.X) + rng.Float64()*float64(bounds.Dx())
+		cy := float64(bounds.Min.Y) + rng.Float64()*float64(bounds.Dy())
+
+		// Most specks are barely a pixel across, with the occasional larger one
+		radius := (0.4 + math.Pow(rng.Float64(), 3)*1.6) * scale
+		opacity := 0.2 + rng.Float64()*0.5
+
+		// Dust mostly catches the light, but some is dark grit
+		light := rng.Float64() < 0.7
+
+		area := image.Rect(int(cx-radius-1), int(cy-radius-1), int(cx+radius+2), int(cy+radius+2)).Intersect(bounds)
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				// Anti-alias the edge of the speck over about a pixel
+				d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy)
+				v := math.Min(math.Max(radius+0.5-d, 0), 1) * opacity
+				if v == 0 {
+					continue
+				}
+
+				i := img.PixOffset(x, y)
+				p := img.Pix[i : i+4 : i+4]
+				for c := range 3 {
+					if light {
+						p[c] += uint8(float64(p[3]-p[c]) * v)
+					} else {
+						p[c] -= uint8(float64(p[c]) * v)
+					}
+				}
+			}
+		}
+	}
+	return img
+}
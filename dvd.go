@@ -0,0 +1,105 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+	"sync"
+)
+
+const (
+	dvdWidth  = 866
+	dvdHeight = 1105
+
+	// dvdSpine is the width of the keep case's spine, on the left
+	dvdSpine = 81
+)
+
+// dvdCaseRect is the area of the DVD frame covered by the keep case, including its spine.
+var dvdCaseRect = image.Rect(10, 10, 10+dvdWidth, 10+dvdHeight)
+
+// dvdArtRect is the area of the DVD frame covered by the cover, which is held under the
+// clear sleeve on the front of the case. A DVD cover is 129mm by 184mm.
+var dvdArtRect = image.Rect(0, 0, targetWidth, 1070).Add(image.Point{
+	X: dvdCaseRect.Min.X + dvdSpine + (dvdWidth-dvdSpine-targetWidth)/2,
+	Y: dvdCaseRect.Min.Y + (dvdHeight-1070)/2,
+})
+
+// dvdFrame renders the keep case and sleeve used for StyleDVD.
+var dvdFrame = sync.OnceValue(func() frameSpec {
+	return frameSpec{
+		img:   renderKeepCase(),
+		art:   dvdArtRect,
+		front: renderKeepCaseSleeve(),
+	}
+})
+
+// renderKeepCase draws an empty black DVD keep case, with a ridged spine on the left.
+func renderKeepCase() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, dvdCaseRect.Max.X+10, dvdCaseRect.Max.Y+10))
+
+	for y := dvdCaseRect.Min.Y; y < dvdCaseRect.Max.Y; y++ {
+		for x := dvdCaseRect.Min.X; x < dvdCaseRect.Max.X; x++ {
+			fx, fy := float64(x)+0.5, float64(y)+0.5
+			coverage := roundedRectCoverage(fx, fy, dvdCaseRect, 12)
+			if coverage == 0 {
+				continue
+			}
+
+			// Black plastic, slightly lighter where the rim curves away
+			v := 24.0
+			if edge := roundedRectDistance(fx, fy, dvdCaseRect, 12); edge < 6 {
+				v = 24 + (6-edge)*6
+			}
+
+			// The spine is moulded with shallow ridges, and a groove where it meets the front
+			spine := float64(x - dvdCaseRect.Min.X)
+			switch {
+			case math.Abs(spine-dvdSpine) < 2:
+				v = 8
+			case spine < dvdSpine && spine > 8:
+				v += 6 * math.Sin(spine/3)
+			}
+
+			o := img.PixOffset(x, y)
+			img.Pix[o+0] = uint8(v * coverage)
+			img.Pix[o+1] = uint8(v * coverage)
+			img.Pix[o+2] = uint8((v + 2) * coverage)
+			img.Pix[o+3] = uint8(255 * coverage)
+		}
+	}
+	return img
+}
+
+// renderKeepCaseSleeve draws the clear plastic sleeve wrapped around the keep case, which
+// goes over the cover, as a translucent white layer. It is brightest along the edges
+// and where it is pinched against the spine, with a soft reflection down the front.
+func renderKeepCaseSleeve() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, dvdCaseRect.Max.X+10, dvdCaseRect.Max.Y+10))
+
+	for y := dvdCaseRect.Min.Y; y < dvdCaseRect.Max.Y; y++ {
+		for x := dvdCaseRect.Min.X; x < dvdCaseRect.Max.X; x++ {
+			fx, fy := float64(x)+0.5, float64(y)+0.5
+			coverage := roundedRectCoverage(fx, fy, dvdCaseRect, 12)
+			if coverage == 0 {
+				continue
+			}
+
+			alpha := 0.03
+			if edge := roundedRectDistance(fx, fy, dvdCaseRect, 12); edge < 3 {
+				alpha = 0.25
+			}
+			if d := math.Abs(fx - float64(dvdCaseRect.Min.X+dvdSpine) - 3); d < 3 {
+				alpha += 0.2 * (1 - d/3)
+			}
+
+			// A broad, faint streak down the front where the sleeve bows slightly
+			d := (fx - float64(dvdArtRect.Min.X) - float64(dvdArtRect.Dx())*0.3 + fy*0.15) / 60
+			alpha += 0.06 * math.Exp(-d*d)
+
+			a := uint8(math.Min(alpha*coverage, 1) * 255)
+			o := img.PixOffset(x, y)
+			img.Pix[o+0], img.Pix[o+1], img.Pix[o+2], img.Pix[o+3] = a, a, a, a
+		}
+	}
+	return img
+}
@@ -0,0 +1,196 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+	"math/rand"
+)
+
+// Effect is a transformation applied to the album art before it is placed in the frame.
+// Implementations may modify the given image in place or return a new one.
+type Effect interface {
+	Apply(img *image.RGBA) *image.RGBA
+}
+
+// EffectFunc adapts an ordinary function to the Effect interface.
+type EffectFunc func(img *image.RGBA) *image.RGBA
+
+// Apply calls f(img).
+func (f EffectFunc) Apply(img *image.RGBA) *image.RGBA {
+	return f(img)
+}
+
+// The built-in effects, for use in Options.Effects. When used by Process they take their
+// parameters from the Options and their randomness from the per-image random source; when
+// applied directly they use the default parameters. Apart from RotationEffect and
+// PerspectiveEffect, they modify the image in place.
+var (
+	ColourCorrectionEffect Effect = builtinEffect{name: "colour", rows: colourCorrectionEffect}
+	PaperTextureEffect     Effect = builtinEffect{name: "paper", apply: paperTextureEffect}
+	GrainEffect            Effect = builtinEffect{name: "grain", apply: grainEffect}
+	VignetteEffect         Effect = builtinEffect{name: "vignette", apply: vignetteEffect}
+	InnerShadowEffect      Effect = builtinEffect{name: "inner-shadow", apply: innerShadowEffect}
+	EdgeSofteningEffect    Effect = builtinEffect{name: "edges", rows: edgeSofteningEffect}
+	RoundedCornersEffect   Effect = builtinEffect{name: "corners", rows: roundedCornersEffect}
+	ReflectionEffect       Effect = builtinEffect{name: "reflection", rows: reflectionEffect}
+	RotationEffect         Effect = builtinEffect{name: "rotation", apply: rotationEffect}
+	PerspectiveEffect      Effect = builtinEffect{name: "perspective", apply: perspectiveEffect}
+)
+
+// DefaultEffects returns the effects that are applied to the art when Options.Effects
+// is nil, as selected by the individual effect options. It can be used as a starting
+// point for inserting custom effects.
+func (o Options) DefaultEffects() []Effect {
+	var effects []Effect
+	if o.ColourCorrection {
+		effects = append(effects, ColourCorrectionEffect)
+	}
+	if o.PaperTexture {
+		effects = append(effects, PaperTextureEffect)
+	}
+	if o.Grain {
+		effects = append(effects, GrainEffect)
+	}
+	if o.Vignette {
+		effects = append(effects, VignetteEffect)
+	}
+	if o.InnerShadow {
+		effects = append(effects, InnerShadowEffect)
+	}
+	if o.EdgeSoftening {
+		effects = append(effects, EdgeSofteningEffect)
+	}
+	if o.RoundedCorners {
+		effects = append(effects, RoundedCornersEffect)
+	}
+	if o.Reflection {
+		effects = append(effects, ReflectionEffect)
+	}
+	if o.RandomRotation {
+		effects = append(effects, RotationEffect)
+	}
+	if o.Perspective {
+		effects = append(effects, PerspectiveEffect)
+	}
+	return effects
+}
+
+// effectContext carries the per-image state that built-in effects need.
+type effectContext struct {
+	buf    *buffers
+	rng    *rand.Rand
+	report *Report
+	opts   Options
+	source image.Image
+
+	// scale is how much larger than usual the case is being rendered, which effects
+	// drawn at a fixed size in pixels are enlarged by
+	scale float64
+}
+
+type builtinEffect struct {
+	name  string
+	apply func(ctx *effectContext, img *image.RGBA) *image.RGBA
+
+	// rows is set instead of apply for effects that adjust each pixel independently of
+	// the others. It returns the adjustment for an image with the given bounds, so that
+	// neighbouring effects like this can be combined into a single pass over the image
+	rows func(ctx *effectContext, bounds image.Rectangle) rowOp
+}
+
+// rowOp adjusts a row of an image in place, given its y coordinate and its premultiplied
+// pixels.
+type rowOp func(y int, pix []uint8)
+
+// applyRowOps runs each of the ops on every row of the image in turn, so that they are
+// all applied in a single pass over it, while each row is still in the CPU's cache.
+func applyRowOps(img *image.RGBA, ops ...rowOp) *image.RGBA {
+	bounds := img.Bounds()
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			pix := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+			for _, op := range ops {
+				op(y, pix)
+			}
+		}
+	})
+	return img
+}
+
+// run applies the effect to img.
+func (e builtinEffect) run(ctx *effectContext, img *image.RGBA) *image.RGBA {
+	if e.rows != nil {
+		return applyRowOps(img, e.rows(ctx, img.Bounds()))
+	}
+	return e.apply(ctx, img)
+}
+
+// Apply runs the effect outside of Process, using the default parameters.
+func (e builtinEffect) Apply(img *image.RGBA) *image.RGBA {
+	return e.run(&effectContext{
+		buf:    &buffers{},
+		rng:    rand.New(rand.NewSource(rand.Int63())),
+		report: &Report{},
+		source: img,
+		scale:  1,
+	}, img)
+}
+
+// applyEffect runs an effect, giving built-in effects access to the per-image state.
+func applyEffect(ctx *effectContext, e Effect, img *image.RGBA) *image.RGBA {
+	if b, ok := e.(builtinEffect); ok {
+		return b.run(ctx, img)
+	}
+	return e.Apply(img)
+}
+
+func colourCorrectionEffect(ctx *effectContext, _ image.Rectangle) rowOp {
+	monochrome := ctx.opts.PreserveGrayscale && isGrayscale(ctx.source)
+	return colourCorrectionOp(ctx.opts.colourGrade(), monochrome)
+}
+
+func paperTextureEffect(ctx *effectContext, img *image.RGBA) *image.RGBA {
+	return applyPaperTexture(img, ctx.rng, ctx.opts.paperOpacity(), ctx.scale)
+}
+
+func grainEffect(ctx *effectContext, img *image.RGBA) *image.RGBA {
+	return applyGrain(img, ctx.rng, ctx.opts.grainIntensity())
+}
+
+func vignetteEffect(ctx *effectContext, img *image.RGBA) *image.RGBA {
+	return applyVignette(img, ctx.opts.vignetteStrength())
+}
+
+func innerShadowEffect(ctx *effectContext, img *image.RGBA) *image.RGBA {
+	drawInnerShadow(img, img.Bounds(), ctx.opts.innerShadowWidth()*ctx.scale)
+	return img
+}
+
+func edgeSofteningEffect(ctx *effectContext, bounds image.Rectangle) rowOp {
+	return edgeSofteningOp(bounds, int(2*ctx.scale))
+}
+
+func roundedCornersEffect(ctx *effectContext, bounds image.Rectangle) rowOp {
+	lo, hi := ctx.opts.cornerRadii()
+	lo, hi = lo*ctx.scale, hi*ctx.scale
+	for i := range ctx.report.CornerRadii {
+		ctx.report.CornerRadii[i] = lo + ctx.rng.Float64()*(hi-lo)
+	}
+	return roundedCornersOp(bounds, ctx.report.CornerRadii)
+}
+
+func reflectionEffect(ctx *effectContext, bounds image.Rectangle) rowOp {
+	light := ctx.opts.reflectionLight()
+	light.streakWidth *= ctx.scale
+	return reflectionOp(bounds, light)
+}
+
+func rotationEffect(ctx *effectContext, img *image.RGBA) *image.RGBA {
+	angle := (ctx.rng.Float64() - 0.5) * (2 * ctx.opts.maxRotation()) * math.Pi / 180
+	ctx.report.RotationAngle = angle * 180 / math.Pi
+	return applyRotation(ctx.buf, img, angle, ctx.opts.resampler())
+}
+
+func perspectiveEffect(ctx *effectContext, img *image.RGBA) *image.RGBA {
+	return applyPerspective(ctx.buf, img, ctx.opts.perspectiveTilt())
+}
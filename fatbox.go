@@ -0,0 +1,72 @@
+package jewelcase
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"sync"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	// fatboxExtra is how much wider the spine of a double-disc fatbox is than the spine of
+	// the embedded jewel case frame
+	fatboxExtra = 66
+
+	// fatboxSpineEdge is the width of the moulded edges either side of the embedded frame's
+	// spine, which are kept as they are while the middle is stretched
+	fatboxSpineEdge = 8
+)
+
+// fatboxArtRect is the area of the fatbox frame that the art is placed in.
+var fatboxArtRect = defaultArtRect.Add(image.Point{X: fatboxExtra})
+
+// fatboxSpineRect is the area of the fatbox frame occupied by the case's spine.
+var fatboxSpineRect = image.Rect(spineRect.Min.X, spineRect.Min.Y, spineRect.Max.X+fatboxExtra, spineRect.Max.Y)
+
+// fatboxFrame renders the frame used for StyleFatbox.
+var fatboxFrame = sync.OnceValue(func() frameSpec {
+	return frameSpec{
+		img:   renderFatbox(),
+		art:   fatboxArtRect,
+		spine: fatboxSpineRect,
+	}
+})
+
+// renderFatbox widens the spine of the embedded jewel case frame to make a double-disc
+// fatbox. The rim of the case and the hinge are copied across unchanged, the plain middle
+// of the spine is stretched to fill the extra space, and a faint seam is moulded down it
+// where the two trays meet.
+func renderFatbox() *image.RGBA {
+	bounds := frame.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, bounds.Dx()+fatboxExtra, bounds.Dy()))
+
+	left := spineRect.Min.X + fatboxSpineEdge
+	right := spineRect.Max.X - fatboxSpineEdge
+	draw.Draw(img, image.Rect(0, 0, left, bounds.Dy()), frame, bounds.Min, draw.Src)
+	xdraw.BiLinear.Scale(img, image.Rect(left, 0, right+fatboxExtra, bounds.Dy()), frame,
+		image.Rect(left, 0, right, bounds.Dy()).Add(bounds.Min), draw.Src, nil)
+	draw.Draw(img, image.Rect(right+fatboxExtra, 0, img.Bounds().Dx(), bounds.Dy()), frame,
+		bounds.Min.Add(image.Point{X: right}), draw.Src)
+
+	seam := float64(fatboxSpineRect.Min.X+fatboxSpineRect.Max.X) / 2
+	for y := fatboxSpineRect.Min.Y; y < fatboxSpineRect.Max.Y; y++ {
+		for x := int(seam) - 3; x <= int(seam)+3; x++ {
+			// A dark groove with a thin highlight along its right-hand lip
+			shade := 1.0
+			switch d := float64(x) + 0.5 - seam; {
+			case math.Abs(d) < 1.5:
+				shade = 0.55
+			case d >= 1.5 && d < 2.5:
+				shade = 1.35
+			}
+
+			o := img.PixOffset(x, y)
+			for c := range 3 {
+				img.Pix[o+c] = uint8(math.Min(float64(img.Pix[o+c])*shade, 255))
+			}
+		}
+	}
+	return img
+}
@@ -0,0 +1,255 @@
+package jewelcase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// loadImage reads and decodes an image file, rotating it according to any EXIF orientation.
+// The format is identified from the file's contents, so misnamed files can still be read.
+// It also returns the file's metadata, and reports whether the metadata shows it was
+// written by jewelcase. Images larger than opts.MaxInputPixels are rejected.
+func loadImage(inputPath string, opts Options) (image.Image, metadata, bool, error) {
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return nil, metadata{}, false, err
+	}
+
+	img, err := decodeData(data, strings.ToLower(filepath.Ext(inputPath)), opts.maxInputPixels())
+	if err != nil {
+		return nil, metadata{}, false, err
+	}
+
+	return applyOrientation(img, jpegOrientation(data)), readMetadata(data), hasFileMarker(data), nil
+}
+
+// checkFile returns the error that would prevent an image loaded from a file from being
+// processed and saved to outputPath, for Options.DryRun. The output format isn't checked
+// if outputPath is empty.
+func checkFile(img image.Image, outputPath string, opts Options, marked bool) error {
+	if _, err := candidateFrames(opts); err != nil {
+		return err
+	}
+
+	if !opts.Force && (marked || hasPixelMarker(img)) {
+		return ErrAlreadyProcessed
+	}
+
+	if outputPath == "" {
+		return nil
+	}
+
+	format := outputFormat(outputPath, opts)
+	if _, ok := encoderFor(format); !ok {
+		return &UnsupportedFormatError{Ext: format, Op: "encode"}
+	}
+	return nil
+}
+
+func saveImage(img image.Image, outputPath string, opts Options, meta metadata) error {
+	format := outputFormat(outputPath, opts)
+	if _, ok := encoderFor(format); !ok {
+		return &UnsupportedFormatError{Ext: format, Op: "encode"}
+	}
+
+	return WriteFileAtomic(outputPath, 0o644, func(w io.Writer) error {
+		return encodeMarked(w, img, format, opts, meta)
+	})
+}
+
+// outputFormat returns the format to save an image to outputPath in: opts.OutputFormat
+// if it is set, otherwise the path's extension.
+func outputFormat(outputPath string, opts Options) string {
+	if opts.OutputFormat != "" {
+		return opts.OutputFormat
+	}
+	return strings.ToLower(filepath.Ext(outputPath))
+}
+
+// WriteFileAtomic calls write to fill a temporary file in the same directory as path,
+// then renames it over path, so that path is never left partially written. If write
+// fails, path is left as it was. If path already exists its permissions are kept,
+// otherwise the file is given perm. Symlinks are followed, so the file they point to is
+// replaced rather than the link.
+func WriteFileAtomic(path string, perm fs.FileMode, write func(w io.Writer) error) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ProcessFile applies the jewel case effect to an image file and saves the result.
+// Reads from inputPath, applies effects, and writes to outputPath. The output format
+// is determined by the outputPath extension, unless opts.OutputFormat is set. Supports
+// JPEG, PNG, WebP, TIFF and BMP formats, and AVIF when built with the avif tag; GIF and
+// HEIC images can be read but not written, and only the first frame of a GIF is used.
+// If the input and output paths are the same and opts.BackupSuffix is set, the original
+// is first copied alongside with that suffix. If opts.WriteSidecar is set, the options
+// and Report are saved to outputPath with ".json" appended.
+func ProcessFile(inputPath, outputPath string, opts Options) error {
+	return ProcessFileContext(context.Background(), inputPath, outputPath, opts)
+}
+
+// ProcessFileContext behaves like ProcessFile, but stops and returns the context's error
+// if ctx is cancelled before the image has been processed. Once writing the output has
+// started it is always allowed to finish, so files are never left half-written.
+func ProcessFileContext(ctx context.Context, inputPath, outputPath string, opts Options) error {
+	img, meta, marked, err := loadImage(inputPath, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.DryRun {
+		return checkFile(img, outputPath, opts, marked)
+	}
+
+	buf := &buffers{pool: &encodePool}
+	defer buf.release()
+	result, report, err := process(ctx, buf, img, opts, marked)
+	if err != nil {
+		return err
+	}
+
+	var inputInfo fs.FileInfo
+	if opts.PreserveFileAttributes {
+		if inputInfo, err = os.Stat(inputPath); err != nil {
+			return err
+		}
+	}
+
+	if opts.BackupSuffix != "" && samePath(inputPath, outputPath) {
+		if err := backupFile(inputPath, inputPath+opts.BackupSuffix); err != nil {
+			return err
+		}
+	}
+
+	if err := saveImage(result, outputPath, opts, meta); err != nil {
+		return err
+	}
+
+	if inputInfo != nil {
+		if err := copyAttributes(outputPath, inputInfo); err != nil {
+			return err
+		}
+	}
+
+	if opts.WriteSidecar {
+		return writeSidecar(outputPath+".json", opts, report)
+	}
+	return nil
+}
+
+// writeSidecar saves the effective options and the report for a processed image as JSON.
+func writeSidecar(path string, opts Options, report *Report) error {
+	data, err := json.MarshalIndent(struct {
+		Options Options
+		Report  *Report
+	}{opts.withDefaults(), report}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, 0o644, func(w io.Writer) error {
+		_, err := w.Write(append(data, '\n'))
+		return err
+	})
+}
+
+// backupFile copies the file at path to backupPath, refusing to overwrite any existing file.
+// As files are replaced rather than modified in place, a hard link is used where possible.
+func backupFile(path, backupPath string) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	err := os.Link(path, backupPath)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("refusing to overwrite existing backup: %w", err)
+	}
+
+	err = copyNewFile(path, backupPath)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("refusing to overwrite existing backup: %w", err)
+	}
+	return err
+}
+
+// copyNewFile copies the file at path to a new file at dest, with the same permissions.
+// It fails if dest already exists.
+func copyNewFile(path, dest string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// copyAttributes sets the modification time and permissions of the file at path to those
+// described by info.
+func copyAttributes(path string, info fs.FileInfo) error {
+	if err := os.Chmod(path, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(path, time.Time{}, info.ModTime())
+}
+
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}
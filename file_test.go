@@ -0,0 +1,203 @@
+package jewelcase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSeedFromContent(t *testing.T) {
+	dir := t.TempDir()
+	firstArt, secondArt := testArt(), solidImage(100, 100, color.RGBA{R: 40, G: 80, B: 160, A: 255})
+	first, second := filepath.Join(dir, "first.png"), filepath.Join(dir, "second.png")
+	writePNG(t, first, firstArt)
+	writePNG(t, second, secondArt)
+
+	opts := Options{SeedFromContent: true, RandomRotation: true, RandomOffset: true, RoundedCorners: true}
+	process := func(input, output string) []byte {
+		t.Helper()
+		output = filepath.Join(dir, output)
+		if err := ProcessFile(input, output, opts); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	if !bytes.Equal(process(first, "a.png"), process(first, "b.png")) {
+		t.Error("processing the same file twice gave different results")
+	}
+	if bytes.Equal(process(first, "c.png"), process(second, "d.png")) {
+		t.Error("processing different files gave the same result")
+	}
+
+	// The random choices themselves should differ, not just the art
+	_, firstReport, err := ProcessWithReport(firstArt, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, secondReport, err := ProcessWithReport(secondArt, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if firstReport.RotationAngle == secondReport.RotationAngle && firstReport.Offset == secondReport.Offset {
+		t.Errorf("different images were given the same rotation and offset: %v, %v", firstReport.RotationAngle, firstReport.Offset)
+	}
+}
+
+func TestBackupSuffix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cover.png")
+	writePNG(t, path, testArt())
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ProcessFile(path, path, Options{BackupSuffix: ".orig"}); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := os.ReadFile(path + ".orig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(backup, original) {
+		t.Error("backup doesn't contain the original image")
+	}
+	if info, err := os.Stat(path + ".orig"); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0o600 {
+		t.Errorf("backup has permissions %v, want the original's %v", info.Mode().Perm(), os.FileMode(0o600))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := decodeData(data, "png", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Size() == testArt().Bounds().Size() || !hasPixelMarker(img) {
+		t.Errorf("output is a %v image without the framed marker, want the framed image", img.Bounds().Size())
+	}
+
+	// A second run mustn't replace the backup of the original with the framed image
+	err = ProcessFile(path, path, Options{BackupSuffix: ".orig", Force: true})
+	if err == nil {
+		t.Error("processing again with an existing backup succeeded, want an error")
+	}
+	if backup, _ := os.ReadFile(path + ".orig"); !bytes.Equal(backup, original) {
+		t.Error("backup of the original was overwritten")
+	}
+}
+
+func TestCopyNewFileKeepsPermissions(t *testing.T) {
+	dir := t.TempDir()
+	path, dest := filepath.Join(dir, "cover.png"), filepath.Join(dir, "cover.png.orig")
+	if err := os.WriteFile(path, []byte("private"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := copyNewFile(path, dest); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "private" {
+		t.Errorf("copy contains %q, want %q", data, "private")
+	}
+	if info, err := os.Stat(dest); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0o600 {
+		t.Errorf("copy has permissions %v, want %v", info.Mode().Perm(), os.FileMode(0o600))
+	}
+
+	if err := copyNewFile(path, dest); err == nil {
+		t.Error("copying over an existing file succeeded, want an error")
+	}
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	dir := t.TempDir()
+	tga, png := filepath.Join(dir, "cover.tga"), filepath.Join(dir, "cover.png")
+	if err := os.WriteFile(tga, []byte{0, 0, 2, 0, 0, 0, 0, 0}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writePNG(t, png, testArt())
+
+	tests := []struct {
+		input, output, op string
+	}{
+		{tga, filepath.Join(dir, "out.png"), "decode"},
+		{png, filepath.Join(dir, "out.tga"), "encode"},
+	}
+	for _, tt := range tests {
+		err := ProcessFile(tt.input, tt.output, Options{})
+		var formatErr *UnsupportedFormatError
+		if !errors.As(err, &formatErr) {
+			t.Errorf("%s: got %v, want an UnsupportedFormatError", tt.op, err)
+			continue
+		}
+		if formatErr.Ext != ".tga" || formatErr.Op != tt.op {
+			t.Errorf("got Ext %q and Op %q, want .tga and %s", formatErr.Ext, formatErr.Op, tt.op)
+		}
+	}
+}
+
+func TestSidecar(t *testing.T) {
+	dir := t.TempDir()
+	input, output := filepath.Join(dir, "in.png"), filepath.Join(dir, "out.png")
+	writePNG(t, input, testArt())
+
+	options := func() Options {
+		return Options{RandomOffset: true, RandomRotation: true, WriteSidecar: true, Rand: rand.New(rand.NewSource(1))}
+	}
+	if err := ProcessFile(input, output, options()); err != nil {
+		t.Fatal(err)
+	}
+	_, want, err := ProcessWithReport(testArt(), options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want.RotationAngle == 0 {
+		t.Fatal("art wasn't rotated, so the angle can't be checked")
+	}
+
+	data, err := os.ReadFile(output + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sidecar struct {
+		Options map[string]any
+		Report  map[string]json.RawMessage
+	}
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		t.Fatalf("sidecar isn't valid JSON: %v", err)
+	}
+
+	var offset image.Point
+	var angle float64
+	if err := json.Unmarshal(sidecar.Report["Offset"], &offset); err != nil {
+		t.Errorf("couldn't read the offset from the sidecar: %v", err)
+	}
+	if err := json.Unmarshal(sidecar.Report["RotationAngle"], &angle); err != nil {
+		t.Errorf("couldn't read the rotation angle from the sidecar: %v", err)
+	}
+	if offset != want.Offset || angle != want.RotationAngle {
+		t.Errorf("sidecar records offset %v and angle %g, want %v and %g", offset, angle, want.Offset, want.RotationAngle)
+	}
+	if sidecar.Options["RandomRotation"] != true || sidecar.Options["MaxRotation"] == nil {
+		t.Errorf("sidecar doesn't record the effective options: %v", sidecar.Options)
+	}
+}
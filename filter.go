@@ -0,0 +1,62 @@
+package jewelcase
+
+import (
+	"fmt"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Filter is the resampling filter used when scaling the art to fit the frame and when
+// rotating it.
+type Filter string
+
+const (
+	// FilterNearest picks the nearest pixel, which is fast but blocky.
+	FilterNearest Filter = "nearest"
+
+	// FilterBilinear interpolates between the nearest four pixels. This is the default.
+	FilterBilinear Filter = "bilinear"
+
+	// FilterCatmullRom uses a cubic kernel, which keeps fine detail much better when
+	// scaling large art down, at a modest extra cost.
+	FilterCatmullRom Filter = "catmull-rom"
+
+	// FilterLanczos uses a three-lobed Lanczos kernel, which is slightly sharper than
+	// FilterCatmullRom but slower.
+	FilterLanczos Filter = "lanczos"
+)
+
+// filters maps each Filter to its implementation.
+var filters = map[Filter]xdraw.Interpolator{
+	FilterNearest:    xdraw.NearestNeighbor,
+	FilterBilinear:   xdraw.BiLinear,
+	FilterCatmullRom: xdraw.CatmullRom,
+	FilterLanczos: &xdraw.Kernel{Support: 3, At: func(t float64) float64 {
+		if t == 0 {
+			return 1
+		}
+		if t >= 3 {
+			return 0
+		}
+		t *= math.Pi
+		return 3 * math.Sin(t) * math.Sin(t/3) / (t * t)
+	}},
+}
+
+// validFilter checks the filter is one of the known values.
+func validFilter(filter Filter) error {
+	if _, ok := filters[filter]; !ok {
+		return fmt.Errorf("unknown filter %q", filter)
+	}
+	return nil
+}
+
+// interpolator returns the implementation of the filter, falling back to bilinear
+// interpolation for unknown filters.
+func (f Filter) interpolator() xdraw.Interpolator {
+	if i, ok := filters[f]; ok {
+		return i
+	}
+	return xdraw.BiLinear
+}
@@ -0,0 +1,96 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+	"math/rand"
+)
+
+// MaxFingerprintIntensity is the largest value used for Options.FingerprintIntensity;
+// larger values are treated as this, so that a request can't ask for an unbounded amount
+// of work.
+const MaxFingerprintIntensity = 10
+
+// applyFingerprints overlays faint greasy fingerprints and smudges on the image at random,
+// as if the case has been handled a lot. intensity scales the number of marks, with
+// roughly three per 250,000 pixels of a case at its usual size at an intensity of 1, and
+// scale is how much larger the image is.
+func applyFingerprints(img *image.RGBA, rng *rand.Rand, intensity, scale float64) *image.RGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	haze := make([]float32, width*height)
+
+	count := int(math.Round(float64(width*height) / (scale * scale) / 250000 * 3 * intensity))
+	for range count {
+		cx := rng.Float64() * float64(width)
+		cy := rng.Float64() * float64(height)
+		angle := rng.Float64() * math.Pi
+		if rng.Float64() < 0.6 {
+			rx := 40 + rng.Float64()*15
+			addMark(haze, width, height, rng, cx, cy, rx, rx*(1.25+rng.Float64()*0.15), angle, 0.08+rng.Float64()*0.08, true, scale)
+		} else {
+			rx := 40 + rng.Float64()*60
+			addMark(haze, width, height, rng, cx, cy, rx, rx*(0.2+rng.Float64()*0.2), angle, 0.05+rng.Float64()*0.06, false, scale)
+		}
+	}
+
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			pix := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+			row := haze[(y-bounds.Min.Y)*width : (y-bounds.Min.Y+1)*width]
+
+			for i, x := 0, 0; i < len(pix); i, x = i+4, x+1 {
+				// Grease scatters light, so lighten towards white where the image is opaque
+				a := pix[i+3]
+				v := float64(row[x])
+				for c := range 3 {
+					pix[i+c] = pix[i+c] + uint8(float64(a-pix[i+c])*v)
+				}
+			}
+		}
+	})
+	return img
+}
+
+// addMark adds an elliptical greasy mark to the haze map, centred on (cx, cy) with the
+// given radii and rotation. Fingerprints have concentric ridges, while smudges are just
+// patchy. Overlapping marks keep the strongest value. The radii are in pixels of a case at
+// its usual size, and the mark is enlarged by scale.
+func addMark(haze []float32, width, height int, rng *rand.Rand, cx, cy, rx, ry, angle, strength float64, ridges bool, scale float64) {
+	sin, cos := math.Sin(angle), math.Cos(angle)
+	extent := int(math.Ceil(math.Max(rx, ry)))
+	size := 2*extent + 1
+	patches := newFractalNoise(rng, size, size, 20, 3)
+	warp := newNoiseField(rng, size, size, 12)
+
+	// The core of a fingerprint's whorl isn't quite in the middle of the print
+	coreX, coreY := (rng.Float64()-0.5)*rx*0.3, -ry*(0.1+rng.Float64()*0.2)
+	period := 3 + rng.Float64()*0.6
+
+	reach := int(math.Ceil(float64(extent) * scale))
+	x0, y0 := int(cx)-reach, int(cy)-reach
+	for py := max(y0, 0); py < min(y0+2*reach+1, height); py++ {
+		for px := max(x0, 0); px < min(x0+2*reach+1, width); px++ {
+			dx, dy := (float64(px)-cx)/scale, (float64(py)-cy)/scale
+			u := dx*cos + dy*sin
+			w := -dx*sin + dy*cos
+
+			d := math.Hypot(u/rx, w/ry)
+			if d >= 1 {
+				continue
+			}
+
+			// Fade out towards the edge of the mark, and leave gaps where less grease was left
+			nx, ny := float64(px-x0)/scale, float64(py-y0)/scale
+			v := math.Pow(1-d*d, 1.5) * smoothstep(math.Min(math.Max((patches.at(nx, ny)-0.3)/0.4, 0), 1))
+
+			if ridges {
+				r := math.Hypot(u-coreX, (w-coreY)*0.85) + (warp.at(nx, ny)-0.5)*6
+				v *= 0.5 + 0.5*math.Sin(2*math.Pi*r/period)
+			}
+
+			i := py*width + px
+			haze[i] = max(haze[i], float32(v*strength))
+		}
+	}
+}
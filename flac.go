@@ -0,0 +1,142 @@
+package jewelcase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+)
+
+var errMalformedFLAC = errors.New("malformed FLAC file")
+
+// flacPicture is the contents of a FLAC PICTURE metadata block, which is also how art is
+// embedded in Ogg Vorbis comments.
+type flacPicture struct {
+	kind        uint32
+	mime        string
+	description []byte
+	width       uint32
+	height      uint32
+	depth       uint32
+	colours     uint32
+	data        []byte
+}
+
+// parseFLACPicture parses the contents of a PICTURE block.
+func parseFLACPicture(b []byte) (*flacPicture, error) {
+	p := &flacPicture{}
+	readField := func() ([]byte, bool) {
+		if len(b) < 4 || uint32(len(b)-4) < binary.BigEndian.Uint32(b) {
+			return nil, false
+		}
+		n := binary.BigEndian.Uint32(b)
+		field := b[4 : 4+n]
+		b = b[4+n:]
+		return field, true
+	}
+
+	if len(b) < 4 {
+		return nil, errMalformedFLAC
+	}
+	p.kind, b = binary.BigEndian.Uint32(b), b[4:]
+
+	mime, ok := readField()
+	if !ok {
+		return nil, errMalformedFLAC
+	}
+	p.mime = string(mime)
+
+	if p.description, ok = readField(); !ok || len(b) < 16 {
+		return nil, errMalformedFLAC
+	}
+	p.width = binary.BigEndian.Uint32(b)
+	p.height = binary.BigEndian.Uint32(b[4:])
+	p.depth = binary.BigEndian.Uint32(b[8:])
+	p.colours = binary.BigEndian.Uint32(b[12:])
+	b = b[16:]
+
+	if p.data, ok = readField(); !ok {
+		return nil, errMalformedFLAC
+	}
+	return p, nil
+}
+
+// bytes returns the encoded contents of the PICTURE block.
+func (p *flacPicture) bytes() []byte {
+	var b []byte
+	b = binary.BigEndian.AppendUint32(b, p.kind)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(p.mime)))
+	b = append(b, p.mime...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(p.description)))
+	b = append(b, p.description...)
+	for _, v := range []uint32{p.width, p.height, p.depth, p.colours} {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	b = binary.BigEndian.AppendUint32(b, uint32(len(p.data)))
+	return append(b, p.data...)
+}
+
+// replaceArt updates the picture to hold new image data.
+func (p *flacPicture) replaceArt(art []byte, mime string, size image.Point) {
+	p.mime = mime
+	p.data = art
+	p.width, p.height = uint32(size.X), uint32(size.Y)
+	p.depth, p.colours = 24, 0
+	if mime == "image/png" {
+		p.depth = 32
+	}
+}
+
+// flacArt finds the front cover in a FLAC file's PICTURE blocks, or the first picture if
+// none of them is marked as the front cover.
+func flacArt(data []byte) ([]byte, func([]byte, string, image.Point) ([]byte, error), error) {
+	if !bytes.HasPrefix(data, []byte("fLaC")) {
+		return nil, nil, errMalformedFLAC
+	}
+
+	var picture *flacPicture
+	var blockStart, blockEnd int
+	for pos := 4; ; {
+		if pos+4 > len(data) {
+			return nil, nil, errMalformedFLAC
+		}
+		header := data[pos]
+		end := pos + 4 + (int(data[pos+1])<<16 | int(data[pos+2])<<8 | int(data[pos+3]))
+		if end > len(data) {
+			return nil, nil, errMalformedFLAC
+		}
+
+		if header&0x7f == 6 {
+			p, err := parseFLACPicture(data[pos+4 : end])
+			if err != nil {
+				return nil, nil, err
+			}
+			if picture == nil || p.kind == pictureFrontCover && picture.kind != pictureFrontCover {
+				picture, blockStart, blockEnd = p, pos, end
+			}
+		}
+
+		pos = end
+		if header&0x80 != 0 {
+			break
+		}
+	}
+
+	if picture == nil {
+		return nil, nil, ErrNoEmbeddedArt
+	}
+
+	return picture.data, func(art []byte, mime string, size image.Point) ([]byte, error) {
+		picture.replaceArt(art, mime, size)
+		block := picture.bytes()
+		if len(block) >= 1<<24 {
+			return nil, errors.New("cover art is too large for a FLAC picture block")
+		}
+
+		out := make([]byte, 0, len(data)-(blockEnd-blockStart)+len(block)+4)
+		out = append(out, data[:blockStart]...)
+		out = append(out, data[blockStart], byte(len(block)>>16), byte(len(block)>>8), byte(len(block)))
+		out = append(out, block...)
+		return append(out, data[blockEnd:]...), nil
+	}, nil
+}
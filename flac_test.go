@@ -0,0 +1,80 @@
+package jewelcase
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"testing"
+)
+
+// flacBlock returns a metadata block of the given type, marked as the last if last is
+// set.
+func flacBlock(kind byte, last bool, body []byte) []byte {
+	if last {
+		kind |= 0x80
+	}
+	return append([]byte{kind, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}, body...)
+}
+
+func TestFLACArtRoundTrip(t *testing.T) {
+	back := &flacPicture{kind: 4, mime: "image/jpeg", description: []byte("back"), width: 10, height: 10, depth: 24, data: []byte("back cover")}
+	front := &flacPicture{kind: pictureFrontCover, mime: "image/jpeg", description: []byte("front"), width: 20, height: 20, depth: 24, data: []byte("front cover")}
+
+	streamInfo := flacBlock(0, false, make([]byte, 34))
+	backBlock := flacBlock(6, false, back.bytes())
+	frontBlock := flacBlock(6, false, front.bytes())
+	padding := flacBlock(1, true, make([]byte, 64))
+	data := append([]byte("fLaC"), streamInfo...)
+	data = append(data, backBlock...)
+	data = append(data, frontBlock...)
+	data = append(data, padding...)
+	data = append(data, "audio frames"...)
+
+	art, replace, err := flacArt(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(art) != "front cover" {
+		t.Fatalf("found art %q, want the front cover", art)
+	}
+
+	for _, newArt := range [][]byte{[]byte("new"), bytes.Repeat([]byte("new front cover"), 1000)} {
+		out, err := replace(newArt, "image/png", image.Pt(750, 700))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, _, err := flacArt(out)
+		if err != nil {
+			t.Fatalf("re-parsing the file: %v", err)
+		}
+		if !bytes.Equal(got, newArt) {
+			t.Errorf("replaced art is %d bytes, want %d", len(got), len(newArt))
+		}
+
+		start := 4 + len(streamInfo) + len(backBlock)
+		size := int(out[start+1])<<16 | int(out[start+2])<<8 | int(out[start+3])
+		if out[start] != 6 || start+4+size > len(out) {
+			t.Fatalf("picture block header is %x", out[start:start+4])
+		}
+		p, err := parseFLACPicture(out[start+4 : start+4+size])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.kind != pictureFrontCover || p.mime != "image/png" || string(p.description) != "front" || p.width != 750 || p.height != 700 || p.depth != 32 {
+			t.Errorf("picture block has kind %d, MIME type %q, description %q, size %dx%d and depth %d", p.kind, p.mime, p.description, p.width, p.height, p.depth)
+		}
+
+		// The other blocks and the audio should be untouched
+		if !bytes.Equal(out[:start], data[:start]) || !bytes.Equal(out[start+4+size:], data[start+len(frontBlock):]) {
+			t.Error("replacing the art changed more than the picture block")
+		}
+	}
+}
+
+func TestFLACArtWithoutPicture(t *testing.T) {
+	data := append([]byte("fLaC"), flacBlock(0, true, make([]byte, 34))...)
+	if _, _, err := flacArt(data); !errors.Is(err, ErrNoEmbeddedArt) {
+		t.Errorf("got error %v, want ErrNoEmbeddedArt", err)
+	}
+}
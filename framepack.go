@@ -0,0 +1,138 @@
+package jewelcase
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// framePackManifest is the name of the manifest file in a frame pack.
+const framePackManifest = "manifest.json"
+
+// FramePack is a custom frame that can be shared without any code, as a directory or zip
+// file containing the frame image and a manifest.json describing how to use it:
+//
+//	{
+//	  "name": "Scuffed jewel case",
+//	  "frame": "frame.png",
+//	  "art": {"x": 98, "y": 13, "width": 750, "height": 750},
+//	  "max_rotation": 1.5,
+//	  "masks": [{"x": 840, "y": 100, "width": 20, "height": 80}]
+//	}
+//
+// Only the frame and art area are required. Masks are areas of the frame that sit in
+// front of the art, such as the tabs that hold an insert in place.
+type FramePack struct {
+	// Name is the human-readable name of the frame
+	Name string
+
+	// Frame is the frame image
+	Frame image.Image
+
+	// ArtRect is the area of Frame that the art is scaled to fill
+	ArtRect image.Rectangle
+
+	// MaxRotation is the largest random rotation the art should be given in degrees, or
+	// zero to leave the option unchanged
+	MaxRotation float64
+
+	// Masks are the areas of Frame that are drawn in front of the art
+	Masks []image.Rectangle
+}
+
+// framePackRect is a rectangle as written in a frame pack manifest.
+type framePackRect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func (r framePackRect) rect() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)
+}
+
+// LoadFramePack reads a frame pack from a directory, or from a zip file if the path
+// ends in .zip.
+func LoadFramePack(p string) (*FramePack, error) {
+	if strings.EqualFold(path.Ext(p), ".zip") {
+		r, err := zip.OpenReader(p)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return readFramePack(r)
+	}
+	return readFramePack(os.DirFS(p))
+}
+
+// readFramePack reads a frame pack from the root of fsys.
+func readFramePack(fsys fs.FS) (*FramePack, error) {
+	data, err := fs.ReadFile(fsys, framePackManifest)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest struct {
+		Name        string          `json:"name"`
+		Frame       string          `json:"frame"`
+		Art         *framePackRect  `json:"art"`
+		MaxRotation float64         `json:"max_rotation"`
+		Masks       []framePackRect `json:"masks"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", framePackManifest, err)
+	}
+	if manifest.Frame == "" || manifest.Art == nil {
+		return nil, fmt.Errorf("%s must give the frame image and art area", framePackManifest)
+	}
+	if manifest.MaxRotation < 0 {
+		return nil, fmt.Errorf("max rotation %g must not be negative", manifest.MaxRotation)
+	}
+
+	f, err := fsys.Open(manifest.Frame)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	frame, err := Decode(f, path.Ext(manifest.Frame))
+	if err != nil {
+		return nil, fmt.Errorf("error reading frame image: %w", err)
+	}
+
+	// Positions in the manifest are relative to the top-left of the frame image
+	bounds := frame.Bounds()
+	pack := &FramePack{
+		Name:        manifest.Name,
+		Frame:       frame,
+		ArtRect:     manifest.Art.rect().Add(bounds.Min),
+		MaxRotation: manifest.MaxRotation,
+	}
+	if pack.ArtRect.Empty() || !pack.ArtRect.In(bounds) {
+		return nil, fmt.Errorf("art area %v must be a non-empty area within the frame %v", manifest.Art.rect(), bounds.Size())
+	}
+	for _, m := range manifest.Masks {
+		mask := m.rect().Add(bounds.Min)
+		if mask.Empty() || !mask.In(bounds) {
+			return nil, fmt.Errorf("mask %v must be a non-empty area within the frame %v", m.rect(), bounds.Size())
+		}
+		pack.Masks = append(pack.Masks, mask)
+	}
+	return pack, nil
+}
+
+// Apply configures the options to use the frame pack.
+func (p *FramePack) Apply(opts *Options) {
+	opts.Frame = p.Frame
+	opts.FrameArtRect = p.ArtRect
+	opts.FrameMasks = p.Masks
+	if p.MaxRotation > 0 {
+		opts.MaxRotation = p.MaxRotation
+	}
+}
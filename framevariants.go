@@ -0,0 +1,58 @@
+package jewelcase
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// FrameVariants is the number of versions of the embedded jewel case photograph that can
+// be selected with Options.FrameVariant.
+const FrameVariants = 4
+
+// frameVariants returns each version of the embedded jewel case photograph, as if it had
+// been taken under different lighting or of a different case: as photographed, under a
+// warm lamp, in cool daylight from a window, and a more scuffed case.
+var frameVariants = sync.OnceValue(func() []frameSpec {
+	bounds := frame.Bounds()
+	base := image.NewRGBA(image.Rectangle{Max: bounds.Size()})
+	draw.Draw(base, base.Bounds(), frame, bounds.Min, draw.Src)
+
+	// The warm lamp is off to the top left, and the window off to the right
+	w, h := float64(base.Bounds().Dx()), float64(base.Bounds().Dy())
+	warm := relightFrame(base, [3]float64{1.06, 1.0, 0.88}, func(x, y float64) float64 {
+		return 1.06 - 0.14*math.Hypot(x/w, y/h)/math.Sqrt2
+	})
+	cool := relightFrame(base, [3]float64{0.94, 0.98, 1.06}, func(x, y float64) float64 {
+		return 0.9 + 0.12*x/w
+	})
+	scuffed := applyScratches(base, rand.New(rand.NewSource(7)), 4, 1)
+
+	variants := make([]frameSpec, FrameVariants)
+	for i, img := range []image.Image{frame, warm, cool, scuffed} {
+		variants[i] = frameSpec{img: img, art: defaultArtRect, spine: spineRect, variant: i + 1}
+	}
+	return variants
+})
+
+// relightFrame returns a copy of the image with each channel scaled by tint, and the
+// brightness at each point scaled by light.
+func relightFrame(img *image.RGBA, tint [3]float64, light func(x, y float64) float64) *image.RGBA {
+	result := image.NewRGBA(img.Bounds())
+	bounds := img.Bounds()
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				l := light(float64(x), float64(y))
+				o := img.PixOffset(x, y)
+				for c := range 3 {
+					result.Pix[o+c] = uint8(math.Min(float64(img.Pix[o+c])*tint[c]*l, float64(img.Pix[o+3])))
+				}
+				result.Pix[o+3] = img.Pix[o+3]
+			}
+		}
+	})
+	return result
+}
@@ -0,0 +1,53 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+	"math/rand"
+)
+
+// applyGrain adds fine monochrome noise to the image, like the grain of a photographed
+// print. intensity is the standard deviation of the noise, as a fraction of full
+// brightness.
+func applyGrain(img *image.RGBA, rng *rand.Rand, intensity float64) *image.RGBA {
+	bounds := img.Bounds()
+	seed := rng.Uint64()
+
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			pix := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+			row := seed ^ uint64(y-bounds.Min.Y)<<32
+
+			for i, x := 0, 0; i < len(pix); i, x = i+4, x+1 {
+				// The pixels are premultiplied, so the noise is scaled by the alpha to keep
+				// transparent areas clear
+				a := float64(pix[i+3])
+				n := grainNoise(row^uint64(x)) * intensity * a
+				for c := range 3 {
+					pix[i+c] = uint8(min(max(math.Round(float64(pix[i+c])+n), 0), a))
+				}
+			}
+		}
+	})
+	return img
+}
+
+// grainNoise returns roughly normally distributed noise with a mean of 0 and a standard
+// deviation of 1, derived from the given value. It is hashed rather than drawn from a
+// random source so that rows can be generated in parallel.
+func grainNoise(v uint64) float64 {
+	// splitmix64
+	v += 0x9e3779b97f4a7c15
+	v = (v ^ v>>30) * 0xbf58476d1ce4e5b9
+	v = (v ^ v>>27) * 0x94d049bb133111eb
+	v ^= v >> 31
+
+	// The sum of four uniform values is close enough to a normal distribution for grain,
+	// with a mean of 2 and a variance of 1/3
+	var sum float64
+	for range 4 {
+		sum += float64(v&0xffff) / 0xffff
+		v >>= 16
+	}
+	return (sum - 2) * math.Sqrt(3)
+}
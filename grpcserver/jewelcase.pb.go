@@ -0,0 +1,1337 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: jewelcase.proto
+
+package grpcserver
+
+import (
+	status "google.golang.org/genproto/googleapis/rpc/status"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ProcessRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The encoded image, in JPEG, PNG, WebP, TIFF, BMP, GIF or HEIC format. Only the first
+	// frame of an animated GIF is used.
+	Image []byte `protobuf:"bytes,1,opt,name=image,proto3" json:"image,omitempty"`
+	// The format to encode the result in: jpeg, png, webp, tiff, bmp, or avif if the server
+	// was built with the avif tag. Defaults to png.
+	Format string `protobuf:"bytes,2,opt,name=format,proto3" json:"format,omitempty"`
+	// Settings for the effect. Defaults to the same look as the command line tool.
+	Options *Options `protobuf:"bytes,3,opt,name=options,proto3" json:"options,omitempty"`
+	// An identifier for the request, which is copied to the response to make it easier to
+	// match up the results of a batch.
+	Id            string `protobuf:"bytes,4,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProcessRequest) Reset() {
+	*x = ProcessRequest{}
+	mi := &file_jewelcase_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProcessRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProcessRequest) ProtoMessage() {}
+
+func (x *ProcessRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_jewelcase_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProcessRequest.ProtoReflect.Descriptor instead.
+func (*ProcessRequest) Descriptor() ([]byte, []int) {
+	return file_jewelcase_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ProcessRequest) GetImage() []byte {
+	if x != nil {
+		return x.Image
+	}
+	return nil
+}
+
+func (x *ProcessRequest) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *ProcessRequest) GetOptions() *Options {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *ProcessRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ProcessResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The id from the request.
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// The processed image, in the requested format. Empty if there was an error.
+	Image []byte `protobuf:"bytes,2,opt,name=image,proto3" json:"image,omitempty"`
+	// The format of the processed image.
+	Format string `protobuf:"bytes,3,opt,name=format,proto3" json:"format,omitempty"`
+	// Why the image couldn't be processed, for ProcessBatch only. The code is the same as
+	// Process would have failed with.
+	Error         *status.Status `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProcessResponse) Reset() {
+	*x = ProcessResponse{}
+	mi := &file_jewelcase_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProcessResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProcessResponse) ProtoMessage() {}
+
+func (x *ProcessResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_jewelcase_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProcessResponse.ProtoReflect.Descriptor instead.
+func (*ProcessResponse) Descriptor() ([]byte, []int) {
+	return file_jewelcase_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ProcessResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ProcessResponse) GetImage() []byte {
+	if x != nil {
+		return x.Image
+	}
+	return nil
+}
+
+func (x *ProcessResponse) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *ProcessResponse) GetError() *status.Status {
+	if x != nil {
+		return x.Error
+	}
+	return nil
+}
+
+// Options mirrors jewelcase.Options. Settings start from a preset, and any that are set
+// here override the preset's. Colours are given as hex triplets, e.g. "#ffd400".
+type Options struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The preset to start from: mint, used or thrashed. Defaults to used, which is the
+	// look the command line tool gives by default.
+	Preset string `protobuf:"bytes,1,opt,name=preset,proto3" json:"preset,omitempty"`
+	// Seed for the random effects, for reproducible output. Random if unset.
+	Seed            *int64 `protobuf:"varint,2,opt,name=seed,proto3,oneof" json:"seed,omitempty"`
+	SeedFromContent *bool  `protobuf:"varint,3,opt,name=seed_from_content,json=seedFromContent,proto3,oneof" json:"seed_from_content,omitempty"`
+	Force           *bool  `protobuf:"varint,4,opt,name=force,proto3,oneof" json:"force,omitempty"`
+	// Don't copy EXIF data and ICC colour profiles from the input to the output.
+	StripMetadata      bool     `protobuf:"varint,5,opt,name=strip_metadata,json=stripMetadata,proto3" json:"strip_metadata,omitempty"`
+	ColourCorrection   *bool    `protobuf:"varint,10,opt,name=colour_correction,json=colourCorrection,proto3,oneof" json:"colour_correction,omitempty"`
+	RoundedCorners     *bool    `protobuf:"varint,11,opt,name=rounded_corners,json=roundedCorners,proto3,oneof" json:"rounded_corners,omitempty"`
+	EdgeSoftening      *bool    `protobuf:"varint,12,opt,name=edge_softening,json=edgeSoftening,proto3,oneof" json:"edge_softening,omitempty"`
+	RandomOffset       *bool    `protobuf:"varint,13,opt,name=random_offset,json=randomOffset,proto3,oneof" json:"random_offset,omitempty"`
+	RandomRotation     *bool    `protobuf:"varint,14,opt,name=random_rotation,json=randomRotation,proto3,oneof" json:"random_rotation,omitempty"`
+	Reflection         *bool    `protobuf:"varint,15,opt,name=reflection,proto3,oneof" json:"reflection,omitempty"`
+	PreserveGrayscale  *bool    `protobuf:"varint,16,opt,name=preserve_grayscale,json=preserveGrayscale,proto3,oneof" json:"preserve_grayscale,omitempty"`
+	CornerRadiusMin    *float64 `protobuf:"fixed64,17,opt,name=corner_radius_min,json=cornerRadiusMin,proto3,oneof" json:"corner_radius_min,omitempty"`
+	CornerRadiusMax    *float64 `protobuf:"fixed64,18,opt,name=corner_radius_max,json=cornerRadiusMax,proto3,oneof" json:"corner_radius_max,omitempty"`
+	MaxRotation        *float64 `protobuf:"fixed64,19,opt,name=max_rotation,json=maxRotation,proto3,oneof" json:"max_rotation,omitempty"`
+	MaxOffsetX         *int32   `protobuf:"varint,20,opt,name=max_offset_x,json=maxOffsetX,proto3,oneof" json:"max_offset_x,omitempty"`
+	MaxOffsetY         *int32   `protobuf:"varint,21,opt,name=max_offset_y,json=maxOffsetY,proto3,oneof" json:"max_offset_y,omitempty"`
+	ReflectionStrength *float64 `protobuf:"fixed64,22,opt,name=reflection_strength,json=reflectionStrength,proto3,oneof" json:"reflection_strength,omitempty"`
+	TintAmount         *float64 `protobuf:"fixed64,23,opt,name=tint_amount,json=tintAmount,proto3,oneof" json:"tint_amount,omitempty"`
+	// The colour to tint towards: cool, warm, sepia or a hex triplet. Defaults to cool.
+	TintColour          *string  `protobuf:"bytes,99,opt,name=tint_colour,json=tintColour,proto3,oneof" json:"tint_colour,omitempty"`
+	SaturationReduction *float64 `protobuf:"fixed64,100,opt,name=saturation_reduction,json=saturationReduction,proto3,oneof" json:"saturation_reduction,omitempty"`
+	ContrastReduction   *float64 `protobuf:"fixed64,101,opt,name=contrast_reduction,json=contrastReduction,proto3,oneof" json:"contrast_reduction,omitempty"`
+	Grain               *bool    `protobuf:"varint,24,opt,name=grain,proto3,oneof" json:"grain,omitempty"`
+	GrainIntensity      *float64 `protobuf:"fixed64,25,opt,name=grain_intensity,json=grainIntensity,proto3,oneof" json:"grain_intensity,omitempty"`
+	Vignette            *bool    `protobuf:"varint,102,opt,name=vignette,proto3,oneof" json:"vignette,omitempty"`
+	VignetteStrength    *float64 `protobuf:"fixed64,103,opt,name=vignette_strength,json=vignetteStrength,proto3,oneof" json:"vignette_strength,omitempty"`
+	ReflectionAngle     *float64 `protobuf:"fixed64,104,opt,name=reflection_angle,json=reflectionAngle,proto3,oneof" json:"reflection_angle,omitempty"`
+	ReflectionWidth     *float64 `protobuf:"fixed64,105,opt,name=reflection_width,json=reflectionWidth,proto3,oneof" json:"reflection_width,omitempty"`
+	DoubleReflection    *bool    `protobuf:"varint,106,opt,name=double_reflection,json=doubleReflection,proto3,oneof" json:"double_reflection,omitempty"`
+	InnerShadow         *bool    `protobuf:"varint,107,opt,name=inner_shadow,json=innerShadow,proto3,oneof" json:"inner_shadow,omitempty"`
+	InnerShadowWidth    *float64 `protobuf:"fixed64,108,opt,name=inner_shadow_width,json=innerShadowWidth,proto3,oneof" json:"inner_shadow_width,omitempty"`
+	Paper               *bool    `protobuf:"varint,109,opt,name=paper,proto3,oneof" json:"paper,omitempty"`
+	PaperOpacity        *float64 `protobuf:"fixed64,110,opt,name=paper_opacity,json=paperOpacity,proto3,oneof" json:"paper_opacity,omitempty"`
+	Sharpen             *bool    `protobuf:"varint,111,opt,name=sharpen,proto3,oneof" json:"sharpen,omitempty"`
+	SharpenAmount       *float64 `protobuf:"fixed64,112,opt,name=sharpen_amount,json=sharpenAmount,proto3,oneof" json:"sharpen_amount,omitempty"`
+	SharpenRadius       *float64 `protobuf:"fixed64,113,opt,name=sharpen_radius,json=sharpenRadius,proto3,oneof" json:"sharpen_radius,omitempty"`
+	Filter              *string  `protobuf:"bytes,114,opt,name=filter,proto3,oneof" json:"filter,omitempty"`
+	LinearLight         *bool    `protobuf:"varint,115,opt,name=linear_light,json=linearLight,proto3,oneof" json:"linear_light,omitempty"`
+	AvifQuality         *int32   `protobuf:"varint,116,opt,name=avif_quality,json=avifQuality,proto3,oneof" json:"avif_quality,omitempty"`
+	HighBitDepth        *bool    `protobuf:"varint,117,opt,name=high_bit_depth,json=highBitDepth,proto3,oneof" json:"high_bit_depth,omitempty"`
+	// Place art with transparent areas over flatten_colour (white by default) before scaling it.
+	Flatten       *bool   `protobuf:"varint,118,opt,name=flatten,proto3,oneof" json:"flatten,omitempty"`
+	FlattenColour *string `protobuf:"bytes,119,opt,name=flatten_colour,json=flattenColour,proto3,oneof" json:"flatten_colour,omitempty"`
+	Style         *string `protobuf:"bytes,30,opt,name=style,proto3,oneof" json:"style,omitempty"`
+	// Which photograph of the jewel case to use, from 1 to 4. Random if unset.
+	FrameVariant *int32 `protobuf:"varint,94,opt,name=frame_variant,json=frameVariant,proto3,oneof" json:"frame_variant,omitempty"`
+	// The colour of the jewel case tray: black, clear, smoke, blue, red, green or random.
+	Tray         *string  `protobuf:"bytes,96,opt,name=tray,proto3,oneof" json:"tray,omitempty"`
+	Crop         *string  `protobuf:"bytes,31,opt,name=crop,proto3,oneof" json:"crop,omitempty"`
+	MatteColour  *string  `protobuf:"bytes,32,opt,name=matte_colour,json=matteColour,proto3,oneof" json:"matte_colour,omitempty"`
+	TrackListing []string `protobuf:"bytes,33,rep,name=track_listing,json=trackListing,proto3" json:"track_listing,omitempty"`
+	// The UPC-A or EAN-13 printed on the back cover. Defaults to a random EAN-13.
+	Barcode          *string  `protobuf:"bytes,37,opt,name=barcode,proto3,oneof" json:"barcode,omitempty"`
+	SpineText        *string  `protobuf:"bytes,34,opt,name=spine_text,json=spineText,proto3,oneof" json:"spine_text,omitempty"`
+	SpineTextSize    *float64 `protobuf:"fixed64,35,opt,name=spine_text_size,json=spineTextSize,proto3,oneof" json:"spine_text_size,omitempty"`
+	SpineTextColour  *string  `protobuf:"bytes,36,opt,name=spine_text_colour,json=spineTextColour,proto3,oneof" json:"spine_text_colour,omitempty"`
+	ParentalAdvisory *bool    `protobuf:"varint,40,opt,name=parental_advisory,json=parentalAdvisory,proto3,oneof" json:"parental_advisory,omitempty"`
+	Obi              *bool    `protobuf:"varint,85,opt,name=obi,proto3,oneof" json:"obi,omitempty"`
+	ObiTitle         *string  `protobuf:"bytes,86,opt,name=obi_title,json=obiTitle,proto3,oneof" json:"obi_title,omitempty"`
+	ObiArtist        *string  `protobuf:"bytes,87,opt,name=obi_artist,json=obiArtist,proto3,oneof" json:"obi_artist,omitempty"`
+	ObiPrice         *string  `protobuf:"bytes,88,opt,name=obi_price,json=obiPrice,proto3,oneof" json:"obi_price,omitempty"`
+	ObiColour        *string  `protobuf:"bytes,89,opt,name=obi_colour,json=obiColour,proto3,oneof" json:"obi_colour,omitempty"`
+	// The paper stock of the obi strip: matte, gloss or washi.
+	ObiPaper          *string  `protobuf:"bytes,90,opt,name=obi_paper,json=obiPaper,proto3,oneof" json:"obi_paper,omitempty"`
+	HypeStickerText   *string  `protobuf:"bytes,41,opt,name=hype_sticker_text,json=hypeStickerText,proto3,oneof" json:"hype_sticker_text,omitempty"`
+	HypeStickerShape  *string  `protobuf:"bytes,42,opt,name=hype_sticker_shape,json=hypeStickerShape,proto3,oneof" json:"hype_sticker_shape,omitempty"`
+	HypeStickerColour *string  `protobuf:"bytes,43,opt,name=hype_sticker_colour,json=hypeStickerColour,proto3,oneof" json:"hype_sticker_colour,omitempty"`
+	HypeStickerSize   *float64 `protobuf:"fixed64,44,opt,name=hype_sticker_size,json=hypeStickerSize,proto3,oneof" json:"hype_sticker_size,omitempty"`
+	HypeStickerCorner *string  `protobuf:"bytes,45,opt,name=hype_sticker_corner,json=hypeStickerCorner,proto3,oneof" json:"hype_sticker_corner,omitempty"`
+	PriceSticker      *bool    `protobuf:"varint,46,opt,name=price_sticker,json=priceSticker,proto3,oneof" json:"price_sticker,omitempty"`
+	PriceText         *string  `protobuf:"bytes,47,opt,name=price_text,json=priceText,proto3,oneof" json:"price_text,omitempty"`
+	PriceCurrency     *string  `protobuf:"bytes,48,opt,name=price_currency,json=priceCurrency,proto3,oneof" json:"price_currency,omitempty"`
+	// The kind of price sticker: label or security.
+	PriceStyle        *string  `protobuf:"bytes,49,opt,name=price_style,json=priceStyle,proto3,oneof" json:"price_style,omitempty"`
+	ShopStickerText   *string  `protobuf:"bytes,80,opt,name=shop_sticker_text,json=shopStickerText,proto3,oneof" json:"shop_sticker_text,omitempty"`
+	ShopStickerColour *string  `protobuf:"bytes,81,opt,name=shop_sticker_colour,json=shopStickerColour,proto3,oneof" json:"shop_sticker_colour,omitempty"`
+	ShopStickerSize   *float64 `protobuf:"fixed64,82,opt,name=shop_sticker_size,json=shopStickerSize,proto3,oneof" json:"shop_sticker_size,omitempty"`
+	// The angle of the shop sticker in degrees, clockwise.
+	ShopStickerRotation *float64 `protobuf:"fixed64,83,opt,name=shop_sticker_rotation,json=shopStickerRotation,proto3,oneof" json:"shop_sticker_rotation,omitempty"`
+	ShopStickerCorner   *string  `protobuf:"bytes,84,opt,name=shop_sticker_corner,json=shopStickerCorner,proto3,oneof" json:"shop_sticker_corner,omitempty"`
+	Glare               *bool    `protobuf:"varint,50,opt,name=glare,proto3,oneof" json:"glare,omitempty"`
+	// The angle of the glare in degrees, clockwise from horizontal. Defaults to 35.
+	GlareAngle           *float64 `protobuf:"fixed64,51,opt,name=glare_angle,json=glareAngle,proto3,oneof" json:"glare_angle,omitempty"`
+	GlareWidth           *float64 `protobuf:"fixed64,52,opt,name=glare_width,json=glareWidth,proto3,oneof" json:"glare_width,omitempty"`
+	Scratches            *bool    `protobuf:"varint,53,opt,name=scratches,proto3,oneof" json:"scratches,omitempty"`
+	ScratchDensity       *float64 `protobuf:"fixed64,54,opt,name=scratch_density,json=scratchDensity,proto3,oneof" json:"scratch_density,omitempty"`
+	Fingerprints         *bool    `protobuf:"varint,58,opt,name=fingerprints,proto3,oneof" json:"fingerprints,omitempty"`
+	FingerprintIntensity *float64 `protobuf:"fixed64,59,opt,name=fingerprint_intensity,json=fingerprintIntensity,proto3,oneof" json:"fingerprint_intensity,omitempty"`
+	Dust                 *bool    `protobuf:"varint,63,opt,name=dust,proto3,oneof" json:"dust,omitempty"`
+	DustDensity          *float64 `protobuf:"fixed64,64,opt,name=dust_density,json=dustDensity,proto3,oneof" json:"dust_density,omitempty"`
+	Cracks               *bool    `protobuf:"varint,65,opt,name=cracks,proto3,oneof" json:"cracks,omitempty"`
+	CrackProbability     *float64 `protobuf:"fixed64,66,opt,name=crack_probability,json=crackProbability,proto3,oneof" json:"crack_probability,omitempty"`
+	ShrinkWrap           *bool    `protobuf:"varint,55,opt,name=shrink_wrap,json=shrinkWrap,proto3,oneof" json:"shrink_wrap,omitempty"`
+	Yellowing            *bool    `protobuf:"varint,97,opt,name=yellowing,proto3,oneof" json:"yellowing,omitempty"`
+	YellowingStrength    *float64 `protobuf:"fixed64,98,opt,name=yellowing_strength,json=yellowingStrength,proto3,oneof" json:"yellowing_strength,omitempty"`
+	Perspective          *bool    `protobuf:"varint,56,opt,name=perspective,proto3,oneof" json:"perspective,omitempty"`
+	PerspectiveTilt      *float64 `protobuf:"fixed64,57,opt,name=perspective_tilt,json=perspectiveTilt,proto3,oneof" json:"perspective_tilt,omitempty"`
+	Tilt                 *bool    `protobuf:"varint,91,opt,name=tilt,proto3,oneof" json:"tilt,omitempty"`
+	// How far to turn the case in degrees. Defaults to 20 and 5.
+	TiltYaw            *float64 `protobuf:"fixed64,92,opt,name=tilt_yaw,json=tiltYaw,proto3,oneof" json:"tilt_yaw,omitempty"`
+	TiltPitch          *float64 `protobuf:"fixed64,93,opt,name=tilt_pitch,json=tiltPitch,proto3,oneof" json:"tilt_pitch,omitempty"`
+	DropShadow         *bool    `protobuf:"varint,60,opt,name=drop_shadow,json=dropShadow,proto3,oneof" json:"drop_shadow,omitempty"`
+	BackgroundColour   *string  `protobuf:"bytes,61,opt,name=background_colour,json=backgroundColour,proto3,oneof" json:"background_colour,omitempty"`
+	BackgroundGradient *string  `protobuf:"bytes,62,opt,name=background_gradient,json=backgroundGradient,proto3,oneof" json:"background_gradient,omitempty"`
+	// The size to fit the output within. Either may be left unset to keep the aspect ratio.
+	OutputWidth  *int32 `protobuf:"varint,70,opt,name=output_width,json=outputWidth,proto3,oneof" json:"output_width,omitempty"`
+	OutputHeight *int32 `protobuf:"varint,71,opt,name=output_height,json=outputHeight,proto3,oneof" json:"output_height,omitempty"`
+	// Renders the jewel case at this multiple of its usual size, up to 3.
+	Scale           *int32 `protobuf:"varint,95,opt,name=scale,proto3,oneof" json:"scale,omitempty"`
+	JpegQuality     *int32 `protobuf:"varint,72,opt,name=jpeg_quality,json=jpegQuality,proto3,oneof" json:"jpeg_quality,omitempty"`
+	JpegProgressive *bool  `protobuf:"varint,73,opt,name=jpeg_progressive,json=jpegProgressive,proto3,oneof" json:"jpeg_progressive,omitempty"`
+	// PNG compression level: default, none, fast or best.
+	PngCompression *string `protobuf:"bytes,74,opt,name=png_compression,json=pngCompression,proto3,oneof" json:"png_compression,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Options) Reset() {
+	*x = Options{}
+	mi := &file_jewelcase_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Options) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Options) ProtoMessage() {}
+
+func (x *Options) ProtoReflect() protoreflect.Message {
+	mi := &file_jewelcase_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Options.ProtoReflect.Descriptor instead.
+func (*Options) Descriptor() ([]byte, []int) {
+	return file_jewelcase_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Options) GetPreset() string {
+	if x != nil {
+		return x.Preset
+	}
+	return ""
+}
+
+func (x *Options) GetSeed() int64 {
+	if x != nil && x.Seed != nil {
+		return *x.Seed
+	}
+	return 0
+}
+
+func (x *Options) GetSeedFromContent() bool {
+	if x != nil && x.SeedFromContent != nil {
+		return *x.SeedFromContent
+	}
+	return false
+}
+
+func (x *Options) GetForce() bool {
+	if x != nil && x.Force != nil {
+		return *x.Force
+	}
+	return false
+}
+
+func (x *Options) GetStripMetadata() bool {
+	if x != nil {
+		return x.StripMetadata
+	}
+	return false
+}
+
+func (x *Options) GetColourCorrection() bool {
+	if x != nil && x.ColourCorrection != nil {
+		return *x.ColourCorrection
+	}
+	return false
+}
+
+func (x *Options) GetRoundedCorners() bool {
+	if x != nil && x.RoundedCorners != nil {
+		return *x.RoundedCorners
+	}
+	return false
+}
+
+func (x *Options) GetEdgeSoftening() bool {
+	if x != nil && x.EdgeSoftening != nil {
+		return *x.EdgeSoftening
+	}
+	return false
+}
+
+func (x *Options) GetRandomOffset() bool {
+	if x != nil && x.RandomOffset != nil {
+		return *x.RandomOffset
+	}
+	return false
+}
+
+func (x *Options) GetRandomRotation() bool {
+	if x != nil && x.RandomRotation != nil {
+		return *x.RandomRotation
+	}
+	return false
+}
+
+func (x *Options) GetReflection() bool {
+	if x != nil && x.Reflection != nil {
+		return *x.Reflection
+	}
+	return false
+}
+
+func (x *Options) GetPreserveGrayscale() bool {
+	if x != nil && x.PreserveGrayscale != nil {
+		return *x.PreserveGrayscale
+	}
+	return false
+}
+
+func (x *Options) GetCornerRadiusMin() float64 {
+	if x != nil && x.CornerRadiusMin != nil {
+		return *x.CornerRadiusMin
+	}
+	return 0
+}
+
+func (x *Options) GetCornerRadiusMax() float64 {
+	if x != nil && x.CornerRadiusMax != nil {
+		return *x.CornerRadiusMax
+	}
+	return 0
+}
+
+func (x *Options) GetMaxRotation() float64 {
+	if x != nil && x.MaxRotation != nil {
+		return *x.MaxRotation
+	}
+	return 0
+}
+
+func (x *Options) GetMaxOffsetX() int32 {
+	if x != nil && x.MaxOffsetX != nil {
+		return *x.MaxOffsetX
+	}
+	return 0
+}
+
+func (x *Options) GetMaxOffsetY() int32 {
+	if x != nil && x.MaxOffsetY != nil {
+		return *x.MaxOffsetY
+	}
+	return 0
+}
+
+func (x *Options) GetReflectionStrength() float64 {
+	if x != nil && x.ReflectionStrength != nil {
+		return *x.ReflectionStrength
+	}
+	return 0
+}
+
+func (x *Options) GetTintAmount() float64 {
+	if x != nil && x.TintAmount != nil {
+		return *x.TintAmount
+	}
+	return 0
+}
+
+func (x *Options) GetTintColour() string {
+	if x != nil && x.TintColour != nil {
+		return *x.TintColour
+	}
+	return ""
+}
+
+func (x *Options) GetSaturationReduction() float64 {
+	if x != nil && x.SaturationReduction != nil {
+		return *x.SaturationReduction
+	}
+	return 0
+}
+
+func (x *Options) GetContrastReduction() float64 {
+	if x != nil && x.ContrastReduction != nil {
+		return *x.ContrastReduction
+	}
+	return 0
+}
+
+func (x *Options) GetGrain() bool {
+	if x != nil && x.Grain != nil {
+		return *x.Grain
+	}
+	return false
+}
+
+func (x *Options) GetGrainIntensity() float64 {
+	if x != nil && x.GrainIntensity != nil {
+		return *x.GrainIntensity
+	}
+	return 0
+}
+
+func (x *Options) GetVignette() bool {
+	if x != nil && x.Vignette != nil {
+		return *x.Vignette
+	}
+	return false
+}
+
+func (x *Options) GetVignetteStrength() float64 {
+	if x != nil && x.VignetteStrength != nil {
+		return *x.VignetteStrength
+	}
+	return 0
+}
+
+func (x *Options) GetReflectionAngle() float64 {
+	if x != nil && x.ReflectionAngle != nil {
+		return *x.ReflectionAngle
+	}
+	return 0
+}
+
+func (x *Options) GetReflectionWidth() float64 {
+	if x != nil && x.ReflectionWidth != nil {
+		return *x.ReflectionWidth
+	}
+	return 0
+}
+
+func (x *Options) GetDoubleReflection() bool {
+	if x != nil && x.DoubleReflection != nil {
+		return *x.DoubleReflection
+	}
+	return false
+}
+
+func (x *Options) GetInnerShadow() bool {
+	if x != nil && x.InnerShadow != nil {
+		return *x.InnerShadow
+	}
+	return false
+}
+
+func (x *Options) GetInnerShadowWidth() float64 {
+	if x != nil && x.InnerShadowWidth != nil {
+		return *x.InnerShadowWidth
+	}
+	return 0
+}
+
+func (x *Options) GetPaper() bool {
+	if x != nil && x.Paper != nil {
+		return *x.Paper
+	}
+	return false
+}
+
+func (x *Options) GetPaperOpacity() float64 {
+	if x != nil && x.PaperOpacity != nil {
+		return *x.PaperOpacity
+	}
+	return 0
+}
+
+func (x *Options) GetSharpen() bool {
+	if x != nil && x.Sharpen != nil {
+		return *x.Sharpen
+	}
+	return false
+}
+
+func (x *Options) GetSharpenAmount() float64 {
+	if x != nil && x.SharpenAmount != nil {
+		return *x.SharpenAmount
+	}
+	return 0
+}
+
+func (x *Options) GetSharpenRadius() float64 {
+	if x != nil && x.SharpenRadius != nil {
+		return *x.SharpenRadius
+	}
+	return 0
+}
+
+func (x *Options) GetFilter() string {
+	if x != nil && x.Filter != nil {
+		return *x.Filter
+	}
+	return ""
+}
+
+func (x *Options) GetLinearLight() bool {
+	if x != nil && x.LinearLight != nil {
+		return *x.LinearLight
+	}
+	return false
+}
+
+func (x *Options) GetAvifQuality() int32 {
+	if x != nil && x.AvifQuality != nil {
+		return *x.AvifQuality
+	}
+	return 0
+}
+
+func (x *Options) GetHighBitDepth() bool {
+	if x != nil && x.HighBitDepth != nil {
+		return *x.HighBitDepth
+	}
+	return false
+}
+
+func (x *Options) GetFlatten() bool {
+	if x != nil && x.Flatten != nil {
+		return *x.Flatten
+	}
+	return false
+}
+
+func (x *Options) GetFlattenColour() string {
+	if x != nil && x.FlattenColour != nil {
+		return *x.FlattenColour
+	}
+	return ""
+}
+
+func (x *Options) GetStyle() string {
+	if x != nil && x.Style != nil {
+		return *x.Style
+	}
+	return ""
+}
+
+func (x *Options) GetFrameVariant() int32 {
+	if x != nil && x.FrameVariant != nil {
+		return *x.FrameVariant
+	}
+	return 0
+}
+
+func (x *Options) GetTray() string {
+	if x != nil && x.Tray != nil {
+		return *x.Tray
+	}
+	return ""
+}
+
+func (x *Options) GetCrop() string {
+	if x != nil && x.Crop != nil {
+		return *x.Crop
+	}
+	return ""
+}
+
+func (x *Options) GetMatteColour() string {
+	if x != nil && x.MatteColour != nil {
+		return *x.MatteColour
+	}
+	return ""
+}
+
+func (x *Options) GetTrackListing() []string {
+	if x != nil {
+		return x.TrackListing
+	}
+	return nil
+}
+
+func (x *Options) GetBarcode() string {
+	if x != nil && x.Barcode != nil {
+		return *x.Barcode
+	}
+	return ""
+}
+
+func (x *Options) GetSpineText() string {
+	if x != nil && x.SpineText != nil {
+		return *x.SpineText
+	}
+	return ""
+}
+
+func (x *Options) GetSpineTextSize() float64 {
+	if x != nil && x.SpineTextSize != nil {
+		return *x.SpineTextSize
+	}
+	return 0
+}
+
+func (x *Options) GetSpineTextColour() string {
+	if x != nil && x.SpineTextColour != nil {
+		return *x.SpineTextColour
+	}
+	return ""
+}
+
+func (x *Options) GetParentalAdvisory() bool {
+	if x != nil && x.ParentalAdvisory != nil {
+		return *x.ParentalAdvisory
+	}
+	return false
+}
+
+func (x *Options) GetObi() bool {
+	if x != nil && x.Obi != nil {
+		return *x.Obi
+	}
+	return false
+}
+
+func (x *Options) GetObiTitle() string {
+	if x != nil && x.ObiTitle != nil {
+		return *x.ObiTitle
+	}
+	return ""
+}
+
+func (x *Options) GetObiArtist() string {
+	if x != nil && x.ObiArtist != nil {
+		return *x.ObiArtist
+	}
+	return ""
+}
+
+func (x *Options) GetObiPrice() string {
+	if x != nil && x.ObiPrice != nil {
+		return *x.ObiPrice
+	}
+	return ""
+}
+
+func (x *Options) GetObiColour() string {
+	if x != nil && x.ObiColour != nil {
+		return *x.ObiColour
+	}
+	return ""
+}
+
+func (x *Options) GetObiPaper() string {
+	if x != nil && x.ObiPaper != nil {
+		return *x.ObiPaper
+	}
+	return ""
+}
+
+func (x *Options) GetHypeStickerText() string {
+	if x != nil && x.HypeStickerText != nil {
+		return *x.HypeStickerText
+	}
+	return ""
+}
+
+func (x *Options) GetHypeStickerShape() string {
+	if x != nil && x.HypeStickerShape != nil {
+		return *x.HypeStickerShape
+	}
+	return ""
+}
+
+func (x *Options) GetHypeStickerColour() string {
+	if x != nil && x.HypeStickerColour != nil {
+		return *x.HypeStickerColour
+	}
+	return ""
+}
+
+func (x *Options) GetHypeStickerSize() float64 {
+	if x != nil && x.HypeStickerSize != nil {
+		return *x.HypeStickerSize
+	}
+	return 0
+}
+
+func (x *Options) GetHypeStickerCorner() string {
+	if x != nil && x.HypeStickerCorner != nil {
+		return *x.HypeStickerCorner
+	}
+	return ""
+}
+
+func (x *Options) GetPriceSticker() bool {
+	if x != nil && x.PriceSticker != nil {
+		return *x.PriceSticker
+	}
+	return false
+}
+
+func (x *Options) GetPriceText() string {
+	if x != nil && x.PriceText != nil {
+		return *x.PriceText
+	}
+	return ""
+}
+
+func (x *Options) GetPriceCurrency() string {
+	if x != nil && x.PriceCurrency != nil {
+		return *x.PriceCurrency
+	}
+	return ""
+}
+
+func (x *Options) GetPriceStyle() string {
+	if x != nil && x.PriceStyle != nil {
+		return *x.PriceStyle
+	}
+	return ""
+}
+
+func (x *Options) GetShopStickerText() string {
+	if x != nil && x.ShopStickerText != nil {
+		return *x.ShopStickerText
+	}
+	return ""
+}
+
+func (x *Options) GetShopStickerColour() string {
+	if x != nil && x.ShopStickerColour != nil {
+		return *x.ShopStickerColour
+	}
+	return ""
+}
+
+func (x *Options) GetShopStickerSize() float64 {
+	if x != nil && x.ShopStickerSize != nil {
+		return *x.ShopStickerSize
+	}
+	return 0
+}
+
+func (x *Options) GetShopStickerRotation() float64 {
+	if x != nil && x.ShopStickerRotation != nil {
+		return *x.ShopStickerRotation
+	}
+	return 0
+}
+
+func (x *Options) GetShopStickerCorner() string {
+	if x != nil && x.ShopStickerCorner != nil {
+		return *x.ShopStickerCorner
+	}
+	return ""
+}
+
+func (x *Options) GetGlare() bool {
+	if x != nil && x.Glare != nil {
+		return *x.Glare
+	}
+	return false
+}
+
+func (x *Options) GetGlareAngle() float64 {
+	if x != nil && x.GlareAngle != nil {
+		return *x.GlareAngle
+	}
+	return 0
+}
+
+func (x *Options) GetGlareWidth() float64 {
+	if x != nil && x.GlareWidth != nil {
+		return *x.GlareWidth
+	}
+	return 0
+}
+
+func (x *Options) GetScratches() bool {
+	if x != nil && x.Scratches != nil {
+		return *x.Scratches
+	}
+	return false
+}
+
+func (x *Options) GetScratchDensity() float64 {
+	if x != nil && x.ScratchDensity != nil {
+		return *x.ScratchDensity
+	}
+	return 0
+}
+
+func (x *Options) GetFingerprints() bool {
+	if x != nil && x.Fingerprints != nil {
+		return *x.Fingerprints
+	}
+	return false
+}
+
+func (x *Options) GetFingerprintIntensity() float64 {
+	if x != nil && x.FingerprintIntensity != nil {
+		return *x.FingerprintIntensity
+	}
+	return 0
+}
+
+func (x *Options) GetDust() bool {
+	if x != nil && x.Dust != nil {
+		return *x.Dust
+	}
+	return false
+}
+
+func (x *Options) GetDustDensity() float64 {
+	if x != nil && x.DustDensity != nil {
+		return *x.DustDensity
+	}
+	return 0
+}
+
+func (x *Options) GetCracks() bool {
+	if x != nil && x.Cracks != nil {
+		return *x.Cracks
+	}
+	return false
+}
+
+func (x *Options) GetCrackProbability() float64 {
+	if x != nil && x.CrackProbability != nil {
+		return *x.CrackProbability
+	}
+	return 0
+}
+
+func (x *Options) GetShrinkWrap() bool {
+	if x != nil && x.ShrinkWrap != nil {
+		return *x.ShrinkWrap
+	}
+	return false
+}
+
+func (x *Options) GetYellowing() bool {
+	if x != nil && x.Yellowing != nil {
+		return *x.Yellowing
+	}
+	return false
+}
+
+func (x *Options) GetYellowingStrength() float64 {
+	if x != nil && x.YellowingStrength != nil {
+		return *x.YellowingStrength
+	}
+	return 0
+}
+
+func (x *Options) GetPerspective() bool {
+	if x != nil && x.Perspective != nil {
+		return *x.Perspective
+	}
+	return false
+}
+
+func (x *Options) GetPerspectiveTilt() float64 {
+	if x != nil && x.PerspectiveTilt != nil {
+		return *x.PerspectiveTilt
+	}
+	return 0
+}
+
+func (x *Options) GetTilt() bool {
+	if x != nil && x.Tilt != nil {
+		return *x.Tilt
+	}
+	return false
+}
+
+func (x *Options) GetTiltYaw() float64 {
+	if x != nil && x.TiltYaw != nil {
+		return *x.TiltYaw
+	}
+	return 0
+}
+
+func (x *Options) GetTiltPitch() float64 {
+	if x != nil && x.TiltPitch != nil {
+		return *x.TiltPitch
+	}
+	return 0
+}
+
+func (x *Options) GetDropShadow() bool {
+	if x != nil && x.DropShadow != nil {
+		return *x.DropShadow
+	}
+	return false
+}
+
+func (x *Options) GetBackgroundColour() string {
+	if x != nil && x.BackgroundColour != nil {
+		return *x.BackgroundColour
+	}
+	return ""
+}
+
+func (x *Options) GetBackgroundGradient() string {
+	if x != nil && x.BackgroundGradient != nil {
+		return *x.BackgroundGradient
+	}
+	return ""
+}
+
+func (x *Options) GetOutputWidth() int32 {
+	if x != nil && x.OutputWidth != nil {
+		return *x.OutputWidth
+	}
+	return 0
+}
+
+func (x *Options) GetOutputHeight() int32 {
+	if x != nil && x.OutputHeight != nil {
+		return *x.OutputHeight
+	}
+	return 0
+}
+
+func (x *Options) GetScale() int32 {
+	if x != nil && x.Scale != nil {
+		return *x.Scale
+	}
+	return 0
+}
+
+func (x *Options) GetJpegQuality() int32 {
+	if x != nil && x.JpegQuality != nil {
+		return *x.JpegQuality
+	}
+	return 0
+}
+
+func (x *Options) GetJpegProgressive() bool {
+	if x != nil && x.JpegProgressive != nil {
+		return *x.JpegProgressive
+	}
+	return false
+}
+
+func (x *Options) GetPngCompression() string {
+	if x != nil && x.PngCompression != nil {
+		return *x.PngCompression
+	}
+	return ""
+}
+
+var File_jewelcase_proto protoreflect.FileDescriptor
+
+const file_jewelcase_proto_rawDesc = "" +
+	"\n" +
+	"\x0fjewelcase.proto\x12\fjewelcase.v1\x1a\x17google/rpc/status.proto\"\x7f\n" +
+	"\x0eProcessRequest\x12\x14\n" +
+	"\x05image\x18\x01 \x01(\fR\x05image\x12\x16\n" +
+	"\x06format\x18\x02 \x01(\tR\x06format\x12/\n" +
+	"\aoptions\x18\x03 \x01(\v2\x15.jewelcase.v1.OptionsR\aoptions\x12\x0e\n" +
+	"\x02id\x18\x04 \x01(\tR\x02id\"y\n" +
+	"\x0fProcessResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05image\x18\x02 \x01(\fR\x05image\x12\x16\n" +
+	"\x06format\x18\x03 \x01(\tR\x06format\x12(\n" +
+	"\x05error\x18\x04 \x01(\v2\x12.google.rpc.StatusR\x05error\"\xf1-\n" +
+	"\aOptions\x12\x16\n" +
+	"\x06preset\x18\x01 \x01(\tR\x06preset\x12\x17\n" +
+	"\x04seed\x18\x02 \x01(\x03H\x00R\x04seed\x88\x01\x01\x12/\n" +
+	"\x11seed_from_content\x18\x03 \x01(\bH\x01R\x0fseedFromContent\x88\x01\x01\x12\x19\n" +
+	"\x05force\x18\x04 \x01(\bH\x02R\x05force\x88\x01\x01\x12%\n" +
+	"\x0estrip_metadata\x18\x05 \x01(\bR\rstripMetadata\x120\n" +
+	"\x11colour_correction\x18\n" +
+	" \x01(\bH\x03R\x10colourCorrection\x88\x01\x01\x12,\n" +
+	"\x0frounded_corners\x18\v \x01(\bH\x04R\x0eroundedCorners\x88\x01\x01\x12*\n" +
+	"\x0eedge_softening\x18\f \x01(\bH\x05R\redgeSoftening\x88\x01\x01\x12(\n" +
+	"\rrandom_offset\x18\r \x01(\bH\x06R\frandomOffset\x88\x01\x01\x12,\n" +
+	"\x0frandom_rotation\x18\x0e \x01(\bH\aR\x0erandomRotation\x88\x01\x01\x12#\n" +
+	"\n" +
+	"reflection\x18\x0f \x01(\bH\bR\n" +
+	"reflection\x88\x01\x01\x122\n" +
+	"\x12preserve_grayscale\x18\x10 \x01(\bH\tR\x11preserveGrayscale\x88\x01\x01\x12/\n" +
+	"\x11corner_radius_min\x18\x11 \x01(\x01H\n" +
+	"R\x0fcornerRadiusMin\x88\x01\x01\x12/\n" +
+	"\x11corner_radius_max\x18\x12 \x01(\x01H\vR\x0fcornerRadiusMax\x88\x01\x01\x12&\n" +
+	"\fmax_rotation\x18\x13 \x01(\x01H\fR\vmaxRotation\x88\x01\x01\x12%\n" +
+	"\fmax_offset_x\x18\x14 \x01(\x05H\rR\n" +
+	"maxOffsetX\x88\x01\x01\x12%\n" +
+	"\fmax_offset_y\x18\x15 \x01(\x05H\x0eR\n" +
+	"maxOffsetY\x88\x01\x01\x124\n" +
+	"\x13reflection_strength\x18\x16 \x01(\x01H\x0fR\x12reflectionStrength\x88\x01\x01\x12$\n" +
+	"\vtint_amount\x18\x17 \x01(\x01H\x10R\n" +
+	"tintAmount\x88\x01\x01\x12$\n" +
+	"\vtint_colour\x18c \x01(\tH\x11R\n" +
+	"tintColour\x88\x01\x01\x126\n" +
+	"\x14saturation_reduction\x18d \x01(\x01H\x12R\x13saturationReduction\x88\x01\x01\x122\n" +
+	"\x12contrast_reduction\x18e \x01(\x01H\x13R\x11contrastReduction\x88\x01\x01\x12\x19\n" +
+	"\x05grain\x18\x18 \x01(\bH\x14R\x05grain\x88\x01\x01\x12,\n" +
+	"\x0fgrain_intensity\x18\x19 \x01(\x01H\x15R\x0egrainIntensity\x88\x01\x01\x12\x1f\n" +
+	"\bvignette\x18f \x01(\bH\x16R\bvignette\x88\x01\x01\x120\n" +
+	"\x11vignette_strength\x18g \x01(\x01H\x17R\x10vignetteStrength\x88\x01\x01\x12.\n" +
+	"\x10reflection_angle\x18h \x01(\x01H\x18R\x0freflectionAngle\x88\x01\x01\x12.\n" +
+	"\x10reflection_width\x18i \x01(\x01H\x19R\x0freflectionWidth\x88\x01\x01\x120\n" +
+	"\x11double_reflection\x18j \x01(\bH\x1aR\x10doubleReflection\x88\x01\x01\x12&\n" +
+	"\finner_shadow\x18k \x01(\bH\x1bR\vinnerShadow\x88\x01\x01\x121\n" +
+	"\x12inner_shadow_width\x18l \x01(\x01H\x1cR\x10innerShadowWidth\x88\x01\x01\x12\x19\n" +
+	"\x05paper\x18m \x01(\bH\x1dR\x05paper\x88\x01\x01\x12(\n" +
+	"\rpaper_opacity\x18n \x01(\x01H\x1eR\fpaperOpacity\x88\x01\x01\x12\x1d\n" +
+	"\asharpen\x18o \x01(\bH\x1fR\asharpen\x88\x01\x01\x12*\n" +
+	"\x0esharpen_amount\x18p \x01(\x01H R\rsharpenAmount\x88\x01\x01\x12*\n" +
+	"\x0esharpen_radius\x18q \x01(\x01H!R\rsharpenRadius\x88\x01\x01\x12\x1b\n" +
+	"\x06filter\x18r \x01(\tH\"R\x06filter\x88\x01\x01\x12&\n" +
+	"\flinear_light\x18s \x01(\bH#R\vlinearLight\x88\x01\x01\x12&\n" +
+	"\favif_quality\x18t \x01(\x05H$R\vavifQuality\x88\x01\x01\x12)\n" +
+	"\x0ehigh_bit_depth\x18u \x01(\bH%R\fhighBitDepth\x88\x01\x01\x12\x1d\n" +
+	"\aflatten\x18v \x01(\bH&R\aflatten\x88\x01\x01\x12*\n" +
+	"\x0eflatten_colour\x18w \x01(\tH'R\rflattenColour\x88\x01\x01\x12\x19\n" +
+	"\x05style\x18\x1e \x01(\tH(R\x05style\x88\x01\x01\x12(\n" +
+	"\rframe_variant\x18^ \x01(\x05H)R\fframeVariant\x88\x01\x01\x12\x17\n" +
+	"\x04tray\x18` \x01(\tH*R\x04tray\x88\x01\x01\x12\x17\n" +
+	"\x04crop\x18\x1f \x01(\tH+R\x04crop\x88\x01\x01\x12&\n" +
+	"\fmatte_colour\x18  \x01(\tH,R\vmatteColour\x88\x01\x01\x12#\n" +
+	"\rtrack_listing\x18! \x03(\tR\ftrackListing\x12\x1d\n" +
+	"\abarcode\x18% \x01(\tH-R\abarcode\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"spine_text\x18\" \x01(\tH.R\tspineText\x88\x01\x01\x12+\n" +
+	"\x0fspine_text_size\x18# \x01(\x01H/R\rspineTextSize\x88\x01\x01\x12/\n" +
+	"\x11spine_text_colour\x18$ \x01(\tH0R\x0fspineTextColour\x88\x01\x01\x120\n" +
+	"\x11parental_advisory\x18( \x01(\bH1R\x10parentalAdvisory\x88\x01\x01\x12\x15\n" +
+	"\x03obi\x18U \x01(\bH2R\x03obi\x88\x01\x01\x12 \n" +
+	"\tobi_title\x18V \x01(\tH3R\bobiTitle\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"obi_artist\x18W \x01(\tH4R\tobiArtist\x88\x01\x01\x12 \n" +
+	"\tobi_price\x18X \x01(\tH5R\bobiPrice\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"obi_colour\x18Y \x01(\tH6R\tobiColour\x88\x01\x01\x12 \n" +
+	"\tobi_paper\x18Z \x01(\tH7R\bobiPaper\x88\x01\x01\x12/\n" +
+	"\x11hype_sticker_text\x18) \x01(\tH8R\x0fhypeStickerText\x88\x01\x01\x121\n" +
+	"\x12hype_sticker_shape\x18* \x01(\tH9R\x10hypeStickerShape\x88\x01\x01\x123\n" +
+	"\x13hype_sticker_colour\x18+ \x01(\tH:R\x11hypeStickerColour\x88\x01\x01\x12/\n" +
+	"\x11hype_sticker_size\x18, \x01(\x01H;R\x0fhypeStickerSize\x88\x01\x01\x123\n" +
+	"\x13hype_sticker_corner\x18- \x01(\tH<R\x11hypeStickerCorner\x88\x01\x01\x12(\n" +
+	"\rprice_sticker\x18. \x01(\bH=R\fpriceSticker\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"price_text\x18/ \x01(\tH>R\tpriceText\x88\x01\x01\x12*\n" +
+	"\x0eprice_currency\x180 \x01(\tH?R\rpriceCurrency\x88\x01\x01\x12$\n" +
+	"\vprice_style\x181 \x01(\tH@R\n" +
+	"priceStyle\x88\x01\x01\x12/\n" +
+	"\x11shop_sticker_text\x18P \x01(\tHAR\x0fshopStickerText\x88\x01\x01\x123\n" +
+	"\x13shop_sticker_colour\x18Q \x01(\tHBR\x11shopStickerColour\x88\x01\x01\x12/\n" +
+	"\x11shop_sticker_size\x18R \x01(\x01HCR\x0fshopStickerSize\x88\x01\x01\x127\n" +
+	"\x15shop_sticker_rotation\x18S \x01(\x01HDR\x13shopStickerRotation\x88\x01\x01\x123\n" +
+	"\x13shop_sticker_corner\x18T \x01(\tHER\x11shopStickerCorner\x88\x01\x01\x12\x19\n" +
+	"\x05glare\x182 \x01(\bHFR\x05glare\x88\x01\x01\x12$\n" +
+	"\vglare_angle\x183 \x01(\x01HGR\n" +
+	"glareAngle\x88\x01\x01\x12$\n" +
+	"\vglare_width\x184 \x01(\x01HHR\n" +
+	"glareWidth\x88\x01\x01\x12!\n" +
+	"\tscratches\x185 \x01(\bHIR\tscratches\x88\x01\x01\x12,\n" +
+	"\x0fscratch_density\x186 \x01(\x01HJR\x0escratchDensity\x88\x01\x01\x12'\n" +
+	"\ffingerprints\x18: \x01(\bHKR\ffingerprints\x88\x01\x01\x128\n" +
+	"\x15fingerprint_intensity\x18; \x01(\x01HLR\x14fingerprintIntensity\x88\x01\x01\x12\x17\n" +
+	"\x04dust\x18? \x01(\bHMR\x04dust\x88\x01\x01\x12&\n" +
+	"\fdust_density\x18@ \x01(\x01HNR\vdustDensity\x88\x01\x01\x12\x1b\n" +
+	"\x06cracks\x18A \x01(\bHOR\x06cracks\x88\x01\x01\x120\n" +
+	"\x11crack_probability\x18B \x01(\x01HPR\x10crackProbability\x88\x01\x01\x12$\n" +
+	"\vshrink_wrap\x187 \x01(\bHQR\n" +
+	"shrinkWrap\x88\x01\x01\x12!\n" +
+	"\tyellowing\x18a \x01(\bHRR\tyellowing\x88\x01\x01\x122\n" +
+	"\x12yellowing_strength\x18b \x01(\x01HSR\x11yellowingStrength\x88\x01\x01\x12%\n" +
+	"\vperspective\x188 \x01(\bHTR\vperspective\x88\x01\x01\x12.\n" +
+	"\x10perspective_tilt\x189 \x01(\x01HUR\x0fperspectiveTilt\x88\x01\x01\x12\x17\n" +
+	"\x04tilt\x18[ \x01(\bHVR\x04tilt\x88\x01\x01\x12\x1e\n" +
+	"\btilt_yaw\x18\\ \x01(\x01HWR\atiltYaw\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"tilt_pitch\x18] \x01(\x01HXR\ttiltPitch\x88\x01\x01\x12$\n" +
+	"\vdrop_shadow\x18< \x01(\bHYR\n" +
+	"dropShadow\x88\x01\x01\x120\n" +
+	"\x11background_colour\x18= \x01(\tHZR\x10backgroundColour\x88\x01\x01\x124\n" +
+	"\x13background_gradient\x18> \x01(\tH[R\x12backgroundGradient\x88\x01\x01\x12&\n" +
+	"\foutput_width\x18F \x01(\x05H\\R\voutputWidth\x88\x01\x01\x12(\n" +
+	"\routput_height\x18G \x01(\x05H]R\foutputHeight\x88\x01\x01\x12\x19\n" +
+	"\x05scale\x18_ \x01(\x05H^R\x05scale\x88\x01\x01\x12&\n" +
+	"\fjpeg_quality\x18H \x01(\x05H_R\vjpegQuality\x88\x01\x01\x12.\n" +
+	"\x10jpeg_progressive\x18I \x01(\bH`R\x0fjpegProgressive\x88\x01\x01\x12,\n" +
+	"\x0fpng_compression\x18J \x01(\tHaR\x0epngCompression\x88\x01\x01B\a\n" +
+	"\x05_seedB\x14\n" +
+	"\x12_seed_from_contentB\b\n" +
+	"\x06_forceB\x14\n" +
+	"\x12_colour_correctionB\x12\n" +
+	"\x10_rounded_cornersB\x11\n" +
+	"\x0f_edge_softeningB\x10\n" +
+	"\x0e_random_offsetB\x12\n" +
+	"\x10_random_rotationB\r\n" +
+	"\v_reflectionB\x15\n" +
+	"\x13_preserve_grayscaleB\x14\n" +
+	"\x12_corner_radius_minB\x14\n" +
+	"\x12_corner_radius_maxB\x0f\n" +
+	"\r_max_rotationB\x0f\n" +
+	"\r_max_offset_xB\x0f\n" +
+	"\r_max_offset_yB\x16\n" +
+	"\x14_reflection_strengthB\x0e\n" +
+	"\f_tint_amountB\x0e\n" +
+	"\f_tint_colourB\x17\n" +
+	"\x15_saturation_reductionB\x15\n" +
+	"\x13_contrast_reductionB\b\n" +
+	"\x06_grainB\x12\n" +
+	"\x10_grain_intensityB\v\n" +
+	"\t_vignetteB\x14\n" +
+	"\x12_vignette_strengthB\x13\n" +
+	"\x11_reflection_angleB\x13\n" +
+	"\x11_reflection_widthB\x14\n" +
+	"\x12_double_reflectionB\x0f\n" +
+	"\r_inner_shadowB\x15\n" +
+	"\x13_inner_shadow_widthB\b\n" +
+	"\x06_paperB\x10\n" +
+	"\x0e_paper_opacityB\n" +
+	"\n" +
+	"\b_sharpenB\x11\n" +
+	"\x0f_sharpen_amountB\x11\n" +
+	"\x0f_sharpen_radiusB\t\n" +
+	"\a_filterB\x0f\n" +
+	"\r_linear_lightB\x0f\n" +
+	"\r_avif_qualityB\x11\n" +
+	"\x0f_high_bit_depthB\n" +
+	"\n" +
+	"\b_flattenB\x11\n" +
+	"\x0f_flatten_colourB\b\n" +
+	"\x06_styleB\x10\n" +
+	"\x0e_frame_variantB\a\n" +
+	"\x05_trayB\a\n" +
+	"\x05_cropB\x0f\n" +
+	"\r_matte_colourB\n" +
+	"\n" +
+	"\b_barcodeB\r\n" +
+	"\v_spine_textB\x12\n" +
+	"\x10_spine_text_sizeB\x14\n" +
+	"\x12_spine_text_colourB\x14\n" +
+	"\x12_parental_advisoryB\x06\n" +
+	"\x04_obiB\f\n" +
+	"\n" +
+	"_obi_titleB\r\n" +
+	"\v_obi_artistB\f\n" +
+	"\n" +
+	"_obi_priceB\r\n" +
+	"\v_obi_colourB\f\n" +
+	"\n" +
+	"_obi_paperB\x14\n" +
+	"\x12_hype_sticker_textB\x15\n" +
+	"\x13_hype_sticker_shapeB\x16\n" +
+	"\x14_hype_sticker_colourB\x14\n" +
+	"\x12_hype_sticker_sizeB\x16\n" +
+	"\x14_hype_sticker_cornerB\x10\n" +
+	"\x0e_price_stickerB\r\n" +
+	"\v_price_textB\x11\n" +
+	"\x0f_price_currencyB\x0e\n" +
+	"\f_price_styleB\x14\n" +
+	"\x12_shop_sticker_textB\x16\n" +
+	"\x14_shop_sticker_colourB\x14\n" +
+	"\x12_shop_sticker_sizeB\x18\n" +
+	"\x16_shop_sticker_rotationB\x16\n" +
+	"\x14_shop_sticker_cornerB\b\n" +
+	"\x06_glareB\x0e\n" +
+	"\f_glare_angleB\x0e\n" +
+	"\f_glare_widthB\f\n" +
+	"\n" +
+	"_scratchesB\x12\n" +
+	"\x10_scratch_densityB\x0f\n" +
+	"\r_fingerprintsB\x18\n" +
+	"\x16_fingerprint_intensityB\a\n" +
+	"\x05_dustB\x0f\n" +
+	"\r_dust_densityB\t\n" +
+	"\a_cracksB\x14\n" +
+	"\x12_crack_probabilityB\x0e\n" +
+	"\f_shrink_wrapB\f\n" +
+	"\n" +
+	"_yellowingB\x15\n" +
+	"\x13_yellowing_strengthB\x0e\n" +
+	"\f_perspectiveB\x13\n" +
+	"\x11_perspective_tiltB\a\n" +
+	"\x05_tiltB\v\n" +
+	"\t_tilt_yawB\r\n" +
+	"\v_tilt_pitchB\x0e\n" +
+	"\f_drop_shadowB\x14\n" +
+	"\x12_background_colourB\x16\n" +
+	"\x14_background_gradientB\x0f\n" +
+	"\r_output_widthB\x10\n" +
+	"\x0e_output_heightB\b\n" +
+	"\x06_scaleB\x0f\n" +
+	"\r_jpeg_qualityB\x13\n" +
+	"\x11_jpeg_progressiveB\x12\n" +
+	"\x10_png_compression2\xa4\x01\n" +
+	"\tJewelcase\x12F\n" +
+	"\aProcess\x12\x1c.jewelcase.v1.ProcessRequest\x1a\x1d.jewelcase.v1.ProcessResponse\x12O\n" +
+	"\fProcessBatch\x12\x1c.jewelcase.v1.ProcessRequest\x1a\x1d.jewelcase.v1.ProcessResponse(\x010\x01B(Z&github.com/csmith/jewelcase/grpcserverb\x06proto3"
+
+var (
+	file_jewelcase_proto_rawDescOnce sync.Once
+	file_jewelcase_proto_rawDescData []byte
+)
+
+func file_jewelcase_proto_rawDescGZIP() []byte {
+	file_jewelcase_proto_rawDescOnce.Do(func() {
+		file_jewelcase_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_jewelcase_proto_rawDesc), len(file_jewelcase_proto_rawDesc)))
+	})
+	return file_jewelcase_proto_rawDescData
+}
+
+var file_jewelcase_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_jewelcase_proto_goTypes = []any{
+	(*ProcessRequest)(nil),  // 0: jewelcase.v1.ProcessRequest
+	(*ProcessResponse)(nil), // 1: jewelcase.v1.ProcessResponse
+	(*Options)(nil),         // 2: jewelcase.v1.Options
+	(*status.Status)(nil),   // 3: google.rpc.Status
+}
+var file_jewelcase_proto_depIdxs = []int32{
+	2, // 0: jewelcase.v1.ProcessRequest.options:type_name -> jewelcase.v1.Options
+	3, // 1: jewelcase.v1.ProcessResponse.error:type_name -> google.rpc.Status
+	0, // 2: jewelcase.v1.Jewelcase.Process:input_type -> jewelcase.v1.ProcessRequest
+	0, // 3: jewelcase.v1.Jewelcase.ProcessBatch:input_type -> jewelcase.v1.ProcessRequest
+	1, // 4: jewelcase.v1.Jewelcase.Process:output_type -> jewelcase.v1.ProcessResponse
+	1, // 5: jewelcase.v1.Jewelcase.ProcessBatch:output_type -> jewelcase.v1.ProcessResponse
+	4, // [4:6] is the sub-list for method output_type
+	2, // [2:4] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_jewelcase_proto_init() }
+func file_jewelcase_proto_init() {
+	if File_jewelcase_proto != nil {
+		return
+	}
+	file_jewelcase_proto_msgTypes[2].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_jewelcase_proto_rawDesc), len(file_jewelcase_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_jewelcase_proto_goTypes,
+		DependencyIndexes: file_jewelcase_proto_depIdxs,
+		MessageInfos:      file_jewelcase_proto_msgTypes,
+	}.Build()
+	File_jewelcase_proto = out.File
+	file_jewelcase_proto_goTypes = nil
+	file_jewelcase_proto_depIdxs = nil
+}
@@ -0,0 +1,168 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: jewelcase.proto
+
+package grpcserver
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Jewelcase_Process_FullMethodName      = "/jewelcase.v1.Jewelcase/Process"
+	Jewelcase_ProcessBatch_FullMethodName = "/jewelcase.v1.Jewelcase/ProcessBatch"
+)
+
+// JewelcaseClient is the client API for Jewelcase service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Jewelcase applies the jewel case effect to album art.
+type JewelcaseClient interface {
+	// Process applies the effect to a single image. Images that have already been
+	// processed are rejected with ALREADY_EXISTS, unless options.force is set.
+	Process(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*ProcessResponse, error)
+	// ProcessBatch applies the effect to each image sent on the stream, and sends back a
+	// response for each one in the same order. Failures are reported in the response's
+	// error, rather than ending the stream.
+	ProcessBatch(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ProcessRequest, ProcessResponse], error)
+}
+
+type jewelcaseClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewJewelcaseClient(cc grpc.ClientConnInterface) JewelcaseClient {
+	return &jewelcaseClient{cc}
+}
+
+func (c *jewelcaseClient) Process(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*ProcessResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProcessResponse)
+	err := c.cc.Invoke(ctx, Jewelcase_Process_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jewelcaseClient) ProcessBatch(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ProcessRequest, ProcessResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Jewelcase_ServiceDesc.Streams[0], Jewelcase_ProcessBatch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ProcessRequest, ProcessResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Jewelcase_ProcessBatchClient = grpc.BidiStreamingClient[ProcessRequest, ProcessResponse]
+
+// JewelcaseServer is the server API for Jewelcase service.
+// All implementations must embed UnimplementedJewelcaseServer
+// for forward compatibility.
+//
+// Jewelcase applies the jewel case effect to album art.
+type JewelcaseServer interface {
+	// Process applies the effect to a single image. Images that have already been
+	// processed are rejected with ALREADY_EXISTS, unless options.force is set.
+	Process(context.Context, *ProcessRequest) (*ProcessResponse, error)
+	// ProcessBatch applies the effect to each image sent on the stream, and sends back a
+	// response for each one in the same order. Failures are reported in the response's
+	// error, rather than ending the stream.
+	ProcessBatch(grpc.BidiStreamingServer[ProcessRequest, ProcessResponse]) error
+	mustEmbedUnimplementedJewelcaseServer()
+}
+
+// UnimplementedJewelcaseServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedJewelcaseServer struct{}
+
+func (UnimplementedJewelcaseServer) Process(context.Context, *ProcessRequest) (*ProcessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Process not implemented")
+}
+func (UnimplementedJewelcaseServer) ProcessBatch(grpc.BidiStreamingServer[ProcessRequest, ProcessResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ProcessBatch not implemented")
+}
+func (UnimplementedJewelcaseServer) mustEmbedUnimplementedJewelcaseServer() {}
+func (UnimplementedJewelcaseServer) testEmbeddedByValue()                   {}
+
+// UnsafeJewelcaseServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to JewelcaseServer will
+// result in compilation errors.
+type UnsafeJewelcaseServer interface {
+	mustEmbedUnimplementedJewelcaseServer()
+}
+
+func RegisterJewelcaseServer(s grpc.ServiceRegistrar, srv JewelcaseServer) {
+	// If the following call pancis, it indicates UnimplementedJewelcaseServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Jewelcase_ServiceDesc, srv)
+}
+
+func _Jewelcase_Process_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProcessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JewelcaseServer).Process(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Jewelcase_Process_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JewelcaseServer).Process(ctx, req.(*ProcessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Jewelcase_ProcessBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(JewelcaseServer).ProcessBatch(&grpc.GenericServerStream[ProcessRequest, ProcessResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Jewelcase_ProcessBatchServer = grpc.BidiStreamingServer[ProcessRequest, ProcessResponse]
+
+// Jewelcase_ServiceDesc is the grpc.ServiceDesc for Jewelcase service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Jewelcase_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "jewelcase.v1.Jewelcase",
+	HandlerType: (*JewelcaseServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Process",
+			Handler:    _Jewelcase_Process_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ProcessBatch",
+			Handler:       _Jewelcase_ProcessBatch_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "jewelcase.proto",
+}
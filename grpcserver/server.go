@@ -0,0 +1,301 @@
+// Package grpcserver provides a gRPC service that applies the jewel case effect to images,
+// so that it can be used from other languages. The service is defined in jewelcase.proto.
+package grpcserver
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative jewelcase.proto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"log"
+	"math/rand"
+	"strings"
+
+	"github.com/csmith/jewelcase"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements the Jewelcase service.
+type Server struct {
+	UnimplementedJewelcaseServer
+
+	// MaxInputPixels is the largest image, in pixels, that the server will process; see
+	// jewelcase.Options.MaxInputPixels. Clients can't change it
+	MaxInputPixels int
+}
+
+// Register adds the Jewelcase service to a gRPC server.
+func Register(s *grpc.Server) {
+	RegisterJewelcaseServer(s, &Server{})
+}
+
+// Process applies the jewel case effect to a single image.
+func (s *Server) Process(ctx context.Context, req *ProcessRequest) (*ProcessResponse, error) {
+	return s.process(ctx, req)
+}
+
+// ProcessBatch applies the jewel case effect to each image received on the stream,
+// replying to each in turn, until the client closes its side of the stream.
+func (s *Server) ProcessBatch(stream Jewelcase_ProcessBatchServer) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		resp, err := s.process(stream.Context(), req)
+		if err != nil {
+			if stream.Context().Err() != nil {
+				return status.FromContextError(stream.Context().Err()).Err()
+			}
+			resp = &ProcessResponse{Id: req.GetId(), Error: status.Convert(err).Proto()}
+		}
+
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// process handles a single request, returning a gRPC status error if it fails.
+func (s *Server) process(ctx context.Context, req *ProcessRequest) (*ProcessResponse, error) {
+	opts, err := options(req.GetOptions())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	opts.MaxInputPixels = s.MaxInputPixels
+
+	format := req.GetFormat()
+	if format == "" {
+		format = "png"
+	}
+
+	var output bytes.Buffer
+	err = jewelcase.ProcessReaderContext(ctx, bytes.NewReader(req.GetImage()), &output, format, opts)
+
+	var unsupported *jewelcase.UnsupportedFormatError
+	switch {
+	case err == nil:
+		return &ProcessResponse{Id: req.GetId(), Image: output.Bytes(), Format: format}, nil
+	case ctx.Err() != nil:
+		return nil, status.FromContextError(ctx.Err()).Err()
+	case errors.Is(err, jewelcase.ErrAlreadyProcessed):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	case errors.As(err, &unsupported), errors.Is(err, image.ErrFormat), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, jewelcase.ErrImageTooLarge):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	default:
+		log.Printf("Error processing image: %v", err)
+		return nil, status.Error(codes.Internal, "error processing image")
+	}
+}
+
+// options converts the options from a request into jewelcase.Options, starting from the
+// requested preset and overriding it with any fields that are set.
+func options(o *Options) (jewelcase.Options, error) {
+	presetName := o.GetPreset()
+	if presetName == "" {
+		presetName = "used"
+	}
+	opts, ok := jewelcase.Presets[presetName]
+	if !ok {
+		return jewelcase.Options{}, fmt.Errorf("unknown preset %q", presetName)
+	}
+	opts.PreserveMetadata = !o.GetStripMetadata()
+
+	// Zero is a valid angle, so the library can't default it like the command line does
+	opts.GlareAngle = jewelcase.DefaultGlareAngle
+	opts.TiltYaw = jewelcase.DefaultTiltYaw
+	opts.TiltPitch = jewelcase.DefaultTiltPitch
+
+	if o == nil {
+		return opts, nil
+	}
+
+	override(&opts.SeedFromContent, o.SeedFromContent)
+	override(&opts.Force, o.Force)
+	override(&opts.ColourCorrection, o.ColourCorrection)
+	override(&opts.RoundedCorners, o.RoundedCorners)
+	override(&opts.EdgeSoftening, o.EdgeSoftening)
+	override(&opts.RandomOffset, o.RandomOffset)
+	override(&opts.RandomRotation, o.RandomRotation)
+	override(&opts.Reflection, o.Reflection)
+	override(&opts.PreserveGrayscale, o.PreserveGrayscale)
+	override(&opts.CornerRadiusMin, o.CornerRadiusMin)
+	override(&opts.CornerRadiusMax, o.CornerRadiusMax)
+	override(&opts.MaxRotation, o.MaxRotation)
+	overrideInt(&opts.MaxOffsetX, o.MaxOffsetX)
+	overrideInt(&opts.MaxOffsetY, o.MaxOffsetY)
+	override(&opts.ReflectionStrength, o.ReflectionStrength)
+	override(&opts.ReflectionAngle, o.ReflectionAngle)
+	override(&opts.ReflectionWidth, o.ReflectionWidth)
+	override(&opts.DoubleReflection, o.DoubleReflection)
+	override(&opts.TintAmount, o.TintAmount)
+	override(&opts.SaturationReduction, o.SaturationReduction)
+	override(&opts.ContrastReduction, o.ContrastReduction)
+	override(&opts.PaperTexture, o.Paper)
+	override(&opts.PaperOpacity, o.PaperOpacity)
+	override(&opts.Grain, o.Grain)
+	override(&opts.GrainIntensity, o.GrainIntensity)
+	override(&opts.Vignette, o.Vignette)
+	override(&opts.VignetteStrength, o.VignetteStrength)
+	override(&opts.InnerShadow, o.InnerShadow)
+	override(&opts.InnerShadowWidth, o.InnerShadowWidth)
+	override((*string)(&opts.Style), o.Style)
+	overrideInt(&opts.FrameVariant, o.FrameVariant)
+	override((*string)(&opts.Tray), o.Tray)
+	override((*string)(&opts.Crop), o.Crop)
+	override((*string)(&opts.Filter), o.Filter)
+	override(&opts.LinearLight, o.LinearLight)
+	override(&opts.HighBitDepth, o.HighBitDepth)
+	override(&opts.Flatten, o.Flatten)
+	override(&opts.Sharpen, o.Sharpen)
+	override(&opts.SharpenAmount, o.SharpenAmount)
+	override(&opts.SharpenRadius, o.SharpenRadius)
+	override(&opts.Barcode, o.Barcode)
+	override(&opts.SpineText, o.SpineText)
+	override(&opts.SpineTextSize, o.SpineTextSize)
+	override(&opts.ParentalAdvisory, o.ParentalAdvisory)
+	override(&opts.Obi, o.Obi)
+	override(&opts.ObiTitle, o.ObiTitle)
+	override(&opts.ObiArtist, o.ObiArtist)
+	override(&opts.ObiPrice, o.ObiPrice)
+	override((*string)(&opts.ObiPaper), o.ObiPaper)
+	override(&opts.HypeStickerText, o.HypeStickerText)
+	override((*string)(&opts.HypeStickerShape), o.HypeStickerShape)
+	override(&opts.HypeStickerSize, o.HypeStickerSize)
+	override((*string)(&opts.HypeStickerCorner), o.HypeStickerCorner)
+	override(&opts.ShopStickerText, o.ShopStickerText)
+	override(&opts.ShopStickerSize, o.ShopStickerSize)
+	override(&opts.ShopStickerRotation, o.ShopStickerRotation)
+	override((*string)(&opts.ShopStickerCorner), o.ShopStickerCorner)
+	override(&opts.PriceSticker, o.PriceSticker)
+	override(&opts.PriceText, o.PriceText)
+	override(&opts.PriceCurrency, o.PriceCurrency)
+	override((*string)(&opts.PriceStyle), o.PriceStyle)
+	override(&opts.Glare, o.Glare)
+	override(&opts.GlareAngle, o.GlareAngle)
+	override(&opts.GlareWidth, o.GlareWidth)
+	override(&opts.Scratches, o.Scratches)
+	override(&opts.ScratchDensity, o.ScratchDensity)
+	override(&opts.Dust, o.Dust)
+	override(&opts.DustDensity, o.DustDensity)
+	override(&opts.Fingerprints, o.Fingerprints)
+	override(&opts.FingerprintIntensity, o.FingerprintIntensity)
+	override(&opts.Cracks, o.Cracks)
+	override(&opts.CrackProbability, o.CrackProbability)
+	override(&opts.ShrinkWrap, o.ShrinkWrap)
+	override(&opts.Yellowing, o.Yellowing)
+	override(&opts.YellowingStrength, o.YellowingStrength)
+	override(&opts.Perspective, o.Perspective)
+	override(&opts.PerspectiveTilt, o.PerspectiveTilt)
+	override(&opts.Tilt, o.Tilt)
+	override(&opts.TiltYaw, o.TiltYaw)
+	override(&opts.TiltPitch, o.TiltPitch)
+	override(&opts.DropShadow, o.DropShadow)
+	overrideInt(&opts.OutputWidth, o.OutputWidth)
+	overrideInt(&opts.OutputHeight, o.OutputHeight)
+	overrideInt(&opts.Scale, o.Scale)
+	overrideInt(&opts.JPEGQuality, o.JpegQuality)
+	overrideInt(&opts.AVIFQuality, o.AvifQuality)
+	override(&opts.JPEGProgressive, o.JpegProgressive)
+
+	if len(o.TrackListing) > 0 {
+		opts.TrackListing = o.TrackListing
+	}
+
+	// Laying out very long text takes a lot of time and memory; the numeric options that
+	// drive the cost of a request are limited by the library
+	texts := []struct {
+		name  string
+		value string
+	}{
+		{"spine text", opts.SpineText},
+		{"barcode", opts.Barcode},
+		{"obi title", opts.ObiTitle},
+		{"obi artist", opts.ObiArtist},
+		{"obi price", opts.ObiPrice},
+		{"hype sticker text", opts.HypeStickerText},
+		{"shop sticker text", opts.ShopStickerText},
+		{"price text", opts.PriceText},
+		{"price currency", opts.PriceCurrency},
+		{"track listing", strings.Join(opts.TrackListing, "\n")},
+	}
+	for _, t := range texts {
+		if len(t.value) > jewelcase.MaxTextLength {
+			return jewelcase.Options{}, fmt.Errorf("invalid %s: longer than %d bytes", t.name, jewelcase.MaxTextLength)
+		}
+	}
+
+	if o.Seed != nil {
+		opts.Rand = rand.New(rand.NewSource(*o.Seed))
+	}
+
+	colours := []struct {
+		name string
+		dst  *color.Color
+		src  *string
+	}{
+		{"matte colour", &opts.MatteColour, o.MatteColour},
+		{"flatten colour", &opts.FlattenColour, o.FlattenColour},
+		{"spine text colour", &opts.SpineTextColour, o.SpineTextColour},
+		{"obi colour", &opts.ObiColour, o.ObiColour},
+		{"hype sticker colour", &opts.HypeStickerColour, o.HypeStickerColour},
+		{"shop sticker colour", &opts.ShopStickerColour, o.ShopStickerColour},
+		{"background colour", &opts.BackgroundColour, o.BackgroundColour},
+		{"background gradient", &opts.BackgroundGradient, o.BackgroundGradient},
+	}
+	for _, c := range colours {
+		if c.src == nil {
+			continue
+		}
+		colour, err := jewelcase.ParseColour(*c.src)
+		if err != nil {
+			return jewelcase.Options{}, fmt.Errorf("invalid %s: %w", c.name, err)
+		}
+		*c.dst = colour
+	}
+
+	if o.TintColour != nil {
+		colour, ok := jewelcase.TintColours[*o.TintColour]
+		if !ok {
+			var err error
+			if colour, err = jewelcase.ParseColour(*o.TintColour); err != nil {
+				return jewelcase.Options{}, fmt.Errorf("invalid tint colour: %w", err)
+			}
+		}
+		opts.TintColour = colour
+	}
+
+	if o.PngCompression != nil {
+		level, err := jewelcase.ParseCompression(*o.PngCompression)
+		if err != nil {
+			return jewelcase.Options{}, err
+		}
+		opts.PNGCompression = level
+	}
+	return opts, nil
+}
+
+// override sets dst to the value of src, if src is set.
+func override[T any](dst *T, src *T) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
+// overrideInt sets dst to the value of src, if src is set.
+func overrideInt(dst *int, src *int32) {
+	if src != nil {
+		*dst = int(*src)
+	}
+}
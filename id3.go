@@ -0,0 +1,161 @@
+package jewelcase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+)
+
+var errMalformedID3 = errors.New("malformed ID3 tag")
+
+// id3Art finds the front cover in the APIC frames of an ID3v2.3 or v2.4 tag at the start
+// of the file, or the first picture if none of them is marked as the front cover.
+func id3Art(data []byte) ([]byte, func([]byte, string, image.Point) ([]byte, error), error) {
+	if len(data) < 10 || !bytes.HasPrefix(data, []byte("ID3")) {
+		return nil, nil, ErrNoEmbeddedArt
+	}
+
+	version, flags := data[3], data[5]
+	if version != 3 && version != 4 {
+		return nil, nil, fmt.Errorf("unsupported ID3 version 2.%d", version)
+	}
+	if flags&0x80 != 0 {
+		return nil, nil, errors.New("unsynchronised ID3 tags are not supported")
+	}
+
+	tagEnd := 10 + syncsafe(data[6:10])
+	if tagEnd > len(data) {
+		return nil, nil, errMalformedID3
+	}
+
+	pos := 10
+	if flags&0x40 != 0 {
+		// Skip the extended header, whose size includes itself only in v2.4
+		if pos+4 > tagEnd {
+			return nil, nil, errMalformedID3
+		}
+		if version == 3 {
+			pos += 4 + int(binary.BigEndian.Uint32(data[pos:]))
+		} else {
+			pos += syncsafe(data[pos : pos+4])
+		}
+	}
+
+	var frameStart, frameEnd int
+	var kind byte
+	var art []byte
+	for pos+10 <= tagEnd && data[pos] != 0 {
+		size := int(binary.BigEndian.Uint32(data[pos+4:]))
+		if version == 4 {
+			size = syncsafe(data[pos+4 : pos+8])
+		}
+		end := pos + 10 + size
+		if end > tagEnd {
+			return nil, nil, errMalformedID3
+		}
+
+		// Frames that are compressed or encrypted can't be read, so are skipped
+		formatFlags := data[pos+9]
+		unreadable := formatFlags&0xe0 != 0
+		if version == 4 {
+			unreadable = formatFlags&0x4f != 0
+		}
+
+		if string(data[pos:pos+4]) == "APIC" && !unreadable {
+			k, picture, ok := parseAPIC(data[pos+10 : end])
+			if ok && (art == nil || k == pictureFrontCover && kind != pictureFrontCover) {
+				frameStart, frameEnd, kind, art = pos, end, k, picture
+			}
+		}
+		pos = end
+	}
+
+	if art == nil {
+		return nil, nil, ErrNoEmbeddedArt
+	}
+
+	return art, func(newArt []byte, mime string, _ image.Point) ([]byte, error) {
+		// Keep everything about the frame apart from the MIME type and image data
+		body := data[frameStart+10 : frameEnd]
+		meta := body[:len(body)-len(art)]
+		mimeEnd := bytes.IndexByte(meta[1:], 0) + 1
+
+		frame := append([]byte("APIC"), 0, 0, 0, 0, data[frameStart+8], 0)
+		frame = append(frame, meta[0])
+		frame = append(frame, mime...)
+		frame = append(frame, meta[mimeEnd:]...)
+		frame = append(frame, newArt...)
+
+		size := len(frame) - 10
+		tagSize := tagEnd - 10 + len(frame) - (frameEnd - frameStart)
+		if size >= 1<<28 || tagSize >= 1<<28 {
+			return nil, errors.New("cover art is too large for an ID3 tag")
+		}
+		if version == 4 {
+			putSyncsafe(frame[4:8], size)
+		} else {
+			binary.BigEndian.PutUint32(frame[4:8], uint32(size))
+		}
+
+		out := make([]byte, 0, len(data)+len(frame))
+		out = append(out, data[:frameStart]...)
+		out = append(out, frame...)
+		out = append(out, data[frameEnd:]...)
+		putSyncsafe(out[6:10], tagSize)
+
+		// v2.4 tags may have a footer repeating the header
+		if flags&0x10 != 0 {
+			footer := 10 + tagSize
+			if footer+10 > len(out) {
+				return nil, errMalformedID3
+			}
+			putSyncsafe(out[footer+6:footer+10], tagSize)
+		}
+		return out, nil
+	}, nil
+}
+
+// parseAPIC returns the picture type and image data from the body of an APIC frame.
+func parseAPIC(body []byte) (byte, []byte, bool) {
+	if len(body) < 1 {
+		return 0, nil, false
+	}
+	encoding := body[0]
+
+	mimeEnd := bytes.IndexByte(body[1:], 0) + 1
+	if mimeEnd == 0 || mimeEnd+2 > len(body) {
+		return 0, nil, false
+	}
+	kind := body[mimeEnd+1]
+	rest := body[mimeEnd+2:]
+
+	// The description is terminated by a single null, or two for UTF-16
+	if encoding == 1 || encoding == 2 {
+		for i := 0; ; i += 2 {
+			if i+2 > len(rest) {
+				return 0, nil, false
+			}
+			if rest[i] == 0 && rest[i+1] == 0 {
+				return kind, rest[i+2:], true
+			}
+		}
+	}
+
+	end := bytes.IndexByte(rest, 0)
+	if end < 0 {
+		return 0, nil, false
+	}
+	return kind, rest[end+1:], true
+}
+
+// syncsafe decodes a 28-bit integer stored in the low 7 bits of four bytes.
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// putSyncsafe encodes a 28-bit integer in the low 7 bits of four bytes.
+func putSyncsafe(b []byte, v int) {
+	b[0], b[1], b[2], b[3] = byte(v>>21&0x7f), byte(v>>14&0x7f), byte(v>>7&0x7f), byte(v&0x7f)
+}
@@ -0,0 +1,126 @@
+package jewelcase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"testing"
+)
+
+// id3Frame returns an ID3 frame with the given ID and body, with its size encoded for
+// the given major version.
+func id3Frame(version byte, id string, body []byte) []byte {
+	frame := append([]byte(id), 0, 0, 0, 0, 0, 0)
+	if version == 4 {
+		putSyncsafe(frame[4:8], len(body))
+	} else {
+		binary.BigEndian.PutUint32(frame[4:8], uint32(len(body)))
+	}
+	return append(frame, body...)
+}
+
+// apicBody returns the body of an APIC frame with a Latin-1 description.
+func apicBody(mime string, kind byte, description string, art []byte) []byte {
+	body := append([]byte{0}, mime...)
+	body = append(body, 0, kind)
+	body = append(body, description...)
+	body = append(body, 0)
+	return append(body, art...)
+}
+
+// id3File returns an ID3 tag holding the given frames, followed by some audio.
+func id3File(version, flags byte, frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+
+	// Leave some padding after the frames, as taggers usually do
+	body = append(body, make([]byte, 32)...)
+
+	tag := []byte{'I', 'D', '3', version, 0, flags, 0, 0, 0, 0}
+	putSyncsafe(tag[6:10], len(body))
+	tag = append(tag, body...)
+	if flags&0x10 != 0 {
+		footer := []byte{'3', 'D', 'I', version, 0, flags, 0, 0, 0, 0}
+		putSyncsafe(footer[6:10], len(body))
+		tag = append(tag, footer...)
+	}
+	return append(tag, "audio frames"...)
+}
+
+func TestID3ArtRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		version byte
+		flags   byte
+	}{
+		{"v2.3", 3, 0},
+		{"v2.4", 4, 0},
+		{"v2.4 with footer", 4, 0x10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title := id3Frame(tt.version, "TIT2", []byte("\x00Album"))
+			back := id3Frame(tt.version, "APIC", apicBody("image/jpeg", 4, "back", []byte("back cover")))
+			front := id3Frame(tt.version, "APIC", apicBody("image/jpeg", pictureFrontCover, "front", []byte("front cover")))
+			data := id3File(tt.version, tt.flags, title, back, front)
+
+			art, replace, err := id3Art(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(art) != "front cover" {
+				t.Fatalf("found art %q, want the front cover", art)
+			}
+
+			for _, newArt := range [][]byte{[]byte("new"), bytes.Repeat([]byte("new front cover"), 1000)} {
+				out, err := replace(newArt, "image/png", image.Pt(750, 750))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, _, err := id3Art(out)
+				if err != nil {
+					t.Fatalf("re-parsing the tag: %v", err)
+				}
+				if !bytes.Equal(got, newArt) {
+					t.Errorf("replaced art is %d bytes, want %d", len(got), len(newArt))
+				}
+
+				wantFrame := id3Frame(tt.version, "APIC", apicBody("image/png", pictureFrontCover, "front", newArt))
+				delta := len(wantFrame) - len(front)
+				tagSize := len(data) - len("audio frames") - 10 + delta
+				if tt.flags&0x10 != 0 {
+					tagSize -= 10
+				}
+				if got := syncsafe(out[6:10]); got != tagSize {
+					t.Errorf("tag size is %d, want %d", got, tagSize)
+				}
+				if tt.flags&0x10 != 0 {
+					footer := out[10+tagSize:]
+					if !bytes.HasPrefix(footer, []byte("3DI")) || syncsafe(footer[6:10]) != tagSize {
+						t.Errorf("footer doesn't repeat the tag size %d", tagSize)
+					}
+				}
+
+				// The other frames and the audio should be untouched
+				want := append([]byte{}, data[:10+len(title)+len(back)]...)
+				want = append(want, wantFrame...)
+				want = append(want, data[10+len(title)+len(back)+len(front):]...)
+				putSyncsafe(want[6:10], tagSize)
+				if tt.flags&0x10 != 0 {
+					putSyncsafe(want[10+tagSize+6:10+tagSize+10], tagSize)
+				}
+				if !bytes.Equal(out, want) {
+					t.Error("replacing the art changed more than the APIC frame and sizes")
+				}
+			}
+		})
+	}
+}
+
+func TestID3ArtWithoutPicture(t *testing.T) {
+	data := id3File(3, 0, id3Frame(3, "TIT2", []byte("\x00Album")))
+	if _, _, err := id3Art(data); !errors.Is(err, ErrNoEmbeddedArt) {
+		t.Errorf("got error %v, want ErrNoEmbeddedArt", err)
+	}
+}
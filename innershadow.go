@@ -0,0 +1,53 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+)
+
+// drawInnerShadow darkens the edges of the art within the given rectangle, where the lip
+// of the case overhangs the insert, so the art looks like it sits inside the case rather than on
+// top of it. The lip casts more shadow on the top and left edges, away from the light.
+// width is how far the shadow reaches into the art, in pixels.
+func drawInnerShadow(img *image.RGBA, art image.Rectangle, width float64) {
+	art = art.Intersect(img.Bounds())
+	if art.Empty() || width <= 0 {
+		return
+	}
+
+	// How much each edge is darkened at its darkest: top, left, bottom, right
+	darkness := [4]float64{0.5, 0.45, 0.3, 0.25}
+	reach := int(math.Ceil(width))
+
+	parallelRows(art, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			top := float64(y-art.Min.Y) + 0.5
+			bottom := float64(art.Max.Y-y) - 0.5
+			nearTop := top < width || bottom < width
+
+			for x := art.Min.X; x < art.Max.X; x++ {
+				// Skip the middle of each row, which is too far from the edges to be shaded
+				if !nearTop && x == art.Min.X+reach && art.Max.X-reach > x {
+					x = art.Max.X - reach
+				}
+				left := float64(x-art.Min.X) + 0.5
+				right := float64(art.Max.X-x) - 0.5
+
+				v := 1.0
+				for e, d := range [4]float64{top, left, bottom, right} {
+					if d < width {
+						v *= 1 - darkness[e]*(1-smoothstep(d/width))
+					}
+				}
+				if v == 1 {
+					continue
+				}
+
+				p := img.Pix[img.PixOffset(x, y):]
+				for c := range 3 {
+					p[c] = uint8(float64(p[c]) * v)
+				}
+			}
+		}
+	})
+}
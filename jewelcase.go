@@ -2,7 +2,10 @@ package jewelcase
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	_ "embed"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"image"
@@ -12,11 +15,11 @@ import (
 	"image/png"
 	"math"
 	"math/rand"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 
 	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
 )
 
 //go:embed frame.jpg
@@ -24,7 +27,8 @@ var frameData []byte
 
 var frame image.Image
 
-// ErrAlreadyProcessed is returned when an image appears to already have the jewel case effect applied.
+// ErrAlreadyProcessed is returned when an image carries the marker that jewelcase embeds in
+// all of its output, showing it already has the jewel case effect applied.
 var ErrAlreadyProcessed = errors.New("image appears to be already processed")
 
 func init() {
@@ -42,6 +46,18 @@ const (
 	frameOffsetY = 13
 )
 
+// defaultArtRect is the area of the embedded frame that the art is placed in.
+var defaultArtRect = image.Rect(frameOffsetX, frameOffsetY, frameOffsetX+targetWidth, frameOffsetY+targetHeight)
+
+// DefaultGlareAngle, DefaultTiltYaw and DefaultTiltPitch are the angles, in degrees, that
+// the command line tool and servers use for Options.GlareAngle, TiltYaw and TiltPitch
+// unless told otherwise. Zero is a valid angle, so Options can't default to them itself.
+const (
+	DefaultGlareAngle = 35
+	DefaultTiltYaw    = 20
+	DefaultTiltPitch  = 5
+)
+
 // Options controls which visual effects are applied to the album art.
 type Options struct {
 	// ColourCorrection applies subtle saturation and contrast reduction with a blue tint
@@ -64,259 +80,1650 @@ type Options struct {
 
 	// Force processes images even if they appear to already be processed
 	Force bool
+
+	// MaxInputPixels is the largest image, in pixels, that will be decoded, so that huge or
+	// corrupt images can't use up all the available memory. Larger images are rejected with
+	// ErrImageTooLarge. It has no effect on images passed to Process directly (defaults to
+	// 100 million; negative for no limit)
+	MaxInputPixels int
+
+	// ParentalAdvisory adds a Parental Advisory label to the bottom right of the art
+	ParentalAdvisory bool
+
+	// Obi wraps a Japanese-style obi strip around the left-hand side of the case, with
+	// ObiTitle and ObiArtist running down it and ObiPrice at the bottom
+	Obi bool
+
+	// ObiTitle is the album title printed on the obi strip
+	ObiTitle string
+
+	// ObiArtist is the artist printed on the obi strip
+	ObiArtist string
+
+	// ObiPrice is the price printed at the bottom of the obi strip, including any currency
+	ObiPrice string
+
+	// ObiColour is the colour of the obi strip (defaults to red). The text is black or
+	// white, whichever is more readable
+	ObiColour color.Color
+
+	// ObiPaper is the paper stock the obi strip is printed on (defaults to ObiPaperMatte)
+	ObiPaper ObiPaper
+
+	// HypeStickerText, if set, adds a "hype sticker" with this text to a corner of the art.
+	// Lines are separated by newlines
+	HypeStickerText string
+
+	// HypeStickerShape is the shape of the hype sticker (defaults to StickerCircle)
+	HypeStickerShape StickerShape
+
+	// HypeStickerColour is the colour of the hype sticker (defaults to yellow). The text is
+	// black or white, whichever is more readable
+	HypeStickerColour color.Color
+
+	// HypeStickerSize is the width of the hype sticker in pixels, up to MaxStickerSize
+	// (defaults to 170)
+	HypeStickerSize float64
+
+	// HypeStickerCorner is the corner of the art the hype sticker is placed in (defaults
+	// to CornerTopRight)
+	HypeStickerCorner Corner
+
+	// ShopStickerText, if set, adds a round sticker with this text to a corner of the art,
+	// like the ones second-hand shops put on their stock. ShopStickerUsed and
+	// ShopStickerPromo are the usual ones. Lines are separated by newlines
+	ShopStickerText string
+
+	// ShopStickerColour is the colour of the shop sticker (defaults to orange). The text is
+	// black or white, whichever is more readable
+	ShopStickerColour color.Color
+
+	// ShopStickerSize is the width of the shop sticker in pixels, up to MaxStickerSize
+	// (defaults to 100)
+	ShopStickerSize float64
+
+	// ShopStickerRotation is the angle of the shop sticker in degrees, clockwise
+	ShopStickerRotation float64
+
+	// ShopStickerCorner is the corner of the art the shop sticker is placed in (defaults
+	// to CornerBottomLeft)
+	ShopStickerCorner Corner
+
+	// PriceSticker adds a record shop price sticker to a random corner of the art,
+	// overlapping its edge. Some stickers are randomly left half torn off
+	PriceSticker bool
+
+	// PriceText is the price printed on the price sticker (defaults to "7.99")
+	PriceText string
+
+	// PriceCurrency is the currency symbol printed before the price (defaults to "£")
+	PriceCurrency string
+
+	// PriceStyle is the kind of price sticker (defaults to PriceLabel)
+	PriceStyle PriceStyle
+
+	// Style selects which of the built-in frames to use (defaults to StyleJewelCase).
+	// It is ignored if Frames or Frame is set
+	Style Style
+
+	// BackImage is used for the tray insert instead of the art when using StyleBack
+	BackImage image.Image `json:"-"`
+
+	// TrackListing is the list of tracks printed on the tray insert when using StyleBack
+	TrackListing []string
+
+	// Barcode is the 12-digit UPC-A or 13-digit EAN-13 printed on the tray insert when
+	// using StyleBack (defaults to a random EAN-13)
+	Barcode string
+
+	// Frames optionally provides a set of frame images, one of which is picked at random
+	// for each processed image. When empty, Frame or Style is used instead.
+	Frames []image.Image `json:"-"`
+
+	// FrameOffsets gives the position of the art within each of the Frames, and must be
+	// the same length as Frames. The art is always 750x750 pixels in these frames.
+	FrameOffsets []image.Point
+
+	// Frame optionally replaces the embedded jewel case frame with a custom image.
+	// It is ignored if Frames is set.
+	Frame image.Image `json:"-"`
+
+	// FrameArtRect is the area of Frame that the art is scaled to fill. It must be set
+	// when using a custom Frame.
+	FrameArtRect image.Rectangle
+
+	// FrameMasks are areas of Frame that are drawn in front of the art, such as the tabs
+	// that hold an insert in place
+	FrameMasks []image.Rectangle
+
+	// FrameVariant picks which version of the embedded jewel case photograph to use, from
+	// 1 to FrameVariants. When zero, one is picked at random for each image so a whole
+	// library doesn't end up in identical cases
+	FrameVariant int
+
+	// Tray is the colour of the plastic tray in the embedded jewel case, or TrayRandom to
+	// pick one for each image (defaults to TrayBlack)
+	Tray Tray
+
+	// PreserveGrayscale skips the saturation and tint parts of colour correction for
+	// source images that are effectively monochrome, so they remain neutral
+	PreserveGrayscale bool
+
+	// PaperTexture blends a halftoned print of the art on slightly uneven paper into it, so
+	// pristine digital art looks like a printed insert
+	PaperTexture bool
+
+	// PaperOpacity is how much of the printed version of the art is blended in, from 0 to
+	// 1 (defaults to 0.3)
+	PaperOpacity float64
+
+	// Grain adds fine noise to the art after colour correction, like the grain of a
+	// photographed print, so very clean digital art blends in with the frame
+	Grain bool
+
+	// GrainIntensity is the strength of the grain, as the standard deviation of the noise
+	// relative to full brightness (defaults to 0.03)
+	GrainIntensity float64
+
+	// Vignette darkens the art towards its edges, like the light falloff in a photograph of
+	// a printed insert
+	Vignette bool
+
+	// VignetteStrength is how much the corners of the art are darkened, as a fraction of
+	// their brightness (defaults to 0.25)
+	VignetteStrength float64
+
+	// InnerShadow darkens the edges of the art slightly where the lip of the case
+	// overhangs it, so the art looks like it sits inside the case
+	InnerShadow bool
+
+	// InnerShadowWidth is how far the inner shadow reaches into the art, in pixels
+	// (defaults to 6)
+	InnerShadowWidth float64
+
+	// Glare adds a bright angled streak across the whole case, like light catching the plastic
+	Glare bool
+
+	// GlareAngle is the angle of the glare streak in degrees, measured clockwise from
+	// horizontal. DefaultGlareAngle suits most art
+	GlareAngle float64
+
+	// GlareWidth is the approximate width of the glare streak in pixels (defaults to 80)
+	GlareWidth float64
+
+	// Scratches draws faint light streaks at random across the whole case, like a well-used case
+	Scratches bool
+
+	// ScratchDensity is the number of scratches per 100,000 pixels of the case, up to
+	// MaxScratchDensity (defaults to 2)
+	ScratchDensity float64
+
+	// Dust scatters tiny light and dark specks at random across the case, like dust on
+	// the plastic
+	Dust bool
+
+	// DustDensity is the number of dust specks per 10,000 pixels of the case, up to
+	// MaxDustDensity (defaults to 1)
+	DustDensity float64
+
+	// Cracks draws one or two jagged cracks spreading from the edges of the case, as most
+	// real cases have
+	Cracks bool
+
+	// CrackProbability is the chance of each image getting cracks when Cracks is set, from
+	// 0 to 1, so only some images in a batch are cracked (defaults to 1)
+	CrackProbability float64
+
+	// Fingerprints overlays faint greasy fingerprints and smudges at random across the case
+	Fingerprints bool
+
+	// FingerprintIntensity scales the number of fingerprints and smudges, with 1 giving
+	// about three per 250,000 pixels of the case, up to MaxFingerprintIntensity (defaults
+	// to 1)
+	FingerprintIntensity float64
+
+	// ShrinkWrap overlays randomly generated plastic wrap wrinkles and glints on the whole
+	// case, as if the album is still sealed
+	ShrinkWrap bool
+
+	// Yellowing tints the case amber, like clear plastic that has spent decades in the
+	// sun, with the side that faced the window the most yellowed
+	Yellowing bool
+
+	// YellowingStrength is how yellowed the plastic is, from 0 to 1 (defaults to 0.5)
+	YellowingStrength float64
+
+	// Perspective warps the art as if the case were turned slightly away from the viewer
+	Perspective bool
+
+	// PerspectiveTilt is the fraction of the art's height by which the far edge is shortened.
+	// Positive values turn the right edge away, negative values the left (defaults to 0.04)
+	PerspectiveTilt float64
+
+	// Tilt turns the finished case in 3D for a more dynamic product shot, shrinking it to
+	// fit. Cases with a spine are given depth, so the spine can be seen down the side
+	Tilt bool
+
+	// TiltYaw is how far the case is turned about its vertical axis in degrees. Positive
+	// values turn the right edge away, showing the spine
+	TiltYaw float64
+
+	// TiltPitch is how far the case is turned about its horizontal axis in degrees.
+	// Positive values tip the top edge away
+	TiltPitch float64
+
+	// DropShadow places the finished case on a larger background with a soft shadow beneath
+	// it, like a product shot
+	DropShadow bool
+
+	// BackgroundColour is the colour of the background used by DropShadow (defaults to
+	// transparent)
+	BackgroundColour color.Color
+
+	// BackgroundGradient, if set, makes the DropShadow background fade from BackgroundColour
+	// at the top to this colour at the bottom
+	BackgroundGradient color.Color
+
+	// Crop is how art that isn't the same shape as the frame is fitted into it (defaults to
+	// CropCenter)
+	Crop CropMode
+
+	// MatteColour is the colour around the art when using CropLetterbox (defaults to black)
+	MatteColour color.Color
+
+	// Flatten places art with transparent areas, such as logos saved as PNGs, over
+	// FlattenColour before it is scaled, rather than letting the frame show through
+	Flatten bool
+
+	// FlattenColour is the colour transparent art is flattened over (defaults to white)
+	FlattenColour color.Color
+
+	// Filter is the resampling filter used to scale the art to fit the frame and to rotate
+	// it (defaults to FilterBilinear)
+	Filter Filter
+
+	// LinearLight scales, rotates and composites the art in linear light rather than
+	// directly on its sRGB values, which otherwise darkens fine detail and soft edges
+	LinearLight bool
+
+	// HighBitDepth keeps images with 16 bits per channel, such as 16-bit PNGs, at that
+	// depth through scaling, compositing and the colour, edge, corner, reflection and
+	// rotation effects, so the result can be saved as a 16-bit PNG or TIFF. Any other
+	// effects reduce the image to 8 bits per channel
+	HighBitDepth bool
+
+	// Sharpen applies an unsharp mask to the art once it has been scaled to fit the frame,
+	// to restore detail softened by the resampling
+	Sharpen bool
+
+	// SharpenAmount is how much of the detail found by the unsharp mask is added back
+	// (defaults to 0.5)
+	SharpenAmount float64
+
+	// SharpenRadius is the radius of the blur used by the unsharp mask, in pixels, up to
+	// MaxSharpenRadius (defaults to 1)
+	SharpenRadius float64
+
+	// OutputWidth and OutputHeight, if set, resize the final image to fit within this size,
+	// keeping its aspect ratio. If only one is set, the other is unconstrained. Neither
+	// may be more than MaxOutputSize
+	OutputWidth  int
+	OutputHeight int
+
+	// Scale renders the case at a multiple of its usual size, up to MaxScale, for
+	// high-density displays. Unlike OutputWidth and OutputHeight, the art is resampled at
+	// the larger size rather than the finished image being enlarged, but the photograph of
+	// the case is upscaled as there is no larger version of it. It can only be used with
+	// the embedded jewel case (defaults to 1)
+	Scale int
+
+	// JPEGQuality is the quality to use when saving JPEG images, from 1 to 100 (defaults to 95)
+	JPEGQuality int
+
+	// JPEGProgressive saves JPEG images in progressive rather than baseline format, so they
+	// can be shown at a low quality before they've fully loaded
+	JPEGProgressive bool
+
+	// PNGCompression is the compression level to use when saving PNG images
+	PNGCompression png.CompressionLevel
+
+	// AVIFQuality is the quality to use when saving AVIF images, from 1 to 100 (defaults to 60).
+	// AVIF output is only available when built with the avif tag
+	AVIFQuality int
+
+	// OutputFormat, if set, is the format ProcessFile and ProcessFileVariants save images
+	// in, such as "png" or "webp", instead of the one given by the output path's extension
+	OutputFormat string
+
+	// SeedFromContent derives the random effects from the content of the image, so
+	// re-processing the same image gives the same result while different images still vary
+	SeedFromContent bool
+
+	// BackupSuffix, if set, makes ProcessFile copy the original file to a path with this
+	// suffix appended before overwriting it in place. Existing backups are never overwritten
+	BackupSuffix string
+
+	// SpineText is drawn along the spine of the case, if set. It is only used with the
+	// jewel case and fatbox styles
+	SpineText string
+
+	// SpineTextSize is the font size of the spine text in pixels, up to MaxSpineTextSize
+	// (defaults to 28)
+	SpineTextSize float64
+
+	// SpineTextColour is the colour of the spine text (defaults to a light grey, or a dark
+	// grey on a clear tray)
+	SpineTextColour color.Color
+
+	// WriteSidecar makes ProcessFile write a JSON file alongside the output, recording
+	// the options used and the Report of random choices made
+	WriteSidecar bool
+
+	// PreserveMetadata copies the EXIF data and ICC colour profile of the input into the
+	// output, where both formats support it. The EXIF orientation is reset, as it has
+	// already been applied
+	PreserveMetadata bool
+
+	// PreserveFileAttributes makes ProcessFile give the output the same modification time
+	// and permissions as the input, so tools that watch for changes don't notice it
+	PreserveFileAttributes bool
+
+	// Rand, if set, is used as the source of randomness for all effects, allowing
+	// deterministic output. It takes priority over SeedFromContent. A rand.Rand is not safe
+	// for concurrent use, so the same one shouldn't be shared between concurrent calls
+	Rand *rand.Rand `json:"-"`
+
+	// OnStart, if set, is called by ProcessDirectory with the number of files it is going
+	// to process, before it starts on any of them
+	OnStart func(total int) `json:"-"`
+
+	// OnFile, if set, is called by ProcessDirectory after each file has been processed,
+	// with any error that occurred. Calls are never made concurrently
+	OnFile func(path string, err error) `json:"-"`
+
+	// OnResult, if set, is called by ProcessDirectory after each file has been processed,
+	// like OnFile, but with more detail about the outcome. Calls are never made concurrently
+	OnResult func(result FileResult) `json:"-"`
+
+	// RateLimit is the maximum number of images ProcessDirectory will start processing
+	// per second (zero for unlimited)
+	RateLimit float64
+
+	// Newest, if positive, limits ProcessDirectory to the given number of most recently
+	// modified images. Images that have already been processed are skipped as usual, and
+	// don't count towards the limit unless Force is set
+	Newest int
+
+	// Include, if non-empty, limits ProcessDirectory to files whose names match at least
+	// one of these glob patterns (as used by filepath.Match)
+	Include []string
+
+	// Exclude stops ProcessDirectory from processing files, or descending into directories,
+	// whose names match any of these glob patterns
+	Exclude []string
+
+	// IncludeAudio makes ProcessDirectory also process the art embedded in audio files
+	IncludeAudio bool
+
+	// AlbumArtOutput, if set, makes ProcessMusicLibrary save the processed art to a file
+	// with this name (such as "folder.jpg") instead of replacing the original
+	AlbumArtOutput string
+
+	// DryRun makes ProcessFile, ProcessAudioFile, ProcessFileVariants, ProcessDirectory and
+	// ProcessMusicLibrary check that each file could be processed, returning the error that
+	// would occur (such as ErrAlreadyProcessed), without processing or writing anything
+	DryRun bool
+
+	// Effects, if non-nil, is the ordered list of effects applied to the art. It replaces
+	// the ColourCorrection, EdgeSoftening, RoundedCorners, Reflection, RandomRotation and
+	// Perspective options; see DefaultEffects.
+	Effects []Effect `json:"-"`
+
+	// CornerRadiusMin and CornerRadiusMax give the range of radii used for rounded
+	// corners, in pixels (defaults to 6 and 12)
+	CornerRadiusMin float64
+	CornerRadiusMax float64
+
+	// MaxRotation is the largest angle the art may be randomly rotated by in either
+	// direction, in degrees (defaults to 0.5)
+	MaxRotation float64
+
+	// MaxOffsetX and MaxOffsetY give the largest random offset that may be applied
+	// in each direction, in pixels (defaults to 8 and 5)
+	MaxOffsetX int
+	MaxOffsetY int
+
+	// ReflectionStrength scales the brightness of the reflection (defaults to 1)
+	ReflectionStrength float64
+
+	// ReflectionAngle is how far the light that makes the reflection is turned clockwise
+	// from the top-left of the art, in degrees, so that it can match the lighting in a
+	// custom frame (defaults to 0, lit from the top-left)
+	ReflectionAngle float64
+
+	// ReflectionWidth is the width of a brighter streak in the reflection, running
+	// across the art at right angles to the light, in pixels (defaults to 0, no streak)
+	ReflectionWidth float64
+
+	// DoubleReflection adds a second, fainter streak beside the one given by
+	// ReflectionWidth, as the front and back surfaces of a real case lid each reflect
+	// the light
+	DoubleReflection bool
+
+	// TintAmount is the fraction by which colour correction boosts the channels of the
+	// TintColour (defaults to 0.02)
+	TintAmount float64
+
+	// TintColour is the colour that colour correction tints the art towards, such as one
+	// of the TintColours (defaults to TintCool)
+	TintColour color.Color
+
+	// SaturationReduction is the fraction by which colour correction reduces saturation
+	// (defaults to 0.1)
+	SaturationReduction float64
+
+	// ContrastReduction is the fraction by which colour correction reduces contrast
+	// (defaults to 0.05)
+	ContrastReduction float64
+}
+
+// Report details the random choices made while processing an image, so that the
+// result can be audited or reproduced.
+type Report struct {
+	// FrameIndex is the index of the entry in Options.Frames that was used, or -1 if
+	// the embedded frame was used
+	FrameIndex int
+
+	// FrameVariant is the version of the embedded jewel case photograph that was used,
+	// from 1 to FrameVariants, or 0 if a different frame was used
+	FrameVariant int
+
+	// Tray is the colour of the tray in the embedded jewel case, if it was used
+	Tray Tray
+
+	// Offset is the position of the top-left corner of the art within the frame
+	Offset image.Point
+
+	// RotationAngle is the angle the art was rotated by, in degrees
+	RotationAngle float64
+
+	// CornerRadii are the radii of the rounded corners: top left, top right, bottom left,
+	// then bottom right
+	CornerRadii [4]float64
+
+	// Cracks is the number of cracks drawn in the case, if any
+	Cracks int
+
+	// Barcode is the EAN-13 or UPC-A printed on the tray insert, if any
+	Barcode string
+
+	// PriceStickerCorner is the corner of the art the price sticker was put in, if any
+	PriceStickerCorner Corner
+
+	// PriceStickerTorn is whether the price sticker was partly torn off
+	PriceStickerTorn bool
+}
+
+// withDefaults returns a copy of the options with any unset parameters replaced by
+// the defaults that are actually used.
+func (o Options) withDefaults() Options {
+	o.CornerRadiusMin, o.CornerRadiusMax = o.cornerRadii()
+	o.MaxRotation = o.maxRotation()
+	o.MaxOffsetX, o.MaxOffsetY = o.maxOffset()
+	o.ReflectionStrength = o.reflectionStrength()
+	o.ReflectionWidth = o.reflectionWidth()
+	o.TintAmount = o.tintAmount()
+	o.TintColour = o.tintColour()
+	o.SaturationReduction = o.saturationReduction()
+	o.ContrastReduction = o.contrastReduction()
+	o.PaperOpacity = o.paperOpacity()
+	o.GrainIntensity = o.grainIntensity()
+	o.VignetteStrength = o.vignetteStrength()
+	o.GlareWidth = o.glareWidth()
+	o.ScratchDensity = o.scratchDensity()
+	o.DustDensity = o.dustDensity()
+	o.YellowingStrength = o.yellowingStrength()
+	o.InnerShadowWidth = o.innerShadowWidth()
+	o.CrackProbability = o.crackProbability()
+	o.FingerprintIntensity = o.fingerprintIntensity()
+	o.Crop = o.crop()
+	o.Filter = o.filter()
+	o.SharpenAmount, o.SharpenRadius = o.sharpening()
+	o.MatteColour = o.matteColour()
+	o.FlattenColour = o.flattenColour()
+	o.ObiColour = o.obiColour()
+	o.ObiPaper = o.obiPaper()
+	o.HypeStickerShape = o.hypeStickerShape()
+	o.HypeStickerColour = o.hypeStickerColour()
+	o.HypeStickerSize = o.hypeStickerSize()
+	o.HypeStickerCorner = o.hypeStickerCorner()
+	o.ShopStickerColour = o.shopStickerColour()
+	o.ShopStickerSize = o.shopStickerSize()
+	o.ShopStickerCorner = o.shopStickerCorner()
+	o.PriceText = o.priceText()
+	o.PriceCurrency = o.priceCurrency()
+	o.PriceStyle = o.priceStyle()
+	o.PerspectiveTilt = o.perspectiveTilt()
+	o.SpineTextSize = o.spineTextSize()
+	if o.tray() != TrayClear && o.tray() != TrayRandom {
+		o.SpineTextColour = o.spineTextColour()
+	}
+	o.Scale = o.scale()
+	o.Tray = o.tray()
+	o.MaxInputPixels = o.maxInputPixels()
+	return o
+}
+
+func (o Options) maxInputPixels() int {
+	if o.MaxInputPixels == 0 {
+		return 100_000_000
+	}
+	return o.MaxInputPixels
+}
+
+func (o Options) cornerRadii() (float64, float64) {
+	lo, hi := o.CornerRadiusMin, o.CornerRadiusMax
+	if lo <= 0 {
+		lo = 6
+	}
+	if hi <= 0 {
+		hi = max(lo, 12)
+	}
+	return lo, max(lo, hi)
+}
+
+func (o Options) maxRotation() float64 {
+	if o.MaxRotation <= 0 {
+		return 0.5
+	}
+	return o.MaxRotation
+}
+
+func (o Options) maxOffset() (int, int) {
+	x, y := o.MaxOffsetX, o.MaxOffsetY
+	if x <= 0 {
+		x = 8
+	}
+	if y <= 0 {
+		y = 5
+	}
+	return x, y
+}
+
+func (o Options) reflectionStrength() float64 {
+	if o.ReflectionStrength <= 0 {
+		return 1
+	}
+	return o.ReflectionStrength
+}
+
+func (o Options) reflectionWidth() float64 {
+	return math.Max(o.ReflectionWidth, 0)
+}
+
+func (o Options) tintAmount() float64 {
+	if o.TintAmount <= 0 {
+		return 0.02
+	}
+	return o.TintAmount
+}
+
+func (o Options) tintColour() color.Color {
+	if o.TintColour == nil {
+		return TintCool
+	}
+	return o.TintColour
+}
+
+func (o Options) saturationReduction() float64 {
+	if o.SaturationReduction <= 0 {
+		return 0.1
+	}
+	return o.SaturationReduction
+}
+
+func (o Options) contrastReduction() float64 {
+	if o.ContrastReduction <= 0 {
+		return 0.05
+	}
+	return o.ContrastReduction
+}
+
+func (o Options) vignetteStrength() float64 {
+	if o.VignetteStrength <= 0 {
+		return 0.25
+	}
+	return o.VignetteStrength
+}
+
+func (o Options) paperOpacity() float64 {
+	if o.PaperOpacity <= 0 {
+		return 0.3
+	}
+	return math.Min(o.PaperOpacity, 1)
+}
+
+func (o Options) grainIntensity() float64 {
+	if o.GrainIntensity <= 0 {
+		return 0.03
+	}
+	return o.GrainIntensity
+}
+
+func (o Options) tray() Tray {
+	if o.Tray == "" {
+		return TrayBlack
+	}
+	return o.Tray
+}
+
+func (o Options) scale() int {
+	if o.Scale <= 0 {
+		return 1
+	}
+	return o.Scale
+}
+
+func (o Options) glareWidth() float64 {
+	if o.GlareWidth <= 0 {
+		return 80
+	}
+	return o.GlareWidth
+}
+
+func (o Options) scratchDensity() float64 {
+	if o.ScratchDensity <= 0 {
+		return 2
+	}
+	return min(o.ScratchDensity, MaxScratchDensity)
+}
+
+func (o Options) innerShadowWidth() float64 {
+	if o.InnerShadowWidth <= 0 {
+		return 6
+	}
+	return o.InnerShadowWidth
+}
+
+func (o Options) yellowingStrength() float64 {
+	if o.YellowingStrength <= 0 {
+		return 0.5
+	}
+	return o.YellowingStrength
+}
+
+func (o Options) dustDensity() float64 {
+	if o.DustDensity <= 0 {
+		return 1
+	}
+	return min(o.DustDensity, MaxDustDensity)
+}
+
+func (o Options) crackProbability() float64 {
+	if o.CrackProbability <= 0 {
+		return 1
+	}
+	return min(o.CrackProbability, 1)
+}
+
+func (o Options) fingerprintIntensity() float64 {
+	if o.FingerprintIntensity <= 0 {
+		return 1
+	}
+	return min(o.FingerprintIntensity, MaxFingerprintIntensity)
+}
+
+func (o Options) crop() CropMode {
+	if o.Crop == "" {
+		return CropCenter
+	}
+	return o.Crop
+}
+
+func (o Options) filter() Filter {
+	if o.Filter == "" {
+		return FilterBilinear
+	}
+	return o.Filter
+}
+
+func (o Options) sharpening() (amount, radius float64) {
+	amount, radius = o.SharpenAmount, o.SharpenRadius
+	if amount <= 0 {
+		amount = 0.5
+	}
+	if radius <= 0 {
+		radius = 1
+	}
+	return amount, min(radius, MaxSharpenRadius)
+}
+
+func (o Options) matteColour() color.Color {
+	if o.MatteColour == nil {
+		return color.Black
+	}
+	return o.MatteColour
+}
+
+func (o Options) flattenColour() color.Color {
+	if o.FlattenColour == nil {
+		return color.White
+	}
+	return o.FlattenColour
+}
+
+func (o Options) obiColour() color.Color {
+	if o.ObiColour == nil {
+		return color.RGBA{R: 0xb3, G: 0x1b, B: 0x2c, A: 0xff}
+	}
+	return o.ObiColour
+}
+
+func (o Options) obiPaper() ObiPaper {
+	if o.ObiPaper == "" {
+		return ObiPaperMatte
+	}
+	return o.ObiPaper
+}
+
+func (o Options) hypeStickerShape() StickerShape {
+	if o.HypeStickerShape == "" {
+		return StickerCircle
+	}
+	return o.HypeStickerShape
+}
+
+func (o Options) hypeStickerColour() color.Color {
+	if o.HypeStickerColour == nil {
+		return color.RGBA{R: 0xff, G: 0xd4, B: 0x00, A: 0xff}
+	}
+	return o.HypeStickerColour
+}
+
+func (o Options) hypeStickerSize() float64 {
+	if o.HypeStickerSize <= 0 {
+		return 170
+	}
+	return min(o.HypeStickerSize, MaxStickerSize)
+}
+
+func (o Options) hypeStickerCorner() Corner {
+	if o.HypeStickerCorner == "" {
+		return CornerTopRight
+	}
+	return o.HypeStickerCorner
+}
+
+func (o Options) shopStickerColour() color.Color {
+	if o.ShopStickerColour == nil {
+		return color.RGBA{R: 0xf2, G: 0x6a, B: 0x1b, A: 0xff}
+	}
+	return o.ShopStickerColour
+}
+
+func (o Options) shopStickerSize() float64 {
+	if o.ShopStickerSize <= 0 {
+		return 100
+	}
+	return min(o.ShopStickerSize, MaxStickerSize)
+}
+
+func (o Options) shopStickerCorner() Corner {
+	if o.ShopStickerCorner == "" {
+		return CornerBottomLeft
+	}
+	return o.ShopStickerCorner
+}
+
+func (o Options) priceText() string {
+	if o.PriceText == "" {
+		return "7.99"
+	}
+	return o.PriceText
+}
+
+func (o Options) priceCurrency() string {
+	if o.PriceCurrency == "" {
+		return "£"
+	}
+	return o.PriceCurrency
+}
+
+func (o Options) priceStyle() PriceStyle {
+	if o.PriceStyle == "" {
+		return PriceLabel
+	}
+	return o.PriceStyle
+}
+
+func (o Options) spineTextSize() float64 {
+	if o.SpineTextSize <= 0 {
+		return 28
+	}
+	return min(o.SpineTextSize, MaxSpineTextSize)
+}
+
+func (o Options) spineTextColour() color.Color {
+	if o.SpineTextColour == nil {
+		return color.RGBA{R: 225, G: 225, B: 225, A: 255}
+	}
+	return o.SpineTextColour
+}
+
+func (o Options) perspectiveTilt() float64 {
+	if o.PerspectiveTilt == 0 {
+		return 0.04
+	}
+	return o.PerspectiveTilt
 }
 
 // Process applies the jewel case frame and effects to the provided album art image.
 // The input image is scaled and cropped to fit the frame, then various effects are applied
-// based on the provided Options. Returns ErrAlreadyProcessed if the image appears to already
-// be processed (unless opts.Force is true). Returns the final framed image.
+// based on the provided Options. Returns ErrAlreadyProcessed if the image has already been
+// processed (unless opts.Force is true, in which case only the art within the existing
+// frame is reused). Returns the final framed image.
+//
+// The output contains an invisible marker used to detect that it has been processed.
+// It survives lossless formats such as PNG and WebP, but not JPEG; files written by
+// ProcessFile and ProcessReader also record the marker in their metadata.
 func Process(albumArt image.Image, opts Options) (image.Image, error) {
-	// Skip images that are already the output size unless forced
-	if !opts.Force {
-		bounds := albumArt.Bounds()
-		frameBounds := frame.Bounds()
-		if bounds.Dx() == frameBounds.Dx() && bounds.Dy() == frameBounds.Dy() {
-			return nil, ErrAlreadyProcessed
+	return ProcessContext(context.Background(), albumArt, opts)
+}
+
+// ProcessContext behaves like Process, but stops early and returns the context's error
+// if ctx is cancelled before processing is complete.
+func ProcessContext(ctx context.Context, albumArt image.Image, opts Options) (image.Image, error) {
+	result, _, err := process(ctx, &buffers{}, albumArt, opts, false)
+	return result, err
+}
+
+// ProcessWithReport behaves like Process, but also returns a Report detailing the
+// random choices that were made while processing the image.
+func ProcessWithReport(albumArt image.Image, opts Options) (image.Image, *Report, error) {
+	return process(context.Background(), &buffers{}, albumArt, opts, false)
+}
+
+// process implements Process. If marked is true, the image is treated as already processed
+// regardless of whether it carries the pixel marker (e.g. because the marker was found in
+// the file's metadata instead).
+func process(ctx context.Context, buf *buffers, albumArt image.Image, opts Options, marked bool) (image.Image, *Report, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	frames, err := candidateFrames(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Skip images that have already been processed unless forced. If forced, only take
+	// the art from within the old frame so we don't end up with a frame within a frame.
+	if marked || hasPixelMarker(albumArt) {
+		if !opts.Force {
+			return nil, nil, ErrAlreadyProcessed
+		}
+		if art, ok := matchFrame(albumArt, frames); ok {
+			albumArt = cropArt(buf, albumArt, art)
 		}
 	}
 
-	output := scaleAndCrop(albumArt)
+	rng := newRand(albumArt, opts)
+	report := &Report{}
+	selected := selectFrame(frames, rng, report)
+	if selected.variant > 0 {
+		report.Tray = opts.tray()
+		if report.Tray == TrayRandom {
+			report.Tray = Trays[rng.Intn(len(Trays))]
+		}
+		selected = scaleFrame(trayFrame(selected, report.Tray), opts.scale())
+	}
+	scale := float64(opts.scale())
 
-	if opts.ColourCorrection {
-		output = applyColourCorrection(output)
+	highBitDepth := opts.HighBitDepth && isHighBitDepth(albumArt)
+	if !highBitDepth {
+		albumArt = shrinkArt(buf, albumArt, selected.art.Size(), opts.crop(), opts.LinearLight)
 	}
-	if opts.EdgeSoftening {
-		output = applyEdgeSoftening(output)
+	if opts.Flatten {
+		albumArt = flattenArt(buf, albumArt, opts.flattenColour(), opts.resampler())
 	}
-	if opts.RoundedCorners {
-		output = applyRoundedCorners(output)
+
+	// deep holds the art instead of output while it is kept at 16 bits per channel, until
+	// a step that only works with 8 bits reduces it
+	var output *image.RGBA
+	var deep *image.RGBA64
+	if highBitDepth {
+		deep, err = scaleAndCrop64(buf, albumArt, selected.art.Size(), opts.crop(), opts.matteColour(), opts.resampler())
+	} else {
+		output, err = scaleAndCrop(buf, albumArt, selected.art.Size(), opts.crop(), opts.matteColour(), opts.resampler())
 	}
-	if opts.Reflection {
-		output = applyReflection(output)
+	if err != nil {
+		return nil, nil, err
 	}
-	if opts.RandomRotation {
-		output = applyRotation(output)
+	reduce := func() {
+		if deep != nil {
+			output = reduceDepth(buf, deep)
+			buf.recycle64(deep)
+			deep = nil
+		}
 	}
 
-	finalX := frameOffsetX
-	finalY := frameOffsetY
+	if opts.Sharpen {
+		reduce()
+		amount, radius := opts.sharpening()
+		sharpened := applySharpening(buf, output, amount, radius)
+		buf.recycle(output)
+		output = sharpened
+	}
+	if selected.artOverlay != nil {
+		reduce()
+		drawArtOverlay(output, selected)
+	}
+	ec := &effectContext{buf: buf, rng: rng, report: report, opts: opts, source: albumArt, scale: scale}
+	if selected.decorate != nil {
+		reduce()
+		output, err = selected.decorate(ec, output)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if opts.ParentalAdvisory {
+		reduce()
+		if err := drawParentalAdvisory(output); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	effects := opts.Effects
+	if effects == nil {
+		effects = opts.DefaultEffects()
+	}
+
+	for i := 0; i < len(effects); i++ {
+		e := effects[i]
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		if deep != nil {
+			if b, ok := e.(builtinEffect); ok && deepEffects[b.name] != nil {
+				deep = deepEffects[b.name](ec, deep)
+				continue
+			}
+			reduce()
+		}
+
+		// Effects that adjust each pixel on its own are combined with any that follow
+		// them, and applied together in one pass over the art
+		if b, ok := e.(builtinEffect); ok && b.rows != nil {
+			ops := []rowOp{b.rows(ec, output.Bounds())}
+			for ; i+1 < len(effects); i++ {
+				next, ok := effects[i+1].(builtinEffect)
+				if !ok || next.rows == nil {
+					break
+				}
+				ops = append(ops, next.rows(ec, output.Bounds()))
+			}
+			output = applyRowOps(output, ops...)
+			continue
+		}
+
+		// Built-in effects that don't work in place are finished with their input once
+		// they return, but custom effects might return part of it or keep hold of it
+		next := applyEffect(ec, e, output)
+		if _, ok := e.(builtinEffect); ok && next != output {
+			buf.recycle(output)
+		}
+		output = next
+	}
+
+	finalX := selected.art.Min.X
+	finalY := selected.art.Min.Y
 	if opts.RandomOffset {
-		finalX += int(rand.Float64()*17) - 8 // -8 to +8
-		finalY += int(rand.Float64()*11) - 5 // -5 to +5
+		maxX, maxY := opts.maxOffset()
+		finalX += (int(rng.Float64()*float64(2*maxX+1)) - maxX) * opts.scale() // -maxX to +maxX
+		finalY += (int(rng.Float64()*float64(2*maxY+1)) - maxY) * opts.scale() // -maxY to +maxY
+	}
+	report.Offset = image.Point{X: finalX, Y: finalY}
+
+	frameBounds := selected.img.Bounds()
+	if deep != nil && !opts.finishes8Bit(selected) {
+		result := buf.newRGBA64(image.Rect(0, 0, frameBounds.Dx(), frameBounds.Dy()))
+		draw.Draw(result, result.Bounds(), selected.img, frameBounds.Min, draw.Src)
+		opts.resampler().over64(result, deep.Bounds().Add(image.Point{X: finalX, Y: finalY}), deep, image.Point{})
+		if selected.front != nil {
+			draw.Draw(result, result.Bounds(), selected.front, selected.front.Bounds().Min, draw.Over)
+		}
+		embedPixelMarker64(result)
+		return result, report, nil
 	}
+	reduce()
 
-	result := image.NewRGBA(frame.Bounds())
-	draw.Draw(result, result.Bounds(), frame, image.Point{}, draw.Src)
-	draw.Draw(result, image.Rect(finalX, finalY, finalX+targetWidth, finalY+targetHeight), output, image.Point{}, draw.Over)
-	return result, nil
-}
+	result := buf.newRGBA(image.Rect(0, 0, frameBounds.Dx(), frameBounds.Dy()))
+	draw.Draw(result, result.Bounds(), selected.img, frameBounds.Min, draw.Src)
+	opts.resampler().over(result, output.Bounds().Add(image.Point{X: finalX, Y: finalY}), output, image.Point{})
+	if selected.front != nil {
+		draw.Draw(result, result.Bounds(), selected.front, selected.front.Bounds().Min, draw.Over)
+	}
 
-func loadImage(inputPath string) (image.Image, error) {
-	inputFile, err := os.Open(inputPath)
-	if err != nil {
-		return nil, err
+	if opts.SpineText != "" && !selected.spine.Empty() {
+		colour := opts.spineTextColour()
+		if opts.SpineTextColour == nil && selected.tray == TrayClear {
+			// The usual light text can't be read against a clear tray
+			colour = color.RGBA{R: 40, G: 40, B: 40, A: 255}
+		}
+		if err := drawSpineText(result, selected.spine, opts.SpineText, opts.spineTextSize()*scale, colour); err != nil {
+			return nil, nil, err
+		}
+	}
+	if opts.Yellowing {
+		// The plastic of the lid covers the art and the spine, but not anything stuck to
+		// the outside of the case
+		result = applyYellowing(result, rng, opts.yellowingStrength(), scale)
+	}
+	if opts.Obi {
+		art := output.Bounds().Add(image.Point{X: finalX, Y: finalY})
+		if err := drawObi(result, art, opts, rng); err != nil {
+			return nil, nil, err
+		}
+	}
+	if opts.HypeStickerText != "" {
+		art := output.Bounds().Add(image.Point{X: finalX, Y: finalY})
+		if err := drawHypeSticker(result, art, opts, rng, scale); err != nil {
+			return nil, nil, err
+		}
+	}
+	if opts.ShopStickerText != "" {
+		art := output.Bounds().Add(image.Point{X: finalX, Y: finalY})
+		if err := drawShopSticker(result, art, opts, scale); err != nil {
+			return nil, nil, err
+		}
+	}
+	if opts.PriceSticker {
+		art := output.Bounds().Add(image.Point{X: finalX, Y: finalY})
+		if err := drawPriceSticker(result, art, opts, rng, report); err != nil {
+			return nil, nil, err
+		}
+	}
+	if opts.Glare {
+		result = applyGlare(result, opts.GlareAngle, opts.glareWidth()*scale)
+	}
+	if opts.Scratches {
+		result = applyScratches(result, rng, opts.scratchDensity(), scale)
+	}
+	if opts.Dust {
+		result = applyDust(result, rng, opts.dustDensity(), scale)
+	}
+	if opts.Fingerprints {
+		result = applyFingerprints(result, rng, opts.fingerprintIntensity(), scale)
+	}
+	if opts.Cracks && rng.Float64() < opts.crackProbability() {
+		result, report.Cracks = applyCracks(result, rng, scale)
+	}
+	if opts.ShrinkWrap {
+		result = applyShrinkWrap(result, rng, scale)
+	}
+	if opts.Tilt {
+		tilted, err := applyTilt(buf, result, selected.spine, opts.TiltYaw, opts.TiltPitch)
+		if err != nil {
+			return nil, nil, err
+		}
+		buf.recycle(result)
+		result = tilted
 	}
-	defer inputFile.Close()
 
-	var img image.Image
-	ext := strings.ToLower(filepath.Ext(inputPath))
-	switch ext {
-	case ".jpg", ".jpeg":
-		img, err = jpeg.Decode(inputFile)
-	case ".png":
-		img, err = png.Decode(inputFile)
-	default:
-		return nil, fmt.Errorf("unsupported image format: %s", ext)
+	if opts.DropShadow {
+		shadowed := applyDropShadow(buf, result, opts.BackgroundColour, opts.BackgroundGradient)
+		buf.recycle(result)
+		result = shadowed
+	}
+
+	if opts.OutputWidth > 0 || opts.OutputHeight > 0 {
+		resized := resizeOutput(buf, result, opts.OutputWidth, opts.OutputHeight)
+		if resized != result {
+			buf.recycle(result)
+		}
+		result = resized
 	}
 
-	return img, err
+	embedPixelMarker(result)
+	return result, report, nil
 }
 
-func saveImage(img image.Image, outputPath string) error {
-	outputFile, err := os.Create(outputPath)
-	if err != nil {
-		return err
+// newRand returns the source of randomness used for the effects applied to an image.
+// This is opts.Rand if given; otherwise if opts.SeedFromContent is set it is seeded from
+// the image's pixels, so the same image always receives the same effects.
+func newRand(albumArt image.Image, opts Options) *rand.Rand {
+	if opts.Rand != nil {
+		return opts.Rand
 	}
-	defer outputFile.Close()
 
-	ext := strings.ToLower(filepath.Ext(outputPath))
-	switch ext {
-	case ".jpg", ".jpeg":
-		return jpeg.Encode(outputFile, img, &jpeg.Options{Quality: 95})
-	case ".png":
-		return png.Encode(outputFile, img)
-	default:
-		return fmt.Errorf("unsupported output format: %s", ext)
+	if !opts.SeedFromContent {
+		return rand.New(rand.NewSource(rand.Int63()))
 	}
+
+	h := sha256.New()
+	hashImage(h, albumArt)
+	return rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(h.Sum(nil)))))
 }
 
-// ProcessFile applies the jewel case effect to an image file and saves the result.
-// Reads from inputPath, applies effects, and writes to outputPath. The output format
-// is determined by the outputPath extension. Supports JPEG and PNG output formats.
-func ProcessFile(inputPath, outputPath string, opts Options) error {
-	img, err := loadImage(inputPath)
-	if err != nil {
-		return err
+// frameSpec describes a frame image, and the area within it (relative to the top-left
+// corner of the frame) that the art is placed in.
+type frameSpec struct {
+	img image.Image
+	art image.Rectangle
+
+	// spine, if set, is the area of img occupied by the case's spine, which the spine text
+	// is drawn along
+	spine image.Rectangle
+
+	// artOverlay, if set, is drawn over the art before any effects are applied
+	artOverlay *image.RGBA
+
+	// decorate, if set, is called to add details such as text to the art before any effects
+	// are applied. It may return a different image of the same size
+	decorate func(ctx *effectContext, art *image.RGBA) (*image.RGBA, error)
+
+	// front, if set, is the same size as img and drawn over the art once it has been
+	// placed, for parts of the frame that sit in front of it
+	front *image.RGBA
+
+	// variant, if set, is which version of the embedded jewel case photograph this is
+	variant int
+
+	// tray, if set, is the colour the tray of the embedded jewel case has been changed to
+	tray Tray
+}
+
+// candidateFrames returns all the frames that may be used when processing images with
+// the given options.
+func candidateFrames(opts Options) ([]frameSpec, error) {
+	jewelCase := len(opts.Frames) == 0 && opts.Frame == nil && (opts.Style == "" || opts.Style == StyleJewelCase)
+	if scale := opts.scale(); scale > MaxScale {
+		return nil, fmt.Errorf("scale %d must be between 1 and %d", scale, MaxScale)
+	} else if scale > 1 && !jewelCase {
+		return nil, fmt.Errorf("scale can only be used with the embedded jewel case")
+	}
+	if opts.OutputWidth > MaxOutputSize || opts.OutputHeight > MaxOutputSize {
+		return nil, fmt.Errorf("%w: output size %dx%d exceeds the limit of %d pixels in each direction", ErrImageTooLarge, opts.OutputWidth, opts.OutputHeight, MaxOutputSize)
+	}
+	if err := validFilter(opts.filter()); err != nil {
+		return nil, err
+	}
+	if err := validTray(opts.tray()); err != nil {
+		return nil, err
+	} else if opts.tray() != TrayBlack && !jewelCase {
+		return nil, fmt.Errorf("tray can only be used with the embedded jewel case")
 	}
 
-	result, err := Process(img, opts)
-	if err != nil {
-		return err
+	if len(opts.Frames) > 0 {
+		if len(opts.Frames) != len(opts.FrameOffsets) {
+			return nil, fmt.Errorf("got %d frames but %d frame offsets", len(opts.Frames), len(opts.FrameOffsets))
+		}
+
+		frames := make([]frameSpec, len(opts.Frames))
+		for i := range opts.Frames {
+			frames[i] = frameSpec{
+				img: opts.Frames[i],
+				art: image.Rectangle{Max: image.Point{X: targetWidth, Y: targetHeight}}.Add(opts.FrameOffsets[i]),
+			}
+		}
+		return frames, nil
 	}
 
-	return saveImage(result, outputPath)
-}
+	if opts.Frame != nil {
+		bounds := opts.Frame.Bounds()
+		if opts.FrameArtRect.Empty() || !opts.FrameArtRect.In(bounds) {
+			return nil, fmt.Errorf("frame art rectangle %v must be a non-empty area within the frame %v", opts.FrameArtRect, bounds)
+		}
+		spec := frameSpec{img: opts.Frame, art: opts.FrameArtRect.Sub(bounds.Min)}
+		if len(opts.FrameMasks) > 0 {
+			spec.front = image.NewRGBA(image.Rectangle{Max: bounds.Size()})
+			for _, m := range opts.FrameMasks {
+				if !m.In(bounds) {
+					return nil, fmt.Errorf("frame mask %v must be within the frame %v", m, bounds)
+				}
+				draw.Draw(spec.front, m.Sub(bounds.Min), opts.Frame, m.Min, draw.Src)
+			}
+		}
+		return []frameSpec{spec}, nil
+	}
+
+	if jewelCase {
+		variants := frameVariants()
+		if opts.FrameVariant == 0 {
+			return variants, nil
+		}
+		if opts.FrameVariant < 1 || opts.FrameVariant > len(variants) {
+			return nil, fmt.Errorf("frame variant %d must be between 1 and %d", opts.FrameVariant, len(variants))
+		}
+		return variants[opts.FrameVariant-1 : opts.FrameVariant], nil
+	}
 
-func scaleAndCrop(albumArt image.Image) *image.RGBA {
-	bounds := albumArt.Bounds()
-	width := bounds.Dx()
-	height := bounds.Dy()
+	spec, err := styleFrame(opts.Style)
+	if err != nil {
+		return nil, err
+	}
+	return []frameSpec{spec}, nil
+}
 
-	scale := max(float64(targetWidth)/float64(width), float64(targetHeight)/float64(height))
-	scaledWidth := int(float64(width) * scale)
-	scaledHeight := int(float64(height) * scale)
+// selectFrame returns the frame to use for an image. If multiple frames are available,
+// one is picked at random.
+func selectFrame(frames []frameSpec, rng *rand.Rand, report *Report) frameSpec {
+	report.FrameIndex = -1
+	f, i := frames[0], 0
+	if len(frames) > 1 {
+		i = rng.Intn(len(frames))
+		f = frames[i]
+	}
+	if f.variant > 0 {
+		report.FrameVariant = f.variant
+	} else if len(frames) > 1 {
+		report.FrameIndex = i
+	}
+	return f
+}
 
-	scaled := image.NewRGBA(image.Rect(0, 0, scaledWidth, scaledHeight))
-	xdraw.BiLinear.Scale(scaled, scaled.Bounds(), albumArt, albumArt.Bounds(), xdraw.Over, nil)
+// matchFrame finds the frame that a processed image was most likely made with, by
+// comparing aspect ratios so that images which have since been resized still match.
+// Returns the area of the image containing the art.
+func matchFrame(img image.Image, frames []frameSpec) (image.Rectangle, bool) {
+	bounds := img.Bounds()
+	for _, f := range frames {
+		// The image may also have been placed on a drop shadow background
+		frameBounds := f.img.Bounds()
+		for _, pad := range []int{0, dropShadowPadding(frameBounds.Size())} {
+			scaleX := float64(bounds.Dx()) / float64(frameBounds.Dx()+2*pad)
+			scaleY := float64(bounds.Dy()) / float64(frameBounds.Dy()+2*pad)
+			if math.Abs(scaleX-scaleY) > 0.01*scaleX {
+				continue
+			}
 
-	cropX := (scaledWidth - targetWidth) / 2
-	cropY := (scaledHeight - targetHeight) / 2
-	output := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
-	draw.Draw(output, output.Bounds(), scaled, image.Point{X: cropX, Y: cropY}, draw.Src)
+			art := f.art.Add(image.Point{X: pad, Y: pad})
+			return image.Rect(
+				int(math.Round(float64(art.Min.X)*scaleX)),
+				int(math.Round(float64(art.Min.Y)*scaleY)),
+				int(math.Round(float64(art.Max.X)*scaleX)),
+				int(math.Round(float64(art.Max.Y)*scaleY)),
+			), true
+		}
+	}
+	return image.Rectangle{}, false
+}
 
+// cropArt extracts the art window from an image that has already been framed.
+func cropArt(buf *buffers, img image.Image, art image.Rectangle) *image.RGBA {
+	output := buf.newRGBA(image.Rectangle{Max: art.Size()})
+	draw.Draw(output, output.Bounds(), img, img.Bounds().Min.Add(art.Min), draw.Src)
 	return output
 }
 
-func applyRotation(img *image.RGBA) *image.RGBA {
+// MaxOutputSize is the largest value allowed for Options.OutputWidth and OutputHeight, so
+// that a request for an enormous output can't exhaust the memory of a server.
+const MaxOutputSize = 10000
+
+// resizeOutput scales the finished image so it fits within the given width and height,
+// either of which may be zero to leave it unconstrained.
+func resizeOutput(buf *buffers, img *image.RGBA, width, height int) *image.RGBA {
 	bounds := img.Bounds()
+	scale := math.Inf(1)
+	if width > 0 {
+		scale = float64(width) / float64(bounds.Dx())
+	}
+	if height > 0 {
+		scale = math.Min(scale, float64(height)/float64(bounds.Dy()))
+	}
 
-	angle := (rand.Float64() - 0.5) * math.Pi / 180
-	cos := math.Abs(math.Cos(angle))
-	sin := math.Abs(math.Sin(angle))
-	scale := math.Min(1.0/(cos+sin), 1.0)
-
-	scaledSize := int(float64(targetWidth) * scale)
-	scaled := image.NewRGBA(image.Rect(0, 0, scaledSize, scaledSize))
-	xdraw.BiLinear.Scale(scaled, scaled.Bounds(), img, img.Bounds(), xdraw.Over, nil)
-
-	result := image.NewRGBA(bounds)
-	centerX, centerY := float64(targetWidth)/2, float64(targetHeight)/2
-
-	for y := 0; y < targetHeight; y++ {
-		for x := 0; x < targetWidth; x++ {
-			// Translate to center, rotate, translate back
-			fx := float64(x) - centerX
-			fy := float64(y) - centerY
-			rx := fx*math.Cos(-angle) - fy*math.Sin(-angle)
-			ry := fx*math.Sin(-angle) + fy*math.Cos(-angle)
-			rx += float64(scaledSize) / 2
-			ry += float64(scaledSize) / 2
-
-			// Bilinear interpolation for smooth edges
-			if rx >= 1 && ry >= 1 && rx < float64(scaledSize-1) && ry < float64(scaledSize-1) {
-				x0, y0 := int(rx), int(ry)
-				x1, y1 := x0+1, y0+1
-				fx, fy := rx-float64(x0), ry-float64(y0)
-
-				c00 := scaled.RGBAAt(x0, y0)
-				c01 := scaled.RGBAAt(x0, y1)
-				c10 := scaled.RGBAAt(x1, y0)
-				c11 := scaled.RGBAAt(x1, y1)
-
-				r := uint8(float64(c00.R)*(1-fx)*(1-fy) + float64(c10.R)*fx*(1-fy) +
-					float64(c01.R)*(1-fx)*fy + float64(c11.R)*fx*fy)
-				g := uint8(float64(c00.G)*(1-fx)*(1-fy) + float64(c10.G)*fx*(1-fy) +
-					float64(c01.G)*(1-fx)*fy + float64(c11.G)*fx*fy)
-				b := uint8(float64(c00.B)*(1-fx)*(1-fy) + float64(c10.B)*fx*(1-fy) +
-					float64(c01.B)*(1-fx)*fy + float64(c11.B)*fx*fy)
-				a := uint8(float64(c00.A)*(1-fx)*(1-fy) + float64(c10.A)*fx*(1-fy) +
-					float64(c01.A)*(1-fx)*fy + float64(c11.A)*fx*fy)
-
-				result.Set(x, y, color.RGBA{R: r, G: g, B: b, A: a})
-			}
-		}
+	size := image.Point{
+		X: max(int(math.Round(float64(bounds.Dx())*scale)), 1),
+		Y: max(int(math.Round(float64(bounds.Dy())*scale)), 1),
+	}
+	if size == bounds.Size() {
+		return img
 	}
 
+	result := buf.newRGBA(image.Rectangle{Max: size})
+	parallelScale(xdraw.CatmullRom, result, img, draw.Src)
 	return result
 }
 
-func applyReflection(img *image.RGBA) *image.RGBA {
+// applyRotation rotates the image about its centre by angle (in radians), shrinking it
+// so the corners stay within the original bounds. The rotation and scaling are done as a
+// single affine transform, so the art is only resampled once.
+func applyRotation(buf *buffers, img *image.RGBA, angle float64, rs resampler) *image.RGBA {
 	bounds := img.Bounds()
-	result := image.NewRGBA(bounds)
+	result := buf.newRGBA(bounds)
+	rs.transform(buf, result, rotationTransform(bounds, angle), img, draw.Src)
+	return result
+}
 
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			original := img.RGBAAt(x, y)
+// rotationTransform returns the transform used by applyRotation for an image with the
+// given bounds.
+func rotationTransform(bounds image.Rectangle, angle float64) f64.Aff3 {
+	sin, cos := math.Sin(angle), math.Cos(angle)
+	scale := math.Min(1.0/(math.Abs(cos)+math.Abs(sin)), 1.0)
+
+	// Map the centre of the image onto itself, rotating and scaling around it
+	cx := float64(bounds.Min.X) + float64(bounds.Dx())/2
+	cy := float64(bounds.Min.Y) + float64(bounds.Dy())/2
+	a, b := scale*cos, -scale*sin
+	d, e := scale*sin, scale*cos
+	return f64.Aff3{
+		a, b, cx - a*cx - b*cy,
+		d, e, cy - d*cx - e*cy,
+	}
+}
 
-			fx := float64(x) / float64(targetWidth)
-			fy := float64(y) / float64(targetHeight)
+// setPix sets the pixel at (x, y) to c. Unlike SetRGBA, it assumes the point is within
+// the image's bounds.
+func setPix(img *image.RGBA, x, y int, c color.RGBA) {
+	o := img.PixOffset(x, y)
+	img.Pix[o+0], img.Pix[o+1], img.Pix[o+2], img.Pix[o+3] = c.R, c.G, c.B, c.A
+}
 
-			// Add slight white highlight based on diagonal position
-			reflectionIntensity := math.Max(0, 0.3*(1-(fx+fy)/2))
-			r := math.Min(255, float64(original.R)+reflectionIntensity*40)
-			g := math.Min(255, float64(original.G)+reflectionIntensity*40)
-			b := math.Min(255, float64(original.B)+reflectionIntensity*40)
+// sampleBilinear returns the colour at the given fractional position in the image,
+// interpolating between the four nearest pixels. The position must lie within the
+// image bounds.
+func sampleBilinear(img *image.RGBA, px, py float64) color.RGBA {
+	bounds := img.Bounds()
+	x0, y0 := int(px), int(py)
+	x1, y1 := min(x0+1, bounds.Max.X-1), min(y0+1, bounds.Max.Y-1)
+	fx, fy := px-float64(x0), py-float64(y0)
+
+	c00 := img.RGBAAt(x0, y0)
+	c01 := img.RGBAAt(x0, y1)
+	c10 := img.RGBAAt(x1, y0)
+	c11 := img.RGBAAt(x1, y1)
+
+	// Rounded rather than truncated, so that blending identical pixels gives them back
+	r := uint8(float64(c00.R)*(1-fx)*(1-fy) + float64(c10.R)*fx*(1-fy) +
+		float64(c01.R)*(1-fx)*fy + float64(c11.R)*fx*fy + 0.5)
+	g := uint8(float64(c00.G)*(1-fx)*(1-fy) + float64(c10.G)*fx*(1-fy) +
+		float64(c01.G)*(1-fx)*fy + float64(c11.G)*fx*fy + 0.5)
+	b := uint8(float64(c00.B)*(1-fx)*(1-fy) + float64(c10.B)*fx*(1-fy) +
+		float64(c01.B)*(1-fx)*fy + float64(c11.B)*fx*fy + 0.5)
+	a := uint8(float64(c00.A)*(1-fx)*(1-fy) + float64(c10.A)*fx*(1-fy) +
+		float64(c01.A)*(1-fx)*fy + float64(c11.A)*fx*fy + 0.5)
+
+	return color.RGBA{R: r, G: g, B: b, A: a}
+}
 
-			result.Set(x, y, color.RGBA{
-				R: uint8(r),
-				G: uint8(g),
-				B: uint8(b),
-				A: original.A,
-			})
-		}
+// applyPerspective warps the image as if it were turned slightly away from the viewer.
+// A positive tilt shortens the right edge, and a negative tilt the left edge, by the
+// given fraction of the image's height.
+func applyPerspective(buf *buffers, img *image.RGBA, tilt float64) *image.RGBA {
+	bounds := img.Bounds()
+	result := buf.newRGBA(bounds)
+
+	w := float64(bounds.Dx() - 1)
+	h := float64(bounds.Dy() - 1)
+	inset := math.Abs(tilt) * h / 2
+
+	// Corners of the destination quad, clockwise from the top left
+	leftInset, rightInset := 0.0, inset
+	if tilt < 0 {
+		leftInset, rightInset = inset, 0.0
 	}
+	m := squareToQuad(
+		0, leftInset,
+		w, rightInset,
+		w, h-rightInset,
+		0, h-leftInset,
+	).inverse()
+
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				u, v := m.apply(float64(x-bounds.Min.X), float64(y-bounds.Min.Y))
+				// Allow for rounding error, which would otherwise lose the edges of the art
+				const epsilon = 1e-9
+				if u >= -epsilon && v >= -epsilon && u <= 1+epsilon && v <= 1+epsilon {
+					u, v = min(max(u, 0), 1), min(max(v, 0), 1)
+					setPix(result, x, y, sampleBilinear(img, float64(bounds.Min.X)+u*w, float64(bounds.Min.Y)+v*h))
+				}
+			}
+		}
+	})
 
 	return result
 }
 
-func applyColourCorrection(img *image.RGBA) *image.RGBA {
+// projection is a 3x3 matrix describing a projective transform.
+type projection [3][3]float64
+
+// squareToQuad returns the projective transform that maps the unit square onto the
+// quad with the given corners, listed clockwise from the one the origin maps to.
+func squareToQuad(x0, y0, x1, y1, x2, y2, x3, y3 float64) projection {
+	dx1, dx2, dx3 := x1-x2, x3-x2, x0-x1+x2-x3
+	dy1, dy2, dy3 := y1-y2, y3-y2, y0-y1+y2-y3
+
+	if dx3 == 0 && dy3 == 0 {
+		return projection{
+			{x1 - x0, x2 - x1, x0},
+			{y1 - y0, y2 - y1, y0},
+			{0, 0, 1},
+		}
+	}
+
+	den := dx1*dy2 - dx2*dy1
+	g := (dx3*dy2 - dx2*dy3) / den
+	h := (dx1*dy3 - dx3*dy1) / den
+	return projection{
+		{x1 - x0 + g*x1, x3 - x0 + h*x3, x0},
+		{y1 - y0 + g*y1, y3 - y0 + h*y3, y0},
+		{g, h, 1},
+	}
+}
+
+// inverse returns the transform that undoes this one.
+func (p projection) inverse() projection {
+	a, b, c := p[0][0], p[0][1], p[0][2]
+	d, e, f := p[1][0], p[1][1], p[1][2]
+	g, h, i := p[2][0], p[2][1], p[2][2]
+
+	// The adjugate is sufficient, as the result is only ever used homogeneously
+	return projection{
+		{e*i - f*h, c*h - b*i, b*f - c*e},
+		{f*g - d*i, a*i - c*g, c*d - a*f},
+		{d*h - e*g, b*g - a*h, a*e - b*d},
+	}
+}
+
+// apply maps the given point through the transform.
+func (p projection) apply(x, y float64) (float64, float64) {
+	w := p[2][0]*x + p[2][1]*y + p[2][2]
+	return (p[0][0]*x + p[0][1]*y + p[0][2]) / w, (p[1][0]*x + p[1][1]*y + p[1][2]) / w
+}
+
+func applyGlare(img *image.RGBA, angle, width float64) *image.RGBA {
 	bounds := img.Bounds()
-	corrected := image.NewRGBA(bounds)
 
+	rad := angle * math.Pi / 180
+	sin, cos := math.Sin(rad), math.Cos(rad)
+	centerX := float64(bounds.Min.X+bounds.Max.X) / 2
+	centerY := float64(bounds.Min.Y+bounds.Max.Y) / 2
+	sigma := width / 4
+
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			pix := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+
+			for i, x := 0, bounds.Min.X; i < len(pix); i, x = i+4, x+1 {
+				// Perpendicular distance from the line through the centre at the given angle
+				dist := -(float64(x)-centerX)*sin + (float64(y)-centerY)*cos
+
+				// Gaussian falloff across the width of the band
+				glareIntensity := math.Exp(-(dist * dist) / (2 * sigma * sigma))
+				pix[i] = uint8(math.Min(255, float64(pix[i])+glareIntensity*90))
+				pix[i+1] = uint8(math.Min(255, float64(pix[i+1])+glareIntensity*90))
+				pix[i+2] = uint8(math.Min(255, float64(pix[i+2])+glareIntensity*90))
+			}
+		}
+	})
+
+	return img
+}
+
+// isGrayscale reports whether every pixel in the image has (near enough) equal red,
+// green and blue components.
+func isGrayscale(img image.Image) bool {
+	switch img.(type) {
+	case *image.Gray, *image.Gray16:
+		return true
+	}
+
+	const tolerance = 2 << 8
+	bounds := img.Bounds()
 	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
 		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, a := img.At(x, y).RGBA()
-			fr := float64(uint8(r >> 8))
-			fg := float64(uint8(g >> 8))
-			fb := float64(uint8(b >> 8))
+			r, g, b, _ := img.At(x, y).RGBA()
+			if absDiff(r, g) > tolerance || absDiff(g, b) > tolerance || absDiff(r, b) > tolerance {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func absDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// Colours for Options.TintColour.
+var (
+	TintCool  color.Color = color.RGBA{B: 255, A: 255}
+	TintWarm  color.Color = color.RGBA{R: 255, G: 120, A: 255}
+	TintSepia color.Color = color.RGBA{R: 255, G: 180, B: 100, A: 255}
+)
+
+// TintColours maps the names of the built-in tint colours to their values.
+var TintColours = map[string]color.Color{
+	"cool":  TintCool,
+	"warm":  TintWarm,
+	"sepia": TintSepia,
+}
+
+// ParseColour parses an opaque colour in the form "#rrggbb" or "rrggbb".
+func ParseColour(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return nil, fmt.Errorf("expected a six digit hex colour, got %q", s)
+	}
 
-			// Reduce saturation
-			avg := (fr + fg + fb) / 3
-			fr = fr*0.9 + avg*0.1
-			fg = fg*0.9 + avg*0.1
-			fb = fb*0.9 + avg*0.1
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("expected a six digit hex colour, got %q", s)
+	}
 
-			// Reduce contrast
-			fr = fr*0.95 + 128*0.05
-			fg = fg*0.95 + 128*0.05
-			fb = fb*0.95 + 128*0.05
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
 
-			// Blue tint
-			fb = math.Min(255, fb*1.02)
+// colourGrade describes the adjustments made by colour correction.
+type colourGrade struct {
+	// tint is how much each channel is boosted by, as a fraction
+	tint [3]float64
 
-			corrected.Set(x, y, color.RGBA{
-				R: uint8(math.Max(0, math.Min(255, fr))),
-				G: uint8(math.Max(0, math.Min(255, fg))),
-				B: uint8(math.Max(0, math.Min(255, fb))),
-				A: uint8(a >> 8),
-			})
+	// saturation and contrast are the fractions they are reduced by
+	saturation, contrast float64
+}
+
+// colourGrade returns the adjustments colour correction makes with these options.
+func (o Options) colourGrade() colourGrade {
+	r, g, b, _ := o.tintColour().RGBA()
+	amount := o.tintAmount()
+	return colourGrade{
+		tint:       [3]float64{amount * float64(r) / 0xffff, amount * float64(g) / 0xffff, amount * float64(b) / 0xffff},
+		saturation: o.saturationReduction(),
+		contrast:   o.contrastReduction(),
+	}
+}
+
+// colourCorrectionTables holds the colour correction maths precomputed for every input,
+// so that grading a pixel only needs table lookups.
+type colourCorrectionTables struct {
+	// channel maps a channel value to its graded value before the average of all three
+	// channels is added, for each of red, green and blue, in 32.32 fixed point
+	channel [3][256]int64
+	// average maps the sum of all three channels to the graded contribution of their
+	// average, for each of red, green and blue, in 32.32 fixed point
+	average [3][3*255 + 1]int64
+	// contrast maps a channel value to its final value when only contrast is reduced
+	contrast [256]uint8
+}
+
+func newColourCorrectionTables(grade colourGrade) *colourCorrectionTables {
+	t := &colourCorrectionTables{}
+	keep := 1 - grade.contrast
+	for c, tint := range grade.tint {
+		for i := range t.channel[c] {
+			t.channel[c][i] = toFixed((float64(i)*(1-grade.saturation)*keep + 128*grade.contrast) * (1 + tint))
+		}
+		for i := range t.average[c] {
+			t.average[c][i] = toFixed(float64(i) / 3 * grade.saturation * keep * (1 + tint))
 		}
 	}
+	for i := range t.contrast {
+		t.contrast[i] = uint8(math.Max(0, math.Min(255, float64(i)*(1-grade.contrast)+128*grade.contrast)))
+	}
+	return t
+}
 
-	return corrected
+// toFixed converts v to 32.32 fixed point.
+func toFixed(v float64) int64 {
+	return int64(math.Round(v * (1 << 32)))
 }
 
-func applyRoundedCorners(img *image.RGBA) *image.RGBA {
-	bounds := img.Bounds()
-	result := image.NewRGBA(bounds)
+// fromFixed converts a 32.32 fixed point value to a channel value, clamping it to the
+// range of a uint8 and discarding the fraction.
+func fromFixed(v int64) uint8 {
+	return uint8(min(max(v>>32, 0), 255))
+}
 
-	topLeftRadius := 6 + rand.Float64()*6
-	topRightRadius := 6 + rand.Float64()*6
-	bottomLeftRadius := 6 + rand.Float64()*6
-	bottomRightRadius := 6 + rand.Float64()*6
+// colourCorrectionOp returns an adjustment that grades each pixel as described by grade,
+// or only reduces its contrast if monochrome is set.
+func colourCorrectionOp(grade colourGrade, monochrome bool) rowOp {
+	tables := newColourCorrectionTables(grade)
+
+	return func(_ int, pix []uint8) {
+		for i := 0; i < len(pix); i += 4 {
+			r, g, b := pix[i], pix[i+1], pix[i+2]
+
+			if monochrome {
+				// Reduce contrast only
+				pix[i] = tables.contrast[r]
+				pix[i+1] = tables.contrast[g]
+				pix[i+2] = tables.contrast[b]
+				continue
+			}
 
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// Reduce saturation by mixing in the average, then reduce contrast and tint,
+			// all of which the tables have folded together
+			sum := int(r) + int(g) + int(b)
+			pix[i] = fromFixed(tables.channel[0][r] + tables.average[0][sum])
+			pix[i+1] = fromFixed(tables.channel[1][g] + tables.average[1][sum])
+			pix[i+2] = fromFixed(tables.channel[2][b] + tables.average[2][sum])
+		}
+	}
+}
+
+// roundedCornersOp returns an adjustment that clears the pixels outside the rounded
+// corners of an image with the given bounds. radii are for the top-left, top-right,
+// bottom-left and bottom-right corners.
+func roundedCornersOp(bounds image.Rectangle, radii [4]float64) rowOp {
+	topLeftRadius := radii[0]
+	topRightRadius := radii[1]
+	bottomLeftRadius := radii[2]
+	bottomRightRadius := radii[3]
+	reach := int(math.Ceil(max(topLeftRadius, topRightRadius, bottomLeftRadius, bottomRightRadius)))
+
+	return func(y int, pix []uint8) {
+		if y-bounds.Min.Y >= reach && bounds.Max.Y-y-1 >= reach {
+			// No corner reaches this far into the image
+			return
+		}
+
+		for i, x := 0, bounds.Min.X; i < len(pix); i, x = i+4, x+1 {
 			distFromLeft := float64(x - bounds.Min.X)
 			distFromRight := float64(bounds.Max.X - x - 1)
 			distFromTop := float64(y - bounds.Min.Y)
@@ -326,14 +1733,10 @@ func applyRoundedCorners(img *image.RGBA) *image.RGBA {
 				shouldRound(distFromRight, distFromTop, topRightRadius) ||
 				shouldRound(distFromLeft, distFromBottom, bottomLeftRadius) ||
 				shouldRound(distFromRight, distFromBottom, bottomRightRadius) {
-				result.Set(x, y, color.NRGBA{})
-			} else {
-				result.Set(x, y, img.At(x, y))
+				clear(pix[i : i+4])
 			}
 		}
 	}
-
-	return result
 }
 
 func shouldRound(dist1, dist2, radius float64) bool {
@@ -345,40 +1748,26 @@ func shouldRound(dist1, dist2, radius float64) bool {
 	return cornerDist > 0
 }
 
-func applyEdgeSoftening(img *image.RGBA) *image.RGBA {
-	bounds := img.Bounds()
-	result := image.NewRGBA(bounds)
-
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			distFromLeft := float64(x - bounds.Min.X)
-			distFromRight := float64(bounds.Max.X - x - 1)
-			distFromTop := float64(y - bounds.Min.Y)
-			distFromBottom := float64(bounds.Max.Y - y - 1)
-
-			minDist := math.Min(
-				math.Min(distFromLeft, distFromRight),
-				math.Min(distFromTop, distFromBottom),
-			)
-
-			if minDist < 2 {
-				alpha := minDist / 2.0
-				if alpha < 1.0 {
-					r, g, b, _ := img.At(x, y).RGBA()
-					newAlpha := uint8(255.0 * alpha)
-					result.Set(x, y, color.NRGBA{
-						R: uint8(r >> 8),
-						G: uint8(g >> 8),
-						B: uint8(b >> 8),
-						A: newAlpha,
-					})
-					continue
+// edgeSofteningOp returns an adjustment that fades an image with the given bounds out
+// over the given number of pixels at its edges.
+func edgeSofteningOp(bounds image.Rectangle, width int) rowOp {
+	return func(y int, pix []uint8) {
+		for i, x := 0, bounds.Min.X; i < len(pix); i, x = i+4, x+1 {
+			minDist := min(x-bounds.Min.X, bounds.Max.X-x-1, y-bounds.Min.Y, bounds.Max.Y-y-1)
+			if minDist >= width {
+				if x < bounds.Max.X-width {
+					// Skip over the middle of the row
+					i, x = i+4*(bounds.Max.X-width-1-x), bounds.Max.X-width-1
 				}
+				continue
 			}
 
-			result.Set(x, y, img.At(x, y))
+			// The colour is scaled by the new alpha, as color.NRGBA does when converted
+			a := uint32(float64(pix[i+3]) * (float64(minDist) / float64(width)))
+			for c := range 3 {
+				pix[i+c] = uint8(uint32(pix[i+c]) * 0x101 * a / 0xff >> 8)
+			}
+			pix[i+3] = uint8(a)
 		}
 	}
-
-	return result
 }
@@ -0,0 +1,336 @@
+package jewelcase
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// testGrades are colour grades covering the default, no change, and strong adjustments.
+var testGrades = []colourGrade{
+	Options{}.withDefaults().colourGrade(),
+	{},
+	{tint: [3]float64{0.1, 0.05, -0.02}, saturation: 0.3, contrast: 0.2},
+	{tint: [3]float64{0.5, 0.5, 0.5}, saturation: 1, contrast: 1},
+}
+
+// gradeReference grades a pixel with the colour correction maths done in floating point
+// for every pixel, as it was before the tables were introduced.
+func gradeReference(grade colourGrade, p []uint8) {
+	avg := (float64(p[0]) + float64(p[1]) + float64(p[2])) / 3 * grade.saturation
+	for c := range 3 {
+		v := float64(p[c])*(1-grade.saturation) + avg
+		v = v*(1-grade.contrast) + 128*grade.contrast
+		v = math.Min(255, v*(1+grade.tint[c]))
+		p[c] = uint8(math.Max(0, math.Min(255, v)))
+	}
+}
+
+func TestColourCorrectionMatchesReference(t *testing.T) {
+	for _, grade := range testGrades {
+		op := colourCorrectionOp(grade, false)
+		got, want := make([]uint8, 4), make([]uint8, 4)
+		for r := range 256 {
+			for g := range 256 {
+				for b := range 256 {
+					got[0], got[1], got[2], got[3] = uint8(r), uint8(g), uint8(b), 255
+					copy(want, got)
+					op(0, got)
+					gradeReference(grade, want)
+
+					// The tables are exact where the floating point maths can fall just
+					// short of a whole number, so they may differ by one level
+					for c := range 3 {
+						if d := int(got[c]) - int(want[c]); d < -1 || d > 1 {
+							t.Fatalf("grade %+v, colour %d,%d,%d: got %v, want %v", grade, r, g, b, got[:3], want[:3])
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestColourCorrectionMonochromeOnlyReducesContrast(t *testing.T) {
+	grade := testGrades[2]
+	op := colourCorrectionOp(grade, true)
+	for v := range 256 {
+		p := []uint8{uint8(v), uint8(v), uint8(v), 255}
+		op(0, p)
+		want := uint8(float64(v)*(1-grade.contrast) + 128*grade.contrast)
+		if p[0] != want || p[1] != want || p[2] != want {
+			t.Fatalf("grey %d: got %v, want %d", v, p[:3], want)
+		}
+	}
+}
+
+// benchmarkArt returns an image with varied colours, the size of the art in the case.
+func benchmarkArt() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 750, 750))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	return img
+}
+
+func BenchmarkColourCorrection(b *testing.B) {
+	img := benchmarkArt()
+	grade := testGrades[0]
+	for b.Loop() {
+		applyRowOps(img, colourCorrectionOp(grade, false))
+	}
+}
+
+func BenchmarkColourCorrectionReference(b *testing.B) {
+	img := benchmarkArt()
+	grade := testGrades[0]
+	for b.Loop() {
+		parallelRows(img.Bounds(), func(minY, maxY int) {
+			for y := minY; y < maxY; y++ {
+				pix := img.Pix[img.PixOffset(0, y):img.PixOffset(img.Bounds().Max.X, y)]
+				for i := 0; i < len(pix); i += 4 {
+					gradeReference(grade, pix[i:i+4])
+				}
+			}
+		})
+	}
+}
+
+// testArt returns a small image with varied colours to use as album art.
+func testArt() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	for y := range 100 {
+		for x := range 100 {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x * 2), G: uint8(y * 2), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+// solidImage returns an opaque image of the given size filled with c.
+func solidImage(width, height int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestFramesAreAllSelected(t *testing.T) {
+	frames := []image.Image{
+		solidImage(800, 800, color.RGBA{R: 255, A: 255}),
+		solidImage(900, 850, color.RGBA{G: 255, A: 255}),
+		solidImage(1000, 900, color.RGBA{B: 255, A: 255}),
+	}
+	opts := Options{
+		Frames:       frames,
+		FrameOffsets: []image.Point{{10, 10}, {20, 30}, {40, 50}},
+	}
+
+	seen := make([]bool, len(frames))
+	for seed := range 30 {
+		opts.Rand = rand.New(rand.NewSource(int64(seed)))
+		result, report, err := ProcessWithReport(testArt(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.FrameIndex < 0 || report.FrameIndex >= len(frames) {
+			t.Fatalf("seed %d: got frame index %d", seed, report.FrameIndex)
+		}
+		seen[report.FrameIndex] = true
+
+		if got, want := result.Bounds().Size(), frames[report.FrameIndex].Bounds().Size(); got != want {
+			t.Errorf("seed %d: got output size %v for frame %d, want %v", seed, got, report.FrameIndex, want)
+		}
+	}
+
+	for i, ok := range seen {
+		if !ok {
+			t.Errorf("frame %d was never selected", i)
+		}
+	}
+}
+
+func TestPreserveGrayscaleKeepsArtNeutral(t *testing.T) {
+	art := image.NewGray(image.Rect(0, 0, 100, 100))
+	for i := range art.Pix {
+		art.Pix[i] = uint8(i)
+	}
+
+	for _, preserve := range []bool{false, true} {
+		result, report, err := ProcessWithReport(art, Options{
+			ColourCorrection:  true,
+			PreserveGrayscale: preserve,
+			Rand:              rand.New(rand.NewSource(1)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Only look at the art, as the frame around it is in colour
+		neutral := true
+		area := image.Rectangle{Min: report.Offset, Max: report.Offset.Add(image.Pt(750, 750))}.Inset(10)
+		for y := area.Min.Y; y < area.Max.Y && neutral; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				r, g, b, _ := result.At(x, y).RGBA()
+				if r != g || g != b {
+					neutral = false
+					break
+				}
+			}
+		}
+
+		if neutral != preserve {
+			t.Errorf("with PreserveGrayscale %t, got neutral art %t", preserve, neutral)
+		}
+	}
+}
+
+func TestGlareBand(t *testing.T) {
+	tests := []struct {
+		angle     float64
+		lit, dark []image.Point
+	}{
+		{0, []image.Point{{10, 100}, {100, 100}, {190, 100}}, []image.Point{{100, 10}, {100, 190}}},
+		{90, []image.Point{{100, 10}, {100, 100}, {100, 190}}, []image.Point{{10, 100}, {190, 100}}},
+		// Clockwise from horizontal, so the band runs from top left to bottom right
+		{45, []image.Point{{20, 20}, {100, 100}, {180, 180}}, []image.Point{{180, 20}, {20, 180}}},
+	}
+
+	base := color.RGBA{R: 100, G: 100, B: 100, A: 255}
+	for _, tt := range tests {
+		img := applyGlare(solidImage(200, 200, base), tt.angle, 40)
+		for _, p := range tt.lit {
+			if c := img.RGBAAt(p.X, p.Y); c.R < base.R+80 {
+				t.Errorf("angle %g: pixel at %v is %v, want it brightened by the glare", tt.angle, p, c)
+			}
+		}
+		for _, p := range tt.dark {
+			if c := img.RGBAAt(p.X, p.Y); c != base {
+				t.Errorf("angle %g: pixel at %v is %v, want it untouched", tt.angle, p, c)
+			}
+		}
+	}
+}
+
+// opaqueHeight returns the number of opaque pixels in column x of img.
+func opaqueHeight(img *image.RGBA, x int) int {
+	n := 0
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		if img.RGBAAt(x, y).A == 255 {
+			n++
+		}
+	}
+	return n
+}
+
+func TestPerspectiveShortensFarEdge(t *testing.T) {
+	for _, tilt := range []float64{0.2, -0.2} {
+		img := applyPerspective(&buffers{}, solidImage(200, 200, color.RGBA{R: 255, G: 255, B: 255, A: 255}), tilt)
+		left, right := opaqueHeight(img, 1), opaqueHeight(img, 198)
+
+		near, far := left, right
+		if tilt < 0 {
+			near, far = right, left
+		}
+		if near < 198 {
+			t.Errorf("tilt %g: near edge is %d pixels tall, want close to the full 200", tilt, near)
+		}
+		// The far edge loses tilt*height/2 from both the top and bottom
+		if far < 158 || far > 162 {
+			t.Errorf("tilt %g: far edge is %d pixels tall, want around 160", tilt, far)
+		}
+	}
+}
+
+// artDifference returns the mean absolute difference between the red, green and blue
+// values of the art in two images processed with the given reports.
+func artDifference(a image.Image, aReport *Report, b image.Image, bReport *Report) float64 {
+	var total, n float64
+	for y := 0; y < targetHeight; y++ {
+		for x := 0; x < targetWidth; x++ {
+			ar, ag, ab, _ := a.At(aReport.Offset.X+x, aReport.Offset.Y+y).RGBA()
+			br, bg, bb, _ := b.At(bReport.Offset.X+x, bReport.Offset.Y+y).RGBA()
+			for _, d := range []float64{float64(ar) - float64(br), float64(ag) - float64(bg), float64(ab) - float64(bb)} {
+				total += math.Abs(d) / 257
+				n++
+			}
+		}
+	}
+	return total / n
+}
+
+func TestForcedReprocessingDoesNotDoubleFrame(t *testing.T) {
+	opts := Options{Rand: rand.New(rand.NewSource(1))}
+	first, firstReport, err := ProcessWithReport(testArt(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Process(first, opts); !errors.Is(err, ErrAlreadyProcessed) {
+		t.Fatalf("reprocessing without Force gave %v, want ErrAlreadyProcessed", err)
+	}
+
+	opts.Force = true
+	second, secondReport, err := ProcessWithReport(first, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := second.Bounds().Size(), first.Bounds().Size(); got != want {
+		t.Errorf("reprocessed image is %v, want %v", got, want)
+	}
+	// If the whole of the first image had been used as the art, the frame would appear
+	// again, shrunk, within the art
+	if diff := artDifference(first, firstReport, second, secondReport); diff > 2 {
+		t.Errorf("art differs by %.1f levels on average after reprocessing, want it unchanged", diff)
+	}
+}
+
+func TestParseColour(t *testing.T) {
+	want := color.RGBA{R: 0x12, G: 0x34, B: 0xab, A: 255}
+	for _, s := range []string{"#1234ab", "1234AB"} {
+		if c, err := ParseColour(s); err != nil || c != want {
+			t.Errorf("ParseColour(%q) = %v, %v, want %v", s, c, err, want)
+		}
+	}
+	for _, s := range []string{"", "#123", "#1234abc", "12345g"} {
+		if _, err := ParseColour(s); err == nil {
+			t.Errorf("ParseColour(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestCostlyOptionsAreBounded(t *testing.T) {
+	opts := Options{
+		DustDensity:          1e9,
+		ScratchDensity:       1e9,
+		FingerprintIntensity: 1e9,
+		SharpenRadius:        1e9,
+		HypeStickerSize:      1e9,
+		ShopStickerSize:      1e9,
+		SpineTextSize:        1e9,
+	}
+	_, sharpenRadius := opts.sharpening()
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"dust density", opts.dustDensity(), MaxDustDensity},
+		{"scratch density", opts.scratchDensity(), MaxScratchDensity},
+		{"fingerprint intensity", opts.fingerprintIntensity(), MaxFingerprintIntensity},
+		{"sharpen radius", sharpenRadius, MaxSharpenRadius},
+		{"hype sticker size", opts.hypeStickerSize(), MaxStickerSize},
+		{"shop sticker size", opts.shopStickerSize(), MaxStickerSize},
+		{"spine text size", opts.spineTextSize(), MaxSpineTextSize},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s of 1e9 gave %g, want %g", tt.name, tt.got, tt.want)
+		}
+	}
+}
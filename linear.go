@@ -0,0 +1,220 @@
+package jewelcase
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+)
+
+// resampler scales, rotates and composites the art, either directly on its sRGB values
+// or in linear light. Averaging sRGB values darkens fine detail and soft edges, because
+// sRGB isn't proportional to the amount of light; converting to linear light first
+// avoids this, at some extra cost.
+type resampler struct {
+	filter xdraw.Interpolator
+	linear bool
+}
+
+// resampler returns the resampler to use with these options.
+func (o Options) resampler() resampler {
+	return resampler{filter: o.filter().interpolator(), linear: o.LinearLight}
+}
+
+// scale scales the whole of src to fill dst, splitting the work across CPUs.
+func (rs resampler) scale(buf *buffers, dst *image.RGBA, src image.Image, op draw.Op) {
+	if !rs.linear {
+		parallelScale(rs.filter, dst, src, op)
+		return
+	}
+
+	lin := toLinear(buf, src)
+	dr := dst.Bounds()
+	scaled := buf.newRGBA64(dr)
+	parallelRows(dr, func(minY, maxY int) {
+		band := scaled.SubImage(image.Rect(dr.Min.X, minY, dr.Max.X, maxY)).(*image.RGBA64)
+		rs.filter.Scale(band, dr, lin, lin.Bounds(), draw.Src, nil)
+	})
+	rs.composite(dst, scaled, op)
+	buf.recycle64(lin)
+	buf.recycle64(scaled)
+}
+
+// transform draws src onto dst through the affine transform s2d, splitting the work
+// across CPUs.
+func (rs resampler) transform(buf *buffers, dst *image.RGBA, s2d f64.Aff3, src *image.RGBA, op draw.Op) {
+	if !rs.linear {
+		parallelTransform(rs.filter, dst, s2d, src, op)
+		return
+	}
+
+	lin := toLinear(buf, src)
+	dr := dst.Bounds()
+	transformed := buf.newRGBA64(dr)
+	parallelRows(dr, func(minY, maxY int) {
+		band := transformed.SubImage(image.Rect(dr.Min.X, minY, dr.Max.X, maxY)).(*image.RGBA64)
+		rs.filter.Transform(band, s2d, lin, lin.Bounds(), draw.Src, nil)
+	})
+	rs.composite(dst, transformed, op)
+	buf.recycle64(lin)
+	buf.recycle64(transformed)
+}
+
+// over draws src over the r part of dst, starting from the point sp in src, like
+// draw.Draw.
+func (rs resampler) over(dst *image.RGBA, r image.Rectangle, src *image.RGBA, sp image.Point) {
+	if !rs.linear {
+		draw.Draw(dst, r, src, sp, draw.Over)
+		return
+	}
+
+	t := linearTables()
+	r = r.Intersect(dst.Bounds()).Intersect(src.Bounds().Add(r.Min.Sub(sp)))
+	parallelRows(r, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			sy := y - r.Min.Y + sp.Y
+			for x := r.Min.X; x < r.Max.X; x++ {
+				s := src.Pix[src.PixOffset(x-r.Min.X+sp.X, sy):]
+				d := dst.Pix[dst.PixOffset(x, y):]
+				switch s[3] {
+				case 0:
+				case 0xff:
+					copy(d[:4], s[:4])
+				default:
+					sl, dl := t.pixelToLinear(s), t.pixelToLinear(d)
+					for c := range dl {
+						dl[c] = sl[c] + dl[c]*(0xffff-sl[3])/0xffff
+					}
+					t.pixelFromLinear(d, dl)
+				}
+			}
+		}
+	})
+}
+
+// composite converts the linear light image src back to sRGB and draws it onto dst,
+// which must have the same bounds, with the given op.
+func (rs resampler) composite(dst *image.RGBA, src *image.RGBA64, op draw.Op) {
+	t := linearTables()
+	bounds := dst.Bounds()
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				c := src.RGBA64At(x, y)
+				sl := [4]uint32{uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A)}
+				d := dst.Pix[dst.PixOffset(x, y):]
+				if op == draw.Over && sl[3] < 0xffff {
+					dl := t.pixelToLinear(d)
+					for i := range dl {
+						sl[i] += dl[i] * (0xffff - uint32(c.A)) / 0xffff
+					}
+				}
+				t.pixelFromLinear(d, sl)
+			}
+		}
+	})
+}
+
+// toLinear returns a copy of the image in linear light, with 16 bits per channel so
+// that dark colours keep their precision.
+func toLinear(buf *buffers, src image.Image) *image.RGBA64 {
+	bounds := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok {
+		rgba = buf.newRGBA(bounds)
+		draw.Draw(rgba, bounds, src, bounds.Min, draw.Src)
+	}
+
+	t := linearTables()
+	lin := buf.newRGBA64(bounds)
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				l := t.pixelToLinear(rgba.Pix[rgba.PixOffset(x, y):])
+				lin.SetRGBA64(x, y, color64(l))
+			}
+		}
+	})
+	if !ok {
+		buf.recycle(rgba)
+	}
+	return lin
+}
+
+// linearTable converts between 8 bit sRGB values and 16 bit linear light values.
+type linearTable struct {
+	toLinear [256]uint32
+	toSRGB   [0x10000]uint8
+}
+
+var linearTables = sync.OnceValue(func() *linearTable {
+	t := &linearTable{}
+	for i := range t.toLinear {
+		c := float64(i) / 0xff
+		if c <= 0.04045 {
+			c /= 12.92
+		} else {
+			c = math.Pow((c+0.055)/1.055, 2.4)
+		}
+		t.toLinear[i] = uint32(math.Round(c * 0xffff))
+	}
+	for i := range t.toSRGB {
+		l := float64(i) / 0xffff
+		if l <= 0.0031308 {
+			l *= 12.92
+		} else {
+			l = 1.055*math.Pow(l, 1/2.4) - 0.055
+		}
+		t.toSRGB[i] = uint8(math.Round(l * 0xff))
+	}
+	return t
+})
+
+// pixelToLinear converts a premultiplied sRGB pixel to premultiplied linear light.
+func (t *linearTable) pixelToLinear(p []uint8) [4]uint32 {
+	a := uint32(p[3])
+	switch a {
+	case 0:
+		return [4]uint32{}
+	case 0xff:
+		return [4]uint32{t.toLinear[p[0]], t.toLinear[p[1]], t.toLinear[p[2]], 0xffff}
+	}
+
+	var l [4]uint32
+	for c := range 3 {
+		// Unpremultiply to find the real colour, then premultiply again once linear
+		v := min((uint32(p[c])*0xff+a/2)/a, 0xff)
+		l[c] = t.toLinear[v] * a / 0xff
+	}
+	l[3] = a * 0x101
+	return l
+}
+
+// pixelFromLinear sets a premultiplied sRGB pixel from premultiplied linear light.
+func (t *linearTable) pixelFromLinear(p []uint8, l [4]uint32) {
+	a := min(l[3], 0xffff)
+	switch {
+	case a == 0:
+		p[0], p[1], p[2], p[3] = 0, 0, 0, 0
+		return
+	case a == 0xffff:
+		p[0], p[1], p[2], p[3] = t.toSRGB[min(l[0], 0xffff)], t.toSRGB[min(l[1], 0xffff)], t.toSRGB[min(l[2], 0xffff)], 0xff
+		return
+	}
+
+	p8 := (a + 0x80) / 0x101
+	for c := range 3 {
+		v := min(l[c]*0xffff/a, 0xffff)
+		p[c] = uint8((uint32(t.toSRGB[v])*p8 + 0x7f) / 0xff)
+	}
+	p[3] = uint8(p8)
+}
+
+// color64 converts a premultiplied linear light pixel to a colour.
+func color64(l [4]uint32) color.RGBA64 {
+	return color.RGBA64{R: uint16(l[0]), G: uint16(l[1]), B: uint16(l[2]), A: uint16(l[3])}
+}
@@ -0,0 +1,181 @@
+package jewelcase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"io"
+	"math"
+)
+
+// markerText identifies images written by jewelcase. It is stored as a comment in JPEG
+// output and as the Software text chunk in PNG output.
+const markerText = "jewelcase"
+
+// pixelMarker is hidden in the least significant bits of the blue channel along the top
+// row of every processed image, so the marker survives in lossless formats that don't
+// carry our metadata and in images that never touch a file. The alpha channel is left
+// alone, so opaque images stay opaque.
+const pixelMarker uint32 = 0x4a43a5e1
+
+const pixelMarkerBits = 32
+
+// embedPixelMarker hides pixelMarker in the top row of img.
+func embedPixelMarker(img *image.RGBA) {
+	bounds := img.Bounds()
+	if bounds.Dx() < pixelMarkerBits || bounds.Dy() < 1 {
+		return
+	}
+
+	for i := range pixelMarkerBits {
+		o := img.PixOffset(bounds.Min.X+i, bounds.Min.Y)
+		b := img.Pix[o+2]&^1 | uint8(pixelMarker>>(pixelMarkerBits-1-i))&1
+
+		// Colour values are premultiplied, so can't exceed the alpha. Only pixels that
+		// are already transparent need it raising
+		img.Pix[o+2] = b
+		img.Pix[o+3] = max(img.Pix[o+3], b)
+	}
+}
+
+// embedPixelMarker64 behaves like embedPixelMarker for a 16-bit image. The marker is kept
+// in the bits that remain the least significant if it is reduced to 8 bits per channel.
+func embedPixelMarker64(img *image.RGBA64) {
+	bounds := img.Bounds()
+	if bounds.Dx() < pixelMarkerBits || bounds.Dy() < 1 {
+		return
+	}
+
+	for i := range pixelMarkerBits {
+		x := bounds.Min.X + i
+		c := img.RGBA64At(x, bounds.Min.Y)
+		c.B = c.B&^0x100 | uint16(pixelMarker>>(pixelMarkerBits-1-i))&1<<8
+		c.A = max(c.A, c.B)
+		img.SetRGBA64(x, bounds.Min.Y, c)
+	}
+}
+
+// hasPixelMarker reports whether img carries the marker added by embedPixelMarker.
+func hasPixelMarker(img image.Image) bool {
+	bounds := img.Bounds()
+	if bounds.Dx() < pixelMarkerBits || bounds.Dy() < 1 {
+		return false
+	}
+
+	var v uint32
+	for i := range pixelMarkerBits {
+		_, _, b, _ := img.At(bounds.Min.X+i, bounds.Min.Y).RGBA()
+		v = v<<1 | (b>>8)&1
+	}
+	return v == pixelMarker
+}
+
+// encodeMarked encodes img in the given format like Encode, but also records markerText
+// in the file's metadata if the format supports it, along with meta if
+// opts.PreserveMetadata is set.
+func encodeMarked(w io.Writer, img image.Image, format string, opts Options, meta metadata) error {
+	var data bytes.Buffer
+	if err := Encode(&data, img, format, opts); err != nil {
+		return err
+	}
+
+	out := data.Bytes()
+	switch normaliseFormat(format) {
+	case "jpeg":
+		out = addJPEGComment(out, markerText)
+	case "png":
+		out = addPNGText(out, "Software", markerText)
+	}
+
+	if opts.PreserveMetadata {
+		out = meta.addTo(out, img, format)
+	}
+
+	_, err := w.Write(out)
+	return err
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// addJPEGComment inserts a COM segment containing text directly after the SOI marker.
+func addJPEGComment(data []byte, text string) []byte {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 || len(text) > math.MaxUint16-2 {
+		return data
+	}
+
+	out := make([]byte, 0, len(data)+len(text)+4)
+	out = append(out, data[:2]...)
+	out = append(out, 0xff, 0xfe)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(text)+2))
+	out = append(out, text...)
+	return append(out, data[2:]...)
+}
+
+// addPNGText inserts a tEXt chunk directly after the IHDR chunk.
+func addPNGText(data []byte, keyword, text string) []byte {
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4
+	if len(data) < ihdrEnd || !bytes.HasPrefix(data, pngSignature) {
+		return data
+	}
+
+	body := append([]byte("tEXt"+keyword+"\x00"), text...)
+	out := make([]byte, 0, len(data)+len(body)+8)
+	out = append(out, data[:ihdrEnd]...)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(body)-4))
+	out = append(out, body...)
+	out = binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(body))
+	return append(out, data[ihdrEnd:]...)
+}
+
+// hasFileMarker reports whether the encoded image in data has markerText in its metadata.
+func hasFileMarker(data []byte) bool {
+	switch {
+	case len(data) >= 2 && data[0] == 0xff && data[1] == 0xd8:
+		return hasJPEGComment(data[2:], markerText)
+	case bytes.HasPrefix(data, pngSignature):
+		return hasPNGText(data[len(pngSignature):], "Software", markerText)
+	default:
+		return false
+	}
+}
+
+// hasJPEGComment looks for a COM segment matching text in the segments preceding the
+// image data.
+func hasJPEGComment(data []byte, text string) bool {
+	for len(data) >= 4 && data[0] == 0xff {
+		marker := data[1]
+		if marker == 0xda || marker == 0xd9 {
+			// Start of scan or end of image: no more metadata
+			return false
+		}
+
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if length < 2 || len(data) < length+2 {
+			return false
+		}
+		if marker == 0xfe && string(data[4:length+2]) == text {
+			return true
+		}
+		data = data[length+2:]
+	}
+	return false
+}
+
+// hasPNGText looks for a tEXt chunk with the given keyword and text in the chunks
+// preceding the image data.
+func hasPNGText(data []byte, keyword, text string) bool {
+	want := keyword + "\x00" + text
+	for len(data) >= 12 {
+		length := int(binary.BigEndian.Uint32(data[:4]))
+		kind := string(data[4:8])
+		if kind == "IDAT" || kind == "IEND" || length > len(data)-12 {
+			return false
+		}
+		if kind == "tEXt" && string(data[8:8+length]) == want {
+			return true
+		}
+		data = data[12+length:]
+	}
+	return false
+}
@@ -0,0 +1,323 @@
+package jewelcase
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"io"
+	"math"
+	"slices"
+)
+
+// metadata holds the metadata from an image file that Options.PreserveMetadata carries
+// over to the output.
+type metadata struct {
+	// exif is the TIFF-structured EXIF data, without any "Exif\0\0" header
+	exif []byte
+
+	// icc is the ICC colour profile. Only RGB profiles are kept, as the output is always RGB
+	icc []byte
+}
+
+var (
+	jpegExifHeader = []byte("Exif\x00\x00")
+	jpegICCHeader  = []byte("ICC_PROFILE\x00")
+)
+
+// jpegICCChunkSize is the most profile data that fits in one APP2 segment, after the
+// segment length and the ICC header, sequence number and count.
+const jpegICCChunkSize = math.MaxUint16 - 2 - 14
+
+// readMetadata extracts the EXIF data and ICC profile from an encoded JPEG, PNG or WebP
+// image. Anything it can't find or understand is left out.
+func readMetadata(data []byte) metadata {
+	var m metadata
+	switch {
+	case len(data) >= 2 && data[0] == 0xff && data[1] == 0xd8:
+		m = readJPEGMetadata(data[2:])
+	case bytes.HasPrefix(data, pngSignature):
+		m = readPNGMetadata(data[len(pngSignature):])
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		m = readWebPMetadata(data[12:])
+	}
+
+	if len(m.icc) < 20 || string(m.icc[16:20]) != "RGB " {
+		m.icc = nil
+	}
+	if m.exif != nil {
+		// The orientation has already been applied to the pixels, so mustn't be applied again
+		m.exif = slices.Clone(m.exif)
+		resetExifOrientation(m.exif)
+	}
+	return m
+}
+
+// readJPEGMetadata reads the APP1 EXIF segment and APP2 ICC profile segments preceding
+// the image data.
+func readJPEGMetadata(data []byte) metadata {
+	var (
+		m      metadata
+		chunks [][]byte
+	)
+	for len(data) >= 4 && data[0] == 0xff {
+		marker := data[1]
+		if marker == 0xda || marker == 0xd9 {
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if length < 2 || len(data) < length+2 {
+			break
+		}
+
+		segment := data[4 : length+2]
+		switch {
+		case marker == 0xe1 && m.exif == nil && bytes.HasPrefix(segment, jpegExifHeader):
+			m.exif = segment[len(jpegExifHeader):]
+		case marker == 0xe2 && bytes.HasPrefix(segment, jpegICCHeader) && len(segment) >= len(jpegICCHeader)+2:
+			// Each chunk has a 1-based sequence number and the total number of chunks
+			seq, count := int(segment[len(jpegICCHeader)]), int(segment[len(jpegICCHeader)+1])
+			if chunks == nil {
+				chunks = make([][]byte, count)
+			}
+			if seq >= 1 && seq <= len(chunks) {
+				chunks[seq-1] = segment[len(jpegICCHeader)+2:]
+			}
+		}
+		data = data[length+2:]
+	}
+
+	missing := func(chunk []byte) bool { return chunk == nil }
+	if len(chunks) > 0 && !slices.ContainsFunc(chunks, missing) {
+		m.icc = bytes.Join(chunks, nil)
+	}
+	return m
+}
+
+// readPNGMetadata reads the eXIf and iCCP chunks preceding the image data.
+func readPNGMetadata(data []byte) metadata {
+	var m metadata
+	for len(data) >= 12 {
+		length := int(binary.BigEndian.Uint32(data[:4]))
+		kind := string(data[4:8])
+		if kind == "IDAT" || kind == "IEND" || length > len(data)-12 {
+			break
+		}
+
+		body := data[8 : 8+length]
+		switch kind {
+		case "eXIf":
+			m.exif = body
+		case "iCCP":
+			// A profile name, a null separator, the compression method (always zlib), then
+			// the compressed profile
+			if i := bytes.IndexByte(body, 0); i >= 0 && i+2 <= len(body) {
+				if r, err := zlib.NewReader(bytes.NewReader(body[i+2:])); err == nil {
+					m.icc, _ = io.ReadAll(r)
+				}
+			}
+		}
+		data = data[12+length:]
+	}
+	return m
+}
+
+// readWebPMetadata reads the EXIF and ICCP chunks from the body of a WebP file's RIFF
+// container.
+func readWebPMetadata(data []byte) metadata {
+	var m metadata
+	for len(data) >= 8 {
+		length := int(binary.LittleEndian.Uint32(data[4:8]))
+		if length > len(data)-8 {
+			break
+		}
+
+		body := data[8 : 8+length]
+		switch string(data[:4]) {
+		case "EXIF":
+			// Some writers include the JPEG-style header, though they aren't meant to
+			m.exif = bytes.TrimPrefix(body, jpegExifHeader)
+		case "ICCP":
+			m.icc = body
+		}
+
+		// Chunks are padded to an even length
+		data = data[min(8+length+length%2, len(data)):]
+	}
+	return m
+}
+
+// resetExifOrientation sets the orientation tag in the first IFD of the TIFF-structured
+// EXIF data to 1 (the right way up), if it has one.
+func resetExifOrientation(tiff []byte) {
+	if len(tiff) < 8 {
+		return
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	entries := tiff[offset+2:]
+	for i := 0; i < count && len(entries) >= 12; i++ {
+		const tagOrientation, typeShort = 0x0112, 3
+		if order.Uint16(entries[0:2]) == tagOrientation && order.Uint16(entries[2:4]) == typeShort {
+			order.PutUint16(entries[8:10], 1)
+			return
+		}
+		entries = entries[12:]
+	}
+}
+
+// addTo inserts the metadata into the image encoded in data, in the given format. Formats
+// that metadata can't be added to are returned unchanged.
+func (m metadata) addTo(data []byte, img image.Image, format string) []byte {
+	if m.exif == nil && m.icc == nil {
+		return data
+	}
+
+	switch normaliseFormat(format) {
+	case "jpeg":
+		return m.addToJPEG(data)
+	case "png":
+		return m.addToPNG(data)
+	case "webp":
+		return m.addToWebP(data, img.Bounds().Size())
+	default:
+		return data
+	}
+}
+
+// addToJPEG inserts APP1 and APP2 segments for the metadata directly after the SOI marker.
+func (m metadata) addToJPEG(data []byte) []byte {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return data
+	}
+
+	var segments []byte
+	if m.exif != nil && len(m.exif)+len(jpegExifHeader) <= math.MaxUint16-2 {
+		segments = append(segments, 0xff, 0xe1)
+		segments = binary.BigEndian.AppendUint16(segments, uint16(len(m.exif)+len(jpegExifHeader)+2))
+		segments = append(segments, jpegExifHeader...)
+		segments = append(segments, m.exif...)
+	}
+
+	chunks := slices.Collect(slices.Chunk(m.icc, jpegICCChunkSize))
+	if len(chunks) <= math.MaxUint8 {
+		for i, chunk := range chunks {
+			segments = append(segments, 0xff, 0xe2)
+			segments = binary.BigEndian.AppendUint16(segments, uint16(len(chunk)+len(jpegICCHeader)+4))
+			segments = append(segments, jpegICCHeader...)
+			segments = append(segments, byte(i+1), byte(len(chunks)))
+			segments = append(segments, chunk...)
+		}
+	}
+
+	out := make([]byte, 0, len(data)+len(segments))
+	out = append(out, data[:2]...)
+	out = append(out, segments...)
+	return append(out, data[2:]...)
+}
+
+// addToPNG inserts iCCP and eXIf chunks for the metadata directly after the IHDR chunk.
+func (m metadata) addToPNG(data []byte) []byte {
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4
+	if len(data) < ihdrEnd || !bytes.HasPrefix(data, pngSignature) {
+		return data
+	}
+
+	var chunks []byte
+	appendChunk := func(kind string, body []byte) {
+		chunk := append([]byte(kind), body...)
+		chunks = binary.BigEndian.AppendUint32(chunks, uint32(len(body)))
+		chunks = append(chunks, chunk...)
+		chunks = binary.BigEndian.AppendUint32(chunks, crc32.ChecksumIEEE(chunk))
+	}
+
+	if m.icc != nil {
+		body := bytes.NewBufferString("ICC profile\x00\x00")
+		w := zlib.NewWriter(body)
+		w.Write(m.icc)
+		w.Close()
+		appendChunk("iCCP", body.Bytes())
+	}
+	if m.exif != nil {
+		appendChunk("eXIf", m.exif)
+	}
+
+	out := make([]byte, 0, len(data)+len(chunks))
+	out = append(out, data[:ihdrEnd]...)
+	out = append(out, chunks...)
+	return append(out, data[ihdrEnd:]...)
+}
+
+// addToWebP converts a simple WebP file to the extended format, so that it can carry
+// ICCP and EXIF chunks for the metadata. size is the size of the image.
+func (m metadata) addToWebP(data []byte, size image.Point) []byte {
+	if len(data) < 20 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return data
+	}
+
+	bitstream := data[12:]
+	var flags byte
+	switch string(bitstream[:4]) {
+	case "VP8L":
+		// The alpha flag is bit 28 of the lossless header, after the signature byte
+		if len(bitstream) >= 13 && binary.LittleEndian.Uint32(bitstream[9:13])&(1<<28) != 0 {
+			flags |= 0x10
+		}
+	case "VP8 ":
+	default:
+		// Already extended or not something we understand, so leave it be
+		return data
+	}
+
+	var chunks []byte
+	appendChunk := func(kind string, body []byte) {
+		chunks = append(chunks, kind...)
+		chunks = binary.LittleEndian.AppendUint32(chunks, uint32(len(body)))
+		chunks = append(chunks, body...)
+		if len(body)%2 == 1 {
+			chunks = append(chunks, 0)
+		}
+	}
+
+	if m.icc != nil {
+		flags |= 0x20
+	}
+	if m.exif != nil {
+		flags |= 0x08
+	}
+
+	header := []byte{flags, 0, 0, 0}
+	header = append(header, byte(size.X-1), byte((size.X-1)>>8), byte((size.X-1)>>16))
+	header = append(header, byte(size.Y-1), byte((size.Y-1)>>8), byte((size.Y-1)>>16))
+	appendChunk("VP8X", header)
+	if m.icc != nil {
+		appendChunk("ICCP", m.icc)
+	}
+	chunks = append(chunks, bitstream...)
+	if m.exif != nil {
+		appendChunk("EXIF", m.exif)
+	}
+
+	out := make([]byte, 0, len(chunks)+12)
+	out = append(out, "RIFF"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(chunks)+4))
+	out = append(out, "WEBP"...)
+	return append(out, chunks...)
+}
@@ -0,0 +1,85 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+	"sync"
+)
+
+const (
+	minidiscWidth  = 680
+	minidiscHeight = 760
+)
+
+// minidiscCaseRect is the area of the MiniDisc frame covered by the case.
+var minidiscCaseRect = image.Rect(10, 10, 10+minidiscWidth, 10+minidiscHeight)
+
+// minidiscArtRect is the small square window at the top of the MiniDisc case that the
+// insert shows through.
+var minidiscArtRect = image.Rect(70, 40, 70+560, 40+560)
+
+// minidiscShutter is the metal shutter of the cartridge, which can be seen through the
+// case below the insert.
+var minidiscShutter = image.Rect(190, 622, 510, 748)
+
+// minidiscFrame renders the case and cartridge used for StyleMiniDisc.
+var minidiscFrame = sync.OnceValue(func() frameSpec {
+	return frameSpec{
+		img: renderMiniDiscCase(),
+		art: minidiscArtRect,
+	}
+})
+
+// renderMiniDiscCase draws a clear MiniDisc case with the cartridge inside it. Below the
+// insert the cartridge's dark plastic and its brushed metal shutter show through the case.
+func renderMiniDiscCase() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, minidiscCaseRect.Max.X+10, minidiscCaseRect.Max.Y+10))
+	cartridge := image.Rect(minidiscCaseRect.Min.X+24, minidiscArtRect.Max.Y-20, minidiscCaseRect.Max.X-24, minidiscCaseRect.Max.Y-10)
+
+	for y := minidiscCaseRect.Min.Y; y < minidiscCaseRect.Max.Y; y++ {
+		for x := minidiscCaseRect.Min.X; x < minidiscCaseRect.Max.X; x++ {
+			fx, fy := float64(x)+0.5, float64(y)+0.5
+			coverage := roundedRectCoverage(fx, fy, minidiscCaseRect, 14)
+			if coverage == 0 {
+				continue
+			}
+
+			// Smoked clear plastic, catching the light around the rim
+			red, green, blue, alpha := 70.0, 70.0, 76.0, 0.8
+			if edge := roundedRectDistance(fx, fy, minidiscCaseRect, 14); edge < 5 {
+				red, green, blue, alpha = 190-edge*20, 190-edge*20, 196-edge*20, 0.95
+			}
+
+			p := image.Point{X: x, Y: y}
+			switch {
+			case p.In(minidiscShutter):
+				// Brushed aluminium, with a recess for the drive's spring near the top
+				t := (fy - float64(minidiscShutter.Min.Y)) / float64(minidiscShutter.Dy())
+				v := 200 - 50*t + 4*math.Sin(fy*2.3) + 3*math.Sin(fy*0.9+fx*0.004)
+				if y < minidiscShutter.Min.Y+18 && x > minidiscShutter.Min.X+40 && x < minidiscShutter.Min.X+110 {
+					v -= 60
+				}
+				if d := math.Min(math.Min(fx-float64(minidiscShutter.Min.X), float64(minidiscShutter.Max.X)-fx),
+					math.Min(fy-float64(minidiscShutter.Min.Y), float64(minidiscShutter.Max.Y)-fy)); d < 2 {
+					v -= 50
+				}
+				red, green, blue, alpha = v, v, v+4, 1
+			case roundedRectDistance(fx, fy, cartridge, 10) >= 0:
+				// The dark grey plastic of the cartridge, with ridges to grip it by
+				v := 48.0
+				if x < cartridge.Min.X+40 || x > cartridge.Max.X-40 {
+					v += 8 * math.Sin(fy/2.5)
+				}
+				red, green, blue, alpha = v, v, v+3, 1
+			}
+
+			a := alpha * coverage
+			o := img.PixOffset(x, y)
+			img.Pix[o+0] = uint8(math.Min(red, 255) * a)
+			img.Pix[o+1] = uint8(math.Min(green, 255) * a)
+			img.Pix[o+2] = uint8(math.Min(blue, 255) * a)
+			img.Pix[o+3] = uint8(255 * a)
+		}
+	}
+	return img
+}
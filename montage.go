@@ -0,0 +1,167 @@
+package jewelcase
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+)
+
+// MontageLayout selects how Montage lays out the covers.
+type MontageLayout string
+
+const (
+	// MontageGrid lays the covers out flat in rows and columns. This is the default.
+	MontageGrid MontageLayout = "grid"
+
+	// MontageShelf stands each row of covers on a wooden shelf, casting shadows on the
+	// wall behind them.
+	MontageShelf MontageLayout = "shelf"
+)
+
+// MontageOptions configures how Montage lays out the covers.
+type MontageOptions struct {
+	// Layout is the arrangement of the covers (defaults to MontageGrid)
+	Layout MontageLayout
+
+	// Columns is the number of covers in each row (defaults to enough to make the
+	// montage roughly square)
+	Columns int
+
+	// Spacing is the gap in pixels between the covers, and around the edges of the image
+	Spacing int
+
+	// Background fills the image behind the covers (defaults to transparent)
+	Background color.Color
+}
+
+const (
+	// shelfDepth and shelfFront are the heights of the top surface and the front edge of a
+	// shelf, as a fraction of the height of the covers standing on it
+	shelfDepth = 0.06
+	shelfFront = 0.05
+)
+
+// Montage lays many processed covers out in a single image, as a grid or on shelves.
+// The covers are scaled to the height of the first one, and each is centred within a
+// cell as wide as the widest cover.
+func Montage(covers []image.Image, opts MontageOptions) (*image.RGBA, error) {
+	if len(covers) == 0 {
+		return nil, errors.New("no covers for montage")
+	}
+	if opts.Columns < 0 || opts.Spacing < 0 {
+		return nil, fmt.Errorf("columns (%d) and spacing (%d) must not be negative", opts.Columns, opts.Spacing)
+	}
+
+	scaled, err := scaleCovers(covers)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := opts.Columns
+	if columns == 0 {
+		columns = int(math.Ceil(math.Sqrt(float64(len(scaled)))))
+	}
+	columns = min(columns, len(scaled))
+	rows := (len(scaled) + columns - 1) / columns
+
+	cell := image.Point{Y: scaled[0].Bounds().Dy()}
+	for _, cover := range scaled {
+		cell.X = max(cell.X, cover.Bounds().Dx())
+	}
+
+	var shelf, depth int
+	switch opts.Layout {
+	case "", MontageGrid:
+	case MontageShelf:
+		depth = int(math.Round(float64(cell.Y) * shelfDepth))
+		shelf = depth + int(math.Round(float64(cell.Y)*shelfFront))
+	default:
+		return nil, fmt.Errorf("unknown montage layout %q", opts.Layout)
+	}
+
+	// Each row is followed by its shelf, if any. The covers stand on the top surface of
+	// the shelf, a third of the way in from its front edge
+	back := depth * 2 / 3
+	rowHeight := cell.Y + shelf - back
+	result := image.NewRGBA(image.Rect(0, 0,
+		columns*cell.X+(columns+1)*opts.Spacing,
+		rows*(rowHeight+opts.Spacing)+opts.Spacing,
+	))
+	if opts.Background != nil {
+		draw.Draw(result, result.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+	}
+
+	layer := image.NewRGBA(result.Bounds())
+	for i, cover := range scaled {
+		row, column := i/columns, i%columns
+		size := cover.Bounds().Size()
+		at := image.Point{
+			X: opts.Spacing + column*(cell.X+opts.Spacing) + (cell.X-size.X)/2,
+			Y: opts.Spacing + row*(rowHeight+opts.Spacing) + (cell.Y - size.Y),
+		}
+		draw.Draw(layer, cover.Bounds().Add(at), cover, image.Point{}, draw.Src)
+	}
+
+	if shelf == 0 {
+		draw.Draw(result, result.Bounds(), layer, image.Point{}, draw.Over)
+		return result, nil
+	}
+
+	// The covers stand on the shelves, and cast shadows onto both them and the wall
+	rng := rand.New(rand.NewSource(5))
+	for row := range rows {
+		top := opts.Spacing + row*(rowHeight+opts.Spacing) + cell.Y - back
+		drawShelf(result, image.Rect(0, top, result.Bounds().Dx(), top+shelf), depth, rng)
+	}
+	castShadow(result, layer, dropShadowPadding(cell))
+	draw.Draw(result, result.Bounds(), layer, image.Point{}, draw.Over)
+	return result, nil
+}
+
+// drawShelf draws a wooden shelf filling area: a lighter top surface depth pixels high,
+// and below it the darker front edge. The shelf casts a soft shadow onto the wall below
+// it, into the space left by the spacing between rows.
+func drawShelf(img *image.RGBA, area image.Rectangle, depth int, rng *rand.Rand) {
+	grain := newFractalNoise(rng, area.Dx()/20+1, area.Dy()*4+1, 3, 3)
+	bounds := img.Bounds()
+
+	for y := area.Min.Y; y < min(area.Max.Y+area.Dy(), bounds.Max.Y); y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			o := img.PixOffset(x, y)
+			p := img.Pix[o : o+4 : o+4]
+
+			if y >= area.Max.Y {
+				// The shadow under the shelf, fading away down the wall
+				s := 0.45 * (1 - float64(y-area.Max.Y)/float64(area.Dy()))
+				s *= s / 0.45
+				for c := range 3 {
+					p[c] = uint8(float64(p[c]) * (1 - s))
+				}
+				p[3] = uint8(255*s + float64(p[3])*(1-s))
+				continue
+			}
+
+			// Long streaks of grain, with the top surface catching more light than the front
+			// and a bright arris where they meet
+			g := grain.at(float64(x-area.Min.X)/20, float64(y-area.Min.Y)*4)
+			light := 1.0
+			switch d := y - area.Min.Y; {
+			case d < depth:
+				light = 1.15 - 0.15*float64(d)/float64(max(depth, 1))
+			case d == depth:
+				light = 1.3
+			default:
+				light = 0.8
+			}
+			v := light * (0.8 + 0.35*g)
+			p[0] = uint8(math.Min(128*v, 255))
+			p[1] = uint8(math.Min(88*v, 255))
+			p[2] = uint8(math.Min(54*v, 255))
+			p[3] = 255
+		}
+	}
+}
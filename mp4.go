@@ -0,0 +1,174 @@
+package jewelcase
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"math"
+)
+
+var errMalformedMP4 = errors.New("malformed MP4 file")
+
+// mp4Atom is the position of an atom (or box) within an MP4 file.
+type mp4Atom struct {
+	kind       string
+	start, end int
+	headerSize int
+}
+
+// children returns the range of the file containing the atom's children.
+func (a mp4Atom) children() (int, int) {
+	start := a.start + a.headerSize
+	if a.kind == "meta" {
+		// meta is a full box, with a version and flags before its children
+		start += 4
+	}
+	return start, a.end
+}
+
+// mp4Atoms returns the atoms directly within data[start:end].
+func mp4Atoms(data []byte, start, end int) ([]mp4Atom, error) {
+	var atoms []mp4Atom
+	for pos := start; pos < end; {
+		if pos+8 > end {
+			return nil, errMalformedMP4
+		}
+
+		size, headerSize := int(binary.BigEndian.Uint32(data[pos:])), 8
+		switch size {
+		case 0:
+			size = end - pos
+		case 1:
+			if pos+16 > end || binary.BigEndian.Uint64(data[pos+8:]) > uint64(end-pos) {
+				return nil, errMalformedMP4
+			}
+			size, headerSize = int(binary.BigEndian.Uint64(data[pos+8:])), 16
+		}
+		if size < headerSize || pos+size > end {
+			return nil, errMalformedMP4
+		}
+
+		atoms = append(atoms, mp4Atom{kind: string(data[pos+4 : pos+8]), start: pos, end: pos + size, headerSize: headerSize})
+		pos += size
+	}
+	return atoms, nil
+}
+
+// mp4Art finds the first image in the iTunes-style cover atom of an MP4 file.
+func mp4Art(data []byte) ([]byte, func([]byte, string, image.Point) ([]byte, error), error) {
+	var path []mp4Atom
+	start, end := 0, len(data)
+	for _, kind := range []string{"moov", "udta", "meta", "ilst", "covr", "data"} {
+		atoms, err := mp4Atoms(data, start, end)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		found := false
+		for _, atom := range atoms {
+			if atom.kind == kind {
+				path, found = append(path, atom), true
+				start, end = atom.children()
+				break
+			}
+		}
+		if !found {
+			return nil, nil, ErrNoEmbeddedArt
+		}
+	}
+
+	// The data atom has a type and locale before the image itself
+	dataAtom := path[len(path)-1]
+	if dataAtom.start+dataAtom.headerSize+8 > dataAtom.end {
+		return nil, nil, errMalformedMP4
+	}
+	art := data[dataAtom.start+dataAtom.headerSize+8 : dataAtom.end]
+
+	return art, func(newArt []byte, mime string, _ image.Point) ([]byte, error) {
+		kind := uint32(13)
+		if mime == "image/png" {
+			kind = 14
+		}
+
+		atom := binary.BigEndian.AppendUint32(nil, uint32(16+len(newArt)))
+		atom = append(atom, "data"...)
+		atom = binary.BigEndian.AppendUint32(atom, kind)
+		atom = binary.BigEndian.AppendUint32(atom, 0)
+		atom = append(atom, newArt...)
+		delta := len(atom) - (dataAtom.end - dataAtom.start)
+
+		out := make([]byte, 0, len(data)+delta)
+		out = append(out, data[:dataAtom.start]...)
+		out = append(out, atom...)
+		out = append(out, data[dataAtom.end:]...)
+
+		// All the containers start before the change, so only their sizes need updating
+		for _, parent := range path[:len(path)-1] {
+			size := parent.end - parent.start + delta
+			if parent.headerSize == 16 {
+				binary.BigEndian.PutUint64(out[parent.start+8:], uint64(size))
+			} else if size > math.MaxUint32 {
+				return nil, errors.New("cover art is too large for the MP4 file")
+			} else {
+				binary.BigEndian.PutUint32(out[parent.start:], uint32(size))
+			}
+		}
+
+		moov := path[0]
+		if err := shiftChunkOffsets(out, moov.start, moov.end+delta, dataAtom.end, delta); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}, nil
+}
+
+// shiftChunkOffsets adjusts the chunk offset tables of every track within data[start:end]
+// for data that has moved, adding delta to any offset at or after the given position.
+func shiftChunkOffsets(data []byte, start, end, after, delta int) error {
+	atoms, err := mp4Atoms(data, start, end)
+	if err != nil {
+		return err
+	}
+
+	for _, atom := range atoms {
+		switch atom.kind {
+		case "moov", "trak", "mdia", "minf", "stbl":
+			childStart, childEnd := atom.children()
+			if err := shiftChunkOffsets(data, childStart, childEnd, after, delta); err != nil {
+				return err
+			}
+
+		case "stco", "co64":
+			entrySize := 4
+			if atom.kind == "co64" {
+				entrySize = 8
+			}
+
+			table := data[atom.start+atom.headerSize : atom.end]
+			if len(table) < 8 || uint64(len(table)-8) < uint64(binary.BigEndian.Uint32(table[4:]))*uint64(entrySize) {
+				return errMalformedMP4
+			}
+
+			count := int(binary.BigEndian.Uint32(table[4:]))
+			for i := range count {
+				entry := table[8+i*entrySize:]
+				if entrySize == 8 {
+					if offset := binary.BigEndian.Uint64(entry); offset >= uint64(after) {
+						binary.BigEndian.PutUint64(entry, uint64(int64(offset)+int64(delta)))
+					}
+					continue
+				}
+
+				offset := int64(binary.BigEndian.Uint32(entry))
+				if offset < int64(after) {
+					continue
+				}
+				if offset+int64(delta) > math.MaxUint32 {
+					return errors.New("cover art is too large for the MP4 file")
+				}
+				binary.BigEndian.PutUint32(entry, uint32(offset+int64(delta)))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package jewelcase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"testing"
+)
+
+// mp4Box returns an atom of the given kind holding the given contents, using a 64-bit
+// size if large is set.
+func mp4Box(kind string, large bool, contents ...[]byte) []byte {
+	body := bytes.Join(contents, nil)
+	if large {
+		b := binary.BigEndian.AppendUint32(nil, 1)
+		b = append(b, kind...)
+		b = binary.BigEndian.AppendUint64(b, uint64(16+len(body)))
+		return append(b, body...)
+	}
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	b = append(b, kind...)
+	return append(b, body...)
+}
+
+// mp4Track returns a trak atom whose chunk offset table, of the given kind, holds the
+// given offsets.
+func mp4Track(kind string, offsets []uint64) []byte {
+	table := binary.BigEndian.AppendUint32(nil, 0)
+	table = binary.BigEndian.AppendUint32(table, uint32(len(offsets)))
+	for _, offset := range offsets {
+		if kind == "co64" {
+			table = binary.BigEndian.AppendUint64(table, offset)
+		} else {
+			table = binary.BigEndian.AppendUint32(table, uint32(offset))
+		}
+	}
+	return mp4Box("trak", false, mp4Box("mdia", false, mp4Box("minf", false, mp4Box("stbl", false, mp4Box(kind, false, table)))))
+}
+
+// mp4File returns an MP4 file with the given cover art, and two tracks whose chunks are
+// in an mdat atom either before or after the moov atom.
+func mp4File(art []byte, mdatFirst, largeMoov bool) []byte {
+	samples := []string{"first chunk", "second chunk"}
+	mdat := mp4Box("mdat", false, []byte(samples[0]), []byte(samples[1]))
+	ftyp := mp4Box("ftyp", false, []byte("M4A \x00\x00\x00\x00"))
+
+	moov := func(offsets []uint64) []byte {
+		cover := mp4Box("data", false, []byte{0, 0, 0, 13, 0, 0, 0, 0}, art)
+		meta := mp4Box("meta", false, []byte{0, 0, 0, 0}, mp4Box("hdlr", false, make([]byte, 25)), mp4Box("ilst", false, mp4Box("covr", false, cover)))
+		return mp4Box("moov", largeMoov, mp4Track("stco", offsets), mp4Track("co64", offsets), mp4Box("udta", false, meta))
+	}
+
+	mdatStart := len(ftyp) + len(moov([]uint64{0, 0}))
+	if mdatFirst {
+		mdatStart = len(ftyp)
+	}
+	offsets := []uint64{uint64(mdatStart + 8), uint64(mdatStart + 8 + len(samples[0]))}
+
+	if mdatFirst {
+		return bytes.Join([][]byte{ftyp, mdat, moov(offsets)}, nil)
+	}
+	return bytes.Join([][]byte{ftyp, moov(offsets), mdat}, nil)
+}
+
+// mp4ChunkOffsets returns the entries of every chunk offset table within data[start:end].
+func mp4ChunkOffsets(t *testing.T, data []byte, start, end int) []uint64 {
+	t.Helper()
+	atoms, err := mp4Atoms(data, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var offsets []uint64
+	for _, atom := range atoms {
+		switch atom.kind {
+		case "moov", "trak", "mdia", "minf", "stbl":
+			offsets = append(offsets, mp4ChunkOffsets(t, data, atom.start+atom.headerSize, atom.end)...)
+		case "stco":
+			table := data[atom.start+atom.headerSize:]
+			for i := range int(binary.BigEndian.Uint32(table[4:])) {
+				offsets = append(offsets, uint64(binary.BigEndian.Uint32(table[8+i*4:])))
+			}
+		case "co64":
+			table := data[atom.start+atom.headerSize:]
+			for i := range int(binary.BigEndian.Uint32(table[4:])) {
+				offsets = append(offsets, binary.BigEndian.Uint64(table[8+i*8:]))
+			}
+		}
+	}
+	return offsets
+}
+
+func TestMP4ArtRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		mdatFirst bool
+		largeMoov bool
+	}{
+		{"mdat after moov", false, false},
+		{"mdat before moov", true, false},
+		{"64-bit moov size", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := mp4File([]byte("front cover"), tt.mdatFirst, tt.largeMoov)
+
+			art, replace, err := mp4Art(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(art) != "front cover" {
+				t.Fatalf("found art %q, want the front cover", art)
+			}
+
+			for _, newArt := range [][]byte{[]byte("new"), bytes.Repeat([]byte("new front cover"), 1000)} {
+				out, err := replace(newArt, "image/png", image.Pt(750, 750))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, _, err := mp4Art(out)
+				if err != nil {
+					t.Fatalf("re-parsing the file: %v", err)
+				}
+				if !bytes.Equal(got, newArt) {
+					t.Errorf("replaced art is %d bytes, want %d", len(got), len(newArt))
+				}
+				if kind := binary.BigEndian.Uint32(out[bytes.Index(out, newArt)-8:]); kind != 14 {
+					t.Errorf("data atom has type %d, want 14 for PNG", kind)
+				}
+
+				// The atom sizes and chunk offsets should be those of a file that had the new
+				// art all along
+				expected := mp4File(newArt, tt.mdatFirst, tt.largeMoov)
+				binary.BigEndian.PutUint32(expected[bytes.Index(expected, newArt)-8:], 14)
+				if !bytes.Equal(out, expected) {
+					t.Error("replacing the art didn't give the expected file")
+				}
+				if _, err := mp4Atoms(out, 0, len(out)); err != nil {
+					t.Errorf("top-level atoms don't fit the file: %v", err)
+				}
+
+				// Every chunk offset should still point at the same chunk
+				want := mp4ChunkOffsets(t, data, 0, len(data))
+				offsets := mp4ChunkOffsets(t, out, 0, len(out))
+				if len(offsets) != len(want) {
+					t.Fatalf("found %d chunk offsets, want %d", len(offsets), len(want))
+				}
+				for i, offset := range offsets {
+					chunk := data[want[i] : want[i]+5]
+					if offset+5 > uint64(len(out)) || !bytes.Equal(out[offset:offset+5], chunk) {
+						t.Errorf("chunk offset %d is %d, which doesn't point at %q", i, offset, chunk)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMP4ArtWithoutCover(t *testing.T) {
+	data := mp4Box("moov", false, mp4Track("stco", nil))
+	if _, _, err := mp4Art(data); !errors.Is(err, ErrNoEmbeddedArt) {
+		t.Errorf("got error %v, want ErrNoEmbeddedArt", err)
+	}
+}
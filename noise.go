@@ -0,0 +1,69 @@
+package jewelcase
+
+import (
+	"math"
+	"math/rand"
+)
+
+// noiseField is smoothly varying random noise, built by interpolating between random
+// values on a grid of the given cell size.
+type noiseField struct {
+	cols, rows int
+	cell       float64
+	grid       []float64
+}
+
+// newNoiseField creates noise covering an area of the given size, with features roughly
+// cell pixels across.
+func newNoiseField(rng *rand.Rand, width, height int, cell float64) *noiseField {
+	n := &noiseField{
+		cols: int(float64(width)/cell) + 2,
+		rows: int(float64(height)/cell) + 2,
+		cell: cell,
+	}
+	n.grid = make([]float64, n.cols*n.rows)
+	for i := range n.grid {
+		n.grid[i] = rng.Float64()
+	}
+	return n
+}
+
+// at returns the value of the noise at the given point, between 0 and 1.
+func (n *noiseField) at(x, y float64) float64 {
+	gx, gy := math.Max(x, 0)/n.cell, math.Max(y, 0)/n.cell
+	x0, y0 := min(int(gx), n.cols-2), min(int(gy), n.rows-2)
+	fx, fy := smoothstep(gx-float64(x0)), smoothstep(gy-float64(y0))
+
+	top := n.grid[y0*n.cols+x0]*(1-fx) + n.grid[y0*n.cols+x0+1]*fx
+	bottom := n.grid[(y0+1)*n.cols+x0]*(1-fx) + n.grid[(y0+1)*n.cols+x0+1]*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// fractalNoise combines several noise fields of decreasing size and influence.
+type fractalNoise []*noiseField
+
+// newFractalNoise creates noise with the given number of octaves, the largest of which
+// has features roughly cell pixels across.
+func newFractalNoise(rng *rand.Rand, width, height int, cell float64, octaves int) fractalNoise {
+	f := make(fractalNoise, octaves)
+	for i := range f {
+		f[i] = newNoiseField(rng, width, height, math.Max(cell/math.Pow(2, float64(i)), 1))
+	}
+	return f
+}
+
+// at returns the value of the noise at the given point, between 0 and 1.
+func (f fractalNoise) at(x, y float64) float64 {
+	var total, weight float64
+	for i, n := range f {
+		w := 1 / math.Pow(2, float64(i))
+		total += n.at(x, y) * w
+		weight += w
+	}
+	return total / weight
+}
+
+// smoothstep eases t (between 0 and 1) in and out.
+func smoothstep(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
@@ -0,0 +1,175 @@
+package jewelcase
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+)
+
+// ObiPaper is the paper stock an obi strip is printed on.
+type ObiPaper string
+
+const (
+	// ObiPaperMatte is plain uncoated card with a fine grain. This is the default.
+	ObiPaperMatte ObiPaper = "matte"
+
+	// ObiPaperGloss is smooth coated card that catches the light.
+	ObiPaperGloss ObiPaper = "gloss"
+
+	// ObiPaperWashi is fibrous Japanese paper, with long fibres running down the strip.
+	ObiPaperWashi ObiPaper = "washi"
+)
+
+// drawObi wraps an obi strip around the left-hand side of the case, covering the left edge
+// of the art. The title and artist run down the strip, with the price in a panel at the
+// bottom.
+func drawObi(img *image.RGBA, art image.Rectangle, opts Options, rng *rand.Rand) error {
+	scale := float64(art.Dx()) / targetWidth
+	width := int(120 * scale)
+	strip := image.Rect(art.Min.X, img.Bounds().Min.Y, art.Min.X+width, img.Bounds().Max.Y)
+
+	obi := image.NewRGBA(image.Rectangle{Max: strip.Size()})
+	fill := opts.obiColour()
+	draw.Draw(obi, obi.Bounds(), image.NewUniform(fill), image.Point{}, draw.Src)
+	ink := image.NewUniform(contrastingColour(fill))
+	margin := int(10 * scale)
+
+	// The price goes in a pale panel at the bottom, printed in the colour of the strip
+	text := image.Rect(margin, art.Min.Y-strip.Min.Y+margin, width-margin, art.Max.Y-strip.Min.Y-margin)
+	if opts.ObiPrice != "" {
+		panel := image.Rect(0, text.Max.Y-int(44*scale), width, text.Max.Y)
+		draw.Draw(obi, panel, image.NewUniform(color.RGBA{R: 0xf4, G: 0xf0, B: 0xe6, A: 0xff}), image.Point{}, draw.Src)
+		mask, err := fitText([]string{opts.ObiPrice}, panel.Inset(int(6*scale)).Size(), 22*scale)
+		if err != nil {
+			return err
+		}
+		drawMaskCentred(obi, panel, mask, image.NewUniform(fill))
+		text.Max.Y = panel.Min.Y - margin
+	}
+
+	// The title and artist read from top to bottom, with the title in the wider column
+	// on the right as it is read first
+	columns := []struct {
+		text string
+		bold bool
+		size float64
+	}{
+		{opts.ObiTitle, true, 40 * scale},
+		{opts.ObiArtist, false, 26 * scale},
+	}
+	var (
+		masks []*image.Alpha
+		total int
+	)
+	for _, c := range columns {
+		if c.text == "" {
+			continue
+		}
+
+		var (
+			mask *image.Alpha
+			err  error
+		)
+		for size := c.size; ; size *= 0.9 {
+			if c.bold {
+				mask, err = renderBoldLines([]string{c.text}, size)
+			} else {
+				mask, err = renderText(c.text, size)
+			}
+			if err != nil {
+				return err
+			}
+			if size < 6 || mask.Bounds().Dx() <= text.Dy() {
+				break
+			}
+		}
+
+		mask = rotateMaskClockwise(mask)
+		masks = append(masks, mask)
+		total += mask.Bounds().Dx()
+	}
+
+	x := text.Max.X - max(text.Dx()-total, 0)/2
+	for _, mask := range masks {
+		column := image.Rect(x-mask.Bounds().Dx(), text.Min.Y, x, text.Max.Y)
+		drawMaskCentred(obi, column, mask, ink)
+		x = column.Min.X
+	}
+
+	if err := texturePaper(obi, opts.obiPaper(), rng); err != nil {
+		return err
+	}
+
+	// The strip casts a faint shadow on the case to its right, then goes on top of it
+	shadow := int(math.Max(4*scale, 1))
+	bounds := img.Bounds()
+	for y := strip.Min.Y; y < strip.Max.Y; y++ {
+		for i := range shadow {
+			x := strip.Max.X + i
+			if x >= bounds.Max.X {
+				break
+			}
+			o := img.PixOffset(x, y)
+			v := 0.3 * (1 - float64(i)/float64(shadow))
+			for c := range 3 {
+				img.Pix[o+c] -= uint8(float64(img.Pix[o+c]) * v)
+			}
+		}
+	}
+	draw.Draw(img, strip, obi, image.Point{}, draw.Over)
+	return nil
+}
+
+// texturePaper varies the brightness of the image to look like it is printed on the
+// given paper stock. Shades above 1 lighten towards white, and those below darken.
+func texturePaper(img *image.RGBA, paper ObiPaper, rng *rand.Rand) error {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	var shade func(x, y float64) float64
+	switch paper {
+	case ObiPaperMatte:
+		mottle := newFractalNoise(rng, w, h, 40, 3)
+		seed := rng.Uint64()
+		shade = func(x, y float64) float64 {
+			grain := grainNoise(seed ^ uint64(y)<<32 ^ uint64(x))
+			return 1 + (mottle.at(x, y)-0.5)*0.08 + grain*0.015
+		}
+	case ObiPaperGloss:
+		// A soft highlight runs down the strip where the coating catches the light
+		centre, spread := float64(w)*(0.25+rng.Float64()*0.3), float64(w)*0.18
+		shade = func(x, y float64) float64 {
+			d := (x - centre) / spread
+			return 1 + 0.25*math.Exp(-d*d)
+		}
+	case ObiPaperWashi:
+		// Long fibres run down the strip, so stretch the noise vertically
+		fibres := newNoiseField(rng, w, h/12+1, 1.5)
+		mottle := newFractalNoise(rng, w, h, 30, 2)
+		shade = func(x, y float64) float64 {
+			f := fibres.at(x, y/12)
+			return 1 + (mottle.at(x, y)-0.5)*0.1 + math.Max(f-0.7, 0)*0.5 - math.Max(0.2-f, 0)*0.3
+		}
+	default:
+		return fmt.Errorf("unknown obi paper %q", paper)
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			v := shade(float64(x-bounds.Min.X)+0.5, float64(y-bounds.Min.Y)+0.5)
+			o := img.PixOffset(x, y)
+			p := img.Pix[o : o+4 : o+4]
+			for c := range 3 {
+				if v > 1 {
+					p[c] += uint8(float64(p[3]-p[c]) * min(v-1, 1))
+				} else {
+					p[c] = uint8(float64(p[c]) * max(v, 0))
+				}
+			}
+		}
+	}
+	return nil
+}
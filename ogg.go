@@ -0,0 +1,312 @@
+package jewelcase
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"image"
+	"strings"
+)
+
+var errMalformedOgg = errors.New("malformed Ogg file")
+
+// oggPage is the position and header fields of a page within an Ogg file.
+type oggPage struct {
+	start, end int
+	serial     uint32
+	segments   []byte
+	body       []byte
+}
+
+// oggCRC is the lookup table for the CRC used by Ogg pages, which (unlike hash/crc32)
+// isn't bit-reflected.
+var oggCRC = func() (t [256]uint32) {
+	for i := range t {
+		r := uint32(i) << 24
+		for range 8 {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return t
+}()
+
+// parseOggPages splits an Ogg file into its pages.
+func parseOggPages(data []byte) ([]oggPage, error) {
+	var pages []oggPage
+	for pos := 0; pos < len(data); {
+		if pos+27 > len(data) || !bytes.HasPrefix(data[pos:], []byte("OggS")) {
+			return nil, errMalformedOgg
+		}
+
+		count := int(data[pos+26])
+		if pos+27+count > len(data) {
+			return nil, errMalformedOgg
+		}
+		segments := data[pos+27 : pos+27+count]
+
+		bodyStart := pos + 27 + count
+		bodyEnd := bodyStart
+		for _, s := range segments {
+			bodyEnd += int(s)
+		}
+		if bodyEnd > len(data) {
+			return nil, errMalformedOgg
+		}
+
+		pages = append(pages, oggPage{
+			start:    pos,
+			end:      bodyEnd,
+			serial:   binary.LittleEndian.Uint32(data[pos+14:]),
+			segments: segments,
+			body:     data[bodyStart:bodyEnd],
+		})
+		pos = bodyEnd
+	}
+	return pages, nil
+}
+
+// oggArt finds the front cover in the METADATA_BLOCK_PICTURE comments of an Ogg Vorbis
+// or Opus file, or the first picture if none of them is marked as the front cover.
+func oggArt(data []byte) ([]byte, func([]byte, string, image.Point) ([]byte, error), error) {
+	pages, err := parseOggPages(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(pages) == 0 {
+		return nil, nil, errMalformedOgg
+	}
+
+	// Collect the header packets, which must finish at the end of a page
+	var packets [][]byte
+	var packet []byte
+	headers, headerPages := 0, 0
+	for i, page := range pages {
+		if page.serial != pages[0].serial {
+			return nil, nil, errors.New("multiplexed Ogg streams are not supported")
+		}
+
+		offset := 0
+		for j, s := range page.segments {
+			packet = append(packet, page.body[offset:offset+int(s)]...)
+			offset += int(s)
+			if s == 255 {
+				continue
+			}
+
+			packets, packet = append(packets, packet), nil
+			if len(packets) == 1 {
+				switch {
+				case bytes.HasPrefix(packets[0], []byte("\x01vorbis")):
+					headers = 3
+				case bytes.HasPrefix(packets[0], []byte("OpusHead")):
+					headers = 2
+				default:
+					return nil, nil, errors.New("unsupported Ogg codec")
+				}
+			}
+
+			if len(packets) == headers {
+				if j != len(page.segments)-1 {
+					return nil, nil, errMalformedOgg
+				}
+				headerPages = i + 1
+				break
+			}
+		}
+
+		if headerPages > 0 {
+			break
+		}
+	}
+	if headerPages == 0 {
+		return nil, nil, errMalformedOgg
+	}
+
+	// The comments follow a codec-specific prefix, and Vorbis adds a framing bit after them
+	prefix := []byte("OpusTags")
+	if headers == 3 {
+		prefix = []byte("\x03vorbis")
+	}
+	vendor, comments, tail, err := parseVorbisComments(packets[1], prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := -1
+	var picture *flacPicture
+	for i, comment := range comments {
+		key, value, _ := strings.Cut(comment, "=")
+		if !strings.EqualFold(key, "METADATA_BLOCK_PICTURE") {
+			continue
+		}
+
+		block, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		p, err := parseFLACPicture(block)
+		if err != nil {
+			continue
+		}
+		if picture == nil || p.kind == pictureFrontCover && picture.kind != pictureFrontCover {
+			index, picture = i, p
+		}
+	}
+
+	if picture == nil {
+		return nil, nil, ErrNoEmbeddedArt
+	}
+
+	return picture.data, func(art []byte, mime string, size image.Point) ([]byte, error) {
+		picture.replaceArt(art, mime, size)
+		comments[index] = "METADATA_BLOCK_PICTURE=" + base64.StdEncoding.EncodeToString(picture.bytes())
+
+		headerPackets := append([][]byte{}, packets[:headers]...)
+		headerPackets[1] = appendVorbisComments(append([]byte{}, prefix...), vendor, comments, tail)
+
+		// The identification header always gets a page to itself
+		first := pages[0]
+		out := make([]byte, 0, len(data)+len(art)*2)
+		out, sequence := appendOggPackets(out, data[first.start+5], first.serial, binary.LittleEndian.Uint32(data[first.start+18:]), headerPackets[:1])
+		out, sequence = appendOggPackets(out, 0, first.serial, sequence, headerPackets[1:])
+
+		// Audio pages are unchanged, apart from being renumbered
+		for _, page := range pages[headerPages:] {
+			start := len(out)
+			out = append(out, data[page.start:page.end]...)
+			binary.LittleEndian.PutUint32(out[start+18:], sequence)
+			setOggCRC(out[start:])
+			sequence++
+		}
+		return out, nil
+	}, nil
+}
+
+// parseVorbisComments returns the vendor string and comments from a comment header
+// packet, along with any data following them.
+func parseVorbisComments(packet, prefix []byte) (vendor []byte, comments []string, tail []byte, err error) {
+	if !bytes.HasPrefix(packet, prefix) {
+		return nil, nil, nil, errMalformedOgg
+	}
+	b := packet[len(prefix):]
+
+	readField := func() ([]byte, bool) {
+		if len(b) < 4 || uint32(len(b)-4) < binary.LittleEndian.Uint32(b) {
+			return nil, false
+		}
+		n := binary.LittleEndian.Uint32(b)
+		field := b[4 : 4+n]
+		b = b[4+n:]
+		return field, true
+	}
+
+	vendor, ok := readField()
+	if !ok || len(b) < 4 {
+		return nil, nil, nil, errMalformedOgg
+	}
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+
+	for range count {
+		comment, ok := readField()
+		if !ok {
+			return nil, nil, nil, errMalformedOgg
+		}
+		comments = append(comments, string(comment))
+	}
+	return vendor, comments, b, nil
+}
+
+// appendVorbisComments appends the body of a comment header to b.
+func appendVorbisComments(b, vendor []byte, comments []string, tail []byte) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(vendor)))
+	b = append(b, vendor...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(comments)))
+	for _, comment := range comments {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(comment)))
+		b = append(b, comment...)
+	}
+	return append(b, tail...)
+}
+
+// appendOggPackets lays the packets out on as many pages as needed, starting with the
+// given sequence number, and returns the next sequence number.
+func appendOggPackets(out []byte, flags byte, serial, sequence uint32, packets [][]byte) ([]byte, uint32) {
+	var lacing, body []byte
+	var ends []int
+	for _, packet := range packets {
+		for n := len(packet); ; n -= 255 {
+			lacing = append(lacing, byte(min(n, 255)))
+			if n < 255 {
+				break
+			}
+		}
+		ends = append(ends, len(lacing))
+		body = append(body, packet...)
+	}
+
+	continued := false
+	for len(lacing) > 0 {
+		count := min(len(lacing), 255)
+
+		// Pages where no packet finishes have a granule position of -1
+		granule := ^uint64(0)
+		for _, end := range ends {
+			if end > 0 && end <= count {
+				granule = 0
+			}
+		}
+
+		start := len(out)
+		pageFlags := flags
+		if continued {
+			pageFlags |= 0x01
+		}
+		out = append(out, "OggS"...)
+		out = append(out, 0, pageFlags)
+		out = binary.LittleEndian.AppendUint64(out, granule)
+		out = binary.LittleEndian.AppendUint32(out, serial)
+		out = binary.LittleEndian.AppendUint32(out, sequence)
+		out = append(out, 0, 0, 0, 0, byte(count))
+		out = append(out, lacing[:count]...)
+
+		size := 0
+		for _, l := range lacing[:count] {
+			size += int(l)
+		}
+		out = append(out, body[:size]...)
+		setOggCRC(out[start:])
+
+		continued = lacing[count-1] == 255
+		lacing, body = lacing[count:], body[size:]
+		for i := range ends {
+			ends[i] -= count
+		}
+		flags &^= 0x02
+		sequence++
+	}
+	return out, sequence
+}
+
+// setOggCRC calculates the checksum of the page at the start of b and stores it in the
+// page header.
+func setOggCRC(b []byte) {
+	size := 27 + int(b[26])
+	for _, s := range b[27 : 27+int(b[26])] {
+		size += int(s)
+	}
+
+	page := b[:size]
+	binary.LittleEndian.PutUint32(page[22:], 0)
+	var crc uint32
+	for _, v := range page {
+		crc = crc<<8 ^ oggCRC[byte(crc>>24)^v]
+	}
+	binary.LittleEndian.PutUint32(page[22:], crc)
+}
@@ -0,0 +1,197 @@
+package jewelcase
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"image"
+	"testing"
+)
+
+// oggChecksum calculates the CRC of an Ogg page a bit at a time, with the checksum field
+// treated as zero, to check the table-driven version against.
+func oggChecksum(page []byte) uint32 {
+	var crc uint32
+	for i, v := range page {
+		if i >= 22 && i < 26 {
+			v = 0
+		}
+		crc ^= uint32(v) << 24
+		for range 8 {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// oggTestPage returns a page holding the given complete packets.
+func oggTestPage(flags byte, granule uint64, sequence uint32, packets ...[]byte) []byte {
+	var lacing []byte
+	for _, packet := range packets {
+		for n := len(packet); ; n -= 255 {
+			lacing = append(lacing, byte(min(n, 255)))
+			if n < 255 {
+				break
+			}
+		}
+	}
+
+	page := append([]byte("OggS"), 0, flags)
+	page = binary.LittleEndian.AppendUint64(page, granule)
+	page = binary.LittleEndian.AppendUint32(page, 1234)
+	page = binary.LittleEndian.AppendUint32(page, sequence)
+	page = append(page, 0, 0, 0, 0, byte(len(lacing)))
+	page = append(page, lacing...)
+	page = append(page, bytes.Join(packets, nil)...)
+	binary.LittleEndian.PutUint32(page[22:], oggChecksum(page))
+	return page
+}
+
+// vorbisComments returns a comment header with the given prefix, comments and tail.
+func vorbisComments(prefix string, comments []string, tail []byte) []byte {
+	b := append([]byte(prefix), 4, 0, 0, 0)
+	b = append(b, "test"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(comments)))
+	for _, comment := range comments {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(comment)))
+		b = append(b, comment...)
+	}
+	return append(b, tail...)
+}
+
+// oggPackets reassembles the packets in an Ogg file, checking that each page's checksum
+// and sequence number are right.
+func oggPackets(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	pages, err := parseOggPages(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var packets [][]byte
+	var packet []byte
+	for i, page := range pages {
+		header := data[page.start:page.end]
+		if got, want := binary.LittleEndian.Uint32(header[22:]), oggChecksum(header); got != want {
+			t.Errorf("page %d has checksum %08x, want %08x", i, got, want)
+		}
+		if got := binary.LittleEndian.Uint32(header[18:]); got != uint32(i) {
+			t.Errorf("page %d has sequence number %d", i, got)
+		}
+		if continued := header[5]&0x01 != 0; continued != (packet != nil) {
+			t.Errorf("page %d has continuation flag %t, want %t", i, continued, packet != nil)
+		}
+
+		offset := 0
+		for _, s := range page.segments {
+			packet = append(packet, page.body[offset:offset+int(s)]...)
+			offset += int(s)
+			if s < 255 {
+				packets, packet = append(packets, packet), nil
+			}
+		}
+	}
+	return packets
+}
+
+func TestOggArtRoundTrip(t *testing.T) {
+	back := &flacPicture{kind: 4, mime: "image/jpeg", description: []byte("back"), data: []byte("back cover")}
+	front := &flacPicture{kind: pictureFrontCover, mime: "image/jpeg", description: []byte("front"), data: []byte("front cover")}
+	comments := []string{
+		"TITLE=Album",
+		"METADATA_BLOCK_PICTURE=" + base64.StdEncoding.EncodeToString(back.bytes()),
+		"metadata_block_picture=" + base64.StdEncoding.EncodeToString(front.bytes()),
+	}
+
+	tests := []struct {
+		name    string
+		headers [][]byte
+		prefix  string
+		tail    []byte
+	}{
+		{"Vorbis", [][]byte{append([]byte("\x01vorbis"), make([]byte, 23)...), nil, []byte("\x05vorbis setup")}, "\x03vorbis", []byte{1}},
+		{"Opus", [][]byte{append([]byte("OpusHead"), make([]byte, 11)...), nil}, "OpusTags", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.headers[1] = vorbisComments(tt.prefix, comments, tt.tail)
+			audio := [][]byte{[]byte("first audio packet"), []byte("second audio packet")}
+
+			data := oggTestPage(0x02, 0, 0, tt.headers[0])
+			data = append(data, oggTestPage(0, 0, 1, tt.headers[1:]...)...)
+			data = append(data, oggTestPage(0, 1000, 2, audio[0])...)
+			data = append(data, oggTestPage(0x04, 2000, 3, audio[1])...)
+
+			art, replace, err := oggArt(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(art) != "front cover" {
+				t.Fatalf("found art %q, want the front cover", art)
+			}
+
+			// The larger art needs more than one page for the comment header
+			for _, newArt := range [][]byte{[]byte("new"), bytes.Repeat([]byte("new front cover"), 5000)} {
+				out, err := replace(newArt, "image/png", image.Pt(750, 750))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got, _, err := oggArt(out)
+				if err != nil {
+					t.Fatalf("re-parsing the file: %v", err)
+				}
+				if !bytes.Equal(got, newArt) {
+					t.Errorf("replaced art is %d bytes, want %d", len(got), len(newArt))
+				}
+
+				packets := oggPackets(t, out)
+				if len(packets) != len(tt.headers)+len(audio) {
+					t.Fatalf("found %d packets, want %d", len(packets), len(tt.headers)+len(audio))
+				}
+
+				picture := *front
+				picture.replaceArt(newArt, "image/png", image.Pt(750, 750))
+				want := append([][]byte{}, tt.headers...)
+				want[1] = vorbisComments(tt.prefix, []string{comments[0], comments[1], "METADATA_BLOCK_PICTURE=" + base64.StdEncoding.EncodeToString(picture.bytes())}, tt.tail)
+				want = append(want, audio...)
+				for i := range packets {
+					if !bytes.Equal(packets[i], want[i]) {
+						t.Errorf("packet %d is %d bytes, want %d", i, len(packets[i]), len(want[i]))
+					}
+				}
+
+				// Only the first page starts the stream, and the audio pages keep their
+				// granule positions and end of stream flag
+				pages, _ := parseOggPages(out)
+				for i, page := range pages {
+					if bos := out[page.start+5]&0x02 != 0; bos != (i == 0) {
+						t.Errorf("page %d has beginning of stream flag %t", i, bos)
+					}
+				}
+				last := pages[len(pages)-2:]
+				for i, page := range last {
+					if granule := binary.LittleEndian.Uint64(out[page.start+6:]); granule != uint64(1000*(i+1)) {
+						t.Errorf("audio page %d has granule position %d, want %d", i, granule, 1000*(i+1))
+					}
+				}
+				if out[last[1].start+5]&0x04 == 0 {
+					t.Error("last page lost its end of stream flag")
+				}
+			}
+		})
+	}
+}
+
+func TestOggArtWithoutPicture(t *testing.T) {
+	data := oggTestPage(0x02, 0, 0, append([]byte("OpusHead"), make([]byte, 11)...))
+	data = append(data, oggTestPage(0, 0, 1, vorbisComments("OpusTags", []string{"TITLE=Album"}, nil))...)
+	if _, _, err := oggArt(data); !errors.Is(err, ErrNoEmbeddedArt) {
+		t.Errorf("got error %v, want ErrNoEmbeddedArt", err)
+	}
+}
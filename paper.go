@@ -0,0 +1,95 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// paperScreens are the angles of the halftone screens used for the red, green and blue
+// channels, in degrees, following the usual angles for cyan, magenta and yellow ink so
+// that the dots don't form moiré patterns.
+var paperScreens = [3]float64{15, 75, 0}
+
+// halftoneLevels maps the value of the halftone screen to the fraction of each cell in
+// which the screen is lower, so that thresholding against it covers the same fraction of
+// the paper with ink as the colour needs.
+var halftoneLevels = sync.OnceValue(func() *[1025]float64 {
+	const samples = 256
+	var counts [1025]int
+	for y := range samples {
+		for x := range samples {
+			u, v := 2*math.Pi*float64(x)/samples, 2*math.Pi*float64(y)/samples
+			counts[int(halftoneScreen(u, v)*1024)]++
+		}
+	}
+
+	levels := &[1025]float64{}
+	total := 0
+	for i, n := range counts {
+		levels[i] = (float64(total) + float64(n)/2) / (samples * samples)
+		total += n
+	}
+	return levels
+})
+
+// halftoneScreen returns the value of a grid of soft dots at the given point, from 0 to 1,
+// which repeats every 2π in each direction.
+func halftoneScreen(u, v float64) float64 {
+	return (math.Cos(u)+math.Cos(v))/4 + 0.5
+}
+
+// applyPaperTexture blends a printed version of the image into it, to make clean digital
+// art look like a printed insert. The printed version is halftoned into dots of ink, on
+// paper with a faint blotchy texture from its fibres. opacity is how much of the printed
+// version is used, from 0 to 1, and scale is how much larger than a case at its usual
+// size the image is.
+func applyPaperTexture(img *image.RGBA, rng *rand.Rand, opacity, scale float64) *image.RGBA {
+	bounds := img.Bounds()
+	seed := rng.Uint64()
+	fibres := newFractalNoise(rng, int(float64(bounds.Dx())/scale)+1, int(float64(bounds.Dy())/scale)+1, 16, 3)
+
+	// The period of the halftone screen in pixels, which is much coarser than real print
+	// so that it survives being scaled down
+	period := 3 * scale
+	var screens [3][2]float64
+	for c, angle := range paperScreens {
+		sin, cos := math.Sincos(angle * math.Pi / 180)
+		screens[c] = [2]float64{sin * 2 * math.Pi / period, cos * 2 * math.Pi / period}
+	}
+
+	levels := halftoneLevels()
+
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			pix := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+			fy := float64(y - bounds.Min.Y)
+			row := seed ^ uint64(y-bounds.Min.Y)<<32
+
+			for i, x := 0, 0; i < len(pix); i, x = i+4, x+1 {
+				a := float64(pix[i+3])
+				if a == 0 {
+					continue
+				}
+				fx := float64(x)
+
+				// Paper is slightly uneven, and ink soaks into it unevenly
+				paper := 1 - (fibres.at(fx/scale, fy/scale)-0.5)*0.12 + grainNoise(row^uint64(x))*0.015
+
+				for c := range 3 {
+					// The screen is a grid of soft dots, covering more of the paper as the
+					// threshold falls, so darker colours get bigger dots of ink
+					s := screens[c]
+					u, v := fx*s[1]+fy*s[0], fy*s[1]-fx*s[0]
+					threshold := levels[int(halftoneScreen(u, v)*1024)]
+					ink := math.Min(math.Max((1-float64(pix[i+c])/a-threshold)/0.2+0.5, 0), 1)
+
+					printed := a * (1 - ink) * paper
+					pix[i+c] = uint8(math.Min(math.Max(float64(pix[i+c])*(1-opacity)+printed*opacity, 0), a))
+				}
+			}
+		}
+	})
+	return img
+}
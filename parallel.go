@@ -0,0 +1,51 @@
+package jewelcase
+
+import (
+	"image"
+	"image/draw"
+	"runtime"
+	"sync"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+)
+
+// parallelRows calls fn for contiguous bands of rows covering bounds, spread across one
+// goroutine per CPU, and waits for them all to finish. fn must only write to the rows
+// it is given.
+func parallelRows(bounds image.Rectangle, fn func(minY, maxY int)) {
+	n := min(runtime.NumCPU(), bounds.Dy())
+	if n <= 1 {
+		fn(bounds.Min.Y, bounds.Max.Y)
+		return
+	}
+
+	var wg sync.WaitGroup
+	rows := (bounds.Dy() + n - 1) / n
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += rows {
+		wg.Go(func() {
+			fn(y, min(y+rows, bounds.Max.Y))
+		})
+	}
+	wg.Wait()
+}
+
+// parallelScale scales the whole of src to fill dst using the given scaler, splitting
+// the work across CPUs.
+func parallelScale(scaler xdraw.Scaler, dst *image.RGBA, src image.Image, op draw.Op) {
+	dr := dst.Bounds()
+	parallelRows(dr, func(minY, maxY int) {
+		band := dst.SubImage(image.Rect(dr.Min.X, minY, dr.Max.X, maxY)).(*image.RGBA)
+		scaler.Scale(band, dr, src, src.Bounds(), op, nil)
+	})
+}
+
+// parallelTransform draws src onto dst through the affine transform s2d using the given
+// transformer, splitting the work across CPUs.
+func parallelTransform(transformer xdraw.Transformer, dst *image.RGBA, s2d f64.Aff3, src image.Image, op draw.Op) {
+	dr := dst.Bounds()
+	parallelRows(dr, func(minY, maxY int) {
+		band := dst.SubImage(image.Rect(dr.Min.X, minY, dr.Max.X, maxY)).(*image.RGBA)
+		transformer.Transform(band, s2d, src, src.Bounds(), op, nil)
+	})
+}
@@ -0,0 +1,146 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+)
+
+// vec3 is a point or direction in 3D. x increases to the right, y downwards, and z away
+// from the viewer.
+type vec3 [3]float64
+
+// view is a camera looking straight at an image that has been turned in 3D. Points are
+// given relative to the centre of the image before it was turned, and are projected
+// relative to the centre of the view.
+type view struct {
+	// axes are the directions of the image's x, y and z axes once it has been turned
+	axes     [3]vec3
+	distance float64
+}
+
+// newView returns the view of an image of the given size once it has been turned by yaw
+// radians about its vertical axis (the right edge moving away) and then pitch radians
+// about its horizontal axis (the top edge moving away). The camera is close enough to
+// give a noticeable perspective.
+func newView(size image.Point, yaw, pitch float64) view {
+	sy, cy := math.Sin(yaw), math.Cos(yaw)
+	sp, cp := math.Sin(pitch), math.Cos(pitch)
+	return view{
+		axes: [3]vec3{
+			{cy, sp * sy, cp * sy},
+			{0, cp, -sp},
+			{-sy, sp * cy, cp * cy},
+		},
+		distance: 2.5 * float64(max(size.X, size.Y)),
+	}
+}
+
+// rotate returns the direction d once the image has been turned.
+func (v view) rotate(d vec3) vec3 {
+	var r vec3
+	for i, axis := range v.axes {
+		for c := range r {
+			r[c] += axis[c] * d[i]
+		}
+	}
+	return r
+}
+
+// project returns where the point appears in the view.
+func (v view) project(p vec3) (float64, float64) {
+	r := v.rotate(p)
+	z := r[2] + v.distance
+	return v.distance * r[0] / z, v.distance * r[1] / z
+}
+
+// faces reports whether the side of a plane through p with the given outward normal
+// can be seen.
+func (v view) faces(p, normal vec3) bool {
+	r, n := v.rotate(p), v.rotate(normal)
+	return r[0]*n[0]+r[1]*n[1]+(r[2]+v.distance)*n[2] < 0
+}
+
+// plane returns the projection of the point origin + x*a + y*b on a plane onto the view.
+func (v view) plane(origin, a, b vec3) projection {
+	ra, rb, ro := v.rotate(a), v.rotate(b), v.rotate(origin)
+	f := v.distance
+	return projection{
+		{f * ra[0], f * rb[0], f * ro[0]},
+		{f * ra[1], f * rb[1], f * ro[1]},
+		{ra[2], rb[2], ro[2] + f},
+	}
+}
+
+// fit returns how much the view must be shrunk so that all the points appear within an
+// area of the given size.
+func (v view) fit(size image.Point, points []vec3) float64 {
+	scale := 1.0
+	for _, p := range points {
+		x, y := v.project(p)
+		scale = math.Min(scale, float64(size.X)/2/math.Abs(x))
+		scale = math.Min(scale, float64(size.Y)/2/math.Abs(y))
+	}
+	return scale
+}
+
+// corners returns the corners of a box centred on the origin, with the given size and
+// extending depth pixels back from the front.
+func corners(size image.Point, depth float64) []vec3 {
+	w, h := float64(size.X)/2, float64(size.Y)/2
+	points := []vec3{{-w, -h, 0}, {w, -h, 0}, {w, h, 0}, {-w, h, 0}}
+	if depth > 0 {
+		points = append(points, vec3{-w, -h, depth}, vec3{w, -h, depth}, vec3{w, h, depth}, vec3{-w, h, depth})
+	}
+	return points
+}
+
+// drawTurned draws src onto the middle of dst, turned by yaw and pitch as described by
+// newView, and then scaled.
+func drawTurned(dst, src *image.RGBA, yaw, pitch, scale float64) {
+	size := src.Bounds().Size()
+	v := newView(size, yaw, pitch)
+	drawPlane(dst, src, v.plane(vec3{-float64(size.X) / 2, -float64(size.Y) / 2, 0}, vec3{1, 0, 0}, vec3{0, 1, 0}), scale, 1)
+}
+
+// drawPlane draws src over the middle of dst through the projection p, which maps points
+// in src (relative to its top-left corner) into the view, and then scaled. The colours
+// are multiplied by brightness.
+func drawPlane(dst, src *image.RGBA, p projection, scale, brightness float64) {
+	srcBounds, dstBounds := src.Bounds(), dst.Bounds()
+	w, h := float64(srcBounds.Dx()), float64(srcBounds.Dy())
+	cx := float64(dstBounds.Min.X) + float64(dstBounds.Dx())/2
+	cy := float64(dstBounds.Min.Y) + float64(dstBounds.Dy())/2
+
+	// Only visit the part of dst that the plane covers
+	area := image.Rectangle{Min: dstBounds.Max, Max: dstBounds.Min}
+	for _, c := range [][2]float64{{0, 0}, {w, 0}, {w, h}, {0, h}} {
+		x, y := p.apply(c[0], c[1])
+		x, y = cx+x*scale, cy+y*scale
+		area.Min.X, area.Min.Y = min(area.Min.X, int(math.Floor(x))), min(area.Min.Y, int(math.Floor(y)))
+		area.Max.X, area.Max.Y = max(area.Max.X, int(math.Ceil(x))), max(area.Max.Y, int(math.Ceil(y)))
+	}
+	area = area.Intersect(dstBounds)
+
+	inv := p.inverse()
+	parallelRows(area, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				u, v := inv.apply((float64(x)+0.5-cx)/scale, (float64(y)+0.5-cy)/scale)
+				sx, sy := u-0.5, v-0.5
+				if sx < 0 || sy < 0 || sx >= w-1 || sy >= h-1 {
+					continue
+				}
+
+				// Brightening can't take a colour past its alpha
+				c := sampleBilinear(src, float64(srcBounds.Min.X)+sx, float64(srcBounds.Min.Y)+sy)
+				a := float64(c.A)
+				o := dst.PixOffset(x, y)
+				d := dst.Pix[o : o+4 : o+4]
+				d[0] = uint8(math.Min(float64(c.R)*brightness, a) + float64(d[0])*(1-a/255))
+				d[1] = uint8(math.Min(float64(c.G)*brightness, a) + float64(d[1])*(1-a/255))
+				d[2] = uint8(math.Min(float64(c.B)*brightness, a) + float64(d[2])*(1-a/255))
+				d[3] = uint8(a + float64(d[3])*(1-a/255))
+			}
+		}
+	})
+}
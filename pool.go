@@ -0,0 +1,133 @@
+package jewelcase
+
+import (
+	"context"
+	"image"
+	"slices"
+	"sync"
+)
+
+// BufferPool holds image buffers for reuse between calls to ProcessPooled, reducing
+// allocations when processing many images. The zero value is ready to use, and a
+// BufferPool is safe for concurrent use.
+type BufferPool struct {
+	pool   sync.Pool
+	pool64 sync.Pool
+}
+
+// encodePool holds the working buffers of the functions that encode the processed image
+// themselves, such as ProcessFile, so that batch runs reuse them without the caller
+// having to manage a BufferPool.
+var encodePool BufferPool
+
+// ProcessPooled behaves like Process, but takes its working buffers from the given pool.
+// The returned release function must be called once the caller has finished with the
+// image (for example, after encoding it), after which the image must not be used.
+func ProcessPooled(pool *BufferPool, albumArt image.Image, opts Options) (image.Image, func(), error) {
+	buf := &buffers{pool: pool}
+	result, _, err := process(context.Background(), buf, albumArt, opts, false)
+	if err != nil {
+		buf.release()
+		return nil, nil, err
+	}
+	return result, buf.release, nil
+}
+
+// buffers hands out the working images used while processing a single image,
+// optionally drawing them from a BufferPool. Images that are finished with part way
+// through can be recycled, so that later steps reuse them rather than allocating more.
+type buffers struct {
+	pool *BufferPool
+
+	// used holds every image handed out, and free those of them that have since been
+	// recycled
+	used, free     []*image.RGBA
+	used64, free64 []*image.RGBA64
+}
+
+// newRGBA returns a blank image with the given bounds.
+func (b *buffers) newRGBA(r image.Rectangle) *image.RGBA {
+	size := 4 * r.Dx() * r.Dy()
+	img := takeFree(&b.free, func(img *image.RGBA) bool { return cap(img.Pix) >= size })
+	if img == nil && b.pool != nil {
+		if pooled, _ := b.pool.pool.Get().(*image.RGBA); pooled != nil && cap(pooled.Pix) >= size {
+			img = pooled
+			b.used = append(b.used, img)
+		}
+	}
+	if img == nil {
+		img = image.NewRGBA(r)
+		b.used = append(b.used, img)
+		return img
+	}
+
+	img.Pix = img.Pix[:size]
+	clear(img.Pix)
+	img.Stride = 4 * r.Dx()
+	img.Rect = r
+	return img
+}
+
+// newRGBA64 returns a blank 16-bit image with the given bounds.
+func (b *buffers) newRGBA64(r image.Rectangle) *image.RGBA64 {
+	size := 8 * r.Dx() * r.Dy()
+	img := takeFree(&b.free64, func(img *image.RGBA64) bool { return cap(img.Pix) >= size })
+	if img == nil && b.pool != nil {
+		if pooled, _ := b.pool.pool64.Get().(*image.RGBA64); pooled != nil && cap(pooled.Pix) >= size {
+			img = pooled
+			b.used64 = append(b.used64, img)
+		}
+	}
+	if img == nil {
+		img = image.NewRGBA64(r)
+		b.used64 = append(b.used64, img)
+		return img
+	}
+
+	img.Pix = img.Pix[:size]
+	clear(img.Pix)
+	img.Stride = 8 * r.Dx()
+	img.Rect = r
+	return img
+}
+
+// recycle marks an image handed out by newRGBA as finished with, so that it can be
+// handed out again. Anything else, such as the caller's own images, is ignored.
+func (b *buffers) recycle(img *image.RGBA) {
+	if slices.Contains(b.used, img) && !slices.Contains(b.free, img) {
+		b.free = append(b.free, img)
+	}
+}
+
+// recycle64 behaves like recycle, for images handed out by newRGBA64.
+func (b *buffers) recycle64(img *image.RGBA64) {
+	if slices.Contains(b.used64, img) && !slices.Contains(b.free64, img) {
+		b.free64 = append(b.free64, img)
+	}
+}
+
+// takeFree removes and returns the first of the free images that fits, or nil if none
+// do.
+func takeFree[T any](free *[]*T, fits func(*T) bool) *T {
+	for i, img := range *free {
+		if fits(img) {
+			*free = slices.Delete(*free, i, i+1)
+			return img
+		}
+	}
+	return nil
+}
+
+// release returns all the images handed out to the pool.
+func (b *buffers) release() {
+	if b.pool != nil {
+		for _, img := range b.used {
+			b.pool.pool.Put(img)
+		}
+		for _, img := range b.used64 {
+			b.pool.pool64.Put(img)
+		}
+	}
+	b.used, b.free = nil, nil
+	b.used64, b.free64 = nil, nil
+}
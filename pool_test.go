@@ -0,0 +1,42 @@
+package jewelcase
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// benchmarkOptions returns the effects most commonly used, with a fixed seed.
+func benchmarkOptions() Options {
+	return Options{
+		ColourCorrection: true,
+		RoundedCorners:   true,
+		EdgeSoftening:    true,
+		RandomOffset:     true,
+		RandomRotation:   true,
+		Reflection:       true,
+		Rand:             rand.New(rand.NewSource(1)),
+	}
+}
+
+func BenchmarkProcess(b *testing.B) {
+	art, opts := benchmarkArt(), benchmarkOptions()
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := Process(art, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProcessPooled(b *testing.B) {
+	art, opts := benchmarkArt(), benchmarkOptions()
+	var pool BufferPool
+	b.ReportAllocs()
+	for b.Loop() {
+		_, release, err := ProcessPooled(&pool, art, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		release()
+	}
+}
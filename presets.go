@@ -0,0 +1,53 @@
+package jewelcase
+
+// PresetMint is a case straight out of the shop: the art sits squarely in the frame, with
+// only a faint reflection.
+var PresetMint = Options{
+	ColourCorrection:   true,
+	RoundedCorners:     true,
+	EdgeSoftening:      true,
+	Reflection:         true,
+	ReflectionStrength: 0.5,
+	CornerRadiusMin:    4,
+	CornerRadiusMax:    6,
+	LinearLight:        true,
+}
+
+// PresetUsed is a case that has spent a few years on a shelf. These are the effects the
+// command line tool applies by default.
+var PresetUsed = Options{
+	ColourCorrection: true,
+	RoundedCorners:   true,
+	EdgeSoftening:    true,
+	RandomOffset:     true,
+	RandomRotation:   true,
+	Reflection:       true,
+	LinearLight:      true,
+}
+
+// PresetThrashed is a case that has been rattling around a car door for a decade: the art
+// has slipped and faded, and the plastic is covered in scratches.
+var PresetThrashed = Options{
+	ColourCorrection: true,
+	RoundedCorners:   true,
+	EdgeSoftening:    true,
+	RandomOffset:     true,
+	RandomRotation:   true,
+	Reflection:       true,
+	Scratches:        true,
+	ScratchDensity:   8,
+	TintAmount:       0.05,
+	CornerRadiusMin:  10,
+	CornerRadiusMax:  24,
+	MaxRotation:      1.5,
+	MaxOffsetX:       16,
+	MaxOffsetY:       10,
+	LinearLight:      true,
+}
+
+// Presets maps the names of the built-in presets to their options.
+var Presets = map[string]Options{
+	"mint":     PresetMint,
+	"used":     PresetUsed,
+	"thrashed": PresetThrashed,
+}
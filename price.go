@@ -0,0 +1,181 @@
+package jewelcase
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+// PriceStyle is the kind of price sticker added by Options.PriceSticker.
+type PriceStyle string
+
+const (
+	// PriceLabel is a small label from a shop's pricing gun
+	PriceLabel PriceStyle = "label"
+
+	// PriceSecurityStrip is a long security strip with the price printed on the end
+	PriceSecurityStrip PriceStyle = "security"
+)
+
+// priceLabelColours are the colours of label that price guns commonly use.
+var priceLabelColours = []color.RGBA{
+	{R: 0xf6, G: 0xf4, B: 0xee, A: 0xff},
+	{R: 0xff, G: 0x8c, B: 0x3a, A: 0xff},
+	{R: 0xff, G: 0xe8, B: 0x4a, A: 0xff},
+	{R: 0x9c, G: 0xe0, B: 0x6e, A: 0xff},
+}
+
+// priceTornChance is the chance of a price sticker having been partly torn off.
+const priceTornChance = 0.3
+
+// drawPriceSticker adds a price sticker as described by opts to a random corner of the
+// art, partly overlapping its edge, and records where it went in the report.
+func drawPriceSticker(img *image.RGBA, art image.Rectangle, opts Options, rng *rand.Rand, report *Report) error {
+	price := opts.priceCurrency() + opts.priceText()
+	scale := float64(art.Dx()) / targetWidth
+
+	var (
+		sticker *image.RGBA
+		err     error
+	)
+	switch opts.priceStyle() {
+	case PriceLabel:
+		fill := priceLabelColours[rng.Intn(len(priceLabelColours))]
+		sticker, err = renderPriceLabel(price, 110*scale, fill)
+	case PriceSecurityStrip:
+		sticker, err = renderSecurityStrip(price, 300*scale, rng)
+	default:
+		return fmt.Errorf("unknown price sticker style %q", opts.PriceStyle)
+	}
+	if err != nil {
+		return err
+	}
+
+	if rng.Float64() < priceTornChance {
+		tearSticker(sticker, rng)
+		report.PriceStickerTorn = true
+	}
+
+	corners := []Corner{CornerTopLeft, CornerTopRight, CornerBottomLeft, CornerBottomRight}
+	report.PriceStickerCorner = corners[rng.Intn(len(corners))]
+
+	// Hang the sticker off the edge of the art, so it overlaps the case as well
+	size := sticker.Bounds().Size()
+	overhang := image.Point{X: size.X / 4, Y: size.Y / 3}
+	if opts.priceStyle() == PriceSecurityStrip {
+		overhang.X = -int(24 * scale)
+	}
+	angle := (rng.Float64()*2 - 1) * 4 * math.Pi / 180
+	area := image.Rectangle{Min: art.Min.Sub(overhang), Max: art.Max.Add(overhang)}.Intersect(img.Bounds())
+	return placeSticker(img, sticker, area, report.PriceStickerCorner, 0, angle)
+}
+
+// renderPriceLabel draws a small rectangular label of the given width with the price
+// printed on it.
+func renderPriceLabel(price string, width float64, fill color.RGBA) (*image.RGBA, error) {
+	size := image.Point{X: int(width), Y: int(width * 0.45)}
+	img := image.NewRGBA(image.Rectangle{Max: size})
+	fillRoundedRect(img, img.Bounds(), float64(size.Y)*0.12, fill)
+
+	inner := img.Bounds().Inset(int(float64(size.Y) * 0.15))
+	mask, err := fitText([]string{price}, inner.Size(), float64(size.Y)*0.6)
+	if err != nil {
+		return nil, err
+	}
+	drawMaskCentred(img, inner, mask, image.NewUniform(color.RGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xff}))
+	return img, nil
+}
+
+// renderSecurityStrip draws a long pale strip of the given width, with a pattern of
+// bars along most of it and the price printed at one end.
+func renderSecurityStrip(price string, width float64, rng *rand.Rand) (*image.RGBA, error) {
+	size := image.Point{X: int(width), Y: int(width * 0.12)}
+	img := image.NewRGBA(image.Rectangle{Max: size})
+	fillRoundedRect(img, img.Bounds(), 2, color.RGBA{R: 0xe4, G: 0xe6, B: 0xe8, A: 0xff})
+
+	// The price takes up the right-hand end, with the bars filling the rest
+	priceWidth := int(float64(size.X) * 0.3)
+	margin := max(size.Y/5, 1)
+	bars := image.Rect(margin, margin, size.X-priceWidth-margin, size.Y-margin)
+	for x := bars.Min.X; x < bars.Max.X; {
+		bar := 1 + rng.Intn(3)
+		for bx := x; bx < min(x+bar, bars.Max.X); bx++ {
+			for y := bars.Min.Y; y < bars.Max.Y; y++ {
+				img.SetRGBA(bx, y, color.RGBA{R: 0x7a, G: 0x80, B: 0x88, A: 0xff})
+			}
+		}
+		x += bar + 1 + rng.Intn(3)
+	}
+
+	area := image.Rect(size.X-priceWidth, margin, size.X-margin, size.Y-margin)
+	mask, err := fitText([]string{price}, area.Size(), float64(size.Y)*0.75)
+	if err != nil {
+		return nil, err
+	}
+	drawMaskCentred(img, area, mask, image.NewUniform(color.RGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xff}))
+	return img, nil
+}
+
+// fillRoundedRect fills the rectangle with an anti-aliased rounded rectangle of the
+// given colour.
+func fillRoundedRect(img *image.RGBA, r image.Rectangle, radius float64, fill color.RGBA) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dist := roundedRectDistance(float64(x)+0.5, float64(y)+0.5, r, radius)
+			coverage := math.Min(math.Max(dist+0.5, 0), 1)
+			o := img.PixOffset(x, y)
+			img.Pix[o+0] = uint8(float64(fill.R) * coverage)
+			img.Pix[o+1] = uint8(float64(fill.G) * coverage)
+			img.Pix[o+2] = uint8(float64(fill.B) * coverage)
+			img.Pix[o+3] = uint8(float64(fill.A) * coverage)
+		}
+	}
+}
+
+// tearSticker removes part of the sticker along a ragged line, as if someone tried to
+// peel it off. A band of torn paper fibres is left along the tear, and only a faint
+// film of glue beyond it.
+func tearSticker(sticker *image.RGBA, rng *rand.Rand) {
+	bounds := sticker.Bounds()
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+
+	// The tear runs roughly across the sticker, somewhere in its middle half
+	angle := math.Pi/2 + (rng.Float64()-0.5)*math.Pi/3
+	nx, ny := math.Cos(angle), math.Sin(angle)
+	if rng.Float64() < 0.5 {
+		nx, ny = -nx, -ny
+	}
+	offset := (rng.Float64() - 0.5) * w * 0.5
+	ragged := newFractalNoise(rng, bounds.Dx(), bounds.Dy(), 10, 3)
+	fibres := newNoiseField(rng, bounds.Dx(), bounds.Dy(), 2)
+	band := math.Max(h*0.15, 3)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			fx, fy := float64(x-bounds.Min.X)+0.5, float64(y-bounds.Min.Y)+0.5
+			d := (fx-w/2)*ny - (fy-h/2)*nx - offset + (ragged.at(fx, fy)-0.5)*h*0.6
+			if d <= 0 {
+				continue
+			}
+
+			o := sticker.PixOffset(x, y)
+			p := sticker.Pix[o : o+4 : o+4]
+			if p[3] == 0 {
+				continue
+			}
+
+			// Paper fibres left behind next to the tear, fading to a film of glue
+			residue := 0.06
+			if d < band {
+				residue = math.Max(residue, (1-d/band)*(0.4+0.6*fibres.at(fx, fy)))
+			}
+			shade := float64(p[3]) / 255 * residue
+			for c := range 3 {
+				p[c] = uint8(240 * shade)
+			}
+			p[3] = uint8(255 * shade)
+		}
+	}
+}
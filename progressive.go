@@ -0,0 +1,388 @@
+package jewelcase
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// The standard library can only write baseline JPEGs, so progressive JPEGs are written
+// by hand. Chroma isn't subsampled, and every scan uses the standard quantisation and
+// Huffman tables from annex K of the spec, so this is no more efficient than image/jpeg
+// apart from the reordering of the data.
+
+// jpegQuant are the unscaled luminance and chrominance quantisation tables, in zig-zag
+// order.
+var jpegQuant = [2][64]byte{
+	{
+		16, 11, 12, 14, 12, 10, 16, 14,
+		13, 14, 18, 17, 16, 19, 24, 40,
+		26, 24, 22, 22, 24, 49, 35, 37,
+		29, 40, 58, 51, 61, 60, 57, 51,
+		56, 55, 64, 72, 92, 78, 64, 68,
+		87, 69, 55, 56, 80, 109, 81, 87,
+		95, 98, 103, 104, 103, 62, 77, 113,
+		121, 112, 100, 120, 92, 101, 103, 99,
+	},
+	{
+		17, 18, 18, 24, 21, 24, 47, 26,
+		26, 47, 99, 66, 56, 66, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	},
+}
+
+// jpegUnzig maps from zig-zag order to the natural (row-major) order of a block.
+var jpegUnzig = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// jpegHuffmanSpec describes a Huffman table by the number of codes of each length from
+// 1 to 16 bits, and the values they encode.
+type jpegHuffmanSpec struct {
+	counts [16]byte
+	values []byte
+}
+
+// jpegHuffman are the luminance DC, luminance AC, chrominance DC and chrominance AC
+// tables.
+var jpegHuffman = [4]jpegHuffmanSpec{
+	{
+		[16]byte{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	{
+		[16]byte{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 125},
+		[]byte{
+			0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12,
+			0x21, 0x31, 0x41, 0x06, 0x13, 0x51, 0x61, 0x07,
+			0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
+			0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0,
+			0x24, 0x33, 0x62, 0x72, 0x82, 0x09, 0x0a, 0x16,
+			0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+			0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39,
+			0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49,
+			0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
+			0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69,
+			0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79,
+			0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+			0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98,
+			0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+			0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6,
+			0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5,
+			0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4,
+			0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+			0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea,
+			0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+	{
+		[16]byte{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	{
+		[16]byte{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 119},
+		[]byte{
+			0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21,
+			0x31, 0x06, 0x12, 0x41, 0x51, 0x07, 0x61, 0x71,
+			0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91,
+			0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0,
+			0x15, 0x62, 0x72, 0xd1, 0x0a, 0x16, 0x24, 0x34,
+			0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+			0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38,
+			0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48,
+			0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58,
+			0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68,
+			0x69, 0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78,
+			0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+			0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96,
+			0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5,
+			0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4,
+			0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3,
+			0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2,
+			0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+			0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9,
+			0xea, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+}
+
+// jpegScans are the scans of a progressive JPEG: a rough version of the whole image from
+// the DC coefficients of every component, then increasingly fine detail. A component of
+// -1 means all components interleaved.
+var jpegScans = []struct{ component, start, end int }{
+	{-1, 0, 0},
+	{0, 1, 5},
+	{2, 1, 63},
+	{1, 1, 63},
+	{0, 6, 63},
+}
+
+// jpegDCT holds the DCT basis: jpegDCT[x][u] is the contribution of sample x to
+// frequency u, including the normalisation factor.
+var jpegDCT = func() (t [8][8]float64) {
+	for x := range 8 {
+		for u := range 8 {
+			c := 0.5
+			if u == 0 {
+				c = 0.5 / math.Sqrt2
+			}
+			t[x][u] = c * math.Cos(float64(2*x+1)*float64(u)*math.Pi/16)
+		}
+	}
+	return t
+}()
+
+// encodeProgressiveJPEG writes img as a progressive JPEG with the given quality, from 1
+// to 100.
+func encodeProgressiveJPEG(w io.Writer, img image.Image, quality int) error {
+	bounds := img.Bounds()
+	if bounds.Dx() >= 1<<16 || bounds.Dy() >= 1<<16 {
+		return errors.New("image is too large to encode as JPEG")
+	}
+
+	quality = min(max(quality, 1), 100)
+	scale := 200 - quality*2
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+	var quant [2][64]byte
+	for i := range quant {
+		for j := range quant[i] {
+			quant[i][j] = byte(min(max((int(jpegQuant[i][j])*scale+50)/100, 1), 255))
+		}
+	}
+
+	cols, rows := (bounds.Dx()+7)/8, (bounds.Dy()+7)/8
+	coefficients := jpegCoefficients(img, cols, rows, &quant)
+
+	bw := bufio.NewWriter(w)
+	bw.Write([]byte{0xff, 0xd8})
+
+	// Quantisation tables
+	writeJPEGMarker(bw, 0xdb, 2*65)
+	for i := range quant {
+		bw.WriteByte(byte(i))
+		bw.Write(quant[i][:])
+	}
+
+	// Frame header: 8-bit samples, three components without subsampling
+	writeJPEGMarker(bw, 0xc2, 15)
+	bw.Write([]byte{8, byte(bounds.Dy() >> 8), byte(bounds.Dy()), byte(bounds.Dx() >> 8), byte(bounds.Dx()), 3})
+	for c := range 3 {
+		bw.Write([]byte{byte(c + 1), 0x11, byte(min(c, 1))})
+	}
+
+	// Huffman tables
+	length := 0
+	for _, spec := range jpegHuffman {
+		length += 17 + len(spec.values)
+	}
+	writeJPEGMarker(bw, 0xc4, length)
+	for i, spec := range jpegHuffman {
+		bw.WriteByte(byte(i%2<<4 | i/2))
+		bw.Write(spec.counts[:])
+		bw.Write(spec.values)
+	}
+
+	var codes [4][256]uint32
+	for i, spec := range jpegHuffman {
+		codes[i] = jpegHuffmanCodes(spec)
+	}
+
+	for _, scan := range jpegScans {
+		components := []int{scan.component}
+		if scan.component < 0 {
+			components = []int{0, 1, 2}
+		}
+
+		writeJPEGMarker(bw, 0xda, 4+2*len(components))
+		bw.WriteByte(byte(len(components)))
+		for _, c := range components {
+			bw.Write([]byte{byte(c + 1), byte(min(c, 1) * 0x11)})
+		}
+		bw.Write([]byte{byte(scan.start), byte(scan.end), 0})
+
+		e := jpegBitWriter{w: bw}
+		if scan.start == 0 {
+			var prev [3]int32
+			for b := range cols * rows {
+				for _, c := range components {
+					dc := coefficients[c][b*64]
+					e.emitValue(&codes[min(c, 1)*2], 0, dc-prev[c])
+					prev[c] = dc
+				}
+			}
+		} else {
+			c := scan.component
+			table := &codes[min(c, 1)*2+1]
+			for b := range cols * rows {
+				block := coefficients[c][b*64 : b*64+64]
+				run := 0
+				for k := scan.start; k <= scan.end; k++ {
+					if block[k] == 0 {
+						run++
+						continue
+					}
+					for ; run > 15; run -= 16 {
+						e.emitCode(table, 0xf0)
+					}
+					e.emitValue(table, run, block[k])
+					run = 0
+				}
+				if run > 0 {
+					e.emitCode(table, 0x00)
+				}
+			}
+		}
+		e.flush()
+	}
+
+	bw.Write([]byte{0xff, 0xd9})
+	return bw.Flush()
+}
+
+// jpegCoefficients converts the image to YCbCr, and returns the quantised DCT
+// coefficients of each component in zig-zag order, 64 for each block in raster order.
+// Blocks that extend past the edge of the image repeat the edge pixels.
+func jpegCoefficients(img image.Image, cols, rows int, quant *[2][64]byte) [3][]int32 {
+	bounds := img.Bounds()
+	var coefficients [3][]int32
+	for c := range coefficients {
+		coefficients[c] = make([]int32, cols*rows*64)
+	}
+
+	parallelRows(image.Rect(0, 0, cols, rows), func(minY, maxY int) {
+		var samples [3][64]float64
+		for by := minY; by < maxY; by++ {
+			for bx := range cols {
+				for i := range 64 {
+					x := min(bounds.Min.X+bx*8+i%8, bounds.Max.X-1)
+					y := min(bounds.Min.Y+by*8+i/8, bounds.Max.Y-1)
+					r, g, b, _ := img.At(x, y).RGBA()
+					fr, fg, fb := float64(r>>8), float64(g>>8), float64(b>>8)
+					samples[0][i] = 0.299*fr + 0.587*fg + 0.114*fb - 128
+					samples[1][i] = -0.168736*fr - 0.331264*fg + 0.5*fb
+					samples[2][i] = 0.5*fr - 0.418688*fg - 0.081312*fb
+				}
+
+				offset := (by*cols + bx) * 64
+				for c := range samples {
+					freq := forwardDCT(&samples[c])
+					q := &quant[min(c, 1)]
+					for k := range 64 {
+						coefficients[c][offset+k] = int32(math.Round(freq[jpegUnzig[k]] / float64(q[k])))
+					}
+				}
+			}
+		}
+	})
+	return coefficients
+}
+
+// forwardDCT returns the 2D DCT of a block of samples, in natural order.
+func forwardDCT(block *[64]float64) [64]float64 {
+	var rows, out [64]float64
+	for y := range 8 {
+		for u := range 8 {
+			var sum float64
+			for x := range 8 {
+				sum += block[y*8+x] * jpegDCT[x][u]
+			}
+			rows[y*8+u] = sum
+		}
+	}
+	for u := range 8 {
+		for v := range 8 {
+			var sum float64
+			for y := range 8 {
+				sum += rows[y*8+u] * jpegDCT[y][v]
+			}
+			out[v*8+u] = sum
+		}
+	}
+	return out
+}
+
+// jpegHuffmanCodes returns the codes for each value in the table, with the length of
+// the code in the top 8 bits.
+func jpegHuffmanCodes(spec jpegHuffmanSpec) [256]uint32 {
+	var codes [256]uint32
+	code, i := uint32(0), 0
+	for length, count := range spec.counts {
+		for range count {
+			codes[spec.values[i]] = uint32(length+1)<<24 | code
+			code++
+			i++
+		}
+		code <<= 1
+	}
+	return codes
+}
+
+// writeJPEGMarker writes the header of a marker segment whose contents are the given
+// number of bytes long.
+func writeJPEGMarker(w *bufio.Writer, marker byte, length int) {
+	w.Write([]byte{0xff, marker, byte((length + 2) >> 8), byte(length + 2)})
+}
+
+// jpegBitWriter writes the entropy-coded data of a scan, stuffing a zero byte after any
+// 0xff so it can't be mistaken for a marker.
+type jpegBitWriter struct {
+	w    *bufio.Writer
+	bits uint32
+	n    uint
+}
+
+func (e *jpegBitWriter) emit(value uint32, size uint) {
+	e.bits = e.bits<<size | value&(1<<size-1)
+	e.n += size
+	for e.n >= 8 {
+		b := byte(e.bits >> (e.n - 8))
+		e.w.WriteByte(b)
+		if b == 0xff {
+			e.w.WriteByte(0)
+		}
+		e.n -= 8
+	}
+}
+
+// emitCode writes the Huffman code for the given symbol.
+func (e *jpegBitWriter) emitCode(codes *[256]uint32, symbol byte) {
+	e.emit(codes[symbol]&0xffffff, uint(codes[symbol]>>24))
+}
+
+// emitValue writes a coefficient preceded by a run of zeros, as the Huffman code for the
+// run and the size of the value, followed by the value's bits.
+func (e *jpegBitWriter) emitValue(codes *[256]uint32, run int, value int32) {
+	abs := value
+	if abs < 0 {
+		abs, value = -value, value-1
+	}
+	size := bits.Len32(uint32(abs))
+	e.emitCode(codes, byte(run<<4|size))
+	e.emit(uint32(value), uint(size))
+}
+
+// flush pads the last byte of the scan with one bits.
+func (e *jpegBitWriter) flush() {
+	if e.n > 0 {
+		e.emit(1<<(8-e.n)-1, 8-e.n)
+	}
+}
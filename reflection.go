@@ -0,0 +1,87 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+)
+
+// reflectionLight describes the light that is reflected off the front of the case.
+type reflectionLight struct {
+	// angle is how far the light is turned clockwise from the top-left, in radians
+	angle    float64
+	strength float64
+	// streakWidth is the width of the brighter streak in pixels, or 0 for no streak
+	streakWidth  float64
+	doubleStreak bool
+}
+
+// reflectionLight returns the light reflected by the case with these options.
+func (o Options) reflectionLight() reflectionLight {
+	return reflectionLight{
+		angle:        o.ReflectionAngle * math.Pi / 180,
+		strength:     o.reflectionStrength(),
+		streakWidth:  o.reflectionWidth(),
+		doubleStreak: o.DoubleReflection,
+	}
+}
+
+// reflectionOp returns an adjustment that brightens an image with the given bounds with
+// a gradient that fades away from the light, and optionally one or two specular streaks
+// running across it at right angles to the light, nearer to the lit side.
+func reflectionOp(bounds image.Rectangle, light reflectionLight) rowOp {
+	intensity := light.intensity(bounds)
+
+	return func(y int, pix []uint8) {
+		for i, x := 0, bounds.Min.X; i < len(pix); i, x = i+4, x+1 {
+			// Add slight white highlight, without letting the premultiplied colour exceed
+			// the alpha in any transparent areas
+			reflectionIntensity := intensity(x, y)
+			a := float64(pix[i+3])
+			pix[i] = uint8(math.Min(a, float64(pix[i])+reflectionIntensity*40*light.strength))
+			pix[i+1] = uint8(math.Min(a, float64(pix[i+1])+reflectionIntensity*40*light.strength))
+			pix[i+2] = uint8(math.Min(a, float64(pix[i+2])+reflectionIntensity*40*light.strength))
+		}
+	}
+}
+
+// intensity returns a function giving the brightness of the reflection at each point
+// within bounds.
+func (light reflectionLight) intensity(bounds image.Rectangle) func(x, y int) float64 {
+	width, height := float64(bounds.Dx()), float64(bounds.Dy())
+
+	// The direction away from the light, which is the diagonal towards the bottom-right
+	// when the angle is zero
+	sin, cos := math.Sincos(light.angle)
+	ux, uy := (cos-sin)/math.Sqrt2, (sin+cos)/math.Sqrt2
+
+	// The gradient runs from 0 in the corner nearest the light to 1 in the furthest, in
+	// proportion to the size of the image
+	n := math.Abs(ux) + math.Abs(uy)
+	gx, gy := ux/n, uy/n
+	g0 := (math.Abs(ux) - ux + math.Abs(uy) - uy) / (2 * n)
+
+	// The streaks are measured in pixels, from the corner nearest the light
+	s0 := (math.Abs(ux)*width - ux*width + math.Abs(uy)*height - uy*height) / 2
+	extent := math.Abs(ux)*width + math.Abs(uy)*height
+	sigma := light.streakWidth / 2
+	streaks := []struct{ centre, brightness float64 }{{extent * 0.3, 0.6}}
+	if light.doubleStreak {
+		streaks = append(streaks, struct{ centre, brightness float64 }{extent*0.3 + light.streakWidth*1.6, 0.3})
+	}
+
+	return func(x, y int) float64 {
+		fx := float64(x-bounds.Min.X) / width
+		fy := float64(y-bounds.Min.Y) / height
+
+		// The gradient is based on the position along the light
+		reflectionIntensity := math.Max(0, 0.3*(1-(fx*gx+fy*gy+g0)))
+		if sigma > 0 {
+			s := float64(x-bounds.Min.X)*ux + float64(y-bounds.Min.Y)*uy + s0
+			for _, streak := range streaks {
+				d := s - streak.centre
+				reflectionIntensity += streak.brightness * math.Exp(-(d*d)/(2*sigma*sigma))
+			}
+		}
+		return reflectionIntensity
+	}
+}
@@ -0,0 +1,46 @@
+package jewelcase
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// MaxScale is the largest value allowed for Options.Scale.
+const MaxScale = 3
+
+// scaledFrames caches the embedded frame variants once they've been enlarged, keyed by
+// scaledFrameKey.
+var scaledFrames sync.Map
+
+type scaledFrameKey struct {
+	variant int
+	tray    Tray
+	scale   int
+}
+
+// scaleFrame returns a version of one of the embedded frame variants enlarged by the given
+// scale. There is only one photograph of the case, so it is resampled with a sharp filter;
+// the art is resampled from the source at the larger size so stays just as detailed.
+func scaleFrame(f frameSpec, scale int) frameSpec {
+	if scale == 1 {
+		return f
+	}
+
+	key := scaledFrameKey{variant: f.variant, tray: f.tray, scale: scale}
+	if cached, ok := scaledFrames.Load(key); ok {
+		return cached.(frameSpec)
+	}
+
+	bounds := f.img.Bounds()
+	img := image.NewRGBA(image.Rectangle{Max: bounds.Size().Mul(scale)})
+	xdraw.CatmullRom.Scale(img, img.Bounds(), f.img, bounds, draw.Src, nil)
+
+	f.img = img
+	f.art = image.Rectangle{Min: f.art.Min.Mul(scale), Max: f.art.Max.Mul(scale)}
+	f.spine = image.Rectangle{Min: f.spine.Min.Mul(scale), Max: f.spine.Max.Mul(scale)}
+	cached, _ := scaledFrames.LoadOrStore(key, f)
+	return cached.(frameSpec)
+}
@@ -0,0 +1,96 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+	"math/rand"
+)
+
+// MaxScratchDensity is the largest value used for Options.ScratchDensity; larger values
+// are treated as this, so that a request can't ask for an unbounded amount of work.
+const MaxScratchDensity = 50
+
+// applyScratches draws thin, faint, slightly curved light streaks over the image at
+// random, to simulate a well-used case. density is the number of scratches per 100,000
+// pixels of a case at its usual size, and scale is how much larger the image is.
+func applyScratches(img *image.RGBA, rng *rand.Rand, density, scale float64) *image.RGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	intensity := make([]float32, width*height)
+
+	count := int(math.Round(float64(width*height) / (scale * scale) / 100000 * density))
+	for range count {
+		x0 := rng.Float64() * float64(width)
+		y0 := rng.Float64() * float64(height)
+		angle := rng.Float64() * math.Pi
+		length := (40 + rng.Float64()*210) * scale
+		bend := (rng.Float64() - 0.5) * length * 0.15
+		strength := 0.1 + rng.Float64()*0.25
+
+		x1 := x0 + math.Cos(angle)*length
+		y1 := y0 + math.Sin(angle)*length
+
+		// Control point for a quadratic curve, bent off to one side of the straight line
+		cx := (x0+x1)/2 - math.Sin(angle)*bend
+		cy := (y0+y1)/2 + math.Cos(angle)*bend
+
+		steps := int(length * 2)
+		for i := 0; i <= steps; i++ {
+			t := float64(i) / float64(steps)
+			px := (1-t)*(1-t)*x0 + 2*(1-t)*t*cx + t*t*x1
+			py := (1-t)*(1-t)*y0 + 2*(1-t)*t*cy + t*t*y1
+
+			// Scratches taper off towards their ends, and are widened to match the scale
+			v := float32(strength * math.Sin(math.Pi*t))
+			for w := range int(scale) {
+				offset := float64(w) - (scale-1)/2
+				splatScratch(intensity, width, height, px-math.Sin(angle)*offset, py+math.Cos(angle)*offset, v)
+			}
+		}
+	}
+
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			pix := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+			row := intensity[(y-bounds.Min.Y)*width : (y-bounds.Min.Y+1)*width]
+
+			for i, x := 0, 0; i < len(pix); i, x = i+4, x+1 {
+				// Lighten towards white, only where the image is opaque
+				a := pix[i+3]
+				v := float64(row[x])
+				for c := range 3 {
+					pix[i+c] = pix[i+c] + uint8(float64(a-pix[i+c])*v)
+				}
+			}
+		}
+	})
+	return img
+}
+
+// splatScratch adds v to the intensity map at the fractional position, spread over the
+// four nearest pixels. Overlapping scratches keep the brightest value.
+func splatScratch(intensity []float32, width, height int, px, py float64, v float32) {
+	x0, y0 := int(math.Floor(px)), int(math.Floor(py))
+	fx, fy := float32(px-float64(x0)), float32(py-float64(y0))
+
+	for dy := range 2 {
+		for dx := range 2 {
+			x, y := x0+dx, y0+dy
+			if x < 0 || y < 0 || x >= width || y >= height {
+				continue
+			}
+
+			wx, wy := 1-fx, 1-fy
+			if dx == 1 {
+				wx = fx
+			}
+			if dy == 1 {
+				wy = fy
+			}
+
+			// Weighting is boosted so a scratch running along a pixel boundary isn't too faint
+			i := y*width + x
+			intensity[i] = max(intensity[i], min(v, v*wx*wy*2))
+		}
+	}
+}
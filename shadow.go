@@ -0,0 +1,124 @@
+package jewelcase
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// applyDropShadow places the image in the middle of a larger canvas filled with the
+// background, with a soft shadow beneath it. A nil background is transparent. If gradient
+// is non-nil the background fades from background at the top to gradient at the bottom.
+func applyDropShadow(buf *buffers, img *image.RGBA, background, gradient color.Color) *image.RGBA {
+	bounds := img.Bounds()
+	pad := dropShadowPadding(bounds.Size())
+	offset := pad / 4
+	radius := max(pad/5, 1)
+
+	result := buf.newRGBA(image.Rect(0, 0, bounds.Dx()+2*pad, bounds.Dy()+2*pad))
+	width, height := result.Bounds().Dx(), result.Bounds().Dy()
+
+	// The shadow is the image's silhouette, dropped slightly and blurred
+	shadow := make([]float32, width*height)
+	for y := range bounds.Dy() {
+		row := shadow[(y+pad+offset)*width+pad:]
+		src := img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y+y):]
+		for x := range bounds.Dx() {
+			row[x] = float32(src[x*4+3]) / 255
+		}
+	}
+	for range 3 {
+		blurAlpha(shadow, width, height, radius)
+	}
+
+	top := premultiplied(background)
+	bottom := top
+	if gradient != nil {
+		bottom = premultiplied(gradient)
+	}
+
+	parallelRows(result.Bounds(), func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			t := float64(y) / float64(max(height-1, 1))
+			var bg [4]float64
+			for c := range bg {
+				bg[c] = top[c]*(1-t) + bottom[c]*t
+			}
+
+			dst := result.Pix[result.PixOffset(0, y):result.PixOffset(width, y)]
+			for x := range width {
+				// The running sums in blurAlpha can drift slightly outside [0, 1]
+				s := min(max(float64(shadow[y*width+x]), 0), 1) * 0.5
+				o := x * 4
+				for c := range 3 {
+					dst[o+c] = uint8(math.Round(bg[c] * (1 - s)))
+				}
+				dst[o+3] = uint8(math.Round(255*s + bg[3]*(1-s)))
+			}
+		}
+	})
+
+	draw.Draw(result, bounds.Sub(bounds.Min).Add(image.Point{X: pad, Y: pad}), img, bounds.Min, draw.Over)
+	return result
+}
+
+// dropShadowPadding returns the space added around each side of an image of the given
+// size by applyDropShadow.
+func dropShadowPadding(size image.Point) int {
+	return int(math.Round(float64(max(size.X, size.Y)) * 0.08))
+}
+
+// premultiplied returns the colour's premultiplied components from 0 to 255, treating nil
+// as transparent.
+func premultiplied(c color.Color) [4]float64 {
+	if c == nil {
+		return [4]float64{}
+	}
+	r, g, b, a := c.RGBA()
+	return [4]float64{float64(r >> 8), float64(g >> 8), float64(b >> 8), float64(a >> 8)}
+}
+
+// blurAlpha applies a box blur of the given radius to a width by height mask in place.
+// Repeated passes approximate a Gaussian blur.
+func blurAlpha(mask []float32, width, height, radius int) {
+	// Horizontal pass, then a vertical pass. parallelRows just divides up a range, so the
+	// vertical pass uses it to split the columns.
+	parallelRows(image.Rect(0, 0, 1, height), func(minY, maxY int) {
+		line := make([]float32, width)
+		for y := minY; y < maxY; y++ {
+			row := mask[y*width : (y+1)*width]
+			copy(line, row)
+			boxBlur(line, row, 1, radius)
+		}
+	})
+	parallelRows(image.Rect(0, 0, 1, width), func(minX, maxX int) {
+		line := make([]float32, height)
+		for x := minX; x < maxX; x++ {
+			for y := range height {
+				line[y] = mask[y*width+x]
+			}
+			boxBlur(line, mask[x:], width, radius)
+		}
+	})
+}
+
+// boxBlur writes the running average of src over a window of radius either side to dst,
+// whose elements are stride apart. Values beyond the ends of src are treated as zero.
+func boxBlur(src, dst []float32, stride, radius int) {
+	var sum float32
+	for i := range min(radius, len(src)) {
+		sum += src[i]
+	}
+
+	scale := 1 / float32(2*radius+1)
+	for i := range src {
+		if i+radius < len(src) {
+			sum += src[i+radius]
+		}
+		if i-radius-1 >= 0 {
+			sum -= src[i-radius-1]
+		}
+		dst[i*stride] = sum * scale
+	}
+}
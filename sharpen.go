@@ -0,0 +1,93 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+)
+
+// MaxSharpenRadius is the largest value used for Options.SharpenRadius; larger values are
+// treated as this, so that a request can't ask for an unbounded blur.
+const MaxSharpenRadius = 20
+
+// applySharpening sharpens the image with an unsharp mask, adding amount times the
+// difference between each pixel's brightness and a Gaussian blur of it with the given
+// radius, in pixels. Only the brightness is sharpened, so that edges don't get coloured
+// fringes.
+func applySharpening(buf *buffers, img *image.RGBA, amount, radius float64) *image.RGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	kernel := gaussianKernel(radius)
+	reach := len(kernel) / 2
+
+	luma := make([]float32, width*height)
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			src := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+			row := luma[(y-bounds.Min.Y)*width:]
+			for i, x := 0, 0; i < len(src); i, x = i+4, x+1 {
+				row[x] = 0.299*float32(src[i]) + 0.587*float32(src[i+1]) + 0.114*float32(src[i+2])
+			}
+		}
+	})
+
+	// Blur horizontally then vertically, repeating the pixels at the edges so that they
+	// aren't mistaken for detail
+	horizontal := make([]float32, width*height)
+	parallelRows(image.Rect(0, 0, 1, height), func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			src, dst := luma[y*width:(y+1)*width], horizontal[y*width:(y+1)*width]
+			for x := range dst {
+				var sum float32
+				for k, w := range kernel {
+					sum += w * src[min(max(x+k-reach, 0), width-1)]
+				}
+				dst[x] = sum
+			}
+		}
+	})
+
+	result := buf.newRGBA(bounds)
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			src := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+			dst := result.Pix[result.PixOffset(bounds.Min.X, y):result.PixOffset(bounds.Max.X, y)]
+			row := y - bounds.Min.Y
+
+			for i, x := 0, 0; i < len(src); i, x = i+4, x+1 {
+				var blurred float32
+				for k, w := range kernel {
+					blurred += w * horizontal[min(max(row+k-reach, 0), height-1)*width+x]
+				}
+
+				// The pixels are premultiplied, and so is the brightness, so the result
+				// just needs to stay within the alpha
+				delta := float64(luma[row*width+x]-blurred) * amount
+				a := float64(src[i+3])
+				for c := range 3 {
+					dst[i+c] = uint8(math.Round(min(max(float64(src[i+c])+delta, 0), a)))
+				}
+				dst[i+3] = src[i+3]
+			}
+		}
+	})
+	return result
+}
+
+// gaussianKernel returns the weights of a one-dimensional Gaussian blur with the given
+// standard deviation, reaching three standard deviations either side of the centre.
+func gaussianKernel(sigma float64) []float32 {
+	reach := max(int(math.Ceil(sigma*3)), 1)
+	kernel := make([]float32, 2*reach+1)
+
+	var total float64
+	weights := make([]float64, len(kernel))
+	for i := range weights {
+		d := float64(i - reach)
+		weights[i] = math.Exp(-(d * d) / (2 * sigma * sigma))
+		total += weights[i]
+	}
+	for i, w := range weights {
+		kernel[i] = float32(w / total)
+	}
+	return kernel
+}
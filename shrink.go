@@ -0,0 +1,81 @@
+package jewelcase
+
+import (
+	"image"
+	"image/draw"
+)
+
+// shrinkMargin is how many times larger than the size it will be scaled to shrinkArt
+// keeps the art, so that the resampling filter still does the final scaling.
+const shrinkMargin = 2
+
+// shrinkArt reduces art that is far larger than the size it will be scaled to, such as
+// a high resolution scan, by averaging blocks of pixels. The resampling filters look at
+// every source pixel under their kernel, and in linear light work on a full size copy of
+// the art, so scaling an 8000 pixel JPEG straight down to the frame is slow and uses a
+// lot of memory. Averaging first is cheap, and the image/jpeg package can't decode at a
+// reduced size itself. Art that can't be at least halved is returned unchanged.
+func shrinkArt(buf *buffers, albumArt image.Image, size image.Point, mode CropMode, linear bool) image.Image {
+	bounds := albumArt.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	scale := max(float64(size.X)/float64(width), float64(size.Y)/float64(height))
+	if mode == CropLetterbox {
+		scale = min(float64(size.X)/float64(width), float64(size.Y)/float64(height))
+	}
+	factor := int(1 / (shrinkMargin * scale))
+	if factor < 2 {
+		return albumArt
+	}
+
+	rgba, _ := albumArt.(*image.RGBA)
+	t := linearTables()
+	output := buf.newRGBA(image.Rect(0, 0, (width+factor-1)/factor, (height+factor-1)/factor))
+	parallelRows(output.Bounds(), func(minY, maxY int) {
+		sums := make([][4]uint64, output.Bounds().Dx())
+		var band *image.RGBA
+		if rgba == nil {
+			// Convert a band of rows at a time, rather than the whole image at once
+			band = image.NewRGBA(image.Rect(bounds.Min.X, 0, bounds.Max.X, factor))
+		}
+
+		for y := minY; y < maxY; y++ {
+			rows := image.Rect(bounds.Min.X, bounds.Min.Y+y*factor, bounds.Max.X, min(bounds.Min.Y+(y+1)*factor, bounds.Max.Y))
+			var src *image.RGBA
+			if rgba != nil {
+				src = rgba.SubImage(rows).(*image.RGBA)
+			} else {
+				r := rows.Sub(rows.Min).Add(image.Point{X: bounds.Min.X})
+				draw.Draw(band, r, albumArt, rows.Min, draw.Src)
+				src = band.SubImage(r).(*image.RGBA)
+			}
+
+			clear(sums)
+			sb := src.Bounds()
+			for sy := sb.Min.Y; sy < sb.Max.Y; sy++ {
+				for sx := sb.Min.X; sx < sb.Max.X; sx++ {
+					p := src.Pix[src.PixOffset(sx, sy):]
+					s := &sums[(sx-sb.Min.X)/factor]
+					if linear {
+						l := t.pixelToLinear(p)
+						s[0], s[1], s[2], s[3] = s[0]+uint64(l[0]), s[1]+uint64(l[1]), s[2]+uint64(l[2]), s[3]+uint64(l[3])
+					} else {
+						s[0], s[1], s[2], s[3] = s[0]+uint64(p[0]), s[1]+uint64(p[1]), s[2]+uint64(p[2]), s[3]+uint64(p[3])
+					}
+				}
+			}
+
+			for x, s := range sums {
+				n := uint64(min(factor, width-x*factor) * sb.Dy())
+				d := output.Pix[output.PixOffset(x, y):]
+				if linear {
+					t.pixelFromLinear(d, [4]uint32{uint32((s[0] + n/2) / n), uint32((s[1] + n/2) / n), uint32((s[2] + n/2) / n), uint32((s[3] + n/2) / n)})
+				} else {
+					for c := range 4 {
+						d[c] = uint8((s[c] + n/2) / n)
+					}
+				}
+			}
+		}
+	})
+	return output
+}
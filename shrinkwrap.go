@@ -0,0 +1,50 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+	"math/rand"
+)
+
+// applyShrinkWrap overlays a randomly generated pattern of plastic wrap wrinkles and
+// glints on the image, along with a faint haze from the film itself. scale is how much
+// larger than a case at its usual size the image is.
+func applyShrinkWrap(img *image.RGBA, rng *rand.Rand, scale float64) *image.RGBA {
+	bounds := img.Bounds()
+
+	// The wrap is stretched taut in one direction, so the wrinkles mostly run that way.
+	// Noise is sampled in a rotated and squashed space that needs to cover the diagonal,
+	// measured in pixels of a case at its usual size.
+	angle := rng.Float64() * math.Pi
+	sin, cos := math.Sin(angle), math.Cos(angle)
+	diagonal := math.Hypot(float64(bounds.Dx()), float64(bounds.Dy())) / scale
+	size := int(2 * diagonal)
+	wrinkles := newFractalNoise(rng, size, size, 150, 3)
+	glints := newNoiseField(rng, size, size, 80)
+
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			pix := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+
+			for i, x := 0, bounds.Min.X; i < len(pix); i, x = i+4, x+1 {
+				fx, fy := float64(x-bounds.Min.X)/scale, float64(y-bounds.Min.Y)/scale
+				u := (fx*cos+fy*sin)*0.3 + diagonal
+				w := -fx*sin + fy*cos + diagonal
+
+				// Wrinkles are the ridges where the noise passes through its midpoint
+				ridge := 1 - math.Abs(2*wrinkles.at(u, w)-1)
+				crease := math.Pow(ridge, 20)
+
+				// Glints are brighter patches along the wrinkles
+				glint := smoothstep(math.Min(math.Max((glints.at(u, w)-0.6)/0.3, 0), 1)) * math.Pow(ridge, 8)
+
+				v := math.Min(0.03+0.1*crease+0.25*glint, 1)
+				a := pix[i+3]
+				for c := range 3 {
+					pix[i+c] = pix[i+c] + uint8(float64(a-pix[i+c])*v)
+				}
+			}
+		}
+	})
+	return img
+}
@@ -0,0 +1,24 @@
+package jewelcase
+
+import (
+	"image"
+	"image/color"
+)
+
+// spineRect is the area of the embedded frame occupied by the case's spine.
+var spineRect = image.Rect(4, 16, 74, 758)
+
+// MaxSpineTextSize is the largest value used for Options.SpineTextSize; larger values are
+// treated as this, so that a request can't ask for an enormous line of text.
+const MaxSpineTextSize = 200
+
+// drawSpineText renders text along the spine, reading from top to bottom.
+func drawSpineText(img *image.RGBA, spine image.Rectangle, text string, size float64, colour color.Color) error {
+	mask, err := renderText(text, size)
+	if err != nil {
+		return err
+	}
+
+	drawMaskCentred(img, spine, rotateMaskClockwise(mask), image.NewUniform(colour))
+	return nil
+}
@@ -0,0 +1,55 @@
+package jewelcase
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func TestSpineText(t *testing.T) {
+	process := func(text string) *image.RGBA {
+		t.Helper()
+		img, err := Process(testArt(), Options{
+			FrameVariant:    1,
+			SpineText:       text,
+			SpineTextColour: color.RGBA{R: 255, A: 255},
+			Rand:            rand.New(rand.NewSource(1)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img.(*image.RGBA)
+	}
+
+	// Counts the pixels in the area that are near enough the colour of the text
+	countText := func(img *image.RGBA, area image.Rectangle) int {
+		n := 0
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				if c := img.RGBAAt(x, y); c.R > 200 && c.G < 80 && c.B < 80 {
+					n++
+				}
+			}
+		}
+		return n
+	}
+
+	with, without := process("Artist - Album"), process("")
+	if n := countText(with, spineRect); n < 100 {
+		t.Errorf("found %d pixels of spine text, want at least 100", n)
+	}
+	if n := countText(without, spineRect); n != 0 {
+		t.Errorf("found %d pixels of spine text with no text set, want none", n)
+	}
+
+	// Nothing outside the spine should have changed
+	bounds := with.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if !image.Pt(x, y).In(spineRect) && with.RGBAAt(x, y) != without.RGBAAt(x, y) {
+				t.Fatalf("pixel at %d,%d outside the spine was changed by the spine text", x, y)
+			}
+		}
+	}
+}
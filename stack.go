@@ -0,0 +1,208 @@
+package jewelcase
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// StackLayout selects how Stack arranges the covers.
+type StackLayout string
+
+const (
+	// StackPile drops the covers loosely on top of each other, each slightly askew, with
+	// the first cover on top. This is the default.
+	StackPile StackLayout = "pile"
+
+	// StackFan spreads the covers out in an arc from left to right like a hand of cards,
+	// each overlapping the one before.
+	StackFan StackLayout = "fan"
+)
+
+// StackOptions configures how Stack arranges the covers.
+type StackOptions struct {
+	// Layout is the arrangement of the covers (defaults to StackPile)
+	Layout StackLayout
+
+	// Background fills the image behind the covers (defaults to transparent)
+	Background color.Color
+
+	// Rand, if set, is used to pick the positions of the covers in a pile, allowing
+	// deterministic output
+	Rand *rand.Rand `json:"-"`
+}
+
+// Stack combines several processed covers into a single image, as a pile or fan of
+// cases each casting a shadow on those beneath it. The covers are scaled to the height
+// of the first one.
+func Stack(covers []image.Image, opts StackOptions) (*image.RGBA, error) {
+	if len(covers) == 0 {
+		return nil, errors.New("no covers to stack")
+	}
+
+	scaled, err := scaleCovers(covers)
+	if err != nil {
+		return nil, err
+	}
+
+	var placements []stackPlacement
+	switch opts.Layout {
+	case "", StackPile:
+		rng := opts.Rand
+		if rng == nil {
+			rng = rand.New(rand.NewSource(rand.Int63()))
+		}
+		placements = pilePlacements(scaled, rng)
+	case StackFan:
+		placements = fanPlacements(scaled)
+	default:
+		return nil, fmt.Errorf("unknown stack layout %q", opts.Layout)
+	}
+
+	// Size the canvas to fit every rotated cover, with room around the edges for shadows
+	var area image.Rectangle
+	for _, p := range placements {
+		area = area.Union(p.bounds())
+	}
+	pad := dropShadowPadding(area.Size())
+	area = area.Inset(-pad)
+
+	result := image.NewRGBA(image.Rect(0, 0, area.Dx(), area.Dy()))
+	if opts.Background != nil {
+		draw.Draw(result, result.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+	}
+
+	layer := image.NewRGBA(result.Bounds())
+	for _, p := range placements {
+		clear(layer.Pix)
+		drawRotated(layer, p.cover, p.centre.Sub(area.Min), p.angle)
+		castShadow(result, layer, pad)
+		draw.Draw(result, result.Bounds(), layer, image.Point{}, draw.Over)
+	}
+	return result, nil
+}
+
+// scaleCovers converts the covers to RGBA images with their top-left corners at the
+// origin, scaling them all to the height of the first one.
+func scaleCovers(covers []image.Image) ([]*image.RGBA, error) {
+	height := covers[0].Bounds().Dy()
+	scaled := make([]*image.RGBA, len(covers))
+	for i, cover := range covers {
+		bounds := cover.Bounds()
+		if bounds.Empty() {
+			return nil, fmt.Errorf("cover %d is empty", i)
+		}
+		width := max(int(math.Round(float64(bounds.Dx())*float64(height)/float64(bounds.Dy()))), 1)
+		scaled[i] = image.NewRGBA(image.Rect(0, 0, width, height))
+		if width == bounds.Dx() && height == bounds.Dy() {
+			draw.Draw(scaled[i], scaled[i].Bounds(), cover, bounds.Min, draw.Src)
+		} else {
+			parallelScale(xdraw.CatmullRom, scaled[i], cover, draw.Src)
+		}
+	}
+	return scaled, nil
+}
+
+// stackPlacement is the position of one cover in a stack.
+type stackPlacement struct {
+	cover  *image.RGBA
+	centre image.Point
+	angle  float64
+}
+
+// bounds returns the area covered by the cover once it has been rotated into place.
+func (p stackPlacement) bounds() image.Rectangle {
+	w, h := float64(p.cover.Bounds().Dx()), float64(p.cover.Bounds().Dy())
+	sin, cos := math.Abs(math.Sin(p.angle)), math.Abs(math.Cos(p.angle))
+	halfW := int(math.Ceil((w*cos + h*sin) / 2))
+	halfH := int(math.Ceil((w*sin + h*cos) / 2))
+	return image.Rect(p.centre.X-halfW, p.centre.Y-halfH, p.centre.X+halfW, p.centre.Y+halfH)
+}
+
+// pilePlacements scatters the covers around a common centre, each turned by up to 8
+// degrees. The covers are returned in drawing order, so the first cover ends up on top
+// and nearest the centre.
+func pilePlacements(covers []*image.RGBA, rng *rand.Rand) []stackPlacement {
+	size := float64(covers[0].Bounds().Dy())
+	placements := make([]stackPlacement, len(covers))
+	for i, cover := range covers {
+		// Covers further down the pile have drifted further from the middle
+		spread := size * 0.04 * math.Sqrt(float64(i))
+		placements[len(covers)-1-i] = stackPlacement{
+			cover: cover,
+			centre: image.Point{
+				X: int(math.Round((rng.Float64()*2 - 1) * spread)),
+				Y: int(math.Round((rng.Float64()*2 - 1) * spread)),
+			},
+			angle: (rng.Float64()*2 - 1) * 8 * math.Pi / 180,
+		}
+	}
+	return placements
+}
+
+// fanPlacements spreads the covers in an arc around a point below them, 12 degrees apart
+// and up to 90 degrees in total, in the order given.
+func fanPlacements(covers []*image.RGBA) []stackPlacement {
+	size := float64(covers[0].Bounds().Dy())
+	step := 12.0
+	if len(covers) > 1 {
+		step = math.Min(step, 90/float64(len(covers)-1))
+	}
+	radius := size * 1.5
+
+	placements := make([]stackPlacement, len(covers))
+	for i, cover := range covers {
+		angle := (float64(i) - float64(len(covers)-1)/2) * step * math.Pi / 180
+		placements[i] = stackPlacement{
+			cover: cover,
+			centre: image.Point{
+				X: int(math.Round(radius * math.Sin(angle))),
+				Y: int(math.Round(radius * (1 - math.Cos(angle)))),
+			},
+			angle: angle,
+		}
+	}
+	return placements
+}
+
+// castShadow darkens dst with a soft shadow of the opaque parts of layer, dropped
+// slightly below them. pad is the padding used for a drop shadow of the same size, which
+// the offset and softness of the shadow are scaled to.
+func castShadow(dst, layer *image.RGBA, pad int) {
+	width, height := dst.Bounds().Dx(), dst.Bounds().Dy()
+	offset := pad / 4
+	radius := max(pad/5, 1)
+
+	shadow := make([]float32, width*height)
+	for y := range height - offset {
+		row := shadow[(y+offset)*width:]
+		src := layer.Pix[layer.PixOffset(0, y):]
+		for x := range width {
+			row[x] = float32(src[x*4+3]) / 255
+		}
+	}
+	for range 3 {
+		blurAlpha(shadow, width, height, radius)
+	}
+
+	parallelRows(dst.Bounds(), func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			row := dst.Pix[dst.PixOffset(0, y):dst.PixOffset(width, y)]
+			for x := range width {
+				// The running sums in blurAlpha can drift slightly outside [0, 1]
+				s := min(max(float64(shadow[y*width+x]), 0), 1) * 0.5
+				o := x * 4
+				for c := range 3 {
+					row[o+c] = uint8(math.Round(float64(row[o+c]) * (1 - s)))
+				}
+				row[o+3] = uint8(math.Round(255*s + float64(row[o+3])*(1-s)))
+			}
+		}
+	})
+}
@@ -0,0 +1,268 @@
+package jewelcase
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// Corner identifies one of the corners of the art.
+type Corner string
+
+const (
+	CornerTopLeft     Corner = "top-left"
+	CornerTopRight    Corner = "top-right"
+	CornerBottomLeft  Corner = "bottom-left"
+	CornerBottomRight Corner = "bottom-right"
+)
+
+// StickerShape is the outline of a sticker.
+type StickerShape string
+
+const (
+	// StickerCircle is a round sticker
+	StickerCircle StickerShape = "circle"
+
+	// StickerRoundedRect is a landscape rectangle with rounded corners
+	StickerRoundedRect StickerShape = "rounded"
+)
+
+// MaxStickerSize is the largest width, in pixels, used for the hype and shop stickers;
+// larger values are treated as this, so that a request can't ask for an enormous sticker.
+const MaxStickerSize = 1000
+
+// stickerSize returns the size of a sticker of the given shape and width.
+func stickerSize(shape StickerShape, width float64) (image.Point, error) {
+	switch shape {
+	case StickerCircle:
+		return image.Point{X: int(width), Y: int(width)}, nil
+	case StickerRoundedRect:
+		return image.Point{X: int(width), Y: int(width * 0.55)}, nil
+	default:
+		return image.Point{}, fmt.Errorf("unknown sticker shape %q", shape)
+	}
+}
+
+// renderSticker draws a sticker of the given shape and width, filled with fill and with
+// the lines of text shrunk as necessary to fit inside it.
+func renderSticker(shape StickerShape, width float64, fill color.Color, lines []string) (*image.RGBA, error) {
+	size, err := stickerSize(shape, width)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rectangle{Max: size})
+	bounds := img.Bounds()
+	cornerRadius := float64(size.Y) * 0.25
+	if shape == StickerCircle {
+		cornerRadius = float64(size.X) / 2
+	}
+
+	fr, fg, fb, _ := fill.RGBA()
+	base := [3]float64{float64(fr >> 8), float64(fg >> 8), float64(fb >> 8)}
+	for y := range size.Y {
+		for x := range size.X {
+			dist := roundedRectDistance(float64(x)+0.5, float64(y)+0.5, bounds, cornerRadius)
+			coverage := math.Min(math.Max(dist+0.5, 0), 1)
+			if coverage == 0 {
+				continue
+			}
+
+			// A slightly darker rim around the edge
+			shade := 1.0
+			if dist < 3 {
+				shade = 0.8
+			}
+
+			o := img.PixOffset(x, y)
+			for c := range 3 {
+				img.Pix[o+c] = uint8(base[c] * shade * coverage)
+			}
+			img.Pix[o+3] = uint8(255 * coverage)
+		}
+	}
+
+	// Text goes in the largest comfortable area inside the outline
+	inner := bounds.Inset(int(float64(size.Y) * 0.12))
+	if shape == StickerCircle {
+		inner = bounds.Inset(int(float64(size.X) * 0.16))
+	}
+
+	mask, err := fitText(lines, inner.Size(), float64(size.Y)*0.2)
+	if err != nil {
+		return nil, err
+	}
+
+	drawMaskCentred(img, inner, mask, image.NewUniform(contrastingColour(fill)))
+	return img, nil
+}
+
+// fitText renders the lines of bold text at the largest size no bigger than maxSize that
+// fits within the given area.
+func fitText(lines []string, area image.Point, maxSize float64) (*image.Alpha, error) {
+	textSize := maxSize
+	for {
+		mask, err := renderBoldLines(lines, textSize)
+		if err != nil {
+			return nil, err
+		}
+		if textSize < 6 || mask.Bounds().Dx() <= area.X && mask.Bounds().Dy() <= area.Y {
+			return mask, nil
+		}
+		textSize *= 0.9
+	}
+}
+
+// contrastingColour returns black or white, whichever is more readable on c.
+func contrastingColour(c color.Color) color.Color {
+	r, g, b, _ := c.RGBA()
+	if 0.299*float64(r)+0.587*float64(g)+0.114*float64(b) > 0x8000 {
+		return color.Black
+	}
+	return color.White
+}
+
+// stickerLines splits sticker text into lines, wrapping any that are longer than
+// maxChars at word boundaries.
+func stickerLines(text string, maxChars int) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		var current string
+		for _, word := range strings.Fields(line) {
+			if current != "" && len(current)+1+len(word) > maxChars {
+				lines = append(lines, current)
+				current = ""
+			}
+			if current != "" {
+				current += " "
+			}
+			current += word
+		}
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// placeSticker draws the sticker onto dst rotated by angle (in radians), positioned
+// in the given corner of area and inset from its edges.
+func placeSticker(dst *image.RGBA, sticker *image.RGBA, area image.Rectangle, corner Corner, inset int, angle float64) error {
+	size := sticker.Bounds().Size()
+	var centre image.Point
+	switch corner {
+	case CornerTopLeft:
+		centre = image.Point{X: area.Min.X + inset + size.X/2, Y: area.Min.Y + inset + size.Y/2}
+	case CornerTopRight:
+		centre = image.Point{X: area.Max.X - inset - size.X/2, Y: area.Min.Y + inset + size.Y/2}
+	case CornerBottomLeft:
+		centre = image.Point{X: area.Min.X + inset + size.X/2, Y: area.Max.Y - inset - size.Y/2}
+	case CornerBottomRight:
+		centre = image.Point{X: area.Max.X - inset - size.X/2, Y: area.Max.Y - inset - size.Y/2}
+	default:
+		return fmt.Errorf("unknown corner %q", corner)
+	}
+
+	drawRotated(dst, sticker, centre, angle)
+	return nil
+}
+
+// drawRotated draws src over dst rotated by angle (in radians) about its centre, which
+// is placed at the given point.
+func drawRotated(dst *image.RGBA, src *image.RGBA, centre image.Point, angle float64) {
+	srcBounds := src.Bounds()
+	w, h := float64(srcBounds.Dx()), float64(srcBounds.Dy())
+	sin, cos := math.Sin(angle), math.Cos(angle)
+
+	// Half the size of the rotated bounding box
+	halfW := (w*math.Abs(cos) + h*math.Abs(sin)) / 2
+	halfH := (w*math.Abs(sin) + h*math.Abs(cos)) / 2
+	area := image.Rect(
+		centre.X-int(math.Ceil(halfW)), centre.Y-int(math.Ceil(halfH)),
+		centre.X+int(math.Ceil(halfW)), centre.Y+int(math.Ceil(halfH)),
+	).Intersect(dst.Bounds())
+
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			// Rotate back into the sticker's own coordinates
+			dx, dy := float64(x-centre.X)+0.5, float64(y-centre.Y)+0.5
+			sx := dx*cos + dy*sin + w/2 - 0.5
+			sy := -dx*sin + dy*cos + h/2 - 0.5
+			if sx < 0 || sy < 0 || sx >= w-1 || sy >= h-1 {
+				continue
+			}
+
+			c := sampleBilinear(src, float64(srcBounds.Min.X)+sx, float64(srcBounds.Min.Y)+sy)
+			if c.A == 0 {
+				continue
+			}
+
+			o := dst.PixOffset(x, y)
+			inv := 255 - uint32(c.A)
+			dst.Pix[o+0] = uint8(uint32(c.R) + uint32(dst.Pix[o+0])*inv/255)
+			dst.Pix[o+1] = uint8(uint32(c.G) + uint32(dst.Pix[o+1])*inv/255)
+			dst.Pix[o+2] = uint8(uint32(c.B) + uint32(dst.Pix[o+2])*inv/255)
+			dst.Pix[o+3] = uint8(uint32(c.A) + uint32(dst.Pix[o+3])*inv/255)
+		}
+	}
+}
+
+// drawHypeSticker adds the hype sticker described by opts to a corner of the art, at a
+// slight random angle, enlarged by scale.
+func drawHypeSticker(img *image.RGBA, art image.Rectangle, opts Options, rng *rand.Rand, scale float64) error {
+	maxChars := 12
+	if opts.hypeStickerShape() == StickerRoundedRect {
+		maxChars = 18
+	}
+
+	lines := stickerLines(opts.HypeStickerText, maxChars)
+	sticker, err := renderSticker(opts.hypeStickerShape(), opts.hypeStickerSize()*scale, opts.hypeStickerColour(), lines)
+	if err != nil {
+		return err
+	}
+
+	angle := (rng.Float64()*2 - 1) * 8 * math.Pi / 180
+	return placeSticker(img, sticker, art, opts.hypeStickerCorner(), int(24*scale), angle)
+}
+
+// Common texts for Options.ShopStickerText.
+const (
+	ShopStickerUsed  = "USED"
+	ShopStickerPromo = "PROMO\nNOT FOR\nSALE"
+)
+
+// drawShopSticker adds the round shop sticker described by opts to a corner of the art,
+// with a printed ring just inside its edge, enlarged by scale.
+func drawShopSticker(img *image.RGBA, art image.Rectangle, opts Options, scale float64) error {
+	size := opts.shopStickerSize() * scale
+	fill := opts.shopStickerColour()
+	sticker, err := renderSticker(StickerCircle, size, fill, stickerLines(opts.ShopStickerText, 10))
+	if err != nil {
+		return err
+	}
+
+	// Shop stickers are printed in bulk with a thin ring around the text
+	ink := contrastingColour(fill)
+	ir, ig, ib, _ := ink.RGBA()
+	centre := size / 2
+	radius, thickness := size*0.42, math.Max(size*0.015, 1)
+	bounds := sticker.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			d := math.Abs(math.Hypot(float64(x)+0.5-centre, float64(y)+0.5-centre) - radius)
+			v := math.Min(math.Max(thickness/2+0.5-d, 0), 1)
+			if v == 0 {
+				continue
+			}
+
+			o := sticker.PixOffset(x, y)
+			p := sticker.Pix[o : o+4 : o+4]
+			for c, ic := range []uint32{ir, ig, ib} {
+				p[c] = uint8(float64(p[c])*(1-v) + float64(ic>>8)*v)
+			}
+		}
+	}
+
+	return placeSticker(img, sticker, art, opts.shopStickerCorner(), int(16*scale), opts.ShopStickerRotation*math.Pi/180)
+}
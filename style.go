@@ -0,0 +1,94 @@
+package jewelcase
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// Style selects one of the built-in frames.
+type Style string
+
+const (
+	// StyleJewelCase places the art in a CD jewel case. This is the default.
+	StyleJewelCase Style = "jewelcase"
+
+	// StyleVinyl places the art on a worn LP sleeve, with the record peeking out.
+	StyleVinyl Style = "vinyl"
+
+	// StyleCassette places the art on the J-card of a cassette tape case, cropped to
+	// cover the spine and front panel.
+	StyleCassette Style = "cassette"
+
+	// StyleBack produces the back of a jewel case: the tray insert, with the track listing,
+	// spine text and a barcode, behind the rear tray. The art is used for the insert unless
+	// Options.BackImage is set.
+	StyleBack Style = "back"
+
+	// StyleDisc prints the art on the face of a CD, on its own.
+	StyleDisc Style = "disc"
+
+	// StyleDiscSlimline prints the art on the face of a CD, which is half pulled out of
+	// a clear slimline case.
+	StyleDiscSlimline Style = "slimline"
+
+	// StyleDVD places the art under the sleeve of a DVD keep case. The cover is taller
+	// than it is wide, so square art is cropped or letterboxed according to Options.Crop.
+	StyleDVD Style = "dvd"
+
+	// StyleMiniDisc places the art in the small square window of a MiniDisc case, with
+	// the cartridge's shutter showing beneath it.
+	StyleMiniDisc Style = "minidisc"
+
+	// StyleVHS places the art on the worn insert of a rental VHS clamshell. The insert is
+	// much taller than it is wide, so square art is fitted according to Options.Crop.
+	StyleVHS Style = "vhs"
+
+	// StyleDigipak prints the art on the front of a matte cardboard digipak, which is a
+	// little wider than it is tall.
+	StyleDigipak Style = "digipak"
+
+	// StyleFatbox places the art in a double-disc "fatbox" jewel case, which has a much
+	// thicker spine than a normal one.
+	StyleFatbox Style = "fatbox"
+)
+
+// Styles lists all the built-in styles.
+var Styles = []Style{StyleJewelCase, StyleVinyl, StyleCassette, StyleBack, StyleDisc, StyleDiscSlimline, StyleDVD, StyleMiniDisc, StyleVHS, StyleDigipak, StyleFatbox}
+
+// styleFrame returns the frame for a built-in style.
+func styleFrame(style Style) (frameSpec, error) {
+	switch style {
+	case "", StyleJewelCase:
+		return frameSpec{img: frame, art: defaultArtRect, spine: spineRect}, nil
+	case StyleVinyl:
+		return vinylFrame(), nil
+	case StyleCassette:
+		return cassetteFrame(), nil
+	case StyleBack:
+		return backFrame(), nil
+	case StyleDisc:
+		return discFrame(), nil
+	case StyleDiscSlimline:
+		return slimlineFrame(), nil
+	case StyleDVD:
+		return dvdFrame(), nil
+	case StyleMiniDisc:
+		return minidiscFrame(), nil
+	case StyleVHS:
+		return vhsFrame(), nil
+	case StyleDigipak:
+		return digipakFrame(), nil
+	case StyleFatbox:
+		return fatboxFrame(), nil
+	default:
+		return frameSpec{}, fmt.Errorf("unknown style %q", style)
+	}
+}
+
+// drawArtOverlay draws the frame's art overlay, if any, onto the scaled art.
+func drawArtOverlay(art *image.RGBA, f frameSpec) {
+	if f.artOverlay != nil {
+		draw.Draw(art, art.Bounds(), f.artOverlay, image.Point{}, draw.Over)
+	}
+}
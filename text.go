@@ -0,0 +1,117 @@
+package jewelcase
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// MaxTextLength is the longest text, in bytes, that the servers accept for any one piece
+// of text drawn on the case, such as the spine text or a sticker's text.
+const MaxTextLength = 1000
+
+var regularFont = sync.OnceValues(func() (*opentype.Font, error) {
+	return opentype.Parse(goregular.TTF)
+})
+
+var boldFont = sync.OnceValues(func() (*opentype.Font, error) {
+	return opentype.Parse(gobold.TTF)
+})
+
+// renderText draws a single line of text at the given size into an alpha mask that
+// is exactly as wide as the text, and as tall as the font's line height.
+func renderText(text string, size float64) (*image.Alpha, error) {
+	return renderTextWithFont(regularFont, text, size)
+}
+
+// renderBoldLines draws each line of text centred below the previous one, in bold.
+func renderBoldLines(lines []string, size float64) (*image.Alpha, error) {
+	return renderLines(boldFont, lines, size, true)
+}
+
+// renderLines draws each line of text below the previous one, either centred or
+// aligned to the left.
+func renderLines(fontFunc func() (*opentype.Font, error), lines []string, size float64, centre bool) (*image.Alpha, error) {
+	masks := make([]*image.Alpha, len(lines))
+	var width, height int
+	for i, line := range lines {
+		mask, err := renderTextWithFont(fontFunc, line, size)
+		if err != nil {
+			return nil, err
+		}
+		masks[i] = mask
+		width = max(width, mask.Bounds().Dx())
+		height += mask.Bounds().Dy()
+	}
+
+	result := image.NewAlpha(image.Rect(0, 0, max(width, 1), max(height, 1)))
+	y := 0
+	for _, mask := range masks {
+		size := mask.Bounds().Size()
+		x := 0
+		if centre {
+			x = (width - size.X) / 2
+		}
+		draw.Draw(result, image.Rect(x, y, x+size.X, y+size.Y), mask, image.Point{}, draw.Src)
+		y += size.Y
+	}
+	return result, nil
+}
+
+func renderTextWithFont(fontFunc func() (*opentype.Font, error), text string, size float64) (*image.Alpha, error) {
+	f, err := fontFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	width := font.MeasureString(face, text).Ceil()
+	height := (metrics.Ascent + metrics.Descent).Ceil()
+
+	mask := image.NewAlpha(image.Rect(0, 0, max(width, 1), max(height, 1)))
+	d := &font.Drawer{
+		Dst:  mask,
+		Src:  image.Opaque,
+		Face: face,
+		Dot:  fixed.Point26_6{Y: metrics.Ascent},
+	}
+	d.DrawString(text)
+	return mask, nil
+}
+
+// rotateMaskClockwise returns a copy of the mask rotated 90° clockwise.
+func rotateMaskClockwise(mask *image.Alpha) *image.Alpha {
+	bounds := mask.Bounds()
+	rotated := image.NewAlpha(image.Rect(0, 0, bounds.Dy(), bounds.Dx()))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			rotated.SetAlpha(bounds.Max.Y-1-y, x-bounds.Min.X, mask.AlphaAt(x, y))
+		}
+	}
+	return rotated
+}
+
+// drawMaskCentred fills the mask with the given source, centred within the rectangle
+// and clipped to it.
+func drawMaskCentred(dst draw.Image, rect image.Rectangle, mask *image.Alpha, src image.Image) {
+	size := mask.Bounds().Size()
+	origin := rect.Min.Add(rect.Size().Sub(size).Div(2))
+	clipped := rect.Intersect(image.Rectangle{Min: origin, Max: origin.Add(size)})
+	draw.DrawMask(dst, clipped, src, image.Point{}, mask, clipped.Min.Sub(origin), draw.Over)
+}
@@ -0,0 +1,67 @@
+package jewelcase
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// maxTilt is the furthest the case can be turned in either direction, in degrees, before
+// it is too close to edge-on to make out.
+const maxTilt = 80
+
+// applyTilt turns the finished case in 3D by yaw and pitch degrees, as described by
+// newView, shrinking it to fit within its original bounds. If the frame has a spine, the
+// case is given depth: the spine is printed down the side nearest it, and the other
+// sides are clear plastic.
+func applyTilt(buf *buffers, img *image.RGBA, spine image.Rectangle, yaw, pitch float64) (*image.RGBA, error) {
+	if math.Abs(yaw) >= maxTilt || math.Abs(pitch) >= maxTilt {
+		return nil, fmt.Errorf("tilt angles must be less than %d degrees, got %g and %g", maxTilt, yaw, pitch)
+	}
+
+	bounds := img.Bounds()
+	size := bounds.Size()
+	v := newView(size, yaw*math.Pi/180, pitch*math.Pi/180)
+
+	var depth float64
+	if !spine.Empty() {
+		// A jewel case is a little shallower than its spine is wide
+		depth = float64(spine.Dx()) * 0.75
+	}
+	scale := v.fit(size, corners(size, depth))
+	result := buf.newRGBA(bounds)
+
+	w, h := float64(size.X)/2, float64(size.Y)/2
+	if depth > 0 {
+		// The sides of the case that can be seen never overlap each other, and are drawn
+		// before the front so that its edges cover theirs
+		printed := img.SubImage(image.Rect(spine.Min.X, bounds.Min.Y, spine.Max.X, bounds.Max.Y)).(*image.RGBA)
+		plastic := func(width, height int) *image.RGBA {
+			side := buf.newRGBA(image.Rect(0, 0, width, height))
+			draw.Draw(side, side.Bounds(), image.NewUniform(color.RGBA{R: 180, G: 182, B: 186, A: 230}), image.Point{}, draw.Src)
+			return side
+		}
+		thickness := int(math.Ceil(depth))
+		sides := []struct {
+			origin, a, b, normal vec3
+			texture              *image.RGBA
+			brightness           float64
+		}{
+			// The spine reads from the back of the case to the front
+			{vec3{-w, -h, depth}, vec3{0, 0, -depth / float64(spine.Dx())}, vec3{0, 1, 0}, vec3{-1, 0, 0}, printed, 0.75},
+			{vec3{w, -h, 0}, vec3{0, 0, 1}, vec3{0, 1, 0}, vec3{1, 0, 0}, plastic(thickness, size.Y), 0.85},
+			{vec3{-w, -h, depth}, vec3{1, 0, 0}, vec3{0, 0, -1}, vec3{0, -1, 0}, plastic(size.X, thickness), 1.1},
+			{vec3{-w, h, 0}, vec3{1, 0, 0}, vec3{0, 0, 1}, vec3{0, 1, 0}, plastic(size.X, thickness), 0.6},
+		}
+		for _, s := range sides {
+			if v.faces(s.origin, s.normal) {
+				drawPlane(result, s.texture, v.plane(s.origin, s.a, s.b), scale, s.brightness)
+			}
+		}
+	}
+
+	drawPlane(result, img, v.plane(vec3{-w, -h, 0}, vec3{1, 0, 0}, vec3{0, 1, 0}), scale, 1)
+	return result, nil
+}
@@ -0,0 +1,118 @@
+package jewelcase
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"slices"
+	"sync"
+)
+
+// Tray is the colour of the plastic tray in the embedded jewel case, which shows down
+// the spine and along the right-hand edge of the art.
+type Tray string
+
+const (
+	// TrayBlack is the tray in the photograph of the case. This is the default.
+	TrayBlack Tray = "black"
+
+	// TrayClear is clear plastic, showing the white back of the inlay through it.
+	TrayClear Tray = "clear"
+
+	// TraySmoke is translucent grey-brown plastic.
+	TraySmoke Tray = "smoke"
+
+	// TrayBlue, TrayRed and TrayGreen are opaque coloured plastic.
+	TrayBlue  Tray = "blue"
+	TrayRed   Tray = "red"
+	TrayGreen Tray = "green"
+
+	// TrayRandom picks one of the other trays at random for each image.
+	TrayRandom Tray = "random"
+)
+
+// Trays lists all the tray colours that can be picked by TrayRandom.
+var Trays = []Tray{TrayBlack, TrayClear, TraySmoke, TrayBlue, TrayRed, TrayGreen}
+
+// trayColours are the colours of the plastic when lit in the same way as the black tray
+// in the photograph.
+var trayColours = map[Tray]color.RGBA{
+	TrayClear: {R: 222, G: 226, B: 230, A: 255},
+	TraySmoke: {R: 118, G: 112, B: 106, A: 255},
+	TrayBlue:  {R: 38, G: 82, B: 176, A: 255},
+	TrayRed:   {R: 178, G: 34, B: 40, A: 255},
+	TrayGreen: {R: 42, G: 134, B: 66, A: 255},
+}
+
+const (
+	// trayBrightness is the typical brightness of the black tray in the photograph, from
+	// 0 to 255, which other colours are shaded relative to
+	trayBrightness = 65
+
+	// trayThreshold is the brightness below which pixels are taken to be part of the tray.
+	// The clear plastic of the lid is much brighter, and is left alone.
+	trayThreshold = 120
+)
+
+// validTray checks the tray is one of the known values.
+func validTray(tray Tray) error {
+	if tray != TrayRandom && !slices.Contains(Trays, tray) {
+		return fmt.Errorf("unknown tray %q", tray)
+	}
+	return nil
+}
+
+// trayFrames caches the embedded frame variants once their trays have been recoloured,
+// keyed by trayFrameKey.
+var trayFrames sync.Map
+
+type trayFrameKey struct {
+	variant int
+	tray    Tray
+}
+
+// trayFrame returns a version of one of the embedded frame variants with the tray
+// recoloured, keeping the shading of the original plastic.
+func trayFrame(f frameSpec, tray Tray) frameSpec {
+	colour, ok := trayColours[tray]
+	if !ok {
+		return f
+	}
+
+	key := trayFrameKey{variant: f.variant, tray: tray}
+	if cached, ok := trayFrames.Load(key); ok {
+		return cached.(frameSpec)
+	}
+
+	bounds := f.img.Bounds()
+	img := image.NewRGBA(image.Rectangle{Max: bounds.Size()})
+	draw.Draw(img, img.Bounds(), f.img, bounds.Min, draw.Src)
+	parallelRows(img.Bounds(), func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			row := img.Pix[img.PixOffset(0, y):img.PixOffset(img.Bounds().Max.X, y)]
+			for i := 0; i < len(row); i += 4 {
+				p := row[i : i+4 : i+4]
+				l := (float64(p[0]) + float64(p[1]) + float64(p[2])) / 3
+
+				// Fade the recolouring out towards the threshold, so the edges of the tray
+				// blend into the lid
+				v := 1 - smoothstep(math.Min(math.Max((l-trayThreshold*0.7)/(trayThreshold*0.3), 0), 1))
+				if v == 0 {
+					continue
+				}
+				// The ridges in the plastic are less pronounced in lighter colours
+				shade := 1 + (l/trayBrightness-1)*0.6
+				for c, tc := range []uint8{colour.R, colour.G, colour.B} {
+					p[c] = uint8(float64(p[c])*(1-v) + math.Min(float64(tc)*shade, 255)*v)
+				}
+			}
+		}
+	})
+
+	f.img = img
+	f.tray = tray
+	cached, _ := trayFrames.LoadOrStore(key, f)
+	return cached.(frameSpec)
+}
@@ -0,0 +1,100 @@
+package jewelcase
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProcessVariants applies the jewel case effect to the album art n times, with different
+// random choices for each, so the best looking result can be picked.
+//
+// The variants are derived from a single source of randomness, so they are reproducible
+// if opts.Rand or opts.SeedFromContent is set, but still differ from each other.
+func ProcessVariants(albumArt image.Image, n int, opts Options) ([]image.Image, error) {
+	results, _, err := processVariants(context.Background(), albumArt, n, opts, false)
+	return results, err
+}
+
+// processVariants implements ProcessVariants, also returning the report for each variant.
+func processVariants(ctx context.Context, albumArt image.Image, n int, opts Options, marked bool) ([]image.Image, []*Report, error) {
+	rng := newRand(albumArt, opts)
+
+	results := make([]image.Image, n)
+	reports := make([]*Report, n)
+	for i := range n {
+		variantOpts := opts
+		variantOpts.Rand = rand.New(rand.NewSource(rng.Int63()))
+
+		var err error
+		results[i], reports[i], err = process(ctx, &buffers{}, albumArt, variantOpts, marked)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return results, reports, nil
+}
+
+// ProcessFileVariants behaves like ProcessVariants, but reads the art from inputPath and
+// saves each variant as for ProcessFile, to outputPath with the variant's number (starting
+// from 1) added before the extension; see VariantPath.
+func ProcessFileVariants(inputPath, outputPath string, n int, opts Options) error {
+	return ProcessFileVariantsContext(context.Background(), inputPath, outputPath, n, opts)
+}
+
+// ProcessFileVariantsContext behaves like ProcessFileVariants, but stops and returns the
+// context's error if ctx is cancelled before the variants have been processed.
+func ProcessFileVariantsContext(ctx context.Context, inputPath, outputPath string, n int, opts Options) error {
+	img, meta, marked, err := loadImage(inputPath, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.DryRun {
+		return checkFile(img, outputPath, opts, marked)
+	}
+
+	results, reports, err := processVariants(ctx, img, n, opts, marked)
+	if err != nil {
+		return err
+	}
+
+	var inputInfo fs.FileInfo
+	if opts.PreserveFileAttributes {
+		if inputInfo, err = os.Stat(inputPath); err != nil {
+			return err
+		}
+	}
+
+	for i := range results {
+		path := VariantPath(outputPath, i+1)
+		if err := saveImage(results[i], path, opts, meta); err != nil {
+			return err
+		}
+
+		if inputInfo != nil {
+			if err := copyAttributes(path, inputInfo); err != nil {
+				return err
+			}
+		}
+
+		if opts.WriteSidecar {
+			if err := writeSidecar(path+".json", opts, reports[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// VariantPath returns the path that ProcessFileVariants saves the given variant of
+// outputPath to, e.g. "cover-2.jpg" for variant 2 of "cover.jpg".
+func VariantPath(outputPath string, variant int) string {
+	ext := filepath.Ext(outputPath)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(outputPath, ext), variant, ext)
+}
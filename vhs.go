@@ -0,0 +1,136 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+const (
+	vhsWidth  = 830
+	vhsHeight = 1250
+
+	// vhsSleeveHeight is the height of the insert, in the proportions of a real one
+	vhsSleeveHeight = 1183
+)
+
+// vhsCaseRect is the area of the VHS frame covered by the clamshell.
+var vhsCaseRect = image.Rect(10, 10, 10+vhsWidth, 10+vhsHeight)
+
+// vhsArtRect is the area of the VHS frame covered by the insert, under the clamshell's
+// clear sleeve.
+var vhsArtRect = image.Rect(0, 0, targetWidth, vhsSleeveHeight).Add(image.Point{
+	X: vhsCaseRect.Min.X + 40,
+	Y: vhsCaseRect.Min.Y + (vhsHeight-vhsSleeveHeight)/2,
+})
+
+// vhsFrame renders the clamshell and insert wear used for StyleVHS.
+var vhsFrame = sync.OnceValue(func() frameSpec {
+	return frameSpec{
+		img:        renderClamshell(),
+		art:        vhsArtRect,
+		artOverlay: renderInsertWear(),
+		front:      renderClamshellSleeve(),
+	}
+})
+
+// renderClamshell draws an empty black rental clamshell, with the hinge on the left and
+// the catch that holds it shut on the right.
+func renderClamshell() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, vhsCaseRect.Max.X+10, vhsCaseRect.Max.Y+10))
+	catch := image.Rect(vhsCaseRect.Max.X-26, vhsCaseRect.Min.Y+vhsHeight/2-70, vhsCaseRect.Max.X-6, vhsCaseRect.Min.Y+vhsHeight/2+70)
+
+	for y := vhsCaseRect.Min.Y; y < vhsCaseRect.Max.Y; y++ {
+		for x := vhsCaseRect.Min.X; x < vhsCaseRect.Max.X; x++ {
+			fx, fy := float64(x)+0.5, float64(y)+0.5
+			coverage := roundedRectCoverage(fx, fy, vhsCaseRect, 18)
+			if coverage == 0 {
+				continue
+			}
+
+			// Slightly textured black plastic, lighter where the rim curves away
+			v := 26.0 + 3*math.Sin(fx*0.9)*math.Sin(fy*1.1)
+			if edge := roundedRectDistance(fx, fy, vhsCaseRect, 18); edge < 8 {
+				v += (8 - edge) * 5
+			}
+			if x < vhsArtRect.Min.X-8 {
+				// The hinge is moulded with a row of ribs
+				v += 8 * math.Max(0, math.Sin(fy/4))
+			}
+			if roundedRectDistance(fx, fy, catch, 8) >= 0 {
+				v = 52 + 10*math.Cos((fx-float64(catch.Min.X))/float64(catch.Dx())*math.Pi)
+			}
+
+			o := img.PixOffset(x, y)
+			img.Pix[o+0] = uint8(v * coverage)
+			img.Pix[o+1] = uint8(v * coverage)
+			img.Pix[o+2] = uint8((v + 2) * coverage)
+			img.Pix[o+3] = uint8(255 * coverage)
+		}
+	}
+	return img
+}
+
+// renderInsertWear draws the scuffed edges and bashed corners of a well-rented insert,
+// as a translucent white overlay the size of the art.
+func renderInsertWear() *image.RGBA {
+	size := vhsArtRect.Size()
+	img := image.NewRGBA(image.Rectangle{Max: size})
+
+	// Fixed seed, so the wear is the same every time
+	rng := rand.New(rand.NewSource(3))
+	scuffs := newFractalNoise(rng, size.X, size.Y, 24, 3)
+
+	for y := range size.Y {
+		for x := range size.X {
+			fx, fy := float64(x)+0.5, float64(y)+0.5
+			dx := math.Min(fx, float64(size.X)-fx)
+			dy := math.Min(fy, float64(size.Y)-fy)
+
+			// Card wears through along the edges in patches, and most at the corners
+			var alpha float64
+			if edge := math.Min(dx, dy); edge < 12 {
+				patch := scuffs.at(fx, fy)
+				alpha = (1 - edge/12) * math.Max(0, patch-0.35) * 1.2
+			}
+			if corner := math.Hypot(math.Min(dx, 40), math.Min(dy, 40)); corner < 40 && dx < 40 && dy < 40 {
+				alpha += (1 - corner/40) * 0.35
+			}
+
+			a := uint8(math.Min(alpha, 1) * 255)
+			o := img.PixOffset(x, y)
+			img.Pix[o+0], img.Pix[o+1], img.Pix[o+2], img.Pix[o+3] = a, a, a, a
+		}
+	}
+	return img
+}
+
+// renderClamshellSleeve draws the clear sleeve over the front of the clamshell as a
+// translucent white layer, brightest at its edges with a soft reflection across it.
+func renderClamshellSleeve() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, vhsCaseRect.Max.X+10, vhsCaseRect.Max.Y+10))
+	sleeve := vhsArtRect.Inset(-6)
+
+	for y := sleeve.Min.Y; y < sleeve.Max.Y; y++ {
+		for x := sleeve.Min.X; x < sleeve.Max.X; x++ {
+			fx, fy := float64(x)+0.5, float64(y)+0.5
+			coverage := roundedRectCoverage(fx, fy, sleeve, 6)
+			if coverage == 0 {
+				continue
+			}
+
+			alpha := 0.04
+			if edge := roundedRectDistance(fx, fy, sleeve, 6); edge < 3 {
+				alpha = 0.3
+			}
+			d := (fx + fy*0.3 - float64(sleeve.Min.X) - 420) / 120
+			alpha += 0.07 * math.Exp(-d*d)
+
+			a := uint8(math.Min(alpha*coverage, 1) * 255)
+			o := img.PixOffset(x, y)
+			img.Pix[o+0], img.Pix[o+1], img.Pix[o+2], img.Pix[o+3] = a, a, a, a
+		}
+	}
+	return img
+}
@@ -0,0 +1,33 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+)
+
+// applyVignette darkens the image towards its edges and corners, like the light falloff
+// in a photograph of a printed insert. strength is how much the corners are darkened, as
+// a fraction of their brightness.
+func applyVignette(img *image.RGBA, strength float64) *image.RGBA {
+	bounds := img.Bounds()
+	cx, cy := float64(bounds.Dx())/2, float64(bounds.Dy())/2
+
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			pix := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+			dy := (float64(y-bounds.Min.Y) + 0.5 - cy) / cy
+
+			for i, x := 0, 0; i < len(pix); i, x = i+4, x+1 {
+				// Distance from the centre, reaching 1 in the corners. The middle of the art
+				// is left alone, with the falloff getting steeper towards the edges
+				dx := (float64(x) + 0.5 - cx) / cx
+				d := math.Min(math.Hypot(dx, dy)/math.Sqrt2, 1)
+				v := 1 - strength*smoothstep(math.Max(d-0.35, 0)/0.65)
+				for c := range 3 {
+					pix[i+c] = uint8(float64(pix[i+c]) * v)
+				}
+			}
+		}
+	})
+	return img
+}
@@ -0,0 +1,126 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+const (
+	vinylWidth   = 990
+	vinylHeight  = 770
+	vinylRadius  = 366
+	vinylLabel   = 120
+	vinylSpindle = 8
+)
+
+// vinylArtRect is the area of the vinyl frame covered by the sleeve.
+var vinylArtRect = image.Rect(10, 10, 10+targetWidth, 10+targetHeight)
+
+// vinylCentre is the centre of the record, which sticks out of the right of the sleeve.
+var vinylCentre = image.Point{X: vinylArtRect.Min.X + targetWidth/2 + 235, Y: vinylArtRect.Min.Y + targetHeight/2}
+
+// vinylFrame renders the record and sleeve wear used for StyleVinyl.
+var vinylFrame = sync.OnceValue(func() frameSpec {
+	return frameSpec{
+		img:        renderRecord(),
+		art:        vinylArtRect,
+		artOverlay: renderSleeveWear(),
+	}
+})
+
+// renderRecord draws a black vinyl record with a plain label, shaded as if lit from the
+// top left, and a soft shadow where the sleeve covers it.
+func renderRecord() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, vinylWidth, vinylHeight))
+	cx, cy := float64(vinylCentre.X)+0.5, float64(vinylCentre.Y)+0.5
+
+	for y := range vinylHeight {
+		for x := range vinylWidth {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			r := math.Hypot(dx, dy)
+
+			// Anti-alias the outer edge and the spindle hole
+			coverage := math.Min(math.Max(vinylRadius-r+0.5, 0), 1) * math.Min(math.Max(r-vinylSpindle+0.5, 0), 1)
+			if coverage == 0 {
+				continue
+			}
+
+			var red, green, blue float64
+			switch {
+			case r < vinylLabel:
+				red, green, blue = 150, 32, 30
+				if r > vinylLabel-3 {
+					red, green, blue = red*0.8, green*0.8, blue*0.8
+				}
+			case r < vinylLabel+30 || r > vinylRadius-6:
+				// Dead wax around the label, and the smooth lead-in at the edge
+				red, green, blue = 24, 24, 26
+			default:
+				// Fine grooves, with the occasional gap between tracks
+				v := 18 + 5*math.Sin(r*2.7)
+				if math.Mod(r-vinylLabel, 47) < 1.5 {
+					v = 32
+				}
+				red, green, blue = v, v, v+1
+			}
+
+			// Light catching the grooves along the diagonal
+			angle := math.Atan2(dy, dx)
+			sheen := math.Pow(math.Abs(math.Cos(angle+math.Pi/4)), 24) * 40
+			if r >= vinylLabel {
+				red, green, blue = red+sheen, green+sheen, blue+sheen
+			}
+
+			// The sleeve casts a shadow on the record just beyond its edge
+			if d := float64(x - vinylArtRect.Max.X); d < 24 {
+				shade := 0.45 + 0.55*math.Max(d, 0)/24
+				red, green, blue = red*shade, green*shade, blue*shade
+			}
+
+			o := img.PixOffset(x, y)
+			img.Pix[o+0] = uint8(math.Min(red, 255) * coverage)
+			img.Pix[o+1] = uint8(math.Min(green, 255) * coverage)
+			img.Pix[o+2] = uint8(math.Min(blue, 255) * coverage)
+			img.Pix[o+3] = uint8(255 * coverage)
+		}
+	}
+	return img
+}
+
+// renderSleeveWear draws the faded ring left by the record inside the sleeve, and scuffs
+// along the edges, as a translucent white overlay the size of the art.
+func renderSleeveWear() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+
+	// Fixed seed, so the wear is the same every time
+	rng := rand.New(rand.NewSource(1))
+	scuff := make([]float64, targetWidth+targetHeight)
+	for i := range scuff {
+		scuff[i] = rng.Float64()
+	}
+
+	cx, cy := float64(targetWidth)/2, float64(targetHeight)/2
+	for y := range targetHeight {
+		for x := range targetWidth {
+			fx, fy := float64(x)+0.5, float64(y)+0.5
+
+			// Ring wear, strongest at the record's edge and fading in and out
+			ring := math.Abs(math.Hypot(fx-cx, fy-cy)-vinylRadius+4) / 10
+			alpha := math.Max(0, 1-ring*ring) * 0.12
+
+			// Edge wear, patchy along the length of each edge
+			edge := math.Min(math.Min(fx, fy), math.Min(float64(targetWidth)-fx, float64(targetHeight)-fy))
+			if edge < 8 {
+				patch := scuff[(x+y)/6%len(scuff)]
+				alpha += (1 - edge/8) * (0.1 + 0.3*patch*patch)
+			}
+
+			a := uint8(math.Min(alpha, 1) * 255)
+			o := img.PixOffset(x, y)
+			img.Pix[o+0], img.Pix[o+1], img.Pix[o+2], img.Pix[o+3] = a, a, a, a
+		}
+	}
+	return img
+}
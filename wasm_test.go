@@ -0,0 +1,61 @@
+package jewelcase
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fileHelpers are the files that work with the filesystem, which WebAssembly consumers
+// can do without.
+var fileHelpers = map[string]bool{
+	"audio.go":     true,
+	"directory.go": true,
+	"file.go":      true,
+	"framepack.go": true,
+	"variants.go":  true,
+}
+
+func TestCoreDoesNotImportOS(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if fileHelpers[name] || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, parser.ImportsOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, imp := range f.Imports {
+			if path, _ := strconv.Unquote(imp.Path.Value); path == "os" {
+				t.Errorf("%s imports os", name)
+			}
+		}
+	}
+}
+
+func TestBuildsForWASM(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the package")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	cmd := exec.Command(goTool, "build", "-o", os.DevNull, ".")
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("building for js/wasm failed: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,44 @@
+package jewelcase
+
+import (
+	"image"
+	"math"
+	"math/rand"
+)
+
+// applyYellowing tints the image amber, like clear plastic that has spent decades in the
+// sun. The plastic acts as a filter, so light areas are tinted the most and dark areas
+// barely change. The side of the case that faced the window is more yellowed than the
+// other, with some blotchiness. scale is how much larger than a case at its usual size
+// the image is.
+func applyYellowing(img *image.RGBA, rng *rand.Rand, strength, scale float64) *image.RGBA {
+	bounds := img.Bounds()
+	width, height := float64(bounds.Dx())/scale, float64(bounds.Dy())/scale
+
+	angle := rng.Float64() * 2 * math.Pi
+	sin, cos := math.Sin(angle), math.Cos(angle)
+	blotches := newFractalNoise(rng, int(width)+1, int(height)+1, 200, 2)
+
+	// How much each channel is absorbed at full strength
+	absorb := [3]float64{0.02, 0.09, 0.38}
+
+	parallelRows(bounds, func(minY, maxY int) {
+		for y := minY; y < maxY; y++ {
+			pix := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+
+			for i, x := 0, 0; i < len(pix); i, x = i+4, x+1 {
+				fx, fy := float64(x)/scale, float64(y-bounds.Min.Y)/scale
+
+				// Exposure runs from 0.5 on the shaded side to 1 on the sunny side
+				along := ((fx/width-0.5)*cos + (fy/height-0.5)*sin) / math.Sqrt2
+				exposure := 0.75 + along*0.5 + (blotches.at(fx, fy)-0.5)*0.3
+				v := strength * math.Min(math.Max(exposure, 0), 1)
+
+				for c := range 3 {
+					pix[i+c] = uint8(float64(pix[i+c]) * (1 - absorb[c]*v))
+				}
+			}
+		}
+	})
+	return img
+}